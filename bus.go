@@ -23,6 +23,47 @@ import (
 
 type MessageBus bus.MessageBus
 
+// ErrPatternsUnsupported is returned when registering topic patterns on a bus without pattern subscriptions
+var ErrPatternsUnsupported = bus.ErrPatternsUnsupported
+
+// Codec encodes request, response and stream payloads. Envelopes are always encoded with protobuf
+type Codec = bus.Codec
+
+var (
+	ProtoCodec = bus.ProtoCodec
+	JSONCodec  = bus.JSONCodec // protojson, for debugging and consumers without generated protobuf types
+	RawCodec   = bus.RawCodec  // opaque bytes in *wrapperspb.BytesValue payloads, for relaying serialized frames
+)
+
+// ProtocolVersion is the envelope version sent by this process. Peers from before versioning send 0
+const ProtocolVersion = bus.ProtocolVersion
+
+// Compression is applied to request and response payloads above a size threshold
+type Compression = bus.Compression
+
+const (
+	CompressionNone = bus.CompressionNone
+	CompressionGzip = bus.CompressionGzip
+	CompressionZstd = bus.CompressionZstd
+)
+
+// RegisterCodec lets servers and clients decode payloads encoded with c. ProtoCodec, JSONCodec and RawCodec are
+// registered by default
+func RegisterCodec(c Codec) {
+	bus.RegisterCodec(c)
+}
+
+// BlobStore holds payloads above a size threshold, which are sent by reference instead of published
+type BlobStore = bus.BlobStore
+
+func NewLocalBlobStore() BlobStore {
+	return bus.NewLocalBlobStore()
+}
+
+func NewRedisBlobStore(rc redis.UniversalClient) BlobStore {
+	return bus.NewRedisBlobStore(rc)
+}
+
 func NewLocalMessageBus() MessageBus {
 	return bus.NewLocalMessageBus()
 }
@@ -34,12 +34,35 @@ type ClientOpts struct {
 	Timeout              time.Duration
 	SelectionTimeout     time.Duration
 	ChannelSize          int
+	StreamWindowSize     int
+	StreamRetryInterval  time.Duration
+	StreamPingInterval   time.Duration
+	StreamPingTimeout    time.Duration
+	Backpressure         BackpressurePolicy
+	SlowRequestThreshold time.Duration
+	OnSlowRequest        SlowRequestHandler
+	OnOverflow           OverflowHandler
+	OnPayloadSize        PayloadSizeHandler
+	Codec                Codec
+	Compression          Compression
+	CompressionThreshold int
+	MaxMessageSize       int
+	BlobStore            BlobStore
+	BlobThreshold        int
 	EnableStreams        bool
+	LazySubscriptions    bool
+	StrictUnmarshal      bool
+	DeterministicMarshal bool
+	RequestIDGenerator   func(ctx context.Context) string
+	MethodOptions        map[string][]RequestOption
+	ShadowService        string
+	ShadowRatio          float64
 	RequestHooks         []ClientRequestHook
 	ResponseHooks        []ClientResponseHook
 	RpcInterceptors      []ClientRPCInterceptor
 	MultiRPCInterceptors []ClientMultiRPCInterceptor
 	StreamInterceptors   []StreamInterceptor
+	StreamOpenHooks      []StreamOpenHook
 }
 
 func WithClientID(id string) ClientOption {
@@ -66,6 +89,143 @@ func WithClientChannelSize(size int) ClientOption {
 	}
 }
 
+// Stream senders may have at most size messages waiting for room in the client's stream channels
+func WithClientStreamWindowSize(size int) ClientOption {
+	return func(o *ClientOpts) {
+		o.StreamWindowSize = size
+	}
+}
+
+// Unacknowledged stream messages are resent every interval until they time out, so streams survive bus outages shorter
+// than the send timeout. Messages are not resent once their Send fails, and later messages are delivered without them
+func WithClientStreamRetryInterval(interval time.Duration) ClientOption {
+	return func(o *ClientOpts) {
+		o.StreamRetryInterval = interval
+	}
+}
+
+// Idle streams ping the server every interval, and are closed if the server does not respond within timeout
+func WithClientStreamKeepalive(interval, timeout time.Duration) ClientOption {
+	return func(o *ClientOpts) {
+		o.StreamPingInterval = interval
+		o.StreamPingTimeout = timeout
+	}
+}
+
+// policy for stream messages and multi-RPC responses that arrive while the channel is full
+func WithClientBackpressurePolicy(policy BackpressurePolicy) ClientOption {
+	return func(o *ClientOpts) {
+		o.Backpressure = policy
+	}
+}
+
+// requests taking longer than threshold are passed to onSlow with the time spent in each phase.
+// If onSlow is nil they are logged with LogSlowRequest
+func WithClientSlowRequestThreshold(threshold time.Duration, onSlow SlowRequestHandler) ClientOption {
+	return func(o *ClientOpts) {
+		o.SlowRequestThreshold = threshold
+		o.OnSlowRequest = onSlow
+		if onSlow == nil {
+			o.OnSlowRequest = LogSlowRequest
+		}
+	}
+}
+
+// onOverflow is called for claims, responses and stream messages dropped because their channel is full, multi-rpc
+// responses that wait for room in theirs, and messages that arrive after their request or stream has finished
+func WithClientOverflowHandler(onOverflow OverflowHandler) ClientOption {
+	return func(o *ClientOpts) {
+		o.OnOverflow = onOverflow
+	}
+}
+
+// onSize is called with the length of each request payload published and each response payload received
+func WithClientPayloadSizes(onSize PayloadSizeHandler) ClientOption {
+	return func(o *ClientOpts) {
+		o.OnPayloadSize = onSize
+	}
+}
+
+// requests and stream messages are encoded with codec, protobuf by default. Servers respond with the same codec
+func WithClientCodec(codec Codec) ClientOption {
+	return func(o *ClientOpts) {
+		o.Codec = codec
+	}
+}
+
+// requests of at least threshold bytes are compressed with c. Servers without compression support cannot read them, so
+// enable it once every server is upgraded. Compressed responses are always accepted
+func WithClientCompression(c Compression, threshold int) ClientOption {
+	return func(o *ClientOpts) {
+		o.Compression = c
+		o.CompressionThreshold = threshold
+	}
+}
+
+// requests with payloads larger than size bytes fail with ResourceExhausted instead of being published. size <= 0
+// disables the limit
+func WithClientMaxMessageSize(size int) ClientOption {
+	return func(o *ClientOpts) {
+		o.MaxMessageSize = size
+	}
+}
+
+// request payloads larger than threshold bytes are put in store and sent by reference. Servers need the same store
+// to load them, and responses sent by reference are loaded from it
+func WithClientBlobStore(store BlobStore, threshold int) ClientOption {
+	return func(o *ClientOpts) {
+		o.BlobStore = store
+		o.BlobThreshold = threshold
+	}
+}
+
+// default request options for calls to method. options passed with the request take precedence
+func WithClientMethodOptions(method string, opts ...RequestOption) ClientOption {
+	return func(o *ClientOpts) {
+		if o.MethodOptions == nil {
+			o.MethodOptions = make(map[string][]RequestOption)
+		}
+		o.MethodOptions[method] = append(o.MethodOptions[method], opts...)
+	}
+}
+
+// mirrors ratio (0-1) of single RPCs to service. shadow responses are only reported to response hooks
+func WithClientShadow(service string, ratio float64) ClientOption {
+	return func(o *ClientOpts) {
+		o.ShadowService = service
+		o.ShadowRatio = ratio
+	}
+}
+
+func WithClientRequestIDGenerator(gen func(ctx context.Context) string) ClientOption {
+	return func(o *ClientOpts) {
+		o.RequestIDGenerator = gen
+	}
+}
+
+// response and claim channels are not subscribed until the first request that needs them
+func WithClientLazySubscriptions() ClientOption {
+	return func(o *ClientOpts) {
+		o.LazySubscriptions = true
+	}
+}
+
+// responses with fields or error detail types unknown to the client fail with MalformedResponse instead of being
+// returned with the unknown data ignored
+func WithClientStrictUnmarshal() ClientOption {
+	return func(o *ClientOpts) {
+		o.StrictUnmarshal = true
+	}
+}
+
+// protobuf request payloads are marshaled with map entries in a stable order, so equal requests have equal bytes for
+// signing, deduplication and replay comparisons. The order is only stable for a given protobuf library version
+func WithClientDeterministicMarshal() ClientOption {
+	return func(o *ClientOpts) {
+		o.DeterministicMarshal = true
+	}
+}
+
 // Request hooks are called as soon as the request is made
 type ClientRequestHook func(ctx context.Context, req proto.Message, info RPCInfo)
 
@@ -113,6 +273,12 @@ func WithClientStreamInterceptors(interceptors ...StreamInterceptor) ClientOptio
 	}
 }
 
+func WithClientStreamOpenHooks(hooks ...StreamOpenHook) ClientOption {
+	return func(o *ClientOpts) {
+		o.StreamOpenHooks = append(o.StreamOpenHooks, hooks...)
+	}
+}
+
 func WithClientOptions(opts ...ClientOption) ClientOption {
 	return func(o *ClientOpts) {
 		for _, opt := range opts {
@@ -23,17 +23,22 @@ import (
 	"github.com/twitchtv/twirp"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/runtime/protoimpl"
 )
 
 var (
-	ErrRequestCanceled = NewErrorf(Canceled, "request canceled")
-	ErrRequestTimedOut = NewErrorf(DeadlineExceeded, "request timed out")
-	ErrNoResponse      = NewErrorf(Unavailable, "no response from servers")
-	ErrStreamEOF       = NewError(Unavailable, io.EOF)
-	ErrClientClosed    = NewErrorf(Canceled, "client is closed")
-	ErrServerClosed    = NewErrorf(Canceled, "server is closed")
-	ErrStreamClosed    = NewErrorf(Canceled, "stream closed")
-	ErrSlowConsumer    = NewErrorf(Unavailable, "stream message discarded by slow consumer")
+	ErrRequestCanceled  = NewErrorf(Canceled, "request canceled")
+	ErrRequestTimedOut  = NewErrorf(DeadlineExceeded, "request timed out")
+	ErrNoResponse       = NewErrorf(Unavailable, "no response from servers")
+	ErrStreamEOF        = NewError(Unavailable, io.EOF)
+	ErrClientClosed     = NewErrorf(Canceled, "client is closed")
+	ErrServerClosed     = NewErrorf(Canceled, "server is closed")
+	ErrStreamClosed     = NewErrorf(Canceled, "stream closed")
+	ErrStreamSendClosed = NewErrorf(FailedPrecondition, "stream closed for sending")
+	ErrSlowConsumer     = NewErrorf(Unavailable, "stream message discarded by slow consumer")
+	ErrPeerUnresponsive = NewErrorf(Unavailable, "stream peer stopped responding")
 )
 
 type Error interface {
@@ -45,6 +50,13 @@ type Error interface {
 	GRPCStatus() *status.Status
 }
 
+// ErrorWithDetails is implemented by errors created with NewErrorWithDetails, and by errors returned to clients and
+// streams with the details their peer sent
+type ErrorWithDetails interface {
+	error
+	Details() []proto.Message
+}
+
 type ErrorCode string
 
 func (e ErrorCode) Error() string {
@@ -58,6 +70,16 @@ func NewError(code ErrorCode, err error) Error {
 	}
 }
 
+// NewErrorWithDetails creates an error carrying proto messages describing the failure. The details are sent to the client
+// with the error. Details with types not registered on the client are returned as *anypb.Any
+func NewErrorWithDetails(code ErrorCode, err error, details ...proto.Message) Error {
+	return &psrpcError{
+		error:   err,
+		code:    code,
+		details: details,
+	}
+}
+
 func NewErrorf(code ErrorCode, msg string, args ...interface{}) Error {
 	return &psrpcError{
 		error: fmt.Errorf(msg, args...),
@@ -65,14 +87,69 @@ func NewErrorf(code ErrorCode, msg string, args ...interface{}) Error {
 	}
 }
 
-func NewErrorFromResponse(code, err string) Error {
+func NewErrorFromResponse(code, err string, details ...proto.Message) Error {
 	if code == "" {
 		code = string(Unknown)
 	}
 
 	return &psrpcError{
-		error: errors.New(err),
-		code:  ErrorCode(code),
+		error:   errors.New(err),
+		code:    ErrorCode(code),
+		details: details,
+	}
+}
+
+// NewErrorFromGRPCStatus converts a gRPC status, keeping its code, message and details
+func NewErrorFromGRPCStatus(st *status.Status) Error {
+	var code ErrorCode
+	switch st.Code() {
+	case codes.OK:
+		code = OK
+	case codes.Canceled:
+		code = Canceled
+	case codes.InvalidArgument:
+		code = InvalidArgument
+	case codes.DeadlineExceeded:
+		code = DeadlineExceeded
+	case codes.NotFound:
+		code = NotFound
+	case codes.AlreadyExists:
+		code = AlreadyExists
+	case codes.PermissionDenied:
+		code = PermissionDenied
+	case codes.ResourceExhausted:
+		code = ResourceExhausted
+	case codes.FailedPrecondition:
+		code = FailedPrecondition
+	case codes.Aborted:
+		code = Aborted
+	case codes.OutOfRange:
+		code = OutOfRange
+	case codes.Unimplemented:
+		code = Unimplemented
+	case codes.Internal:
+		code = Internal
+	case codes.Unavailable:
+		code = Unavailable
+	case codes.DataLoss:
+		code = DataLoss
+	case codes.Unauthenticated:
+		code = Unauthenticated
+	default:
+		code = Unknown
+	}
+
+	var details []proto.Message
+	for _, d := range st.Details() {
+		if m, ok := d.(protoiface.MessageV1); ok {
+			details = append(details, protoimpl.X.ProtoMessageV2Of(m))
+		}
+	}
+
+	return &psrpcError{
+		error:   errors.New(st.Message()),
+		code:    code,
+		details: details,
 	}
 }
 
@@ -122,13 +199,18 @@ const (
 
 type psrpcError struct {
 	error
-	code ErrorCode
+	code    ErrorCode
+	details []proto.Message
 }
 
 func (e psrpcError) Code() ErrorCode {
 	return e.code
 }
 
+func (e psrpcError) Details() []proto.Message {
+	return e.details
+}
+
 func (e psrpcError) ToHttp() int {
 	switch e.code {
 	case OK:
@@ -205,7 +287,17 @@ func (e psrpcError) GRPCStatus() *status.Status {
 		c = codes.Unknown
 	}
 
-	return status.New(c, e.Error())
+	st := status.New(c, e.Error())
+	if len(e.details) != 0 {
+		details := make([]protoiface.MessageV1, 0, len(e.details))
+		for _, d := range e.details {
+			details = append(details, protoimpl.X.ProtoMessageV1Of(d))
+		}
+		if ds, err := st.WithDetails(details...); err == nil {
+			st = ds
+		}
+	}
+	return st
 }
 
 func (e psrpcError) toTwirp() twirp.Error {
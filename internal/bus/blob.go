@@ -0,0 +1,124 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// how long stored payloads outlive the request, so receivers processing it late can still load them
+const blobGracePeriod = 10 * time.Second
+
+// BlobStore holds payloads too large to publish. Receivers load them by the reference sent in the envelope
+type BlobStore interface {
+	Put(ctx context.Context, key string, b []byte, ttl time.Duration) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+func BlobKey(parts ...string) string {
+	return "psrpc|blob|" + strings.Join(parts, "|")
+}
+
+// BlobTTL returns how long a payload for a request expiring at expiry (unix nanoseconds) is kept
+func BlobTTL(expiry int64) time.Duration {
+	return time.Until(time.Unix(0, expiry)) + blobGracePeriod
+}
+
+// Offloads reports whether OffloadPayload stores b instead of sending it
+func Offloads(s BlobStore, threshold int, b []byte) bool {
+	return s != nil && len(b) > threshold
+}
+
+// OffloadPayload stores payloads larger than threshold in s, returning the reference to send in their place
+func OffloadPayload(ctx context.Context, s BlobStore, threshold int, key string, ttl time.Duration, b []byte) ([]byte, string, error) {
+	if !Offloads(s, threshold, b) {
+		return b, "", nil
+	}
+	if err := s.Put(ctx, key, b, ttl); err != nil {
+		return nil, "", err
+	}
+	return nil, key, nil
+}
+
+// LoadPayload returns the payload stored under ref, or b if the payload was sent in the envelope
+func LoadPayload(ctx context.Context, s BlobStore, ref string, b []byte) ([]byte, error) {
+	if ref == "" {
+		return b, nil
+	}
+	if s == nil {
+		return nil, errors.New("payload sent by reference without a blob store")
+	}
+	return s.Get(ctx, ref)
+}
+
+type localBlobStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func NewLocalBlobStore() BlobStore {
+	return &localBlobStore{
+		blobs: make(map[string][]byte),
+	}
+}
+
+func (s *localBlobStore) Put(_ context.Context, key string, b []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = b
+	time.AfterFunc(ttl, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.blobs, key)
+	})
+	return nil
+}
+
+func (s *localBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.blobs[key]
+	if !ok {
+		return nil, fmt.Errorf("payload %s not found", key)
+	}
+	return b, nil
+}
+
+type redisBlobStore struct {
+	rc redis.UniversalClient
+}
+
+func NewRedisBlobStore(rc redis.UniversalClient) BlobStore {
+	return &redisBlobStore{rc: rc}
+}
+
+func (s *redisBlobStore) Put(ctx context.Context, key string, b []byte, ttl time.Duration) error {
+	return s.rc.Set(ctx, key, b, ttl).Err()
+}
+
+func (s *redisBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := s.rc.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("payload %s not found", key)
+	}
+	return b, err
+}
@@ -16,6 +16,8 @@ package bus
 
 import (
 	"context"
+	"errors"
+	"strings"
 
 	"google.golang.org/protobuf/proto"
 )
@@ -30,6 +32,15 @@ type MessageBus interface {
 	SubscribeQueue(ctx context.Context, channel string, channelSize int) (Reader, error)
 }
 
+// PatternMessageBus is implemented by buses that can subscribe to every channel matching a pattern,
+// where * matches any sequence of characters
+type PatternMessageBus interface {
+	SubscribePattern(ctx context.Context, pattern string, channelSize int) (Reader, error)
+	SubscribeQueuePattern(ctx context.Context, pattern string, channelSize int) (Reader, error)
+}
+
+var ErrPatternsUnsupported = errors.New("message bus does not support channel patterns")
+
 type Reader interface {
 	read() ([]byte, bool)
 	Close() error
@@ -64,3 +75,62 @@ func SubscribeQueue[MessageType proto.Message](
 
 	return newSubscription[MessageType](sub, channelSize), nil
 }
+
+func SubscribePattern[MessageType proto.Message](
+	ctx context.Context,
+	bus MessageBus,
+	pattern string,
+	channelSize int,
+) (Subscription[MessageType], error) {
+
+	pb, ok := bus.(PatternMessageBus)
+	if !ok {
+		return nil, ErrPatternsUnsupported
+	}
+	sub, err := pb.SubscribePattern(ctx, pattern, channelSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSubscription[MessageType](sub, channelSize), nil
+}
+
+func SubscribeQueuePattern[MessageType proto.Message](
+	ctx context.Context,
+	bus MessageBus,
+	pattern string,
+	channelSize int,
+) (Subscription[MessageType], error) {
+
+	pb, ok := bus.(PatternMessageBus)
+	if !ok {
+		return nil, ErrPatternsUnsupported
+	}
+	sub, err := pb.SubscribeQueuePattern(ctx, pattern, channelSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSubscription[MessageType](sub, channelSize), nil
+}
+
+// MatchPattern reports whether s matches pattern, where * matches any sequence of characters
+func MatchPattern(pattern, s string) bool {
+	prefix, rest, wildcard := strings.Cut(pattern, "*")
+	if !wildcard {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, prefix) {
+		return false
+	}
+	s = s[len(prefix):]
+	for {
+		if MatchPattern(rest, s) {
+			return true
+		}
+		if s == "" {
+			return false
+		}
+		s = s[1:]
+	}
+}
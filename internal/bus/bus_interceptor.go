@@ -77,6 +77,30 @@ func (l *testBus) SubscribeQueue(ctx context.Context, channel string, size int)
 	return &testReader{r, l.chainSubscribeInterceptors(ctx, channel, r.read)}, nil
 }
 
+func (l *testBus) SubscribePattern(ctx context.Context, pattern string, size int) (Reader, error) {
+	pb, ok := l.bus.(PatternMessageBus)
+	if !ok {
+		return nil, ErrPatternsUnsupported
+	}
+	r, err := pb.SubscribePattern(ctx, pattern, size)
+	if err != nil {
+		return nil, err
+	}
+	return &testReader{r, l.chainSubscribeInterceptors(ctx, pattern, r.read)}, nil
+}
+
+func (l *testBus) SubscribeQueuePattern(ctx context.Context, pattern string, size int) (Reader, error) {
+	pb, ok := l.bus.(PatternMessageBus)
+	if !ok {
+		return nil, ErrPatternsUnsupported
+	}
+	r, err := pb.SubscribeQueuePattern(ctx, pattern, size)
+	if err != nil {
+		return nil, err
+	}
+	return &testReader{r, l.chainSubscribeInterceptors(ctx, pattern, r.read)}, nil
+}
+
 func (l *testBus) chainSubscribeInterceptors(ctx context.Context, channel string, handler ReadHandler) ReadHandler {
 	for i := len(l.subscribeInterceptors) - 1; i >= 0; i-- {
 		handler = l.subscribeInterceptors[i](ctx, channel, handler)
@@ -18,19 +18,24 @@ import (
 	"context"
 	"sync"
 
+	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 )
 
 type localMessageBus struct {
 	sync.RWMutex
-	subs   map[string]*localSubList
-	queues map[string]*localSubList
+	subs          map[string]*localSubList
+	queues        map[string]*localSubList
+	patternSubs   map[string]*localSubList
+	patternQueues map[string]*localSubList
 }
 
 func NewLocalMessageBus() MessageBus {
 	return &localMessageBus{
-		subs:   make(map[string]*localSubList),
-		queues: make(map[string]*localSubList),
+		subs:          make(map[string]*localSubList),
+		queues:        make(map[string]*localSubList),
+		patternSubs:   make(map[string]*localSubList),
+		patternQueues: make(map[string]*localSubList),
 	}
 }
 
@@ -43,6 +48,7 @@ func (l *localMessageBus) Publish(_ context.Context, channel string, msg proto.M
 	l.RLock()
 	subs := l.subs[channel]
 	queues := l.queues[channel]
+	patterns := l.matchPatterns(channel)
 	l.RUnlock()
 
 	if subs != nil {
@@ -51,9 +57,25 @@ func (l *localMessageBus) Publish(_ context.Context, channel string, msg proto.M
 	if queues != nil {
 		queues.dispatch(b)
 	}
+	// each pattern queue receives its own copy, like a queue subscribed to the channel
+	for _, p := range patterns {
+		p.dispatch(b)
+	}
 	return nil
 }
 
+func (l *localMessageBus) matchPatterns(channel string) []*localSubList {
+	var matches []*localSubList
+	for _, subLists := range []map[string]*localSubList{l.patternSubs, l.patternQueues} {
+		for pattern, subList := range subLists {
+			if MatchPattern(pattern, channel) {
+				matches = append(matches, subList)
+			}
+		}
+	}
+	return matches
+}
+
 func (l *localMessageBus) Subscribe(_ context.Context, channel string, size int) (Reader, error) {
 	return l.subscribe(l.subs, channel, size, false)
 }
@@ -62,6 +84,14 @@ func (l *localMessageBus) SubscribeQueue(_ context.Context, channel string, size
 	return l.subscribe(l.queues, channel, size, true)
 }
 
+func (l *localMessageBus) SubscribePattern(_ context.Context, pattern string, size int) (Reader, error) {
+	return l.subscribe(l.patternSubs, pattern, size, false)
+}
+
+func (l *localMessageBus) SubscribeQueuePattern(_ context.Context, pattern string, size int) (Reader, error) {
+	return l.subscribe(l.patternQueues, pattern, size, true)
+}
+
 func (l *localMessageBus) subscribe(subLists map[string]*localSubList, channel string, size int, queue bool) (Reader, error) {
 	l.Lock()
 	defer l.Unlock()
@@ -74,7 +104,7 @@ func (l *localMessageBus) subscribe(subLists map[string]*localSubList, channel s
 			l.Lock()
 			subList.Lock()
 
-			close(subList.subs[index])
+			close(subList.subs[index].msgChan)
 			subList.subs[index] = nil
 			subList.subCount--
 			if subList.subCount == 0 {
@@ -92,7 +122,7 @@ func (l *localMessageBus) subscribe(subLists map[string]*localSubList, channel s
 
 type localSubList struct {
 	sync.RWMutex  // locking while holding localMessageBus lock is allowed
-	subs          []chan []byte
+	subs          []*localSubscription
 	subCount      int
 	queue         bool
 	next          int
@@ -100,7 +130,9 @@ type localSubList struct {
 }
 
 func (l *localSubList) create(size int) *localSubscription {
-	msgChan := make(chan []byte, size)
+	sub := &localSubscription{
+		msgChan: make(chan []byte, size),
+	}
 
 	l.Lock()
 	defer l.Unlock()
@@ -112,22 +144,20 @@ func (l *localSubList) create(size int) *localSubscription {
 		if s == nil {
 			added = true
 			index = i
-			l.subs[i] = msgChan
+			l.subs[i] = sub
 			break
 		}
 	}
 
 	if !added {
 		index = len(l.subs)
-		l.subs = append(l.subs, msgChan)
+		l.subs = append(l.subs, sub)
 	}
 
-	return &localSubscription{
-		msgChan: msgChan,
-		onClose: func() {
-			l.onUnsubscribe(index)
-		},
+	sub.onClose = func() {
+		l.onUnsubscribe(index)
 	}
+	return sub
 }
 
 func (l *localSubList) dispatch(b []byte) {
@@ -143,7 +173,7 @@ func (l *localSubList) dispatch(b []byte) {
 			s := l.subs[l.next]
 			l.next++
 			if s != nil {
-				s <- b
+				s.write(b)
 				return
 			}
 		}
@@ -154,7 +184,7 @@ func (l *localSubList) dispatch(b []byte) {
 		// send to all
 		for _, s := range l.subs {
 			if s != nil {
-				s <- b
+				s.write(b)
 			}
 		}
 	}
@@ -162,9 +192,23 @@ func (l *localSubList) dispatch(b []byte) {
 
 type localSubscription struct {
 	msgChan chan []byte
+	blocked atomic.Uint64
 	onClose func()
 }
 
+func (l *localSubscription) write(b []byte) {
+	select {
+	case l.msgChan <- b:
+	default:
+		l.blocked.Inc()
+		l.msgChan <- b
+	}
+}
+
+func (l *localSubscription) backlog() ChannelStats {
+	return ChannelStats{Depth: len(l.msgChan), Blocked: l.blocked.Load()}
+}
+
 func (l *localSubscription) read() ([]byte, bool) {
 	msg, ok := <-l.msgChan
 	if !ok {
@@ -79,6 +79,12 @@ func (n *natsSubscription) read() ([]byte, bool) {
 	return msg.Data, true
 }
 
+func (n *natsSubscription) backlog() ChannelStats {
+	// nats discards messages for slow consumers instead of blocking
+	dropped, _ := n.sub.Dropped()
+	return ChannelStats{Depth: len(n.msgChan), Dropped: uint64(dropped)}
+}
+
 func (n *natsSubscription) Close() error {
 	err := n.sub.Unsubscribe()
 	close(n.msgChan)
@@ -40,31 +40,39 @@ type redisMessageBus struct {
 	ctx context.Context
 	ps  *redis.PubSub
 
-	mu     sync.Mutex
-	subs   map[string]*redisSubList
-	queues map[string]*redisSubList
+	mu            sync.Mutex
+	subs          map[string]*redisSubList
+	queues        map[string]*redisSubList
+	patternSubs   map[string]*redisSubList
+	patternQueues map[string]*redisSubList
 
 	wakeup          chan struct{}
 	ops             *redisWriteOpQueue
 	publishOps      map[string]*redisWriteOpQueue
 	dirtyChannels   map[string]struct{}
 	currentChannels map[string]struct{}
+	dirtyPatterns   map[string]struct{}
+	currentPatterns map[string]struct{}
 }
 
 func NewRedisMessageBus(rc redis.UniversalClient) MessageBus {
 	ctx := context.Background()
 	r := &redisMessageBus{
-		rc:     rc,
-		ctx:    ctx,
-		ps:     rc.Subscribe(ctx),
-		subs:   map[string]*redisSubList{},
-		queues: map[string]*redisSubList{},
+		rc:            rc,
+		ctx:           ctx,
+		ps:            rc.Subscribe(ctx),
+		subs:          map[string]*redisSubList{},
+		queues:        map[string]*redisSubList{},
+		patternSubs:   map[string]*redisSubList{},
+		patternQueues: map[string]*redisSubList{},
 
 		wakeup:          make(chan struct{}, 1),
 		ops:             &redisWriteOpQueue{},
 		publishOps:      map[string]*redisWriteOpQueue{},
 		dirtyChannels:   map[string]struct{}{},
 		currentChannels: map[string]struct{}{},
+		dirtyPatterns:   map[string]struct{}{},
+		currentPatterns: map[string]struct{}{},
 	}
 	go r.readWorker()
 	go r.writeWorker()
@@ -93,47 +101,65 @@ func (r *redisMessageBus) Publish(_ context.Context, channel string, msg proto.M
 }
 
 func (r *redisMessageBus) Subscribe(ctx context.Context, channel string, size int) (Reader, error) {
-	return r.subscribe(ctx, channel, size, r.subs, false)
+	return r.subscribe(ctx, channel, size, false, false)
 }
 
 func (r *redisMessageBus) SubscribeQueue(ctx context.Context, channel string, size int) (Reader, error) {
-	return r.subscribe(ctx, channel, size, r.queues, true)
+	return r.subscribe(ctx, channel, size, true, false)
 }
 
-func (r *redisMessageBus) subscribe(ctx context.Context, channel string, size int, subLists map[string]*redisSubList, queue bool) (Reader, error) {
+// SubscribePattern uses PSUBSCRIBE, patterns are passed to redis unchanged
+func (r *redisMessageBus) SubscribePattern(ctx context.Context, pattern string, size int) (Reader, error) {
+	return r.subscribe(ctx, pattern, size, false, true)
+}
+
+func (r *redisMessageBus) SubscribeQueuePattern(ctx context.Context, pattern string, size int) (Reader, error) {
+	return r.subscribe(ctx, pattern, size, true, true)
+}
+
+func (r *redisMessageBus) subLists(queue, pattern bool) map[string]*redisSubList {
+	switch {
+	case queue && pattern:
+		return r.patternQueues
+	case pattern:
+		return r.patternSubs
+	case queue:
+		return r.queues
+	default:
+		return r.subs
+	}
+}
+
+func (r *redisMessageBus) subscribe(ctx context.Context, channel string, size int, queue, pattern bool) (Reader, error) {
 	sub := &redisSubscription{
 		bus:     r,
 		ctx:     ctx,
 		channel: channel,
 		msgChan: make(chan *redis.Message, size),
 		queue:   queue,
+		pattern: pattern,
 	}
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	subLists := r.subLists(queue, pattern)
 	subList, ok := subLists[channel]
 	if !ok {
 		subList = &redisSubList{}
 		subLists[channel] = subList
-		r.reconcileSubscriptions(channel)
+		r.reconcileSubscriptions(channel, pattern)
 	}
 	subList.subs = append(subList.subs, sub.msgChan)
 
 	return sub, nil
 }
 
-func (r *redisMessageBus) unsubscribe(channel string, queue bool, msgChan chan *redis.Message) {
+func (r *redisMessageBus) unsubscribe(channel string, queue, pattern bool, msgChan chan *redis.Message) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	var subLists map[string]*redisSubList
-	if queue {
-		subLists = r.queues
-	} else {
-		subLists = r.subs
-	}
-
+	subLists := r.subLists(queue, pattern)
 	subList, ok := subLists[channel]
 	if !ok {
 		return
@@ -148,7 +174,7 @@ func (r *redisMessageBus) unsubscribe(channel string, queue bool, msgChan chan *
 
 	if len(subList.subs) == 0 {
 		delete(subLists, channel)
-		r.reconcileSubscriptions(channel)
+		r.reconcileSubscriptions(channel, pattern)
 	}
 }
 
@@ -156,22 +182,37 @@ func (r *redisMessageBus) readWorker() {
 	for {
 		msg, err := r.ps.ReceiveMessage(r.ctx)
 		if err != nil {
+			if r.ctx.Err() == nil {
+				logger.Error(err, "redis subscription read failed")
+			}
 			return
 		}
 
+		// messages matching a pattern are delivered once for the pattern, in addition to any channel subscription
+		subs, queues := r.subs, r.queues
+		key := msg.Channel
+		if msg.Pattern != "" {
+			subs, queues = r.patternSubs, r.patternQueues
+			key = msg.Pattern
+		}
+
 		r.mu.Lock()
-		if subList, ok := r.subs[msg.Channel]; ok {
+		if subList, ok := subs[key]; ok {
 			subList.dispatch(msg)
 		}
-		if subList, ok := r.queues[msg.Channel]; ok {
+		if subList, ok := queues[key]; ok {
 			subList.dispatchQueue(msg)
 		}
 		r.mu.Unlock()
 	}
 }
 
-func (r *redisMessageBus) reconcileSubscriptions(channel string) {
-	r.dirtyChannels[channel] = struct{}{}
+func (r *redisMessageBus) reconcileSubscriptions(channel string, pattern bool) {
+	if pattern {
+		r.dirtyPatterns[channel] = struct{}{}
+	} else {
+		r.dirtyChannels[channel] = struct{}{}
+	}
 	r.enqueueWriteOp(&redisReconcileSubscriptionsOp{r})
 }
 
@@ -258,49 +299,66 @@ type redisReconcileSubscriptionsOp struct {
 
 func (r *redisReconcileSubscriptionsOp) run() {
 	r.mu.Lock()
-	for len(r.dirtyChannels) > 0 {
-		subscribe := make(map[string]struct{}, len(r.dirtyChannels))
-		unsubscribe := make(map[string]struct{}, len(r.dirtyChannels))
-		for c := range r.dirtyChannels {
-			_, current := r.currentChannels[c]
-			desired := r.subs[c] != nil || r.queues[c] != nil
-			if !current && desired {
-				subscribe[c] = struct{}{}
-			} else if current && !desired {
-				unsubscribe[c] = struct{}{}
-			}
-		}
-		maps.Clear(r.dirtyChannels)
+	for len(r.dirtyChannels) > 0 || len(r.dirtyPatterns) > 0 {
+		subscribe, unsubscribe := diffSubscriptions(r.dirtyChannels, r.currentChannels, r.subs, r.queues)
+		psubscribe, punsubscribe := diffSubscriptions(r.dirtyPatterns, r.currentPatterns, r.patternSubs, r.patternQueues)
 		r.mu.Unlock()
 
-		var subscribeErr, unsubscribeErr error
+		var subscribeErr, unsubscribeErr, psubscribeErr, punsubscribeErr error
 		if len(subscribe) != 0 {
 			subscribeErr = r.ps.Subscribe(r.ctx, maps.Keys(subscribe)...)
 		}
 		if len(unsubscribe) != 0 {
 			unsubscribeErr = r.ps.Unsubscribe(r.ctx, maps.Keys(unsubscribe)...)
 		}
+		if len(psubscribe) != 0 {
+			psubscribeErr = r.ps.PSubscribe(r.ctx, maps.Keys(psubscribe)...)
+		}
+		if len(punsubscribe) != 0 {
+			punsubscribeErr = r.ps.PUnsubscribe(r.ctx, maps.Keys(punsubscribe)...)
+		}
 
-		if err := multierr.Combine(subscribeErr, unsubscribeErr); err != nil {
+		if err := multierr.Combine(subscribeErr, unsubscribeErr, psubscribeErr, punsubscribeErr); err != nil {
 			logger.Error(err, "redis subscription reconciliation failed")
 			time.Sleep(reconcilerRetryInterval)
 		}
 
 		r.mu.Lock()
-		if subscribeErr != nil {
-			maps.Copy(r.dirtyChannels, subscribe)
-		} else {
-			maps.Copy(r.currentChannels, subscribe)
+		applySubscriptions(r.dirtyChannels, r.currentChannels, subscribe, subscribeErr, unsubscribe, unsubscribeErr)
+		applySubscriptions(r.dirtyPatterns, r.currentPatterns, psubscribe, psubscribeErr, punsubscribe, punsubscribeErr)
+	}
+	r.mu.Unlock()
+}
+
+func diffSubscriptions(dirty, current map[string]struct{}, subs, queues map[string]*redisSubList) (map[string]struct{}, map[string]struct{}) {
+	subscribe := make(map[string]struct{}, len(dirty))
+	unsubscribe := make(map[string]struct{}, len(dirty))
+	for c := range dirty {
+		_, isCurrent := current[c]
+		desired := subs[c] != nil || queues[c] != nil
+		if !isCurrent && desired {
+			subscribe[c] = struct{}{}
+		} else if isCurrent && !desired {
+			unsubscribe[c] = struct{}{}
 		}
-		if unsubscribeErr != nil {
-			maps.Copy(r.dirtyChannels, unsubscribe)
-		} else {
-			for c := range unsubscribe {
-				delete(r.currentChannels, c)
-			}
+	}
+	maps.Clear(dirty)
+	return subscribe, unsubscribe
+}
+
+func applySubscriptions(dirty, current, subscribe map[string]struct{}, subscribeErr error, unsubscribe map[string]struct{}, unsubscribeErr error) {
+	if subscribeErr != nil {
+		maps.Copy(dirty, subscribe)
+	} else {
+		maps.Copy(current, subscribe)
+	}
+	if unsubscribeErr != nil {
+		maps.Copy(dirty, unsubscribe)
+	} else {
+		for c := range unsubscribe {
+			delete(current, c)
 		}
 	}
-	r.mu.Unlock()
 }
 
 type redisSubList struct {
@@ -328,6 +386,7 @@ type redisSubscription struct {
 	channel string
 	msgChan chan *redis.Message
 	queue   bool
+	pattern bool
 }
 
 func (r *redisSubscription) read() ([]byte, bool) {
@@ -345,10 +404,20 @@ func (r *redisSubscription) read() ([]byte, bool) {
 		}
 
 		if r.queue {
-			sha := sha256.Sum256([]byte(msg.Payload))
+			// queue subscribers lock each message on its payload, so every release agrees on the lock. Pattern queues
+			// receive their own copy of each message, so they lock separately on the pattern and channel as well
+			key := msg.Payload
+			if r.pattern {
+				key = msg.Pattern + "|" + msg.Channel + "|" + key
+			}
+			sha := sha256.Sum256([]byte(key))
 			hash := base64.StdEncoding.EncodeToString(sha[:])
 			acquired, err := r.bus.rc.SetNX(r.ctx, hash, rand.Int(), lockExpiration).Result()
-			if err != nil || !acquired {
+			if err != nil {
+				logger.Error(err, "failed to lock redis queue message", "channel", msg.Channel)
+				continue
+			}
+			if !acquired {
 				continue
 			}
 		}
@@ -357,7 +426,11 @@ func (r *redisSubscription) read() ([]byte, bool) {
 	}
 }
 
+func (r *redisSubscription) backlog() ChannelStats {
+	return ChannelStats{Depth: len(r.msgChan)}
+}
+
 func (r *redisSubscription) Close() error {
-	r.bus.unsubscribe(r.channel, r.queue, r.msgChan)
+	r.bus.unsubscribe(r.channel, r.queue, r.pattern, r.msgChan)
 	return nil
 }
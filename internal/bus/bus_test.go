@@ -35,6 +35,8 @@ func TestMessageBus(t *testing.T) {
 		testSubscribe(t, bus)
 		testSubscribeQueue(t, bus)
 		testSubscribeClose(t, bus)
+		testSubscriptionStats(t, bus)
+		testSubscribePattern(t, bus)
 	})
 
 	t.Run("Redis", func(t *testing.T) {
@@ -43,6 +45,7 @@ func TestMessageBus(t *testing.T) {
 		testSubscribe(t, bus)
 		testSubscribeQueue(t, bus)
 		testSubscribeClose(t, bus)
+		testSubscribePattern(t, bus)
 	})
 
 	t.Run("Nats", func(t *testing.T) {
@@ -51,9 +54,47 @@ func TestMessageBus(t *testing.T) {
 		testSubscribe(t, bus)
 		testSubscribeQueue(t, bus)
 		testSubscribeClose(t, bus)
+
+		_, err := SubscribePattern[*internal.Request](context.Background(), bus, "*", DefaultChannelSize)
+		require.ErrorIs(t, err, ErrPatternsUnsupported)
+	})
+}
+
+func TestBlobStore(t *testing.T) {
+	t.Run("Local", func(t *testing.T) {
+		testBlobStore(t, NewLocalBlobStore())
+	})
+
+	t.Run("Redis", func(t *testing.T) {
+		rc := redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{"localhost:6379"}})
+		testBlobStore(t, NewRedisBlobStore(rc))
 	})
 }
 
+func testBlobStore(t *testing.T, s BlobStore) {
+	ctx := context.Background()
+	key := BlobKey(rand.NewString(), "REQ")
+	payload := []byte("room state")
+
+	b, ref, err := OffloadPayload(ctx, s, len(payload), key, time.Second, payload)
+	require.NoError(t, err)
+	require.Equal(t, payload, b)
+	require.Empty(t, ref)
+
+	b, ref, err = OffloadPayload(ctx, s, len(payload)-1, key, 100*time.Millisecond, payload)
+	require.NoError(t, err)
+	require.Nil(t, b)
+	require.Equal(t, key, ref)
+
+	b, err = LoadPayload(ctx, s, ref, nil)
+	require.NoError(t, err)
+	require.Equal(t, payload, b)
+
+	time.Sleep(200 * time.Millisecond)
+	_, err = LoadPayload(ctx, s, ref, nil)
+	require.Error(t, err)
+}
+
 func testSubscribe(t *testing.T, bus MessageBus) {
 	ctx := context.Background()
 
@@ -112,6 +153,55 @@ func testSubscribeQueue(t *testing.T, bus MessageBus) {
 	require.Equal(t, 1, received)
 }
 
+func testSubscribePattern(t *testing.T, bus MessageBus) {
+	ctx := context.Background()
+
+	prefix := rand.NewString()
+	sub, err := SubscribePattern[*internal.Request](ctx, bus, prefix+"|*|REQ", DefaultChannelSize)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sub.Close() })
+	queueA, err := SubscribeQueuePattern[*internal.Request](ctx, bus, prefix+"|*|REQ", DefaultChannelSize)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = queueA.Close() })
+	queueB, err := SubscribeQueuePattern[*internal.Request](ctx, bus, prefix+"|*|REQ", DefaultChannelSize)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = queueB.Close() })
+	exact, err := SubscribeQueue[*internal.Request](ctx, bus, prefix+"|a|REQ", DefaultChannelSize)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = exact.Close() })
+	time.Sleep(time.Millisecond * 100)
+
+	// redis queues lock messages on their payload, so the payload differs from the queue test's
+	require.NoError(t, bus.Publish(ctx, prefix+"|a|RES", &internal.Request{RequestId: "1"}))
+	require.NoError(t, bus.Publish(ctx, prefix+"|a|REQ", &internal.Request{RequestId: "2", ClientId: prefix}))
+
+	select {
+	case m := <-sub.Channel():
+		require.Equal(t, "2", m.RequestId)
+	case <-time.After(defaultClientTimeout):
+		require.FailNow(t, "pattern subscription did not receive message")
+	}
+
+	// the channel queue and the pattern queues each receive one copy
+	select {
+	case m := <-exact.Channel():
+		require.Equal(t, "2", m.RequestId)
+	case <-time.After(defaultClientTimeout):
+		require.FailNow(t, "queue subscription did not receive message")
+	}
+
+	received := 0
+	for _, q := range []Subscription[*internal.Request]{queueA, queueB} {
+		select {
+		case m := <-q.Channel():
+			require.Equal(t, "2", m.RequestId)
+			received++
+		case <-time.After(time.Millisecond * 500):
+		}
+	}
+	require.Equal(t, 1, received)
+}
+
 func testSubscribeClose(t *testing.T, bus MessageBus) {
 	ctx := context.Background()
 
@@ -129,3 +219,28 @@ func testSubscribeClose(t *testing.T, bus MessageBus) {
 		require.FailNow(t, "closed subscription channel should not block")
 	}
 }
+
+func testSubscriptionStats(t *testing.T, bus MessageBus) {
+	ctx := context.Background()
+
+	channel := rand.NewString()
+	sub, err := Subscribe[*internal.Request](ctx, bus, channel, 1)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sub.Close() })
+	require.Equal(t, ChannelStats{Capacity: 1}, Stats(sub))
+
+	// the subscription holds one message, a second waits to be buffered, and the bus holds a third
+	go func() {
+		for i := 0; i < 4; i++ {
+			_ = bus.Publish(ctx, channel, &internal.Request{})
+		}
+	}()
+	require.Eventually(t, func() bool { return Stats(sub).Depth == 2 }, time.Second, 10*time.Millisecond)
+	// depending on scheduling, the first messages may also wait for the subscription to read them
+	require.GreaterOrEqual(t, Stats(sub).Blocked, uint64(2))
+
+	for i := 0; i < 4; i++ {
+		<-sub.Channel()
+	}
+	require.Equal(t, 0, Stats(sub).Depth)
+}
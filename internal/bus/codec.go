@@ -0,0 +1,217 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bus
+
+import (
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Codec encodes request, response and stream payloads. Envelopes are always encoded with protobuf, and name the
+// codec of their payload so receivers can decode it
+type Codec interface {
+	Name() string
+	Marshal(m proto.Message) ([]byte, error)
+	Unmarshal(b []byte, m proto.Message) error
+}
+
+const (
+	ProtoCodecName = "proto"
+	JSONCodecName  = "json"
+	RawCodecName   = "raw"
+)
+
+var (
+	ProtoCodec Codec = protoCodec{}
+	JSONCodec  Codec = jsonCodec{}
+	RawCodec   Codec = rawCodec{}
+)
+
+type protoCodec struct{}
+
+func (protoCodec) Name() string {
+	return ProtoCodecName
+}
+
+func (protoCodec) Marshal(m proto.Message) ([]byte, error) {
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(b []byte, m proto.Message) error {
+	return proto.Unmarshal(b, m)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return JSONCodecName
+}
+
+func (jsonCodec) Marshal(m proto.Message) ([]byte, error) {
+	return protojson.Marshal(m)
+}
+
+func (jsonCodec) Unmarshal(b []byte, m proto.Message) error {
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(b, m)
+}
+
+// rawCodec sends the value of *wrapperspb.BytesValue payloads as is, so already serialized frames can be relayed without
+// knowing their type. Other messages are encoded with protobuf
+type rawCodec struct{}
+
+func (rawCodec) Name() string {
+	return RawCodecName
+}
+
+func (rawCodec) Marshal(m proto.Message) ([]byte, error) {
+	if v, ok := m.(*wrapperspb.BytesValue); ok {
+		return v.Value, nil
+	}
+	return proto.Marshal(m)
+}
+
+func (rawCodec) Unmarshal(b []byte, m proto.Message) error {
+	if v, ok := m.(*wrapperspb.BytesValue); ok {
+		v.Value = b
+		return nil
+	}
+	return proto.Unmarshal(b, m)
+}
+
+var codecs = struct {
+	sync.RWMutex
+	m map[string]Codec
+}{
+	m: map[string]Codec{
+		ProtoCodecName: ProtoCodec,
+		JSONCodecName:  JSONCodec,
+		RawCodecName:   RawCodec,
+	},
+}
+
+func RegisterCodec(c Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.m[c.Name()] = c
+}
+
+// GetCodec returns the codec registered with name. Payloads without a codec name are protobuf
+func GetCodec(name string) (Codec, error) {
+	if name == "" {
+		return ProtoCodec, nil
+	}
+
+	codecs.RLock()
+	defer codecs.RUnlock()
+	c, ok := codecs.m[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec %q", name)
+	}
+	return c, nil
+}
+
+// CodecName returns the name of c for envelopes, which is empty for protobuf so older receivers can decode them
+func CodecName(c Codec) string {
+	if c == nil || c.Name() == ProtoCodecName {
+		return ""
+	}
+	return c.Name()
+}
+
+// EncodePayload returns the payload and the codec name for its envelope. A nil codec encodes with protobuf
+func EncodePayload(c Codec, m proto.Message) ([]byte, string, error) {
+	name := CodecName(c)
+	if name == "" {
+		b, err := SerializePayload(m)
+		return b, "", err
+	}
+	b, err := c.Marshal(m)
+	return b, name, err
+}
+
+// EncodePayloadDeterministic encodes like EncodePayload, but protobuf payloads are marshaled with map entries in a
+// stable order, so equal messages produce equal bytes. Payloads for other codecs are encoded as usual
+func EncodePayloadDeterministic(c Codec, m proto.Message) ([]byte, string, error) {
+	if CodecName(c) != "" {
+		return EncodePayload(c, m)
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	return b, "", err
+}
+
+func DecodePayload[T proto.Message](codec string, buf []byte) (T, error) {
+	if codec == "" {
+		return DeserializePayload[T](buf)
+	}
+
+	var p T
+	c, err := GetCodec(codec)
+	if err != nil {
+		return p, err
+	}
+	v := p.ProtoReflect().New().Interface().(T)
+	return v, c.Unmarshal(buf, v)
+}
+
+// DecodePayloadStrict decodes like DecodePayload, but fails if the payload has fields unknown to this process
+func DecodePayloadStrict[T proto.Message](codec string, buf []byte) (T, error) {
+	if codec == JSONCodecName {
+		var p T
+		v := p.ProtoReflect().New().Interface().(T)
+		return v, protojson.Unmarshal(buf, v)
+	}
+
+	v, err := DecodePayload[T](codec, buf)
+	if err != nil {
+		return v, err
+	}
+	return v, CheckUnknownFields(v)
+}
+
+// CheckUnknownFields returns an error if m, or any message it contains, has fields unknown to this process
+func CheckUnknownFields(m proto.Message) error {
+	return checkUnknownFields(m.ProtoReflect())
+}
+
+func checkUnknownFields(m protoreflect.Message) error {
+	if len(m.GetUnknown()) != 0 {
+		return fmt.Errorf("unknown fields in %s", m.Descriptor().FullName())
+	}
+
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			l := v.List()
+			for i := 0; i < l.Len() && err == nil; i++ {
+				err = checkUnknownFields(l.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, v protoreflect.Value) bool {
+				err = checkUnknownFields(v.Message())
+				return err == nil
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			err = checkUnknownFields(v.Message())
+		}
+		return err == nil
+	})
+	return err
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/exp/slices"
+)
+
+type Compression string
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// SupportedCompression lists the algorithms this process can decompress, sent with requests so servers only compress
+// responses for clients that can read them
+var SupportedCompression = []string{string(CompressionGzip), string(CompressionZstd)}
+
+// encoders and decoders are safe for concurrent EncodeAll and DecodeAll calls
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// CompressPayload compresses payloads of at least threshold bytes, returning the compression for the envelope.
+// Smaller payloads, and payloads for peers that do not accept c, are returned unchanged
+func CompressPayload(c Compression, threshold int, accepted []string, b []byte) ([]byte, Compression, error) {
+	if c == CompressionNone || len(b) < threshold || !slices.Contains(accepted, string(c)) {
+		return b, CompressionNone, nil
+	}
+
+	switch c {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(b); err != nil {
+			return nil, CompressionNone, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, CompressionNone, err
+		}
+		return buf.Bytes(), c, nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(b, nil), c, nil
+	default:
+		return nil, CompressionNone, fmt.Errorf("unknown compression %q", c)
+	}
+}
+
+func DecompressPayload(c Compression, b []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return b, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	case CompressionZstd:
+		return zstdDecoder.DecodeAll(b, nil)
+	default:
+		return nil, fmt.Errorf("unknown compression %q", c)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bus
+
+// ProtocolVersion is sent in requests, responses and claims. Peers from before versioning send 0.
+//
+// Envelope fields are only added, never renumbered or removed, and receivers ignore fields they do not know, so
+// older peers keep working with newer envelopes. Changes that older peers cannot safely ignore bump the version, and
+// are only used once the negotiated version reaches it.
+//
+// Versions:
+//
+//	1: versioned requests, responses and claims
+//	2: compressed request payloads, payload references, chunked responses, per server stream channels and request
+//	   topics
+const ProtocolVersion uint32 = 2
+
+// The version each feature was added in. Features are only used with peers whose negotiated version has reached it
+const (
+	CompressionVersion         uint32 = 2
+	PayloadRefVersion          uint32 = 2
+	ChunkedResponseVersion     uint32 = 2
+	ServerStreamChannelVersion uint32 = 2
+	RequestTopicVersion        uint32 = 2
+)
+
+// NegotiateProtocolVersion returns the highest version supported by both this process and a peer sending peer
+func NegotiateProtocolVersion(peer uint32) uint32 {
+	if peer < ProtocolVersion {
+		return peer
+	}
+	return ProtocolVersion
+}
+
+// SupportsVersion reports whether a peer sending peer can use a feature added in version
+func SupportsVersion(peer, version uint32) bool {
+	return NegotiateProtocolVersion(peer) >= version
+}
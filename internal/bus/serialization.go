@@ -15,32 +15,68 @@
 package bus
 
 import (
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
 	"google.golang.org/protobuf/types/known/anypb"
 )
 
+const anyTypeURLPrefix = "type.googleapis.com/"
+
+// serialize writes msg as a google.protobuf.Any, marshaling it once into a buffer sized for the whole message
 func serialize(msg proto.Message) ([]byte, error) {
-	a, err := anypb.New(msg)
-	if err != nil {
-		return nil, err
-	}
+	typeURL := anyTypeURLPrefix + string(msg.ProtoReflect().Descriptor().FullName())
+	size := proto.Size(msg)
 
-	b, err := proto.Marshal(a)
-	if err != nil {
-		return nil, err
+	n := protowire.SizeTag(1) + protowire.SizeBytes(len(typeURL))
+	if size > 0 {
+		n += protowire.SizeTag(2) + protowire.SizeBytes(size)
 	}
-
-	return b, nil
+	b := make([]byte, 0, n)
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, typeURL)
+	if size == 0 {
+		return b, nil
+	}
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendVarint(b, uint64(size))
+	return proto.MarshalOptions{UseCachedSize: true}.MarshalAppend(b, msg)
 }
 
+// deserialize reads a google.protobuf.Any, unmarshaling its value in place instead of copying it out first
 func deserialize(b []byte) (proto.Message, error) {
-	a := &anypb.Any{}
-	err := proto.Unmarshal(b, a)
+	var typeURL string
+	var value []byte
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			typeURL, n = protowire.ConsumeString(b)
+		case num == 2 && typ == protowire.BytesType:
+			value, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+
+	mt, err := protoregistry.GlobalTypes.FindMessageByURL(typeURL)
 	if err != nil {
 		return nil, err
 	}
-
-	return a.UnmarshalNew()
+	m := mt.New().Interface()
+	if err := proto.Unmarshal(value, m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
 func SerializePayload(m proto.Message) ([]byte, error) {
@@ -52,3 +88,29 @@ func DeserializePayload[T proto.Message](buf []byte) (T, error) {
 	v := p.ProtoReflect().New().Interface().(T)
 	return v, proto.Unmarshal(buf, v)
 }
+
+func SerializeErrorDetails(details []proto.Message) []*anypb.Any {
+	var as []*anypb.Any
+	for _, d := range details {
+		if a, err := anypb.New(d); err == nil {
+			as = append(as, a)
+		}
+	}
+	return as
+}
+
+// DeserializeErrorDetails returns details with types unknown to this process as *anypb.Any
+func DeserializeErrorDetails(as []*anypb.Any) []proto.Message {
+	if len(as) == 0 {
+		return nil
+	}
+	details := make([]proto.Message, 0, len(as))
+	for _, a := range as {
+		if d, err := a.UnmarshalNew(); err == nil {
+			details = append(details, d)
+		} else {
+			details = append(details, a)
+		}
+	}
+	return details
+}
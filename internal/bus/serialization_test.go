@@ -15,11 +15,15 @@
 package bus
 
 import (
+	"bytes"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"github.com/livekit/psrpc/internal"
 )
@@ -44,6 +48,44 @@ func TestSerialization(t *testing.T) {
 	require.Equal(t, m.(*internal.Request).Multi, msg.Multi)
 }
 
+func TestSerializationMatchesAny(t *testing.T) {
+	msg := &internal.Request{
+		RequestId:  "reid",
+		RawRequest: []byte("payload"),
+	}
+
+	b, err := serialize(msg)
+	require.NoError(t, err)
+
+	a, err := anypb.New(msg)
+	require.NoError(t, err)
+	expected, err := proto.Marshal(a)
+	require.NoError(t, err)
+	require.Equal(t, expected, b)
+
+	b, err = serialize(&internal.Request{})
+	require.NoError(t, err)
+	m, err := deserialize(b)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(&internal.Request{}, m), "expected empty message to round trip")
+
+	_, err = deserialize([]byte{0x0a, 0x05})
+	require.Error(t, err)
+}
+
+func BenchmarkSerialization(b *testing.B) {
+	msg := &internal.Request{
+		RequestId:  "reid",
+		ClientId:   "clid",
+		RawRequest: make([]byte, 16<<10),
+	}
+
+	for i := 0; i < b.N; i++ {
+		buf, _ := serialize(msg)
+		_, _ = deserialize(buf)
+	}
+}
+
 func TestRawSerialization(t *testing.T) {
 	msg := &internal.Request{
 		RequestId: "reid",
@@ -63,3 +105,141 @@ func TestRawSerialization(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, proto.Equal(msg, msg1), "expected deserialized payload to match source")
 }
+
+func TestCodecs(t *testing.T) {
+	msg := &internal.Request{
+		RequestId: "reid",
+		ClientId:  "clid",
+		Metadata:  map[string]string{"a": "b"},
+	}
+
+	for _, c := range []Codec{ProtoCodec, JSONCodec, nil} {
+		b, name, err := EncodePayload(c, msg)
+		require.NoError(t, err)
+		require.Equal(t, CodecName(c), name)
+
+		m, err := DecodePayload[*internal.Request](name, b)
+		require.NoError(t, err)
+		require.True(t, proto.Equal(msg, m), "expected decoded payload to match source")
+	}
+
+	b, _, err := EncodePayload(JSONCodec, msg)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"requestId":"reid","clientId":"clid","metadata":{"a":"b"}}`, string(b))
+
+	_, err = DecodePayload[*internal.Request]("msgpack", b)
+	require.Error(t, err)
+}
+
+func TestEncodePayloadDeterministic(t *testing.T) {
+	msg := &internal.Request{
+		RequestId: "reid",
+		Metadata:  map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5"},
+	}
+
+	expected, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		b, name, err := EncodePayloadDeterministic(nil, msg)
+		require.NoError(t, err)
+		require.Empty(t, name)
+		require.Equal(t, expected, b)
+	}
+
+	b, name, err := EncodePayloadDeterministic(JSONCodec, msg)
+	require.NoError(t, err)
+	require.Equal(t, JSONCodecName, name)
+	m, err := DecodePayload[*internal.Request](name, b)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(msg, m), "expected decoded payload to match source")
+}
+
+func TestDecodePayloadStrict(t *testing.T) {
+	b, err := proto.Marshal(&internal.Request{RequestId: "reid"})
+	require.NoError(t, err)
+
+	_, err = DecodePayloadStrict[*internal.Request]("", b)
+	require.NoError(t, err)
+
+	// a field added in a newer schema
+	b = protowire.AppendTag(b, 100, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+	_, err = DecodePayload[*internal.Request]("", b)
+	require.NoError(t, err)
+	_, err = DecodePayloadStrict[*internal.Request]("", b)
+	require.Error(t, err)
+
+	// unknown fields in nested messages
+	detail := &anypb.Any{TypeUrl: "type.googleapis.com/internal.Request"}
+	detail.ProtoReflect().SetUnknown(protowire.AppendVarint(protowire.AppendTag(nil, 100, protowire.VarintType), 1))
+	b, err = proto.Marshal(&internal.Response{ErrorDetails: []*anypb.Any{detail}})
+	require.NoError(t, err)
+	_, err = DecodePayloadStrict[*internal.Response]("", b)
+	require.Error(t, err)
+
+	b = []byte(`{"requestId":"reid","futureField":1}`)
+	_, err = DecodePayload[*internal.Request](JSONCodecName, b)
+	require.NoError(t, err)
+	_, err = DecodePayloadStrict[*internal.Request](JSONCodecName, b)
+	require.Error(t, err)
+}
+
+func TestRawCodec(t *testing.T) {
+	frame := []byte{0xde, 0xad, 0xbe, 0xef}
+	b, name, err := EncodePayload(RawCodec, wrapperspb.Bytes(frame))
+	require.NoError(t, err)
+	require.Equal(t, RawCodecName, name)
+	require.Equal(t, frame, b)
+
+	v, err := DecodePayload[*wrapperspb.BytesValue](name, b)
+	require.NoError(t, err)
+	require.Equal(t, frame, v.Value)
+
+	// other messages are protobuf
+	msg := &internal.Request{RequestId: "reid"}
+	b, _, err = EncodePayload(RawCodec, msg)
+	require.NoError(t, err)
+	m, err := DecodePayload[*internal.Request]("", b)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(msg, m), "expected decoded payload to match source")
+}
+
+func TestCompression(t *testing.T) {
+	b := bytes.Repeat([]byte("room state "), 100)
+
+	for _, c := range []Compression{CompressionGzip, CompressionZstd} {
+		compressed, applied, err := CompressPayload(c, 64, SupportedCompression, b)
+		require.NoError(t, err)
+		require.Equal(t, c, applied)
+		require.Less(t, len(compressed), len(b))
+
+		decompressed, err := DecompressPayload(applied, compressed)
+		require.NoError(t, err)
+		require.Equal(t, b, decompressed)
+	}
+
+	// below the threshold
+	out, applied, err := CompressPayload(CompressionGzip, len(b)+1, SupportedCompression, b)
+	require.NoError(t, err)
+	require.Equal(t, CompressionNone, applied)
+	require.Equal(t, b, out)
+
+	// peer does not accept compression
+	out, applied, err = CompressPayload(CompressionZstd, 64, nil, b)
+	require.NoError(t, err)
+	require.Equal(t, CompressionNone, applied)
+	require.Equal(t, b, out)
+
+	_, err = DecompressPayload("brotli", b)
+	require.Error(t, err)
+}
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	require.Equal(t, uint32(0), NegotiateProtocolVersion(0))
+	require.Equal(t, ProtocolVersion, NegotiateProtocolVersion(ProtocolVersion))
+	require.Equal(t, ProtocolVersion, NegotiateProtocolVersion(ProtocolVersion+1))
+
+	require.False(t, SupportsVersion(1, PayloadRefVersion))
+	require.True(t, SupportsVersion(PayloadRefVersion, PayloadRefVersion))
+	require.True(t, SupportsVersion(ProtocolVersion+1, ProtocolVersion))
+}
@@ -0,0 +1,52 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bus
+
+import (
+	"google.golang.org/protobuf/proto"
+)
+
+type ChannelStats struct {
+	Depth    int    // messages buffered by the subscription and its bus
+	Capacity int    // size of the subscription buffer
+	Blocked  uint64 // messages that waited for room in a full buffer
+	Dropped  uint64 // messages discarded by the bus because the buffer was full
+}
+
+// implemented by readers that buffer messages before the subscription reads them
+type backlogReader interface {
+	backlog() ChannelStats
+}
+
+// Stats reports the backlog of a subscription created with Subscribe or SubscribeQueue
+func Stats[MessageType proto.Message](sub Subscription[MessageType]) ChannelStats {
+	s, ok := sub.(*subscription[MessageType])
+	if !ok {
+		return ChannelStats{}
+	}
+
+	stats := ChannelStats{
+		Depth:    len(s.c),
+		Capacity: cap(s.c),
+		Blocked:  s.blocked.Load(),
+	}
+	if r, ok := s.Reader.(backlogReader); ok {
+		b := r.backlog()
+		stats.Depth += b.Depth
+		stats.Blocked += b.Blocked
+		stats.Dropped += b.Dropped
+	}
+	return stats
+}
@@ -15,6 +15,9 @@
 package bus
 
 import (
+	"sync"
+
+	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/livekit/psrpc/internal/logger"
@@ -27,11 +30,16 @@ type Subscription[MessageType proto.Message] interface {
 
 type subscription[MessageType proto.Message] struct {
 	Reader
-	c <-chan MessageType
+	c       chan MessageType
+	blocked atomic.Uint64
 }
 
 func newSubscription[MessageType proto.Message](sub Reader, size int) Subscription[MessageType] {
 	msgChan := make(chan MessageType, size)
+	s := &subscription[MessageType]{
+		Reader: sub,
+		c:      msgChan,
+	}
 	go func() {
 		for {
 			b, ok := sub.read()
@@ -45,16 +53,37 @@ func newSubscription[MessageType proto.Message](sub Reader, size int) Subscripti
 				logger.Error(err, "failed to deserialize message")
 				continue
 			}
-			msgChan <- p.(MessageType)
+			select {
+			case msgChan <- p.(MessageType):
+			default:
+				s.blocked.Inc()
+				msgChan <- p.(MessageType)
+			}
 		}
 	}()
 
-	return &subscription[MessageType]{
-		Reader: sub,
-		c:      msgChan,
+	return s
+}
+
+type closeHookSubscription[MessageType proto.Message] struct {
+	Subscription[MessageType]
+	once    sync.Once
+	onClose func()
+}
+
+// WithCloseHook calls onClose the first time sub is closed
+func WithCloseHook[MessageType proto.Message](sub Subscription[MessageType], onClose func()) Subscription[MessageType] {
+	return &closeHookSubscription[MessageType]{
+		Subscription: sub,
+		onClose:      onClose,
 	}
 }
 
+func (s *closeHookSubscription[MessageType]) Close() error {
+	s.once.Do(s.onClose)
+	return s.Subscription.Close()
+}
+
 func (s *subscription[MessageType]) Channel() <-chan MessageType {
 	return s.c
 }
@@ -1,27 +1,36 @@
-// Copyright 2023 LiveKit, Inc.
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//     http://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
-
 package logger
 
-import "github.com/go-logr/logr"
+import "go.uber.org/atomic"
+
+// Logger is implemented by logr.Logger, and by adapters for other key/value loggers such as slog or zap
+type Logger interface {
+	Info(msg string, keysAndValues ...any)
+	Error(err error, msg string, keysAndValues ...any)
+}
+
+type discard struct{}
+
+func (discard) Info(string, ...any)         {}
+func (discard) Error(error, string, ...any) {}
 
-var logger = logr.Discard()
+type holder struct {
+	Logger
+}
+
+// loggers may be set while subscriptions and handlers are running
+var logger = atomic.NewPointer(&holder{discard{}})
+
+func SetLogger(l Logger) {
+	if l == nil {
+		l = discard{}
+	}
+	logger.Store(&holder{l})
+}
 
-func SetLogger(l logr.Logger) {
-	logger = l
+func Info(msg string, values ...interface{}) {
+	logger.Load().Info(msg, values...)
 }
 
 func Error(err error, msg string, values ...interface{}) {
-	logger.Error(err, msg, values...)
+	logger.Load().Error(err, msg, values...)
 }
@@ -0,0 +1,46 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"github.com/livekit/psrpc"
+)
+
+// overflow applies the backpressure policy to a message received while the channel is full. s.mu must be held.
+// streams with BackpressureFail are closed by HandleStream
+func (s *streamBase[SendType, RecvType]) overflow(msg RecvType) error {
+	if s.backpressure == psrpc.BackpressureDropOldest {
+		for {
+			select {
+			case <-s.recvChan:
+				s.dropped()
+			default:
+			}
+			select {
+			case s.recvChan <- msg:
+				return nil
+			default:
+			}
+		}
+	}
+	s.dropped()
+	return psrpc.ErrSlowConsumer
+}
+
+func (s *streamBase[SendType, RecvType]) dropped() {
+	if s.onDrop != nil {
+		s.onDrop()
+	}
+}
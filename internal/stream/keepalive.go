@@ -0,0 +1,64 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"context"
+	"time"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/internal/logger"
+	"github.com/livekit/psrpc/pkg/rand"
+)
+
+// keepalive pings the other side when nothing has been received for interval, and closes the stream after timeout
+func (s *stream[SendType, RecvType]) keepalive(interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+
+		case now := <-ticker.C:
+			idle := now.Sub(time.Unix(0, s.lastRecv.Load()))
+			if idle >= timeout {
+				if err := s.Close(psrpc.ErrPeerUnresponsive); err != nil {
+					logger.Error(err, "failed to close stream", "streamID", s.streamID)
+				}
+				return
+			}
+
+			if idle >= interval {
+				ctx, cancel := context.WithTimeout(s.ctx, timeout)
+				err := s.adapter.Send(ctx, &internal.Stream{
+					StreamId:  s.streamID,
+					RequestId: rand.NewRequestID(),
+					SentAt:    now.UnixNano(),
+					Expiry:    now.Add(timeout).UnixNano(),
+					Body: &internal.Stream_Ping{
+						Ping: &internal.StreamPing{},
+					},
+				})
+				cancel()
+				if err != nil {
+					logger.Error(err, "failed to ping stream", "streamID", s.streamID)
+				}
+			}
+		}
+	}
+}
@@ -15,9 +15,26 @@
 package stream
 
 import (
+	"time"
+
 	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal/bus"
 )
 
+type Options struct {
+	Timeout       time.Duration
+	RecvWindow    int           // messages held until the consumer makes room, 0 discards messages when the channel is full
+	RetryInterval time.Duration // unacknowledged messages are resent every interval, 0 disables retries
+
+	KeepaliveInterval time.Duration // idle streams ping the other side every interval, 0 disables keepalives
+	KeepaliveTimeout  time.Duration // streams are closed when nothing is received from the other side for timeout
+
+	Backpressure psrpc.BackpressurePolicy // applies to streams without a receive window, messages are discarded by default
+	OnDrop       func()                   // called for each received message discarded because the consumer fell behind
+
+	Codec bus.Codec // encodes sent messages, protobuf by default. received messages name their codec
+}
+
 func getStreamOpts(options psrpc.StreamOpts, opts ...psrpc.StreamOption) psrpc.StreamOpts {
 	o := &psrpc.StreamOpts{
 		Timeout: options.Timeout,
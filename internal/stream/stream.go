@@ -20,6 +20,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gammazero/deque"
+	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/livekit/psrpc"
@@ -33,9 +35,11 @@ import (
 
 type Stream[SendType, RecvType proto.Message] interface {
 	psrpc.ServerStream[SendType, RecvType]
+	CloseSend() error
 
 	Ack(context.Context, *internal.Stream) error
 	HandleStream(is *internal.Stream) error
+	SetSendWindow(size int)
 	Hijacked() bool
 }
 
@@ -58,21 +62,42 @@ type streamBase[SendType, RecvType proto.Message] struct {
 	cancel   context.CancelFunc
 	streamID string
 
-	adapter  StreamAdapter
-	recvChan chan RecvType
-
-	mu      sync.Mutex
-	pending sync.WaitGroup
-	acks    map[string]chan struct{}
-	closed  bool
-	err     error
+	adapter       StreamAdapter
+	recvChan      chan RecvType
+	recvWindow    int
+	recvLimit     int
+	retryInterval time.Duration
+	backpressure  psrpc.BackpressurePolicy
+	onDrop        func()
+	codec         bus.Codec
+	queueing      bool
+	queued        chan struct{}
+	sendSeq       atomic.Uint64
+	lastRecv      atomic.Int64
+
+	mu            sync.Mutex
+	pending       sync.WaitGroup
+	acks          map[string]chan struct{}
+	recvQueue     deque.Deque[*delivery[RecvType]]
+	recvAck       *internal.Stream
+	recvSeq       uint64
+	recvSeen      map[uint64]struct{}
+	received      uint64 // messages queued for delivery
+	delivered     uint64 // messages delivered to the consumer
+	reported      uint64 // deliveries last reported to the sender
+	sendWindow    chan struct{}
+	peerDelivered uint64 // deliveries last reported by the receiver
+	closed        bool
+	sendClosed    bool
+	recvClosed    bool
+	err           error
 }
 
 func NewStream[SendType, RecvType proto.Message](
 	ctx context.Context,
 	i *info.RequestInfo,
 	streamID string,
-	timeout time.Duration,
+	opts Options,
 	adapter StreamAdapter,
 	streamInterceptors []psrpc.StreamInterceptor,
 	recvChan chan RecvType,
@@ -81,25 +106,56 @@ func NewStream[SendType, RecvType proto.Message](
 
 	ctx, cancel := context.WithCancel(ctx)
 	base := &streamBase[SendType, RecvType]{
-		StreamOpts: psrpc.StreamOpts{Timeout: timeout},
-		ctx:        ctx,
-		cancel:     cancel,
-		streamID:   streamID,
-		adapter:    adapter,
-		recvChan:   recvChan,
-		acks:       acks,
+		StreamOpts:    psrpc.StreamOpts{Timeout: opts.Timeout},
+		ctx:           ctx,
+		cancel:        cancel,
+		streamID:      streamID,
+		adapter:       adapter,
+		recvChan:      recvChan,
+		recvWindow:    opts.RecvWindow,
+		retryInterval: opts.RetryInterval,
+		backpressure:  opts.Backpressure,
+		onDrop:        opts.OnDrop,
+		codec:         opts.Codec,
+		queueing:      opts.RecvWindow > 0 || opts.Backpressure == psrpc.BackpressureBlock,
+		acks:          acks,
+		recvSeen:      make(map[uint64]struct{}),
+	}
+	if base.queueing {
+		// blocking streams hold up to a channel's worth of messages beyond the channel
+		base.recvLimit = opts.RecvWindow
+		if base.recvLimit == 0 {
+			base.recvLimit = cap(recvChan)
+		}
+		if base.recvLimit == 0 {
+			base.recvLimit = 1
+		}
+		base.queued = make(chan struct{}, 1)
+		go base.deliver()
 	}
 
-	return &stream[SendType, RecvType]{
+	s := &stream[SendType, RecvType]{
 		streamBase: base,
 		handler: interceptors.ChainClientInterceptors[psrpc.StreamHandler](
 			streamInterceptors, i, base,
 		),
 	}
+	if opts.KeepaliveInterval > 0 {
+		base.lastRecv.Store(time.Now().UnixNano())
+		go s.keepalive(opts.KeepaliveInterval, opts.KeepaliveTimeout)
+	}
+	return s
 }
 
 func (s *stream[SendType, RecvType]) HandleStream(is *internal.Stream) error {
+	s.lastRecv.Store(time.Now().UnixNano())
+
 	switch b := is.Body.(type) {
+	case *internal.Stream_Ping:
+		ctx, cancel := context.WithDeadline(s.ctx, time.Unix(0, is.Expiry))
+		defer cancel()
+		return s.Ack(ctx, is)
+
 	case *internal.Stream_Ack:
 		s.mu.Lock()
 		ack, ok := s.acks[is.RequestId]
@@ -109,6 +165,10 @@ func (s *stream[SendType, RecvType]) HandleStream(is *internal.Stream) error {
 		if ok {
 			close(ack)
 		}
+		if size := b.Ack.WindowSize; size > 0 {
+			s.SetSendWindow(int(size))
+		}
+		s.releaseDelivered(b.Ack.Delivered)
 
 	case *internal.Stream_Message:
 		if err := s.addPending(); err != nil {
@@ -116,7 +176,11 @@ func (s *stream[SendType, RecvType]) HandleStream(is *internal.Stream) error {
 		}
 		defer s.pending.Done()
 
-		v, err := bus.DeserializePayload[RecvType](b.Message.RawMessage)
+		if s.duplicate(is) {
+			return s.ackDuplicate(is)
+		}
+
+		v, err := bus.DecodePayload[RecvType](b.Message.Codec, b.Message.RawMessage)
 		if err != nil {
 			err = psrpc.NewError(psrpc.MalformedRequest, err)
 			go func() {
@@ -127,30 +191,93 @@ func (s *stream[SendType, RecvType]) HandleStream(is *internal.Stream) error {
 			return err
 		}
 
-		if err := s.handler.Recv(v); err != nil {
+		// messages are acked once queued. with a receive window, the window is reopened as the consumer reads them
+		s.mu.Lock()
+		s.recvAck = is
+		s.mu.Unlock()
+
+		err = s.handler.Recv(v)
+
+		s.mu.Lock()
+		ack := s.recvAck
+		s.recvAck = nil
+		s.mu.Unlock()
+
+		if err != nil {
+			if s.backpressure == psrpc.BackpressureFail && errors.Is(err, psrpc.ErrSlowConsumer) {
+				go func() {
+					if e := s.handler.Close(err); e != nil {
+						logger.Error(e, "failed to close stream")
+					}
+				}()
+			}
 			return err
 		}
+		s.markReceived(is)
+		if ack != nil {
+			ctx, cancel := context.WithDeadline(s.ctx, time.Unix(0, is.Expiry))
+			defer cancel()
+			if err := s.Ack(ctx, is); err != nil {
+				return err
+			}
+		}
+
+	case *internal.Stream_CloseSend:
+		if s.duplicate(is) {
+			return s.ackDuplicate(is)
+		}
+		s.markReceived(is)
+		if s.queueing {
+			d := &delivery[RecvType]{closeSend: true}
+			if s.recvWindow == 0 {
+				d.ack = is
+			}
+			s.enqueue(d)
+			if d.ack != nil {
+				return nil
+			}
+		}
 
 		ctx, cancel := context.WithDeadline(s.ctx, time.Unix(0, is.Expiry))
 		defer cancel()
 		if err := s.Ack(ctx, is); err != nil {
 			return err
 		}
+		s.closeRecv()
 
 	case *internal.Stream_Close:
-		cause := psrpc.NewErrorFromResponse(b.Close.Code, b.Close.Error)
+		cause := closeCause(b.Close)
 		if err := s.setClosed(cause); err != nil {
 			return err
 		}
 
 		s.adapter.Close(s.streamID)
 		s.cancel()
-		close(s.recvChan)
+		s.closeRecv()
 	}
 
 	return nil
 }
 
+// closeSentinels are restored by closeCause so receivers can match them with errors.Is
+var closeSentinels = []psrpc.Error{
+	psrpc.ErrStreamEOF,
+	psrpc.ErrStreamClosed,
+	psrpc.ErrSlowConsumer,
+	psrpc.ErrPeerUnresponsive,
+}
+
+func closeCause(msg *internal.StreamClose) error {
+	if len(msg.ErrorDetails) == 0 {
+		for _, e := range closeSentinels {
+			if msg.Code == string(e.Code()) && msg.Error == e.Error() {
+				return e
+			}
+		}
+	}
+	return psrpc.NewErrorFromResponse(msg.Code, msg.Error, bus.DeserializeErrorDetails(msg.ErrorDetails)...)
+}
+
 func (s *stream[SendType, RecvType]) Context() context.Context {
 	return s.ctx
 }
@@ -159,14 +286,22 @@ func (s *stream[SendType, RecvType]) Channel() <-chan RecvType {
 	return s.recvChan
 }
 
-func (s *stream[SendType, RecvType]) Ack(ctx context.Context, is *internal.Stream) error {
+func (s *streamBase[SendType, RecvType]) Ack(ctx context.Context, is *internal.Stream) error {
+	s.mu.Lock()
+	delivered := s.delivered
+	s.reported = delivered
+	s.mu.Unlock()
+
 	return s.adapter.Send(ctx, &internal.Stream{
 		StreamId:  is.StreamId,
 		RequestId: is.RequestId,
 		SentAt:    is.SentAt,
 		Expiry:    is.Expiry,
 		Body: &internal.Stream_Ack{
-			Ack: &internal.StreamAck{},
+			Ack: &internal.StreamAck{
+				WindowSize: int32(s.recvWindow),
+				Delivered:  delivered,
+			},
 		},
 	})
 }
@@ -176,12 +311,36 @@ func (s *stream[SendType, RecvType]) Recv(msg proto.Message) error {
 }
 
 func (s *streamBase[SendType, RecvType]) Recv(msg proto.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.recvClosed {
+		return psrpc.ErrStreamSendClosed
+	}
+
+	// windowed streams ack messages once queued, and the sender's window keeps the queue bounded. blocking streams
+	// ack each message once delivered. messages beyond the limit are not acked, so senders time out or resend them
+	if s.queueing {
+		if s.recvQueue.Len() >= s.recvLimit {
+			s.dropped()
+			return psrpc.ErrSlowConsumer
+		}
+		d := &delivery[RecvType]{msg: msg.(RecvType)}
+		if s.recvWindow == 0 {
+			d.ack = s.recvAck
+			s.recvAck = nil
+		}
+		s.received++
+		s.recvQueue.PushBack(d)
+		s.notifyQueued()
+		return nil
+	}
+
 	select {
 	case s.recvChan <- msg.(RecvType):
+		return nil
 	default:
-		return psrpc.ErrSlowConsumer
+		return s.overflow(msg.(RecvType))
 	}
-	return nil
 }
 
 func (s *stream[SendType, RecvType]) Send(request SendType, opts ...psrpc.StreamOption) (err error) {
@@ -189,6 +348,46 @@ func (s *stream[SendType, RecvType]) Send(request SendType, opts ...psrpc.Stream
 }
 
 func (s *streamBase[SendType, RecvType]) Send(msg proto.Message, opts ...psrpc.StreamOption) (err error) {
+	s.mu.Lock()
+	sendClosed := s.sendClosed
+	s.mu.Unlock()
+	if sendClosed {
+		return psrpc.ErrStreamSendClosed
+	}
+
+	b, codec, err := bus.EncodePayload(s.codec, msg)
+	if err != nil {
+		return psrpc.NewError(psrpc.MalformedRequest, err)
+	}
+
+	return s.send(&internal.Stream{
+		Body: &internal.Stream_Message{
+			Message: &internal.StreamMessage{
+				RawMessage: b,
+				Codec:      codec,
+			},
+		},
+	}, opts...)
+}
+
+// CloseSend tells the other side no more messages will be sent, closing its channel while the stream stays open
+func (s *streamBase[SendType, RecvType]) CloseSend() error {
+	s.mu.Lock()
+	if s.sendClosed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.sendClosed = true
+	s.mu.Unlock()
+
+	return s.send(&internal.Stream{
+		Body: &internal.Stream_CloseSend{
+			CloseSend: &internal.StreamCloseSend{},
+		},
+	})
+}
+
+func (s *streamBase[SendType, RecvType]) send(msg *internal.Stream, opts ...psrpc.StreamOption) (err error) {
 	if err := s.addPending(); err != nil {
 		return err
 	}
@@ -196,11 +395,23 @@ func (s *streamBase[SendType, RecvType]) Send(msg proto.Message, opts ...psrpc.S
 
 	o := getStreamOpts(s.StreamOpts, opts...)
 
-	b, err := bus.SerializePayload(msg)
+	now := time.Now()
+	deadline := now.Add(o.Timeout)
+
+	ctx, cancel := context.WithDeadline(s.ctx, deadline)
+	defer cancel()
+
+	// acked messages hold their place in the window until the receiver reports them delivered
+	window, err := s.acquireSendWindow(ctx)
 	if err != nil {
-		err = psrpc.NewError(psrpc.MalformedRequest, err)
 		return
 	}
+	acked := false
+	defer func() {
+		if !acked {
+			releaseSendWindow(window)
+		}
+	}()
 
 	ackChan := make(chan struct{})
 	requestID := rand.NewRequestID()
@@ -215,39 +426,48 @@ func (s *streamBase[SendType, RecvType]) Send(msg proto.Message, opts ...psrpc.S
 		s.mu.Unlock()
 	}()
 
-	now := time.Now()
-	deadline := now.Add(o.Timeout)
-
-	ctx, cancel := context.WithDeadline(s.ctx, deadline)
-	defer cancel()
-
-	err = s.adapter.Send(ctx, &internal.Stream{
-		StreamId:  s.streamID,
-		RequestId: requestID,
-		SentAt:    now.UnixNano(),
-		Expiry:    deadline.UnixNano(),
-		Body: &internal.Stream_Message{
-			Message: &internal.StreamMessage{
-				RawMessage: b,
-			},
-		},
-	})
-	if err != nil {
+	msg.StreamId = s.streamID
+	msg.RequestId = requestID
+	if s.retryInterval > 0 {
+		msg.Seq = s.sendSeq.Inc()
+	}
+	msg.SentAt = now.UnixNano()
+	msg.Expiry = deadline.UnixNano()
+	if err = s.adapter.Send(ctx, msg); err != nil && s.retryInterval <= 0 {
 		return
 	}
 
-	select {
-	case <-ackChan:
-	case <-ctx.Done():
+	// resend until acked, so messages lost while the bus reconnects are recovered. once the send times out the message
+	// is given up on, and the receiver does not wait for it
+	var retry <-chan time.Time
+	if s.retryInterval > 0 {
+		ticker := time.NewTicker(s.retryInterval)
+		defer ticker.Stop()
+		retry = ticker.C
+	}
+
+	for {
 		select {
-		case <-s.ctx.Done():
-			err = s.Err()
-		default:
-			err = psrpc.ErrRequestTimedOut
+		case <-ackChan:
+			acked = true
+			return nil
+		case <-retry:
+			if err := s.adapter.Send(ctx, msg); err != nil {
+				logger.Error(err, "failed to resend stream message", "streamID", s.streamID)
+			}
+		case <-ctx.Done():
+			return s.ctxErr()
 		}
 	}
+}
 
-	return
+func (s *streamBase[SendType, RecvType]) ctxErr() error {
+	select {
+	case <-s.ctx.Done():
+		return s.Err()
+	default:
+		return psrpc.ErrRequestTimedOut
+	}
 }
 
 func (s *stream[SendType, RecvType]) Hijack() {
@@ -284,6 +504,10 @@ func (s *streamBase[RequestType, ResponseType]) Close(cause error) error {
 		msg.Error = cause.Error()
 		msg.Code = string(psrpc.Unknown)
 	}
+	var d psrpc.ErrorWithDetails
+	if errors.As(cause, &d) {
+		msg.ErrorDetails = bus.SerializeErrorDetails(d.Details())
+	}
 
 	now := time.Now()
 	err := s.adapter.Send(context.Background(), &internal.Stream{
@@ -299,11 +523,25 @@ func (s *streamBase[RequestType, ResponseType]) Close(cause error) error {
 	s.pending.Wait()
 	s.adapter.Close(s.streamID)
 	s.cancel()
-	close(s.recvChan)
+	s.closeRecv()
 
 	return err
 }
 
+func (s *streamBase[SendType, RecvType]) closeRecv() {
+	// queued messages are delivered until the stream context is done, then deliver closes the channel
+	if s.queueing {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.recvClosed {
+		s.recvClosed = true
+		close(s.recvChan)
+	}
+}
+
 func (s *streamBase[SendType, RecvType]) addPending() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
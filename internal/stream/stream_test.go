@@ -16,8 +16,10 @@ package stream
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
@@ -34,7 +36,7 @@ func TestClosePendingSend(t *testing.T) {
 		context.Background(),
 		&info.RequestInfo{},
 		rand.NewStreamID(),
-		psrpc.DefaultClientTimeout,
+		Options{Timeout: psrpc.DefaultClientTimeout},
 		&testStreamAdapter{},
 		nil,
 		make(chan *internal.Response),
@@ -91,7 +93,7 @@ func TestClosePanic(t *testing.T) {
 			context.Background(),
 			&info.RequestInfo{},
 			rand.NewStreamID(),
-			psrpc.DefaultClientTimeout,
+			Options{Timeout: psrpc.DefaultClientTimeout},
 			&testStreamAdapter{},
 			nil,
 			make(chan *internal.Response, 1),
@@ -125,3 +127,201 @@ func TestClosePanic(t *testing.T) {
 		wg.Wait()
 	}
 }
+
+func TestResendUnacked(t *testing.T) {
+	opts := Options{
+		Timeout:       psrpc.DefaultClientTimeout,
+		RetryInterval: 10 * time.Millisecond,
+	}
+	toReceiver := &lossyStreamAdapter{}
+	toReceiver.drop.Store(1)
+	toSender := &lossyStreamAdapter{}
+	toSender.drop.Store(1)
+
+	sender := NewStream[*internal.Response, *internal.Response](
+		context.Background(), &info.RequestInfo{}, "stream", opts, toReceiver, nil, make(chan *internal.Response, 1), make(map[string]chan struct{}),
+	)
+	receiver := NewStream[*internal.Response, *internal.Response](
+		context.Background(), &info.RequestInfo{}, "stream", opts, toSender, nil, make(chan *internal.Response, 2), make(map[string]chan struct{}),
+	)
+	toReceiver.connect(receiver)
+	toSender.connect(sender)
+
+	// the first message and the first ack are lost
+	require.NoError(t, sender.Send(&internal.Response{RequestId: "a"}))
+	require.NoError(t, sender.Send(&internal.Response{RequestId: "b"}))
+
+	require.Equal(t, "a", (<-receiver.Channel()).RequestId)
+	require.Equal(t, "b", (<-receiver.Channel()).RequestId)
+	require.Empty(t, receiver.Channel())
+}
+
+func TestWindowAcksQueued(t *testing.T) {
+	opts := Options{Timeout: 200 * time.Millisecond}
+	toReceiver := &lossyStreamAdapter{}
+	toSender := &lossyStreamAdapter{}
+
+	sender := NewStream[*internal.Response, *internal.Response](
+		context.Background(), &info.RequestInfo{}, "stream", opts, toReceiver, nil, make(chan *internal.Response, 1), make(map[string]chan struct{}),
+	)
+	opts.RecvWindow = 2
+	receiver := NewStream[*internal.Response, *internal.Response](
+		context.Background(), &info.RequestInfo{}, "stream", opts, toSender, nil, make(chan *internal.Response), make(map[string]chan struct{}),
+	)
+	toReceiver.connect(receiver)
+	toSender.connect(sender)
+	sender.SetSendWindow(2)
+
+	// queued messages are acked before the consumer reads them, and the window stops the sender once it is full
+	require.NoError(t, sender.Send(&internal.Response{RequestId: "a"}))
+	require.NoError(t, sender.Send(&internal.Response{RequestId: "b"}))
+	require.ErrorIs(t, sender.Send(&internal.Response{RequestId: "c"}), psrpc.ErrRequestTimedOut)
+
+	// reading reopens the window
+	require.Equal(t, "a", (<-receiver.Channel()).RequestId)
+	require.NoError(t, sender.Send(&internal.Response{RequestId: "d"}))
+	require.Equal(t, "b", (<-receiver.Channel()).RequestId)
+	require.Equal(t, "d", (<-receiver.Channel()).RequestId)
+}
+
+type lossyStreamAdapter struct {
+	mu   sync.Mutex
+	to   Stream[*internal.Response, *internal.Response]
+	drop atomic.Int32
+}
+
+func (a *lossyStreamAdapter) connect(to Stream[*internal.Response, *internal.Response]) {
+	a.mu.Lock()
+	a.to = to
+	a.mu.Unlock()
+}
+
+func (a *lossyStreamAdapter) Send(ctx context.Context, msg *internal.Stream) error {
+	if a.drop.Dec() >= 0 {
+		return errors.New("bus unavailable")
+	}
+	a.mu.Lock()
+	to := a.to
+	a.mu.Unlock()
+	go to.HandleStream(proto.Clone(msg).(*internal.Stream))
+	return nil
+}
+
+func (a *lossyStreamAdapter) Close(streamID string) {}
+
+func TestKeepalive(t *testing.T) {
+	opts := Options{
+		Timeout:           psrpc.DefaultClientTimeout,
+		KeepaliveInterval: 10 * time.Millisecond,
+		KeepaliveTimeout:  50 * time.Millisecond,
+	}
+
+	t.Run("IdlePeer", func(t *testing.T) {
+		toPeer := &lossyStreamAdapter{}
+		toStream := &lossyStreamAdapter{}
+		s := NewStream[*internal.Response, *internal.Response](
+			context.Background(), &info.RequestInfo{}, "stream", opts, toPeer, nil, make(chan *internal.Response, 1), make(map[string]chan struct{}),
+		)
+		// the peer does not send pings of its own, but answers them
+		peer := NewStream[*internal.Response, *internal.Response](
+			context.Background(), &info.RequestInfo{}, "stream", Options{Timeout: opts.Timeout}, toStream, nil, make(chan *internal.Response, 1), make(map[string]chan struct{}),
+		)
+		toPeer.connect(peer)
+		toStream.connect(s)
+
+		time.Sleep(150 * time.Millisecond)
+		require.NoError(t, s.Err())
+	})
+
+	t.Run("DeadPeer", func(t *testing.T) {
+		s := NewStream[*internal.Response, *internal.Response](
+			context.Background(), &info.RequestInfo{}, "stream", opts, &testStreamAdapter{}, nil, make(chan *internal.Response, 1), make(map[string]chan struct{}),
+		)
+
+		select {
+		case <-s.Context().Done():
+			require.ErrorIs(t, s.Err(), psrpc.ErrPeerUnresponsive)
+		case <-time.After(time.Second):
+			t.Fatal("stream not closed")
+		}
+	})
+}
+
+func TestBackpressure(t *testing.T) {
+	newStream := func(policy psrpc.BackpressurePolicy) (Stream[*internal.Response, *internal.Response], *testStreamAdapter) {
+		adapter := &testStreamAdapter{}
+		s := NewStream[*internal.Response, *internal.Response](
+			context.Background(),
+			&info.RequestInfo{},
+			rand.NewStreamID(),
+			Options{Timeout: psrpc.DefaultClientTimeout, Backpressure: policy},
+			adapter,
+			nil,
+			make(chan *internal.Response, 1),
+			make(map[string]chan struct{}),
+		)
+		t.Cleanup(func() { _ = s.Close(nil) })
+		return s, adapter
+	}
+	message := func(id string) *internal.Stream {
+		b, _ := proto.Marshal(&internal.Response{RequestId: id})
+		return &internal.Stream{
+			RequestId: id,
+			Expiry:    time.Now().Add(time.Second).UnixNano(),
+			Body: &internal.Stream_Message{
+				Message: &internal.StreamMessage{RawMessage: b},
+			},
+		}
+	}
+
+	t.Run("Block", func(t *testing.T) {
+		s, adapter := newStream(psrpc.BackpressureBlock)
+		queued := func() int {
+			base := s.(*stream[*internal.Response, *internal.Response]).streamBase
+			base.mu.Lock()
+			defer base.mu.Unlock()
+			return base.recvQueue.Len()
+		}
+
+		require.NoError(t, s.HandleStream(message("a")))
+		require.Eventually(t, func() bool { return adapter.sendCalls.Load() == 1 }, time.Second, 10*time.Millisecond)
+		require.NoError(t, s.HandleStream(message("b")))
+		require.Eventually(t, func() bool { return queued() == 0 }, time.Second, 10*time.Millisecond)
+
+		// the second message is acked once the consumer makes room for it
+		time.Sleep(20 * time.Millisecond)
+		require.EqualValues(t, 1, adapter.sendCalls.Load())
+
+		// the queue holds up to the channel size
+		require.NoError(t, s.HandleStream(message("c")))
+		require.ErrorIs(t, s.HandleStream(message("d")), psrpc.ErrSlowConsumer)
+
+		require.Equal(t, "a", (<-s.Channel()).RequestId)
+		require.Eventually(t, func() bool { return adapter.sendCalls.Load() == 2 }, time.Second, 10*time.Millisecond)
+		require.Equal(t, "b", (<-s.Channel()).RequestId)
+		require.Equal(t, "c", (<-s.Channel()).RequestId)
+	})
+
+	t.Run("DropOldest", func(t *testing.T) {
+		s, _ := newStream(psrpc.BackpressureDropOldest)
+		require.NoError(t, s.HandleStream(message("a")))
+		require.NoError(t, s.HandleStream(message("b")))
+		require.Equal(t, "b", (<-s.Channel()).RequestId)
+	})
+
+	t.Run("DropNewest", func(t *testing.T) {
+		s, _ := newStream(psrpc.BackpressureDropNewest)
+		require.NoError(t, s.HandleStream(message("a")))
+		require.ErrorIs(t, s.HandleStream(message("b")), psrpc.ErrSlowConsumer)
+		require.Equal(t, "a", (<-s.Channel()).RequestId)
+		require.NoError(t, s.Err())
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		s, _ := newStream(psrpc.BackpressureFail)
+		require.NoError(t, s.HandleStream(message("a")))
+		require.ErrorIs(t, s.HandleStream(message("b")), psrpc.ErrSlowConsumer)
+		<-s.Context().Done()
+		require.ErrorIs(t, s.Err(), psrpc.ErrSlowConsumer)
+	})
+}
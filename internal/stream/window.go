@@ -0,0 +1,219 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/internal/logger"
+)
+
+// received messages tracked ahead of a missing sequence number
+const maxSeqGap = 1024
+
+// delivery is a received message waiting for room in the channel. It is acked once delivered,
+// so senders never have more than the receive window of messages outstanding
+type delivery[RecvType proto.Message] struct {
+	msg       RecvType
+	ack       *internal.Stream
+	closeSend bool
+}
+
+// SetSendWindow limits unacknowledged sends to the receive window the other side advertised
+func (s *streamBase[SendType, RecvType]) SetSendWindow(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sendWindow == nil && size > 0 {
+		s.sendWindow = make(chan struct{}, size)
+	}
+}
+
+func (s *streamBase[SendType, RecvType]) acquireSendWindow(ctx context.Context) (chan struct{}, error) {
+	s.mu.Lock()
+	window := s.sendWindow
+	s.mu.Unlock()
+	if window == nil {
+		return nil, nil
+	}
+
+	select {
+	case window <- struct{}{}:
+		return window, nil
+	case <-ctx.Done():
+		return nil, s.ctxErr()
+	}
+}
+
+func releaseSendWindow(window chan struct{}) {
+	if window != nil {
+		select {
+		case <-window:
+		default:
+		}
+	}
+}
+
+// releaseDelivered frees the window held by messages the receiver has delivered since its last ack. Counts only grow,
+// so acks arriving out of order are ignored
+func (s *streamBase[SendType, RecvType]) releaseDelivered(delivered uint64) {
+	s.mu.Lock()
+	window := s.sendWindow
+	n := uint64(0)
+	if delivered > s.peerDelivered {
+		n = delivered - s.peerDelivered
+		s.peerDelivered = delivered
+	}
+	s.mu.Unlock()
+
+	for ; n > 0; n-- {
+		releaseSendWindow(window)
+	}
+}
+
+func (s *streamBase[SendType, RecvType]) enqueue(d *delivery[RecvType]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received++
+	s.recvQueue.PushBack(d)
+	s.notifyQueued()
+}
+
+func (s *streamBase[SendType, RecvType]) notifyQueued() {
+	select {
+	case s.queued <- struct{}{}:
+	default:
+	}
+}
+
+func (s *streamBase[SendType, RecvType]) nextDelivery() (*delivery[RecvType], bool) {
+	for {
+		s.mu.Lock()
+		if s.recvQueue.Len() > 0 {
+			d := s.recvQueue.PopFront()
+			s.mu.Unlock()
+			return d, true
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.queued:
+		case <-s.ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// deliver moves queued messages to the channel as the consumer makes room, until the stream or its sender closes
+func (s *streamBase[SendType, RecvType]) deliver() {
+	defer func() {
+		s.mu.Lock()
+		s.recvClosed = true
+		close(s.recvChan)
+		s.mu.Unlock()
+	}()
+
+	for {
+		d, ok := s.nextDelivery()
+		if !ok {
+			return
+		}
+
+		if !d.closeSend {
+			select {
+			case s.recvChan <- d.msg:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+
+		// the sender may be waiting for window if everything it knows to be delivered fills it
+		s.mu.Lock()
+		s.delivered++
+		update := s.recvWindow > 0 && s.received-s.reported >= uint64(s.recvWindow)
+		s.mu.Unlock()
+		if update {
+			s.updateWindow()
+		}
+
+		if d.ack != nil {
+			ctx, cancel := context.WithDeadline(s.ctx, time.Unix(0, d.ack.Expiry))
+			if err := s.Ack(ctx, d.ack); err != nil {
+				logger.Error(err, "failed to ack stream message")
+			}
+			cancel()
+		}
+
+		if d.closeSend {
+			return
+		}
+	}
+}
+
+// updateWindow tells a sender that filled the window how many messages have been delivered. acks also carry the
+// count, so updates are only needed when no ack has reported the latest deliveries
+func (s *streamBase[SendType, RecvType]) updateWindow() {
+	now := time.Now()
+	ctx, cancel := context.WithTimeout(s.ctx, s.Timeout)
+	defer cancel()
+	if err := s.Ack(ctx, &internal.Stream{
+		StreamId: s.streamID,
+		SentAt:   now.UnixNano(),
+		Expiry:   now.Add(s.Timeout).UnixNano(),
+	}); err != nil {
+		logger.Error(err, "failed to update stream window")
+	}
+}
+
+// duplicate reports whether a resent message was already received. Messages without a sequence number are never duplicates
+func (s *streamBase[SendType, RecvType]) duplicate(is *internal.Stream) bool {
+	if is.Seq == 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.recvSeen[is.Seq]
+	return ok || is.Seq <= s.recvSeq
+}
+
+// markReceived records a message once it has been accepted, so failed messages can be resent
+func (s *streamBase[SendType, RecvType]) markReceived(is *internal.Stream) {
+	if is.Seq == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recvSeen[is.Seq] = struct{}{}
+	for {
+		// skip messages the sender gave up on rather than tracking everything after them
+		if _, ok := s.recvSeen[s.recvSeq+1]; !ok && len(s.recvSeen) <= maxSeqGap {
+			return
+		}
+		s.recvSeq++
+		delete(s.recvSeen, s.recvSeq)
+	}
+}
+
+// ackDuplicate acks a resent message again, in case the first ack was lost
+func (s *streamBase[SendType, RecvType]) ackDuplicate(is *internal.Stream) error {
+	ctx, cancel := context.WithDeadline(s.ctx, time.Unix(0, is.Expiry))
+	defer cancel()
+	return s.Ack(ctx, is)
+}
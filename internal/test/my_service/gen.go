@@ -14,4 +14,4 @@
 
 package my_service
 
-//go:generate protoc --go_out=paths=source_relative:. --psrpc_out=paths=source_relative:. -I ../../../protoc-gen-psrpc/options -I=. my_service.proto
+//go:generate protoc --go_out=paths=source_relative:. --psrpc_out=paths=source_relative,mocks=true,grpc=true,http=true,otel=true,partial=true,docs=true,schema=true:. -I ../../../protoc-gen-psrpc/options -I=. my_service.proto
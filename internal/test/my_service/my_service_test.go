@@ -16,7 +16,14 @@ package my_service
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -24,9 +31,19 @@ import (
 	"github.com/nats-io/nats.go"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/pkg/grpcbridge"
+	"github.com/livekit/psrpc/pkg/info"
 )
 
 func TestGeneratedService(t *testing.T) {
@@ -131,6 +148,30 @@ func testGeneratedService(t *testing.T, bus psrpc.MessageBus) {
 	sA.Unlock()
 	sB.Unlock()
 
+	// rpc ListUpdates(MyRequest) returns (stream MyUpdate);
+	updates, err := cB.ListUpdates(ctx, req)
+	require.NoError(t, err)
+	var updateCount int
+	for range updates.Channel() {
+		updateCount++
+	}
+	require.ErrorIs(t, updates.Err(), psrpc.ErrStreamEOF)
+	require.Equal(t, 2, updateCount)
+
+	// rpc UploadUpdates(stream MyUpdate) returns (MyResponse);
+	uploads, err := cA.UploadUpdates(ctx)
+	require.NoError(t, err)
+	require.NoError(t, uploads.Send(update))
+	require.NoError(t, uploads.Send(update))
+	_, err = uploads.CloseAndRecv()
+	require.NoError(t, err)
+
+	sA.Lock()
+	sB.Lock()
+	require.Equal(t, 2, sA.counts["UploadUpdates"]+sB.counts["UploadUpdates"])
+	sA.Unlock()
+	sB.Unlock()
+
 	// rpc GetRegionStats(MyRequest) returns (MyResponse) {
 	//   option (psrpc.options).topics = true;
 	//   option (psrpc.options).type = MULTI;
@@ -157,6 +198,29 @@ func testGeneratedService(t *testing.T, bus psrpc.MessageBus) {
 	sA.Unlock()
 	sB.Unlock()
 
+	// rpc ExchangeRegionUpdates(MyClientMessage) returns (MyServerMessage) {
+	//   option (psrpc.options).stream = true;
+	//   option (psrpc.options).topics = true;
+	require.NoError(t, sB.server.RegisterExchangeRegionUpdatesTopic("regionB"))
+	time.Sleep(time.Millisecond * 100)
+
+	_, err = cA.ExchangeRegionUpdates(ctx, "regionA", psrpc.WithRequestTimeout(time.Millisecond*100))
+	require.Error(t, err)
+
+	regionStream, err := cA.ExchangeRegionUpdates(ctx, "regionB")
+	require.NoError(t, err)
+	require.NoError(t, regionStream.Send(&MyClientMessage{}))
+	require.NoError(t, regionStream.Close(nil))
+	time.Sleep(time.Millisecond * 100)
+
+	sA.Lock()
+	sB.Lock()
+	require.Equal(t, 0, sA.counts["ExchangeRegionUpdates"])
+	require.Equal(t, 1, sB.counts["ExchangeRegionUpdates"])
+	sA.Unlock()
+	sB.Unlock()
+	sB.server.DeregisterExchangeRegionUpdatesTopic("regionB")
+
 	// rpc ProcessUpdate(Ignored) returns (MyUpdate) {
 	//   option (psrpc.options).subscription = true;
 	subA, err := cA.SubscribeProcessUpdate(ctx)
@@ -185,10 +249,289 @@ func testGeneratedService(t *testing.T, bus psrpc.MessageBus) {
 	require.NoError(t, subA.Close())
 	require.NoError(t, subB.Close())
 
+	subA, err = cA.SubscribeUpdateRegionStateQueue(ctx, "regionA")
+	require.NoError(t, err)
+	subB, err = cB.SubscribeUpdateRegionStateQueue(ctx, "regionA")
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 100)
+
+	// redis queues lock messages on their payload, so each queue is sent a different update
+	require.NoError(t, sB.server.PublishUpdateRegionState(ctx, "regionA", &MyUpdate{Id: "queue"}))
+	requireOne(t, subA, subB)
+	require.NoError(t, subA.Close())
+	require.NoError(t, subB.Close())
+
+	// rpc NotifyUpdate(MyUpdate) returns (Ignored) {
+	//   option (psrpc.options).notification = true;
+	subA, err = sA.server.SubscribeNotifyUpdate(ctx)
+	require.NoError(t, err)
+	subB, err = sB.server.SubscribeNotifyUpdate(ctx)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 100)
+
+	notification := &MyUpdate{Id: "notification"}
+	require.NoError(t, cA.PublishNotifyUpdate(ctx, notification))
+	requireOne(t, subA, subB)
+	require.NoError(t, subA.Close())
+	require.NoError(t, subB.Close())
+
+	// rpc NotifyRegionUpdate(MyUpdate) returns (Ignored) {
+	//   option (psrpc.options).type = MULTI;
+	//   option (psrpc.options).notification = true;
+	//   option (psrpc.options).topics = true;
+	subA, err = sA.server.SubscribeNotifyRegionUpdate(ctx, "regionA")
+	require.NoError(t, err)
+	subB, err = sB.server.SubscribeNotifyRegionUpdate(ctx, "regionA")
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond * 100)
+
+	require.NoError(t, cB.PublishNotifyRegionUpdate(ctx, "regionA", notification))
+	requireTwo(t, subA, subB)
+	require.NoError(t, subA.Close())
+	require.NoError(t, subB.Close())
+
 	shutdown(t, sA)
 	shutdown(t, sB)
 }
 
+func TestGeneratedClientMock(t *testing.T) {
+	mock := &MyServiceClientMock{
+		NormalRPCFunc: func(ctx context.Context, req *MyRequest, opts ...psrpc.RequestOption) (*MyResponse, error) {
+			return &MyResponse{}, nil
+		},
+	}
+	var c MyServiceClient = mock
+
+	res, err := c.NormalRPC(context.Background(), &MyRequest{})
+	require.NoError(t, err)
+	require.NotNil(t, res)
+
+	_, err = c.GetStats(context.Background(), &MyRequest{})
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.Unimplemented))
+}
+
+func TestGeneratedGRPCBridge(t *testing.T) {
+	ctx := context.Background()
+
+	// psrpc client -> bridge server impl -> gRPC -> bridge service -> psrpc server
+	svc := createServer(t, psrpc.NewLocalMessageBus())
+	defer shutdown(t, svc)
+
+	gs := grpc.NewServer()
+	RegisterMyServiceGRPCBridge(gs, createClient(t, svc.bus))
+	lis := bufconn.Listen(1 << 20)
+	go gs.Serve(lis)
+	defer gs.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	bus := psrpc.NewLocalMessageBus()
+	bridge, err := NewMyServiceServer(NewMyServiceGRPCBridge(conn), bus)
+	require.NoError(t, err)
+	defer bridge.Shutdown()
+	c := createClient(t, bus)
+
+	_, err = c.NormalRPC(ctx, &MyRequest{})
+	require.NoError(t, err)
+
+	updates, err := c.ListUpdates(ctx, &MyRequest{})
+	require.NoError(t, err)
+	var updateCount int
+	for range updates.Channel() {
+		updateCount++
+	}
+	require.ErrorIs(t, updates.Err(), psrpc.ErrStreamEOF)
+	require.Equal(t, 2, updateCount)
+
+	uploads, err := c.UploadUpdates(ctx)
+	require.NoError(t, err)
+	require.NoError(t, uploads.Send(&MyUpdate{}))
+	require.NoError(t, uploads.Send(&MyUpdate{}))
+	_, err = uploads.CloseAndRecv()
+	require.NoError(t, err)
+
+	stream, err := c.ExchangeUpdates(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&MyClientMessage{}))
+	require.NoError(t, stream.CloseSend())
+	for range stream.Channel() {
+	}
+	require.ErrorIs(t, stream.Err(), psrpc.ErrStreamClosed)
+
+	// multi-rpcs are not served by the gRPC bridge
+	responses, err := c.GetStats(ctx, &MyRequest{})
+	require.NoError(t, err)
+	res := <-responses
+	require.ErrorIs(t, res.Err, psrpc.ErrorCode(psrpc.Unimplemented))
+
+	// topics are sent in the gRPC metadata
+	require.NoError(t, svc.server.RegisterExchangeRegionUpdatesTopic("regionA"))
+	time.Sleep(time.Millisecond * 100)
+	desc := &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+	method := "/psrpc.internal.test.customservice.MyService/ExchangeRegionUpdates"
+	gstream, err := conn.NewStream(grpcbridge.WithTopics(ctx, "regionA"), desc, method)
+	require.NoError(t, err)
+	require.NoError(t, gstream.SendMsg(&MyClientMessage{}))
+	require.NoError(t, gstream.CloseSend())
+	require.ErrorIs(t, gstream.RecvMsg(&MyServerMessage{}), io.EOF)
+
+	gstream, err = conn.NewStream(ctx, desc, method)
+	require.NoError(t, err)
+	err = gstream.RecvMsg(&MyServerMessage{})
+	require.ErrorIs(t, grpcbridge.FromGRPCError(err), psrpc.ErrorCode(psrpc.InvalidArgument))
+
+	svc.Lock()
+	defer svc.Unlock()
+	require.Equal(t, 1, svc.counts["NormalRPC"])
+	require.Equal(t, 2, svc.counts["UploadUpdates"])
+	require.Equal(t, 1, svc.counts["ExchangeUpdates"])
+	require.Equal(t, 1, svc.counts["ExchangeRegionUpdates"])
+}
+
+func TestGeneratedHTTPHandler(t *testing.T) {
+	svc := createServer(t, psrpc.NewLocalMessageBus())
+	defer shutdown(t, svc)
+	require.NoError(t, svc.server.RegisterGetRegionStatsTopic("regionA"))
+	time.Sleep(time.Millisecond * 100)
+
+	s := httptest.NewServer(NewMyServiceHTTPHandler(createClient(t, svc.bus, psrpc.WithClientTimeout(time.Millisecond*200))))
+	defer s.Close()
+
+	post := func(path, body string) (int, string) {
+		res, err := http.Post(s.URL+"/psrpc.internal.test.customservice.MyService/"+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, string(b)
+	}
+
+	status, body := post("NormalRPC", "{}")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{}`, body)
+
+	status, body = post("GetRegionStats?topic=regionA", "")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `[{"result": {}}]`, body)
+
+	status, body = post("GetRegionStats", "")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Contains(t, body, `"code":"invalid_argument"`)
+
+	status, body = post("NormalRPC", "{")
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Contains(t, body, `"code":"malformed_request"`)
+
+	status, _ = post("ListUpdates", "{}")
+	require.Equal(t, http.StatusNotFound, status)
+
+	res, err := http.Get(s.URL + "/psrpc.internal.test.customservice.MyService/NormalRPC")
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+}
+
+func TestGeneratedTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	bus := psrpc.NewLocalMessageBus()
+	svc := &MyService{counts: make(map[string]int)}
+	server, err := NewMyServiceTracedServer(svc, bus)
+	require.NoError(t, err)
+	defer server.Shutdown()
+
+	client, err := NewMyServiceTracedClient(bus)
+	require.NoError(t, err)
+
+	_, err = client.NormalRPC(context.Background(), &MyRequest{})
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	require.Equal(t, "MyService/NormalRPC", spans[1].Name)
+	require.Equal(t, trace.SpanKindClient, spans[1].SpanKind)
+	require.Equal(t, trace.SpanKindServer, spans[0].SpanKind)
+	require.Equal(t, spans[1].SpanContext.TraceID(), spans[0].SpanContext.TraceID())
+}
+
+func TestGeneratedSchema(t *testing.T) {
+	b, err := os.ReadFile("my_service.psrpc.json")
+	require.NoError(t, err)
+
+	var schema struct {
+		Services []struct {
+			Name     string            `json:"name"`
+			Channels map[string]string `json:"channels"`
+			Methods  []struct {
+				Name     string            `json:"name"`
+				Kind     string            `json:"kind"`
+				Channels map[string]string `json:"channels"`
+			} `json:"methods"`
+		} `json:"services"`
+		Defs map[string]any `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(b, &schema))
+	require.Len(t, schema.Services, 1)
+	require.Equal(t, info.GetResponseChannel("MyService", "CLI_123"), strings.Replace(schema.Services[0].Channels["response"], "{clientId}", "CLI_123", 1))
+
+	methods := schema.Services[0].Methods
+	require.Equal(t, "GetRegionStats", methods[6].Name)
+	require.Equal(t, "multi_rpc", methods[6].Kind)
+	i := &info.RequestInfo{RPCInfo: psrpc.RPCInfo{Service: "MyService", Method: "GetRegionStats", Topic: []string{"regionA"}}}
+	require.Equal(t, i.GetRPCChannel(), strings.Replace(methods[6].Channels["request"], "{topic}", "regionA", 1))
+
+	require.Contains(t, schema.Defs, "internal.Request")
+	require.Contains(t, schema.Defs, "psrpc.internal.test.customservice.MyUpdate")
+}
+
+func TestGeneratedPartialServer(t *testing.T) {
+	bus := psrpc.NewLocalMessageBus()
+	svc := &MyService{counts: make(map[string]int)}
+	server, err := NewMyServicePartialServer(bus)
+	require.NoError(t, err)
+	defer server.Shutdown()
+
+	require.NoError(t, server.RegisterNormalRPCHandler(svc.NormalRPC))
+	require.NoError(t, server.RegisterGetRegionStatsHandler(svc.GetRegionStats, "regionA"))
+
+	client, err := NewMyServiceClient(bus, psrpc.WithClientTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+
+	_, err = client.NormalRPC(context.Background(), &MyRequest{})
+	require.NoError(t, err)
+	_, err = client.IntensiveRPC(context.Background(), &MyRequest{})
+	require.Error(t, err)
+
+	respChan, err := client.GetRegionStats(context.Background(), "regionA", &MyRequest{})
+	require.NoError(t, err)
+	res := <-respChan
+	require.NotNil(t, res)
+	require.NoError(t, res.Err)
+
+	server.DeregisterNormalRPCHandler()
+	_, err = client.NormalRPC(context.Background(), &MyRequest{})
+	require.Error(t, err)
+}
+
+func TestGeneratedDocs(t *testing.T) {
+	b, err := os.ReadFile("my_service.psrpc.md")
+	require.NoError(t, err)
+	docs := string(b)
+
+	require.Contains(t, docs, "## MyService")
+	require.Contains(t, docs, "### MyService.GetRegionStats")
+	require.Contains(t, docs, "- **Delivery:** Every server responds to each request, and the client receives all responses.")
+	require.Contains(t, docs, "- **Delivery:** Clients publish notifications, and one subscribed server receives each notification.")
+	require.Contains(t, docs, "### psrpc.internal.test.customservice.MyUpdate")
+}
+
 func requireOne(t *testing.T, subA, subB psrpc.Subscription[*MyUpdate]) {
 	for i := 0; i < 2; i++ {
 		select {
@@ -227,6 +570,7 @@ func createServer(t *testing.T, bus psrpc.MessageBus) *MyService {
 	server, err := NewMyServiceServer(svc, bus)
 	require.NoError(t, err)
 	svc.server = server
+	svc.bus = bus
 	return svc
 }
 
@@ -254,6 +598,7 @@ type MyService struct {
 	sync.Mutex
 
 	server MyServiceServer
+	bus    psrpc.MessageBus
 	counts map[string]int
 }
 
@@ -294,6 +639,33 @@ func (s *MyService) ExchangeUpdates(stream psrpc.ServerStream[*MyServerMessage,
 	return nil
 }
 
+func (s *MyService) ListUpdates(_ context.Context, _ *MyRequest, w psrpc.StreamWriter[*MyUpdate]) error {
+	for i := 0; i < 2; i++ {
+		if err := w.Send(&MyUpdate{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MyService) UploadUpdates(_ context.Context, r psrpc.StreamReader[*MyUpdate]) (*MyResponse, error) {
+	for range r.Channel() {
+		s.Lock()
+		s.counts["UploadUpdates"]++
+		s.Unlock()
+	}
+	return &MyResponse{}, nil
+}
+
+func (s *MyService) ExchangeRegionUpdates(stream psrpc.ServerStream[*MyServerMessage, *MyClientMessage]) error {
+	for range stream.Channel() {
+		s.Lock()
+		s.counts["ExchangeRegionUpdates"]++
+		s.Unlock()
+	}
+	return nil
+}
+
 func (s *MyService) GetRegionStats(_ context.Context, _ *MyRequest) (*MyResponse, error) {
 	s.Lock()
 	s.counts["GetRegionStats"]++
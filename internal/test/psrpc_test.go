@@ -15,20 +15,34 @@
 package test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/go-logr/logr/funcr"
 	"github.com/nats-io/nats.go"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"github.com/livekit/psrpc"
 	"github.com/livekit/psrpc/internal"
+	psrpcbus "github.com/livekit/psrpc/internal/bus"
 	"github.com/livekit/psrpc/pkg/client"
 	"github.com/livekit/psrpc/pkg/info"
+	"github.com/livekit/psrpc/pkg/metadata"
+	"github.com/livekit/psrpc/pkg/middleware"
 	"github.com/livekit/psrpc/pkg/rand"
 	"github.com/livekit/psrpc/pkg/server"
 )
@@ -236,3 +250,2703 @@ func testStream(t *testing.T, bus psrpc.MessageBus) {
 		t.Fatal("server did not close")
 	}
 }
+
+func TestIdempotencyKey(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_idempotency")
+
+	counter := 0
+	rpc := "add_one"
+	addOne := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		counter++
+		return &internal.Response{RequestId: req.RequestId}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, addOne, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	first, err := client.RequestSingle[*internal.Response](
+		ctx, c, rpc, nil, &internal.Request{RequestId: "1"}, psrpc.WithIdempotencyKey("key"),
+	)
+	require.NoError(t, err)
+
+	second, err := client.RequestSingle[*internal.Response](
+		ctx, c, rpc, nil, &internal.Request{RequestId: "2"}, psrpc.WithIdempotencyKey("key"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 1, counter)
+	require.Equal(t, first.RequestId, second.RequestId)
+
+	_, err = client.RequestSingle[*internal.Response](
+		ctx, c, rpc, nil, &internal.Request{RequestId: "3"}, psrpc.WithIdempotencyKey("other"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 2, counter)
+}
+
+func TestMetadata(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_metadata")
+
+	rpc := "echo_metadata"
+	echo := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		md := psrpc.IncomingMetadata(ctx)
+		return &internal.Response{Error: md["token"], Code: md["tenant"]}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, echo, nil)
+	require.NoError(t, err)
+
+	ctx := psrpc.NewOutgoingContext(context.Background(), psrpc.Metadata{"token": "secret"})
+	ctx = psrpc.AppendToOutgoingContext(ctx, "tenant", "acme")
+	res, err := client.RequestSingle[*internal.Response](ctx, c, rpc, nil, &internal.Request{})
+	require.NoError(t, err)
+	require.Equal(t, "secret", res.Error)
+	require.Equal(t, "acme", res.Code)
+
+	t.Run("TestIdentity", func(t *testing.T) {
+		rpc := "echo_identity"
+		var sentAt time.Time
+		var serverID string
+		var ri psrpc.RPCInfo
+		echo := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+			sentAt = psrpc.IncomingSentAt(ctx)
+			serverID = psrpc.IncomingServerID(ctx)
+			ri, _ = psrpc.IncomingRPCInfo(ctx)
+			return &internal.Response{ServerId: psrpc.IncomingClientID(ctx), RequestId: psrpc.IncomingRequestID(ctx)}, nil
+		}
+
+		s.RegisterMethod(rpc, false, false, true, false)
+		c.RegisterMethod(rpc, false, false, true, false)
+		err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, echo, nil)
+		require.NoError(t, err)
+
+		res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+		require.NoError(t, err)
+		require.Equal(t, c.ID, res.ServerId)
+		require.NotEmpty(t, res.RequestId)
+		require.False(t, sentAt.IsZero())
+		require.Equal(t, s.ID, serverID)
+		require.Equal(t, rpc, ri.Method)
+	})
+}
+
+func TestRequestNone(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_request_none")
+
+	var intercepted bool
+	c.RpcInterceptors = append(c.RpcInterceptors, func(info psrpc.RPCInfo, next psrpc.ClientRPCHandler) psrpc.ClientRPCHandler {
+		return func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+			intercepted = true
+			return next(ctx, req, opts...)
+		}
+	})
+
+	rpc := "notify"
+	received := make(chan string, 1)
+	notify := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		received <- req.RequestId
+		return &internal.Response{}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, true)
+	c.RegisterMethod(rpc, false, false, true, true)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, notify, nil)
+	require.NoError(t, err)
+
+	err = client.RequestNone(context.Background(), c, rpc, nil, &internal.Request{RequestId: "fire"})
+	require.NoError(t, err)
+	require.True(t, intercepted)
+
+	select {
+	case id := <-received:
+		require.Equal(t, "fire", id)
+	case <-time.After(time.Second):
+		t.Fatal("notification not received")
+	}
+
+	c.RegisterMethod("affinity", true, false, true, false)
+	err = client.RequestNone(context.Background(), c, "affinity", nil, &internal.Request{})
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.InvalidArgument))
+}
+
+func TestQuorum(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_quorum")
+
+	rpc := "quorum"
+	echo := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{RequestId: req.RequestId}, nil
+	}
+
+	s.RegisterMethod(rpc, false, true, false, false)
+	c.RegisterMethod(rpc, false, true, false, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, echo, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	resChan, err := client.RequestMulti[*internal.Response](
+		context.Background(), c, rpc, nil, &internal.Request{}, psrpc.WithQuorum(1),
+	)
+	require.NoError(t, err)
+
+	var count int
+	for res := range resChan {
+		require.NoError(t, res.Err)
+		count++
+	}
+	require.Equal(t, 1, count)
+	require.Less(t, time.Since(start), psrpc.DefaultClientTimeout)
+}
+
+func TestExpectedServers(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_expected_servers")
+
+	rpc := "expected"
+	echo := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return nil, psrpc.NewErrorf(psrpc.Internal, "failed")
+	}
+
+	s.RegisterMethod(rpc, false, true, false, false)
+	c.RegisterMethod(rpc, false, true, false, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, echo, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+	resChan, err := client.RequestMulti[*internal.Response](
+		context.Background(), c, rpc, nil, &internal.Request{}, psrpc.WithExpectedServers(1),
+	)
+	require.NoError(t, err)
+
+	var count int
+	for res := range resChan {
+		require.Error(t, res.Err)
+		count++
+	}
+	require.Equal(t, 1, count)
+	require.Less(t, time.Since(start), psrpc.DefaultClientTimeout)
+}
+
+func TestTargetServer(t *testing.T) {
+	serverA, c := newTestServerAndClient(t, "test_target_server")
+
+	rpc := "whoami"
+	serverA.RegisterMethod(rpc, true, false, true, false)
+	c.RegisterMethod(rpc, true, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](serverA, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{ServerId: serverA.ID}, nil
+	}, func(ctx context.Context, req *internal.Request) float32 {
+		return 0
+	})
+	require.NoError(t, err)
+
+	// the server never accepts claims, so only directed requests succeed
+	_, err = client.RequestSingle[*internal.Response](
+		context.Background(), c, rpc, nil, &internal.Request{}, psrpc.WithRequestTimeout(100*time.Millisecond),
+	)
+	require.Error(t, err)
+
+	res, err := client.RequestSingle[*internal.Response](
+		context.Background(), c, rpc, nil, &internal.Request{}, psrpc.WithTargetServer(serverA.ID),
+	)
+	require.NoError(t, err)
+	require.Equal(t, serverA.ID, res.ServerId)
+
+	_, err = client.RequestSingle[*internal.Response](
+		context.Background(), c, rpc, nil, &internal.Request{},
+		psrpc.WithTargetServer("missing"), psrpc.WithRequestTimeout(100*time.Millisecond),
+	)
+	require.ErrorIs(t, err, psrpc.ErrRequestTimedOut)
+	// multi rpcs reach every server and cannot be directed
+	multi := "broadcast"
+	serverA.RegisterMethod(multi, false, true, false, false)
+	c.RegisterMethod(multi, false, true, false, false)
+	_, err = client.RequestMulti[*internal.Response](context.Background(), c, multi, nil, &internal.Request{}, psrpc.WithTargetServer(serverA.ID))
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.InvalidArgument))
+}
+
+func TestCancelPropagation(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_cancel")
+
+	rpc := "block"
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	block := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			close(canceled)
+		case <-time.After(psrpc.DefaultClientTimeout):
+		}
+		return &internal.Response{}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, block, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	_, err = client.RequestSingle[*internal.Response](ctx, c, rpc, nil, &internal.Request{})
+	require.ErrorIs(t, err, psrpc.ErrRequestCanceled)
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("handler context not canceled")
+	}
+
+	t.Run("Queued", func(t *testing.T) {
+		s, c := newTestServerAndClient(t, "test_cancel_queued", psrpc.WithServerMaxConcurrency(1))
+
+		rpc := "queued"
+		release := make(chan struct{})
+		handled := make(chan string, 2)
+		handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+			if req.RequestId == "block" {
+				<-release
+			}
+			handled <- req.RequestId
+			return &internal.Response{}, nil
+		}
+		s.RegisterMethod(rpc, false, false, false, true)
+		c.RegisterMethod(rpc, false, false, false, true)
+		err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, client.RequestNone(context.Background(), c, rpc, nil, &internal.Request{RequestId: "block"}))
+		time.Sleep(50 * time.Millisecond)
+
+		// the cancel arrives while the request waits for the blocked handler
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		ctx, cancelRequest := context.WithCancel(ctx)
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			cancelRequest()
+		}()
+		_, err = client.RequestSingle[*internal.Response](ctx, c, rpc, nil, &internal.Request{RequestId: "canceled"})
+		require.ErrorIs(t, err, psrpc.ErrRequestCanceled)
+		time.Sleep(50 * time.Millisecond)
+
+		close(release)
+		require.Equal(t, "block", <-handled)
+		select {
+		case id := <-handled:
+			t.Fatalf("canceled request %s reached the handler", id)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
+
+func TestPriority(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_priority", psrpc.WithServerMaxConcurrency(1))
+
+	rpc := "prioritized"
+	release := make(chan struct{})
+	handled := make(chan string, 3)
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		if req.RequestId == "block" {
+			<-release
+		}
+		handled <- req.RequestId
+		return &internal.Response{}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, false, true)
+	c.RegisterMethod(rpc, false, false, false, true)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, client.RequestNone(ctx, c, rpc, nil, &internal.Request{RequestId: "block"}))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, client.RequestNone(ctx, c, rpc, nil, &internal.Request{RequestId: "low"}))
+	require.NoError(t, client.RequestNone(ctx, c, rpc, nil, &internal.Request{RequestId: "high"}, psrpc.WithPriority(10)))
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for _, expected := range []string{"block", "high", "low"} {
+		select {
+		case id := <-handled:
+			require.Equal(t, expected, id)
+		case <-time.After(time.Second):
+			t.Fatal("request not handled")
+		}
+	}
+}
+
+func TestLazySubscriptions(t *testing.T) {
+	ts := newTestService(t, "test_lazy")
+	s := ts.newServer()
+	c := ts.newClient(psrpc.WithClientLazySubscriptions())
+
+	rpc := "echo"
+	echo := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{RequestId: req.RequestId}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, echo, nil)
+	require.NoError(t, err)
+
+	res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "lazy"})
+	require.NoError(t, err)
+	require.Equal(t, "lazy", res.RequestId)
+}
+
+func TestRequestIDGenerator(t *testing.T) {
+	ts := newTestService(t, "test_request_id")
+	s := ts.newServer()
+
+	var generated []string
+	gen := func(ctx context.Context) string {
+		id := fmt.Sprintf("trace-%d", len(generated))
+		generated = append(generated, id)
+		return id
+	}
+
+	c := ts.newClient(psrpc.WithClientRequestIDGenerator(gen))
+
+	rpc := "echo"
+	echo := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{RequestId: req.RequestId}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, echo, nil)
+	require.NoError(t, err)
+
+	res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "a"})
+	require.NoError(t, err)
+	require.Equal(t, "a", res.RequestId)
+	require.Equal(t, []string{"trace-0"}, generated)
+}
+
+func TestResponseInfo(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_response_info")
+
+	rpc := "echo"
+	var requestID string
+	echo := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		requestID = psrpc.IncomingRequestID(ctx)
+		return &internal.Response{}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, echo, nil)
+	require.NoError(t, err)
+
+	var ri psrpc.ResponseInfo
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{}, psrpc.WithResponseInfo(&ri))
+	require.NoError(t, err)
+	require.Equal(t, s.ID, ri.ServerID)
+	require.Equal(t, requestID, ri.RequestID)
+	require.Equal(t, 1, ri.Attempts)
+	require.Equal(t, psrpc.ProtocolVersion, ri.ProtocolVersion)
+	require.Greater(t, ri.ClaimLatency, time.Duration(0))
+	require.GreaterOrEqual(t, ri.Latency, ri.ClaimLatency)
+}
+
+func TestRequestIDContext(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_request_id_context")
+
+	rpc := "echo"
+	var requestID string
+	echo := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		requestID = psrpc.IncomingRequestID(ctx)
+		return &internal.Response{}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, echo, nil)
+	require.NoError(t, err)
+
+	ctx := psrpc.NewRequestIDContext(context.Background())
+	require.Equal(t, "", psrpc.OutgoingRequestID(ctx))
+	_, err = client.RequestSingle[*internal.Response](ctx, c, rpc, nil, &internal.Request{})
+	require.NoError(t, err)
+	require.NotEmpty(t, requestID)
+	require.Equal(t, requestID, psrpc.OutgoingRequestID(ctx))
+}
+
+func TestWaitForServer(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_wait_for_server")
+
+	rpc := "echo"
+	var calls atomic.Int32
+	echo := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		calls.Inc()
+		return &internal.Response{}, nil
+	}
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, client.WaitForServer(ctx, c, rpc, nil), psrpc.ErrRequestTimedOut)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, echo, nil)
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), psrpc.DefaultClientTimeout)
+	defer cancel()
+	require.NoError(t, client.WaitForServer(ctx, c, rpc, nil))
+	require.Zero(t, calls.Load())
+}
+
+func TestShadow(t *testing.T) {
+	primary := newTestService(t, "test_shadow_primary")
+	secondary := primary.sibling("test_shadow_secondary")
+
+	rpc := "echo"
+	handled := make(chan string, 2)
+	newServer := func(ts *testService) {
+		name := ts.name
+		s := ts.newServer()
+		s.RegisterMethod(rpc, false, false, true, false)
+		err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+			handled <- name
+			return &internal.Response{}, nil
+		}, nil)
+		require.NoError(t, err)
+	}
+	newServer(primary)
+	newServer(secondary)
+
+	type shadowResult struct {
+		info psrpc.RPCInfo
+		err  error
+	}
+	shadowed := make(chan shadowResult, 1)
+	c := primary.newClient(
+		psrpc.WithClientShadow(secondary.name, 1),
+		psrpc.WithClientResponseHooks(func(ctx context.Context, req proto.Message, info psrpc.RPCInfo, res proto.Message, err error) {
+			if info.Service == secondary.name {
+				shadowed <- shadowResult{info, err}
+			}
+		}),
+	)
+	c.RegisterMethod(rpc, false, false, true, false)
+
+	_, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+	require.NoError(t, err)
+
+	select {
+	case r := <-shadowed:
+		require.NoError(t, r.err)
+		require.Equal(t, rpc, r.info.Method)
+	case <-time.After(time.Second):
+		t.Fatal("shadow response missing")
+	}
+	require.ElementsMatch(t, []string{"test_shadow_primary", "test_shadow_secondary"}, []string{<-handled, <-handled})
+}
+
+func TestRequestAll(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_request_all")
+
+	rpc := "maybe_fail"
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		if req.RequestId == "fail" {
+			return nil, psrpc.NewErrorf(psrpc.Internal, "failed")
+		}
+		return &internal.Response{RequestId: req.RequestId}, nil
+	}
+
+	s.RegisterMethod(rpc, false, true, false, false)
+	c.RegisterMethod(rpc, false, true, false, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	res, err := client.RequestAll[*internal.Response](ctx, c, rpc, nil, &internal.Request{RequestId: "ok"}, psrpc.WithExpectedServers(1))
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, "ok", res[0].Result.RequestId)
+
+	start := time.Now()
+	_, err = client.RequestAll[*internal.Response](ctx, c, rpc, nil, &internal.Request{RequestId: "fail"}, psrpc.WithFailFast())
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.Internal))
+	require.Less(t, time.Since(start), psrpc.DefaultClientTimeout)
+}
+
+func TestRequestMultiWithCancel(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_multi_cancel")
+
+	rpc := "slow"
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		<-ctx.Done()
+		return &internal.Response{}, nil
+	}
+
+	s.RegisterMethod(rpc, false, true, false, false)
+	c.RegisterMethod(rpc, false, true, false, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	rChan, cancel, err := client.RequestMultiWithCancel[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+	require.NoError(t, err)
+	cancel()
+
+	select {
+	case _, ok := <-rChan:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("response channel not closed")
+	}
+}
+
+func TestClientPool(t *testing.T) {
+	ts := newTestService(t, "test_client_pool")
+
+	s := ts.newServer()
+
+	p, err := client.NewClientPool(ts.name, ts.bus, 3)
+	require.NoError(t, err)
+	t.Cleanup(p.Close)
+
+	rpc := "whoami"
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{RequestId: metadata.IncomingHeader(ctx).RemoteID}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	p.RegisterMethod(rpc, false, false, true, false)
+	err = server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	clientIDs := make(map[string]struct{})
+	for i := 0; i < 6; i++ {
+		res, err := client.RequestSingle[*internal.Response](context.Background(), p.Next(), rpc, nil, &internal.Request{})
+		require.NoError(t, err)
+		clientIDs[res.RequestId] = struct{}{}
+	}
+	require.Len(t, clientIDs, 3)
+}
+
+func TestClientShutdown(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_client_shutdown")
+
+	rpc := "slow"
+	started := make(chan struct{})
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return &internal.Response{}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+		done <- err
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- c.Shutdown(context.Background())
+	}()
+
+	require.NoError(t, <-done)
+	require.NoError(t, <-shutdown)
+
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+	require.ErrorIs(t, err, psrpc.ErrClientClosed)
+}
+
+func TestMultiRPCPublishError(t *testing.T) {
+	b := psrpcbus.NewTestBus(psrpc.NewLocalMessageBus(), func(o *psrpcbus.TestBusOpts) {
+		o.PublishInterceptors = append(o.PublishInterceptors, func(next psrpcbus.PublishHandler) psrpcbus.PublishHandler {
+			return func(ctx context.Context, channel string, msg proto.Message) error {
+				if strings.HasSuffix(channel, "|REQ") {
+					return errors.New("publish failed")
+				}
+				return next(ctx, channel, msg)
+			}
+		})
+	})
+	ts := &testService{t: t, bus: b, name: "test_multi_rpc_publish_error"}
+	c := ts.newClient()
+
+	rpc := "unreachable"
+	c.RegisterMethod(rpc, false, true, false, false)
+	_, err := client.RequestMulti[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.Internal))
+	require.Empty(t, c.PendingRequests())
+
+	// the failed request does not hold up the drain
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	require.NoError(t, c.Shutdown(ctx))
+}
+
+func TestServerShutdown(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_server_shutdown", psrpc.WithServerShutdownGracePeriod(time.Second))
+
+	rpc := "slow"
+	started := make(chan struct{})
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return &internal.Response{RequestId: req.RequestId}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+		done <- err
+	}()
+	<-started
+
+	require.NoError(t, s.Shutdown(context.Background()))
+	require.NoError(t, <-done)
+
+	err = server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.ErrorIs(t, err, psrpc.ErrServerClosed)
+}
+
+func TestHandlerConcurrency(t *testing.T) {
+	rpc := "limited"
+	s, c := newTestServerAndClient(t, "test_handler_concurrency",
+		psrpc.WithServerHandlerConcurrency(rpc, 1),
+		psrpc.WithServerRejectExcess(),
+	)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		if req.RequestId == "block" {
+			close(started)
+			<-release
+		}
+		return &internal.Response{}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, false, true)
+	c.RegisterMethod(rpc, false, false, false, true)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "block"})
+		done <- err
+	}()
+	<-started
+
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.ResourceExhausted))
+
+	close(release)
+	require.NoError(t, <-done)
+}
+
+func TestConcurrentTopicRegistration(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_topic_registration")
+
+	rpc := "topic_echo"
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{}, nil
+	}
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			errs <- server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, []string{"room"}, handler, nil)
+		}()
+	}
+
+	var registered int
+	for i := 0; i < cap(errs); i++ {
+		if <-errs == nil {
+			registered++
+		}
+	}
+	require.Equal(t, 1, registered)
+
+	_, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, []string{"room"}, &internal.Request{})
+	require.NoError(t, err)
+
+	s.DeregisterHandler(rpc, []string{"room"})
+	err = server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, []string{"room"}, handler, nil)
+	require.NoError(t, err)
+}
+
+func TestLoadShedding(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_load_shedding", psrpc.WithServerLoadShedding(1))
+
+	rpc := "shed"
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		if req.RequestId == "block" {
+			close(started)
+			<-release
+		}
+		return &internal.Response{}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "block"})
+		done <- err
+	}()
+	<-started
+
+	// the only server is overloaded, so nobody claims the request
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{}, psrpc.WithRequestTimeout(200*time.Millisecond))
+	require.Error(t, err)
+
+	close(release)
+	require.NoError(t, <-done)
+
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+	require.NoError(t, err)
+}
+
+func TestServerHealth(t *testing.T) {
+	ts := newTestService(t, "test_server_health")
+	s := ts.newServer(psrpc.WithServerLoadShedding(1))
+	c := ts.newClient()
+
+	rpc := "echo"
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		close(started)
+		<-release
+		return &internal.Response{}, nil
+	}
+	s.RegisterMethod(rpc, false, false, false, true)
+	c.RegisterMethod(rpc, false, false, false, true)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, []string{"a"}, handler, nil)
+	require.NoError(t, err)
+
+	// servers rejecting the health check are reported with the error
+	rejected := ts.newServer(psrpc.WithServerRPCInterceptors(func(ctx context.Context, req proto.Message, rpcInfo psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+		return nil, psrpc.NewErrorf(psrpc.Unauthenticated, "missing credentials")
+	}))
+
+	// the busy server still reports while it sheds requests
+	go func() {
+		_ = client.RequestNone(context.Background(), c, rpc, []string{"a"}, &internal.Request{})
+	}()
+	<-started
+
+	health, err := client.GetServerHealth(context.Background(), c, psrpc.WithExpectedServers(2))
+	require.NoError(t, err)
+	require.Len(t, health, 2)
+	if health[0].ServerID != s.ID {
+		health[0], health[1] = health[1], health[0]
+	}
+
+	require.NoError(t, health[0].Err)
+	require.Equal(t, s.ID, health[0].ServerID)
+	require.Equal(t, []string{"echo|a"}, health[0].Handlers)
+	require.Equal(t, 1, health[0].InFlight)
+	require.Greater(t, health[0].Uptime, time.Duration(0))
+
+	require.Equal(t, rejected.ID, health[1].ServerID)
+	require.ErrorIs(t, health[1].Err, psrpc.ErrorCode(psrpc.Unauthenticated))
+}
+
+func TestSessionKey(t *testing.T) {
+	ts := newTestService(t, "test_session_key")
+	rpc := "sticky"
+
+	for i := 0; i < 3; i++ {
+		s := ts.newServer()
+
+		s.RegisterMethod(rpc, false, false, true, false)
+		err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+			return &internal.Response{ServerId: s.ID}, nil
+		}, nil)
+		require.NoError(t, err)
+	}
+
+	c := ts.newClient()
+	c.RegisterMethod(rpc, false, false, true, false)
+
+	servers := make(map[string]struct{})
+	for i := 0; i < 5; i++ {
+		res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{}, psrpc.WithSessionKey("room"))
+		require.NoError(t, err)
+		servers[res.ServerId] = struct{}{}
+	}
+	require.Len(t, servers, 1)
+
+	// selection opts set after the session key keep requests sticky
+	for i := 0; i < 5; i++ {
+		res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{},
+			psrpc.WithSessionKey("room"), psrpc.WithSelectionOpts(psrpc.SelectionOpts{AcceptFirstAvailable: true}))
+		require.NoError(t, err)
+		servers[res.ServerId] = struct{}{}
+	}
+	require.Len(t, servers, 1)
+}
+
+func TestServerInterceptors(t *testing.T) {
+	var methods []string
+	record := func(ctx context.Context, req proto.Message, info psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+		methods = append(methods, info.Method)
+		return handler(ctx, req)
+	}
+	tag := func(ctx context.Context, req proto.Message, info psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+		res, err := handler(ctx, req)
+		if err == nil {
+			res.(*internal.Response).Error = info.Service
+		}
+		return res, err
+	}
+
+	serviceName := "test_server_interceptors"
+	s, c := newTestServerAndClient(t, serviceName, psrpc.WithServerRPCInterceptors(middleware.ChainServerRPCInterceptors(record, tag)))
+
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{}, nil
+	}
+	for _, rpc := range []string{"first", "second"} {
+		s.RegisterMethod(rpc, false, false, true, false)
+		c.RegisterMethod(rpc, false, false, true, false)
+		err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+		require.NoError(t, err)
+
+		res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+		require.NoError(t, err)
+		require.Equal(t, serviceName, res.Error)
+	}
+	require.Equal(t, []string{"first", "second"}, methods)
+}
+
+func TestExpiredRequests(t *testing.T) {
+	expired := make(chan string, 1)
+	s, c := newTestServerAndClient(t, "test_expired_requests",
+		psrpc.WithServerMaxConcurrency(1),
+		psrpc.WithServerExpiredRequestHandler(func(info psrpc.RPCInfo, requestID string, expiry time.Time) {
+			expired <- info.Method
+		}),
+	)
+
+	rpc := "slow"
+	release := make(chan struct{})
+	var handled atomic.Int32
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		handled.Inc()
+		if req.RequestId == "block" {
+			<-release
+		}
+		return &internal.Response{}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, false, true)
+	c.RegisterMethod(rpc, false, false, false, true)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, client.RequestNone(ctx, c, rpc, nil, &internal.Request{RequestId: "block"}))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, client.RequestNone(ctx, c, rpc, nil, &internal.Request{}, psrpc.WithRequestTimeout(50*time.Millisecond)))
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	select {
+	case method := <-expired:
+		require.Equal(t, rpc, method)
+	case <-time.After(time.Second):
+		t.Fatal("expired request not reported")
+	}
+	require.Equal(t, int32(1), handled.Load())
+}
+
+func TestTopicsHandler(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_topics_handler")
+
+	rpc := "room"
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		i, ok := psrpc.IncomingRPCInfo(ctx)
+		if !ok {
+			return nil, errors.New("missing rpc info")
+		}
+		return &internal.Response{RequestId: i.Topic[0]}, nil
+	}
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterTopicsHandler[*internal.Request, *internal.Response](s, rpc, [][]string{{"a"}, {"b"}}, handler, nil)
+	require.NoError(t, err)
+
+	for _, topic := range []string{"a", "b"} {
+		res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, []string{topic}, &internal.Request{})
+		require.NoError(t, err)
+		require.Equal(t, topic, res.RequestId)
+	}
+
+	// registration is all or nothing
+	err = server.RegisterTopicsHandler[*internal.Request, *internal.Response](s, rpc, [][]string{{"c"}, {"a"}}, handler, nil)
+	require.Error(t, err)
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, []string{"c"}, &internal.Request{}, psrpc.WithRequestTimeout(100*time.Millisecond))
+	require.Error(t, err)
+}
+
+func TestTopicPatternHandler(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_topic_pattern_handler")
+
+	rpc := "room"
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		i, ok := psrpc.IncomingRPCInfo(ctx)
+		if !ok {
+			return nil, errors.New("missing rpc info")
+		}
+		return &internal.Response{RequestId: i.Topic[0]}, nil
+	}
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterTopicPatternHandler[*internal.Request, *internal.Response](s, rpc, []string{"room.*"}, handler, nil)
+	require.NoError(t, err)
+
+	for _, topic := range []string{"room.a", "room.b"} {
+		res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, []string{topic}, &internal.Request{})
+		require.NoError(t, err)
+		require.Equal(t, topic, res.RequestId)
+	}
+
+	res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, []string{"room.c"}, &internal.Request{}, psrpc.WithTargetServer(s.ID))
+	require.NoError(t, err)
+	require.Equal(t, "room.c", res.RequestId)
+
+	for _, topic := range [][]string{{"lobby"}, {"room.a", "b"}} {
+		_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, topic, &internal.Request{}, psrpc.WithRequestTimeout(100*time.Millisecond))
+		require.Error(t, err)
+	}
+
+	s.DeregisterTopicPatternHandler(rpc, []string{"room.*"})
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, []string{"room.a"}, &internal.Request{}, psrpc.WithRequestTimeout(100*time.Millisecond))
+	require.Error(t, err)
+
+	ns := server.NewRPCServer(&info.ServiceDefinition{Name: "test_topic_pattern_handler", ID: rand.NewString()}, psrpc.NewNatsMessageBus(nil))
+	ns.RegisterMethod(rpc, false, false, true, false)
+	err = server.RegisterTopicPatternHandler[*internal.Request, *internal.Response](ns, rpc, []string{"room.*"}, handler, nil)
+	require.ErrorIs(t, err, psrpc.ErrPatternsUnsupported)
+}
+
+func TestClaimBackoff(t *testing.T) {
+	ts := newTestService(t, "test_claim_backoff")
+	rpc := "work"
+
+	release := make(chan struct{})
+	defer close(release)
+
+	servers := make([]*server.RPCServer, 2)
+	for i := range servers {
+		s := ts.newServer(psrpc.WithServerClaimBackoff(1, 500*time.Millisecond))
+		servers[i] = s
+
+		s.RegisterMethod(rpc, false, false, true, false)
+		err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+			if req.RequestId == "block" {
+				<-release
+			}
+			return &internal.Response{ServerId: s.ID}, nil
+		}, nil)
+		require.NoError(t, err)
+	}
+
+	c := ts.newClient()
+	c.RegisterMethod(rpc, false, false, true, false)
+
+	busy, idle := servers[0], servers[1]
+	go func() {
+		_, _ = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "block"}, psrpc.WithTargetServer(busy.ID))
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+		require.NoError(t, err)
+		require.Equal(t, idle.ID, res.ServerId)
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_error_details")
+
+	rpc := "fail"
+	detail := &internal.Response{ServerId: "detail"}
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return nil, psrpc.NewErrorWithDetails(psrpc.NotFound, errors.New("missing"), detail)
+	}
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+	var e psrpc.Error
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, psrpc.NotFound, e.Code())
+	require.Len(t, e.GRPCStatus().Details(), 1)
+	var d psrpc.ErrorWithDetails
+	require.ErrorAs(t, err, &d)
+	require.Len(t, d.Details(), 1)
+	require.True(t, proto.Equal(detail, d.Details()[0]))
+}
+
+func TestMultipleServices(t *testing.T) {
+	ts := newTestService(t, "test_service_a")
+	rpc := "whoami"
+
+	s := ts.newServer()
+	s.RegisterMethod(rpc, false, false, true, false)
+
+	sd := &info.ServiceDefinition{Name: "test_service_b"}
+	sd.RegisterMethod(rpc, false, false, true, false)
+	sd.RegisterMethod("echo", false, false, true, false)
+	require.NoError(t, s.AddService(sd))
+	require.Empty(t, sd.ID)
+	require.Error(t, s.AddService(&info.ServiceDefinition{Name: "test_service_b"}))
+
+	newHandler := func(service string) func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+			return &internal.Response{ServerId: service}, nil
+		}
+	}
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, newHandler("a"), nil)
+	require.NoError(t, err)
+	err = server.RegisterServiceHandler[*internal.Request, *internal.Response](s, "test_service_b", rpc, nil, newHandler("b"), nil)
+	require.NoError(t, err)
+	err = server.RegisterServiceHandler[*internal.Request, *internal.Response](s, "missing", rpc, nil, newHandler("b"), nil)
+	require.Error(t, err)
+
+	for service, expected := range map[string]string{"test_service_a": "a", "test_service_b": "b"} {
+		c := ts.sibling(service).newClient()
+		c.RegisterMethod(rpc, false, false, true, false)
+
+		res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+		require.NoError(t, err)
+		require.Equal(t, expected, res.ServerId)
+	}
+
+	// claimed streams for added services are routed on the service's own channels
+	err = server.RegisterServiceStreamHandler[*internal.Request, *internal.Response](s, "test_service_b", "echo", nil, func(stream psrpc.ServerStream[*internal.Response, *internal.Request]) error {
+		for req := range stream.Channel() {
+			if err := stream.Send(&internal.Response{RequestId: req.RequestId}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil)
+	require.NoError(t, err)
+
+	c := ts.sibling("test_service_b").newStreamClient()
+	c.RegisterMethod("echo", false, false, true, false)
+	stream, err := client.OpenStream[*internal.Request, *internal.Response](context.Background(), c, "echo", nil)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, stream.Send(&internal.Request{RequestId: fmt.Sprint(i)}))
+		require.Equal(t, fmt.Sprint(i), (<-stream.Channel()).RequestId)
+	}
+	require.NoError(t, stream.Close(nil))
+}
+
+func TestDedupWindow(t *testing.T) {
+	s, _ := newTestServerAndClient(t, "test_dedup_window", psrpc.WithServerDedupWindow(time.Minute))
+
+	rpc := "redelivered"
+	var handled atomic.Int32
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		handled.Inc()
+		return &internal.Response{}, nil
+	}
+	s.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	b, err := proto.Marshal(&internal.Request{})
+	require.NoError(t, err)
+	for _, id := range []string{"a", "a", "b"} {
+		err = s.Publish(context.Background(), rpc, nil, &internal.Request{
+			RequestId:  id,
+			Expiry:     time.Now().Add(time.Second).UnixNano(),
+			RawRequest: b,
+			NoResponse: true,
+		})
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool { return handled.Load() == 2 }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(2), handled.Load())
+}
+
+type handlerMetricsObserver struct {
+	middleware.MetricsObserver
+	mu      sync.Mutex
+	metrics []psrpc.HandlerMetrics
+}
+
+func (o *handlerMetricsObserver) OnUnaryRequest(middleware.MetricRole, psrpc.RPCInfo, time.Duration, error) {
+}
+
+func (o *handlerMetricsObserver) OnHandlerMetrics(m psrpc.HandlerMetrics) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.metrics = append(o.metrics, m)
+}
+
+func TestHandlerMetrics(t *testing.T) {
+	observer := &handlerMetricsObserver{}
+	s, c := newTestServerAndClient(t, "test_handler_metrics", middleware.WithServerMetrics(observer))
+
+	rpc := "counted"
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		if req.RequestId == "fail" {
+			return nil, errors.New("failed")
+		}
+		return &internal.Response{}, nil
+	}
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, []string{"a"}, handler, nil)
+	require.NoError(t, err)
+
+	for _, id := range []string{"ok", "ok", "fail"} {
+		_, _ = client.RequestSingle[*internal.Response](context.Background(), c, rpc, []string{"a"}, &internal.Request{RequestId: id})
+	}
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	// each request reports on start and completion
+	require.Len(t, observer.metrics, 6)
+	require.Equal(t, 1, observer.metrics[4].Active)
+	m := observer.metrics[5]
+	require.Equal(t, rpc, m.Method)
+	require.Equal(t, []string{"a"}, m.Topic)
+	require.Equal(t, 0, m.Active)
+	require.Equal(t, uint64(3), m.Completed)
+	require.Equal(t, uint64(1), m.Errored)
+	require.Greater(t, m.Latency, time.Duration(0))
+}
+
+func TestPayloadSizes(t *testing.T) {
+	ts := newTestService(t, "test_payload_sizes")
+
+	var mu sync.Mutex
+	var sizes []psrpc.PayloadSize
+	onSize := func(p psrpc.PayloadSize) {
+		mu.Lock()
+		defer mu.Unlock()
+		sizes = append(sizes, p)
+	}
+	s := ts.newServer(psrpc.WithServerCompression(psrpc.CompressionGzip, 0), psrpc.WithServerPayloadSizes(onSize))
+	c := ts.newClient(psrpc.WithClientPayloadSizes(onSize))
+
+	rpc := "sized"
+	res := &internal.Response{Error: strings.Repeat("a", 4096)}
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return res, nil
+	}
+	s.RegisterMethod(rpc, false, false, false, false)
+	c.RegisterMethod(rpc, false, false, false, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	req := &internal.Request{RequestId: "sized"}
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, req)
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	// the request and the response are each reported by the client and the server
+	require.Len(t, sizes, 4)
+	var responses int
+	for _, p := range sizes {
+		require.Equal(t, rpc, p.Method)
+		if p.Response {
+			responses++
+			require.Less(t, p.Size, proto.Size(res))
+		} else {
+			require.Equal(t, proto.Size(req), p.Size)
+		}
+	}
+	require.Equal(t, 2, responses)
+}
+
+func TestInFlightRequests(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_in_flight_requests")
+
+	rpc := "stuck"
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		close(started)
+		<-release
+		return &internal.Response{}, nil
+	}
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, []string{"a"}, handler, nil)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, []string{"a"}, &internal.Request{}, psrpc.WithRequestTimeout(time.Second))
+		done <- err
+	}()
+	<-started
+
+	pending := c.PendingRequests()
+	require.Len(t, pending, 1)
+	require.Equal(t, rpc, pending[0].Method)
+	require.Equal(t, []string{"a"}, pending[0].Topic)
+	require.Greater(t, pending[0].Age, time.Duration(0))
+	require.WithinDuration(t, pending[0].StartedAt.Add(time.Second), pending[0].Deadline, time.Millisecond)
+
+	active := s.ActiveRequests()
+	require.Len(t, active, 1)
+	require.Equal(t, pending[0].RequestID, active[0].RequestID)
+	require.Equal(t, c.ID, active[0].RemoteID)
+
+	w := httptest.NewRecorder()
+	s.ActiveRequestsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	var decoded []psrpc.InFlightRequest
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&decoded))
+	require.Len(t, decoded, 1)
+	require.Equal(t, active[0].RequestID, decoded[0].RequestID)
+
+	close(release)
+	require.NoError(t, <-done)
+	require.Empty(t, c.PendingRequests())
+	require.Eventually(t, func() bool { return len(s.ActiveRequests()) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestClaimTimeout(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_claim_timeout", psrpc.WithServerClaimTimeout(50*time.Millisecond))
+
+	rpc := "lookup"
+	canceled := make(chan struct{})
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{}, nil
+	}
+	affinity := func(ctx context.Context, req *internal.Request) float32 {
+		if req.RequestId == "slow" {
+			<-ctx.Done()
+			close(canceled)
+		}
+		return 1
+	}
+	s.RegisterMethod(rpc, true, false, true, false)
+	c.RegisterMethod(rpc, true, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, affinity)
+	require.NoError(t, err)
+
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "fast"})
+	require.NoError(t, err)
+
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "slow"}, psrpc.WithRequestTimeout(200*time.Millisecond))
+	require.Error(t, err)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("affinity context not canceled")
+	}
+}
+
+func TestAffinityPayload(t *testing.T) {
+	ts := newTestService(t, "test_affinity_payload")
+	rpc := "join"
+
+	var mu sync.Mutex
+	var methods []string
+	for _, room := range []string{"a", "b"} {
+		room := room
+		s := ts.newServer(psrpc.WithServerID(room))
+		s.RegisterMethod(rpc, true, false, true, false)
+		err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+			return &internal.Response{ServerId: s.ID}, nil
+		}, func(ctx context.Context, req *internal.Request) float32 {
+			i, _ := psrpc.IncomingRPCInfo(ctx)
+			mu.Lock()
+			methods = append(methods, i.Method)
+			mu.Unlock()
+			if req.RequestId == room {
+				return 1
+			}
+			return 0.5
+		})
+		require.NoError(t, err)
+	}
+
+	c := ts.newClient()
+	c.RegisterMethod(rpc, true, false, true, false)
+
+	for _, room := range []string{"a", "b", "a"} {
+		res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: room})
+		require.NoError(t, err)
+		require.Equal(t, room, res.ServerId)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, methods)
+	for _, method := range methods {
+		require.Equal(t, rpc, method)
+	}
+}
+
+func TestMaxQueueDepth(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_max_queue_depth",
+		psrpc.WithServerMaxConcurrency(1),
+		psrpc.WithServerMaxQueueDepth(1, time.Second),
+	)
+
+	rpc := "queued"
+	release := make(chan struct{})
+	defer close(release)
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		if req.RequestId == "block" {
+			<-release
+		}
+		return &internal.Response{}, nil
+	}
+	// queue rpcs are not claimed, so the full server rejects them itself
+	s.RegisterMethod(rpc, false, false, false, true)
+	c.RegisterMethod(rpc, false, false, false, true)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, client.RequestNone(ctx, c, rpc, nil, &internal.Request{RequestId: "block"}))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, client.RequestNone(ctx, c, rpc, nil, &internal.Request{}))
+	time.Sleep(50 * time.Millisecond)
+
+	_, err = client.RequestSingle[*internal.Response](ctx, c, rpc, nil, &internal.Request{})
+	var e psrpc.Error
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, psrpc.Unavailable, e.Code())
+	var d psrpc.ErrorWithDetails
+	require.ErrorAs(t, err, &d)
+	require.Len(t, d.Details(), 1)
+	require.Equal(t, time.Second, d.Details()[0].(*errdetails.RetryInfo).RetryDelay.AsDuration())
+}
+
+func TestVersionRouting(t *testing.T) {
+	ts := newTestService(t, "test_version_routing")
+	rpc := "whoami"
+
+	for _, version := range []string{"blue", "green"} {
+		version := version
+		s := ts.newServer(psrpc.WithServerVersion(version))
+
+		handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+			return &internal.Response{ServerId: version}, nil
+		}
+		s.RegisterMethod(rpc, false, false, true, false)
+		s.RegisterMethod("multi", false, true, false, false)
+		s.RegisterMethod("queue", false, false, false, true)
+		for _, method := range []string{rpc, "multi", "queue"} {
+			err := server.RegisterHandler[*internal.Request, *internal.Response](s, method, nil, handler, nil)
+			require.NoError(t, err)
+		}
+	}
+
+	c := ts.newClient()
+	c.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod("multi", false, true, false, false)
+	c.RegisterMethod("queue", false, false, false, true)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		res, err := client.RequestSingle[*internal.Response](ctx, c, rpc, nil, &internal.Request{}, psrpc.WithVersion("green"))
+		require.NoError(t, err)
+		require.Equal(t, "green", res.ServerId)
+	}
+
+	_, err := client.RequestSingle[*internal.Response](ctx, c, rpc, nil, &internal.Request{}, psrpc.WithVersion("red"), psrpc.WithRequestTimeout(200*time.Millisecond))
+	require.Error(t, err)
+
+	_, err = client.RequestSingle[*internal.Response](ctx, c, rpc, nil, &internal.Request{}, psrpc.WithPreferredVersion("red"))
+	require.NoError(t, err)
+
+	// servers check the version of requests that are not claimed
+	resChan, err := client.RequestMulti[*internal.Response](ctx, c, "multi", nil, &internal.Request{}, psrpc.WithVersion("green"), psrpc.WithRequestTimeout(200*time.Millisecond))
+	require.NoError(t, err)
+	var versions []string
+	for res := range resChan {
+		require.NoError(t, res.Err)
+		versions = append(versions, res.Result.ServerId)
+	}
+	require.Equal(t, []string{"green"}, versions)
+
+	_, err = client.RequestSingle[*internal.Response](ctx, c, "queue", nil, &internal.Request{}, psrpc.WithVersion("red"))
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.FailedPrecondition))
+
+	_, err = client.RequestMulti[*internal.Response](ctx, c, "multi", nil, &internal.Request{}, psrpc.WithPreferredVersion("green"))
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.InvalidArgument))
+}
+
+func TestCanaryUnclaimed(t *testing.T) {
+	_, c := newTestServerAndClient(t, "test_canary_unclaimed")
+	c.RegisterMethod("queue", false, false, true, true)
+	c.RegisterMethod("multi", false, true, false, false)
+
+	canary := psrpc.WithCanary(map[string]string{"version": "canary"}, 0.5)
+	ctx := context.Background()
+
+	_, err := client.RequestSingle[*internal.Response](ctx, c, "queue", nil, &internal.Request{}, canary)
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.InvalidArgument))
+	_, err = client.RequestMulti[*internal.Response](ctx, c, "multi", nil, &internal.Request{}, canary)
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.InvalidArgument))
+	err = client.RequestNone(ctx, c, "multi", nil, &internal.Request{}, canary)
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.InvalidArgument))
+}
+
+func TestHandlerDeadline(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_handler_deadline")
+
+	rpc := "deadline"
+	deadlines := make(chan time.Time, 1)
+	cancelled := make(chan error, 1)
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return &internal.Response{}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	timeout := 200 * time.Millisecond
+	start := time.Now()
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{}, psrpc.WithRequestTimeout(timeout))
+	require.ErrorIs(t, err, psrpc.ErrRequestTimedOut)
+
+	deadline := <-deadlines
+	require.WithinDuration(t, start.Add(timeout), deadline, 50*time.Millisecond)
+	select {
+	case err := <-cancelled:
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("handler context not cancelled")
+	}
+}
+
+func TestServerRegistry(t *testing.T) {
+	ts := newTestService(t, "test_server_registry")
+
+	r, err := client.NewServerRegistry(ts.name, ts.bus)
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+
+	s := ts.newServer(psrpc.WithServerHeartbeat(20*time.Millisecond), psrpc.WithServerLabels(map[string]string{"region": "us"}))
+
+	rpc := "registered"
+	s.RegisterMethod(rpc, false, false, false, false)
+	err = server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{}, nil
+	}, nil)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		si, ok := r.Server(ts.name, s.ID)
+		return ok && len(si.Handlers) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	servers := r.Servers()
+	require.Len(t, servers, 1)
+	require.Equal(t, ts.name, servers[0].Service)
+	require.Equal(t, []string{s.GetInfo(rpc, nil).GetHandlerKey()}, servers[0].Handlers)
+	require.Equal(t, "us", servers[0].Labels["region"])
+
+	require.NoError(t, s.Shutdown(context.Background()))
+	require.Eventually(t, func() bool {
+		return len(r.Servers()) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// heartbeats are tracked from when they arrive, whatever the server's clock says
+	sentAt := time.Now().Add(-time.Hour)
+	err = ts.bus.Publish(context.Background(), info.GetHeartbeatChannel(ts.name), &internal.ServerHeartbeat{
+		ServerId: "skewed",
+		Service:  ts.name,
+		SentAt:   sentAt.UnixNano(),
+		Expiry:   sentAt.Add(time.Second).UnixNano(),
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		_, ok := r.Server(ts.name, "skewed")
+		return ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDeploymentRegistry(t *testing.T) {
+	ts := newTestService(t, "test_admin_a")
+
+	r, err := client.NewDeploymentRegistry(ts.bus)
+	require.NoError(t, err)
+	t.Cleanup(r.Close)
+
+	rpc := "admin"
+	var servers []*server.RPCServer
+	for _, name := range []string{"test_admin_a", "test_admin_b"} {
+		s := ts.sibling(name).newServer(psrpc.WithServerHeartbeat(20 * time.Millisecond))
+
+		s.RegisterMethod(rpc, false, false, false, false)
+		err = server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, []string{"topic"}, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+			return &internal.Response{}, nil
+		}, nil)
+		require.NoError(t, err)
+		servers = append(servers, s)
+	}
+
+	c := ts.newClient()
+	c.RegisterMethod(rpc, false, false, false, false)
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, []string{"topic"}, &internal.Request{})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		si, ok := r.Server(ts.name, servers[0].ID)
+		return len(r.Servers()) == 2 && ok && len(si.Metrics) == 1 && si.Metrics[0].Completed == 1
+	}, time.Second, 10*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	var listed []*psrpc.ServerInfo
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&listed))
+	require.Len(t, listed, 2)
+	require.Equal(t, "test_admin_a", listed[0].Service)
+	require.Equal(t, []string{"topic"}, listed[0].Metrics[0].Topic)
+	require.Equal(t, "test_admin_b", listed[1].Service)
+
+	servers[1].Close(true)
+	require.Eventually(t, func() bool {
+		return len(r.Servers()) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestServerStream(t *testing.T) {
+	ts := newTestService(t, "test_server_stream")
+
+	s := ts.newServer()
+
+	c := ts.newStreamClient()
+
+	rpc := "count"
+	handler := func(ctx context.Context, req *internal.Request, stream psrpc.StreamWriter[*internal.Response]) error {
+		if req.RequestId == "fail" {
+			return psrpc.NewErrorf(psrpc.InvalidArgument, "bad request")
+		}
+		for i := 0; i < 3; i++ {
+			if err := stream.Send(&internal.Response{RequestId: fmt.Sprint(i)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterServerStreamHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	t.Run("EOF", func(t *testing.T) {
+		stream, err := client.OpenServerStream[*internal.Request, *internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+		require.NoError(t, err)
+
+		var received []string
+		for res := range stream.Channel() {
+			received = append(received, res.RequestId)
+		}
+		require.Equal(t, []string{"0", "1", "2"}, received)
+		require.ErrorIs(t, stream.Err(), psrpc.ErrStreamEOF)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		stream, err := client.OpenServerStream[*internal.Request, *internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "fail"})
+		require.NoError(t, err)
+
+		for range stream.Channel() {
+		}
+		var e psrpc.Error
+		require.ErrorAs(t, stream.Err(), &e)
+		require.Equal(t, psrpc.InvalidArgument, e.Code())
+	})
+}
+
+func TestStreamAuth(t *testing.T) {
+	ts := newTestService(t, "test_stream_auth")
+	s := ts.newServer(middleware.WithServerStreamAuth(func(ctx context.Context, credentials string, info psrpc.RPCInfo) (context.Context, error) {
+		if credentials != "secret" {
+			return nil, errors.New("invalid credentials")
+		}
+		return ctx, nil
+	}))
+
+	rpc := "echo"
+	handler := func(ctx context.Context, req *internal.Request, stream psrpc.StreamWriter[*internal.Response]) error {
+		return stream.Send(&internal.Response{RequestId: req.RequestId})
+	}
+	s.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterServerStreamHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	for _, credentials := range []string{"secret", "wrong"} {
+		credentials := credentials
+		c := ts.newStreamClient(middleware.WithClientAuth(func(ctx context.Context) (string, error) {
+			return credentials, nil
+		}))
+		c.RegisterMethod(rpc, false, false, true, false)
+
+		stream, err := client.OpenServerStream[*internal.Request, *internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "a"})
+		if credentials != "secret" {
+			// the request may be sent before the server closes the stream
+			if err == nil {
+				for range stream.Channel() {
+				}
+				err = stream.Err()
+			}
+			require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.Unauthenticated))
+			continue
+		}
+		require.NoError(t, err)
+
+		var received []string
+		for res := range stream.Channel() {
+			received = append(received, res.RequestId)
+		}
+		require.Equal(t, []string{"a"}, received)
+		require.ErrorIs(t, stream.Err(), psrpc.ErrStreamEOF)
+	}
+}
+
+func TestClientStream(t *testing.T) {
+	ts := newTestService(t, "test_client_stream")
+
+	s := ts.newServer()
+
+	c := ts.newStreamClient()
+
+	rpc := "sum"
+	handler := func(ctx context.Context, stream psrpc.StreamReader[*internal.Request]) (*internal.Response, error) {
+		var sum int32
+		for req := range stream.Channel() {
+			if req.RequestId == "fail" {
+				return nil, psrpc.NewErrorf(psrpc.InvalidArgument, "bad request")
+			}
+			sum += req.Priority
+		}
+		if errors.Is(stream.Err(), psrpc.ErrStreamClosed) {
+			return nil, stream.Err()
+		}
+		return &internal.Response{RequestId: fmt.Sprint(sum)}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterClientStreamHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	t.Run("Response", func(t *testing.T) {
+		stream, err := client.OpenClientStream[*internal.Request, *internal.Response](context.Background(), c, rpc, nil)
+		require.NoError(t, err)
+
+		for i := int32(1); i <= 3; i++ {
+			require.NoError(t, stream.Send(&internal.Request{Priority: i}))
+		}
+		res, err := stream.CloseAndRecv()
+		require.NoError(t, err)
+		require.Equal(t, "6", res.RequestId)
+		require.ErrorIs(t, stream.Send(&internal.Request{}), psrpc.ErrStreamSendClosed)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		stream, err := client.OpenClientStream[*internal.Request, *internal.Response](context.Background(), c, rpc, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, stream.Send(&internal.Request{RequestId: "fail"}))
+		_, err = stream.CloseAndRecv()
+		var e psrpc.Error
+		require.ErrorAs(t, err, &e)
+		require.Equal(t, psrpc.InvalidArgument, e.Code())
+	})
+}
+
+func TestClaimedStreamRouting(t *testing.T) {
+	ts := newTestService(t, "test_claimed_stream_routing")
+
+	var servers []*server.RPCServer
+	rpc := "echo"
+	for i := 0; i < 2; i++ {
+		s := ts.newServer()
+
+		s.RegisterMethod(rpc, false, false, true, false)
+		err := server.RegisterStreamHandler[*internal.Request, *internal.Response](s, rpc, nil, func(stream psrpc.ServerStream[*internal.Response, *internal.Request]) error {
+			for req := range stream.Channel() {
+				if err := stream.Send(&internal.Response{RequestId: req.RequestId}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil)
+		require.NoError(t, err)
+		servers = append(servers, s)
+	}
+
+	c := ts.newStreamClient()
+	c.RegisterMethod(rpc, false, false, true, false)
+
+	shared, err := psrpcbus.Subscribe[*internal.Stream](context.Background(), ts.bus, c.GetInfo(rpc, nil).GetStreamServerChannel(), psrpcbus.DefaultChannelSize)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = shared.Close() })
+
+	stream, err := client.OpenStream[*internal.Request, *internal.Response](context.Background(), c, rpc, nil)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, stream.Send(&internal.Request{RequestId: fmt.Sprint(i)}))
+		res := <-stream.Channel()
+		require.Equal(t, fmt.Sprint(i), res.RequestId)
+	}
+	require.NoError(t, stream.Close(nil))
+
+	// only the open request is broadcast to every server
+	require.NotNil(t, (<-shared.Channel()).GetOpen())
+	select {
+	case is := <-shared.Channel():
+		t.Fatalf("unexpected broadcast stream message %v", is)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestStreamFlowControl(t *testing.T) {
+	ts := newTestService(t, "test_stream_flow_control")
+
+	s := ts.newServer(psrpc.WithServerChannelSize(1), psrpc.WithServerStreamWindowSize(2))
+
+	c := ts.newStreamClient()
+
+	rpc := "slow_reader"
+	received := make(chan int, 10)
+	handler := func(ctx context.Context, stream psrpc.StreamReader[*internal.Request]) (*internal.Response, error) {
+		for range stream.Channel() {
+			time.Sleep(10 * time.Millisecond)
+			received <- 1
+		}
+		return &internal.Response{}, nil
+	}
+
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterClientStreamHandler[*internal.Request, *internal.Response](s, rpc, nil, handler, nil)
+	require.NoError(t, err)
+
+	stream, err := client.OpenClientStream[*internal.Request, *internal.Response](context.Background(), c, rpc, nil)
+	require.NoError(t, err)
+
+	// without flow control the server's single slot channel would discard messages
+	var wg sync.WaitGroup
+	var failed atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := stream.Send(&internal.Request{}); err != nil {
+				failed.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+	require.Zero(t, failed.Load())
+
+	_, err = stream.CloseAndRecv()
+	require.NoError(t, err)
+	require.Len(t, received, 10)
+}
+
+func TestStreamMultiplexing(t *testing.T) {
+	ts := newTestService(t, "test_stream_multiplexing")
+
+	s := ts.newServer()
+
+	c := ts.newStreamClient()
+
+	rpcs := []string{"first", "second"}
+	for _, rpc := range rpcs {
+		rpc := rpc
+		s.RegisterMethod(rpc, false, false, true, false)
+		c.RegisterMethod(rpc, false, false, true, false)
+		err := server.RegisterStreamHandler[*internal.Request, *internal.Response](s, rpc, nil, func(stream psrpc.ServerStream[*internal.Response, *internal.Request]) error {
+			for req := range stream.Channel() {
+				if err := stream.Send(&internal.Response{RequestId: rpc + req.RequestId}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil)
+		require.NoError(t, err)
+	}
+
+	// streams for every handler share the server's stream channel
+	var streams []psrpc.ClientStream[*internal.Request, *internal.Response]
+	for i := 0; i < 4; i++ {
+		stream, err := client.OpenStream[*internal.Request, *internal.Response](context.Background(), c, rpcs[i%2], nil)
+		require.NoError(t, err)
+		streams = append(streams, stream)
+	}
+	for i, stream := range streams {
+		require.NoError(t, stream.Send(&internal.Request{RequestId: fmt.Sprint(i)}))
+	}
+	for i, stream := range streams {
+		require.Equal(t, rpcs[i%2]+fmt.Sprint(i), (<-stream.Channel()).RequestId)
+		require.NoError(t, stream.Close(nil))
+	}
+}
+
+func TestStreamCloseReason(t *testing.T) {
+	ts := newTestService(t, "test_stream_close_reason")
+
+	s := ts.newServer()
+
+	c := ts.newStreamClient()
+
+	rpc := "close"
+	detail := &internal.Response{ServerId: "detail"}
+	serverErr := make(chan error, 1)
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterStreamHandler[*internal.Request, *internal.Response](s, rpc, nil, func(stream psrpc.ServerStream[*internal.Response, *internal.Request]) error {
+		for req := range stream.Channel() {
+			if req.RequestId == "fail" {
+				return psrpc.NewErrorWithDetails(psrpc.NotFound, errors.New("missing"), detail)
+			}
+		}
+		serverErr <- stream.Err()
+		return nil
+	}, nil)
+	require.NoError(t, err)
+
+	t.Run("Graceful", func(t *testing.T) {
+		stream, err := client.OpenStream[*internal.Request, *internal.Response](context.Background(), c, rpc, nil)
+		require.NoError(t, err)
+		require.NoError(t, stream.Close(nil))
+		require.ErrorIs(t, <-serverErr, psrpc.ErrStreamClosed)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		stream, err := client.OpenStream[*internal.Request, *internal.Response](context.Background(), c, rpc, nil)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&internal.Request{RequestId: "fail"}))
+		for range stream.Channel() {
+		}
+
+		var e psrpc.Error
+		require.ErrorAs(t, stream.Err(), &e)
+		require.Equal(t, psrpc.NotFound, e.Code())
+		require.Equal(t, "missing", e.Error())
+		var d psrpc.ErrorWithDetails
+		require.ErrorAs(t, stream.Err(), &d)
+		require.Len(t, d.Details(), 1)
+		require.True(t, proto.Equal(detail, d.Details()[0]))
+	})
+}
+
+func TestChunkedResponse(t *testing.T) {
+	ts := newTestService(t, "test_chunked_response")
+
+	s := ts.newServer(psrpc.WithServerResponseChunkSize(1024))
+
+	c := ts.newClient()
+
+	rpc := "large"
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{RequestId: strings.Repeat(req.RequestId, 10000)}, nil
+	}, nil)
+	require.NoError(t, err)
+
+	chunks, err := psrpcbus.Subscribe[*internal.Response](context.Background(), ts.bus, info.GetResponseChannel(c.Name, c.ID), psrpcbus.DefaultChannelSize)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = chunks.Close() })
+
+	res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "a"})
+	require.NoError(t, err)
+	require.Equal(t, strings.Repeat("a", 10000), res.RequestId)
+
+	chunk := <-chunks.Channel()
+	require.Greater(t, chunk.ChunkCount, uint32(1))
+	require.LessOrEqual(t, len(chunk.RawResponse), 1024)
+
+	// clients from before chunked responses receive them whole
+	t.Run("LegacyClient", func(t *testing.T) {
+		ts := newTestService(t, "test_chunked_response_legacy")
+		ts.bus = rewriteBus(func(_ string, msg proto.Message) proto.Message {
+			if m, ok := msg.(*internal.Request); ok {
+				m = proto.Clone(m).(*internal.Request)
+				m.ProtocolVersion = 1
+				return m
+			}
+			return msg
+		})
+		s := ts.newServer(psrpc.WithServerResponseChunkSize(1024))
+		c := ts.newClient()
+		s.RegisterMethod(rpc, false, false, true, false)
+		c.RegisterMethod(rpc, false, false, true, false)
+		err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+			return &internal.Response{RequestId: strings.Repeat(req.RequestId, 10000)}, nil
+		}, nil)
+		require.NoError(t, err)
+
+		responses, err := psrpcbus.Subscribe[*internal.Response](context.Background(), ts.bus, info.GetResponseChannel(c.Name, c.ID), psrpcbus.DefaultChannelSize)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = responses.Close() })
+
+		res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "a"})
+		require.NoError(t, err)
+		require.Equal(t, strings.Repeat("a", 10000), res.RequestId)
+		require.Zero(t, (<-responses.Channel()).ChunkCount)
+	})
+}
+
+func TestMultiRPCBackpressure(t *testing.T) {
+	cases := []struct {
+		policy psrpc.BackpressurePolicy
+		expect func(t *testing.T, res *psrpc.Response[*internal.Response])
+	}{
+		{psrpc.BackpressureDropOldest, func(t *testing.T, res *psrpc.Response[*internal.Response]) {
+			require.NoError(t, res.Err)
+			require.Equal(t, "3", res.Result.RequestId)
+		}},
+		{psrpc.BackpressureDropNewest, func(t *testing.T, res *psrpc.Response[*internal.Response]) {
+			require.NoError(t, res.Err)
+			require.Equal(t, "1", res.Result.RequestId)
+		}},
+		{psrpc.BackpressureFail, func(t *testing.T, res *psrpc.Response[*internal.Response]) {
+			require.ErrorIs(t, res.Err, psrpc.ErrSlowConsumer)
+		}},
+	}
+
+	for _, tc := range cases {
+		ts := newTestService(t, "test_multi_rpc_backpressure")
+		rpc := "echo"
+
+		// servers respond one after another
+		var started, done atomic.Int32
+		for i := 0; i < 3; i++ {
+			s := ts.newServer()
+			s.RegisterMethod(rpc, false, true, false, false)
+			err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+				n := started.Inc()
+				defer done.Inc()
+				time.Sleep(time.Duration(n) * 20 * time.Millisecond)
+				return &internal.Response{RequestId: fmt.Sprint(n)}, nil
+			}, nil)
+			require.NoError(t, err)
+		}
+
+		c := ts.newClient(psrpc.WithClientChannelSize(1), psrpc.WithClientBackpressurePolicy(tc.policy))
+		c.RegisterMethod(rpc, false, true, false, false)
+
+		resChan, err := client.RequestMulti[*internal.Response](
+			context.Background(), c, rpc, nil, &internal.Request{}, psrpc.WithExpectedServers(3),
+		)
+		require.NoError(t, err)
+
+		// let every response arrive before reading any of them
+		require.Eventually(t, func() bool { return done.Load() == 3 }, time.Second, 10*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+
+		var responses []*psrpc.Response[*internal.Response]
+		for res := range resChan {
+			responses = append(responses, res)
+		}
+		require.Len(t, responses, 1)
+		tc.expect(t, responses[0])
+
+		stats := c.ChannelStats()
+		require.Equal(t, "responses", stats[0].Channel)
+		require.Equal(t, uint64(2), stats[0].Dropped)
+	}
+}
+
+func TestMultiRPCSlowConsumer(t *testing.T) {
+	ts := newTestService(t, "test_multi_rpc_slow_consumer")
+	rpc := "echo"
+
+	const servers = 10
+	for i := 0; i < servers; i++ {
+		s := ts.newServer()
+		s.RegisterMethod(rpc, false, true, false, false)
+		err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+			return &internal.Response{}, nil
+		}, nil)
+		require.NoError(t, err)
+	}
+
+	var mu sync.Mutex
+	reasons := map[psrpc.OverflowReason]int{}
+	c := ts.newClient(psrpc.WithClientChannelSize(2), psrpc.WithClientOverflowHandler(func(o psrpc.Overflow) {
+		mu.Lock()
+		defer mu.Unlock()
+		reasons[o.Reason]++
+	}))
+	c.RegisterMethod(rpc, false, true, false, false)
+
+	resChan, err := client.RequestMulti[*internal.Response](
+		context.Background(), c, rpc, nil, &internal.Request{}, psrpc.WithExpectedServers(servers),
+	)
+	require.NoError(t, err)
+
+	// read more than twice the channel size, slowly enough for the dispatcher to find the channel full
+	var received int
+	for res := range resChan {
+		require.NoError(t, res.Err)
+		received++
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, servers, received)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Zero(t, reasons[psrpc.OverflowDropped])
+	require.Greater(t, reasons[psrpc.OverflowBlocked], 0)
+	require.Equal(t, uint64(0), c.ChannelStats()[0].Dropped)
+}
+
+func TestChannelStats(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_channel_stats", psrpc.WithServerChannelSize(10))
+
+	rpc := "claimed"
+	s.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, []string{"a"}, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{}, nil
+	}, nil)
+	require.NoError(t, err)
+
+	var channels []string
+	for _, st := range s.ChannelStats() {
+		if strings.HasPrefix(st.Channel, rpc) {
+			channels = append(channels, st.Channel)
+			require.Equal(t, 10, st.Capacity)
+		}
+	}
+	require.Equal(t, []string{"claimed|a:requests", "claimed|a:direct", "claimed|a:claims", "claimed|a:cancels", "claimed|a:probes"}, channels)
+
+	stats := c.ChannelStats()
+	require.Len(t, stats, 4)
+	for _, st := range stats {
+		require.Zero(t, st.Depth)
+		require.Zero(t, st.Blocked)
+		require.Zero(t, st.Dropped)
+	}
+}
+
+func TestSlowRequests(t *testing.T) {
+	ts := newTestService(t, "test_slow_requests")
+
+	serverSlow := make(chan psrpc.SlowRequest, 1)
+	s := ts.newServer(psrpc.WithServerSlowRequestThreshold(20*time.Millisecond, func(_ context.Context, r psrpc.SlowRequest) {
+		serverSlow <- r
+	}))
+
+	clientSlow := make(chan psrpc.SlowRequest, 1)
+	c := ts.newClient(psrpc.WithClientSlowRequestThreshold(20*time.Millisecond, func(_ context.Context, r psrpc.SlowRequest) {
+		clientSlow <- r
+	}))
+
+	rpc := "slow"
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		if req.RequestId == "slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return &internal.Response{}, nil
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+	require.NoError(t, err)
+	require.Empty(t, clientSlow)
+
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "slow"})
+	require.NoError(t, err)
+
+	cr := <-clientSlow
+	require.Equal(t, rpc, cr.Method)
+	require.Equal(t, s.ID, cr.RemoteID)
+	require.GreaterOrEqual(t, cr.Handler, 50*time.Millisecond)
+	require.Greater(t, cr.Claim, time.Duration(0))
+	require.Equal(t, cr.Duration, cr.Publish+cr.Claim+cr.Handler+cr.Response)
+
+	sr := <-serverSlow
+	require.Equal(t, cr.RequestID, sr.RequestID)
+	require.Equal(t, c.ID, sr.RemoteID)
+	require.Equal(t, cr.Handler, sr.Handler)
+	require.GreaterOrEqual(t, sr.Duration, sr.Queue+sr.Claim+sr.Handler)
+}
+
+func TestStats(t *testing.T) {
+	s, c := newTestServerAndClient(t, "test_stats")
+
+	rpc := "stats"
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		if req.RequestId == "slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return &internal.Response{}, nil
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{})
+	require.NoError(t, err)
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "slow"}, psrpc.WithRequestTimeout(50*time.Millisecond))
+	require.ErrorIs(t, err, psrpc.ErrRequestTimedOut)
+
+	sub, err := client.Join[*internal.Response](context.Background(), c, rpc, nil)
+	require.NoError(t, err)
+
+	// the timed out request is canceled on the server, so only one response is sent
+	require.Eventually(t, func() bool { return s.Stats().ActiveRequests == 0 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, psrpc.ClientStats{
+		RequestsSent:        2,
+		ResponsesReceived:   1,
+		Timeouts:            1,
+		ActiveSubscriptions: 1,
+	}, c.Stats())
+
+	ss := s.Stats()
+	require.Equal(t, uint64(2), ss.RequestsReceived)
+	require.Equal(t, uint64(1), ss.ResponsesSent)
+	require.Equal(t, uint64(2), ss.ClaimsSent)
+	require.Zero(t, ss.ClaimRacesLost)
+
+	var decoded psrpc.ClientStats
+	require.NoError(t, json.Unmarshal([]byte(c.StatsVar().String()), &decoded))
+	require.Equal(t, c.Stats(), decoded)
+
+	require.NoError(t, sub.Close())
+	require.Zero(t, c.Stats().ActiveSubscriptions)
+}
+
+func TestInternalLogging(t *testing.T) {
+	logged := make(chan string, 1)
+	psrpc.SetLogger(funcr.New(func(prefix, args string) {
+		logged <- args
+	}, funcr.Options{}))
+	t.Cleanup(func() { psrpc.SetLogger(nil) })
+
+	ts := newTestService(t, "test_internal_logging")
+	c := ts.newClient()
+
+	// responses for unknown requests are dropped
+	err := ts.bus.Publish(context.Background(), info.GetResponseChannel(ts.name, c.ID), &internal.Response{RequestId: "unknown"})
+	require.NoError(t, err)
+	select {
+	case args := <-logged:
+		require.Contains(t, args, "dropping response for unknown request")
+		require.Contains(t, args, `"requestID"="unknown"`)
+	case <-time.After(time.Second):
+		t.Fatal("response not logged")
+	}
+
+	// handlers that run past the request deadline are not failures
+	s := ts.newServer()
+	rpc := "deadline"
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err = server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, nil)
+	require.NoError(t, err)
+
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{}, psrpc.WithRequestTimeout(50*time.Millisecond))
+	require.ErrorIs(t, err, psrpc.ErrRequestTimedOut)
+	select {
+	case args := <-logged:
+		t.Fatalf("unexpected log: %s", args)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestOverflowHandler(t *testing.T) {
+	ts := newTestService(t, "test_overflow_handler")
+
+	overflows := make(chan psrpc.Overflow, 2)
+	c := ts.newClient(psrpc.WithClientOverflowHandler(func(o psrpc.Overflow) {
+		overflows <- o
+	}))
+
+	// claims and responses for finished requests are reported
+	err := ts.bus.Publish(context.Background(), info.GetResponseChannel(ts.name, c.ID), &internal.Response{RequestId: "late", ServerId: "server"})
+	require.NoError(t, err)
+	require.Equal(t, psrpc.Overflow{
+		Channel:   "responses",
+		RequestID: "late",
+		RemoteID:  "server",
+		Reason:    psrpc.OverflowUnregistered,
+	}, <-overflows)
+
+	err = ts.bus.Publish(context.Background(), info.GetClaimRequestChannel(ts.name, c.ID), &internal.ClaimRequest{RequestId: "late", ServerId: "server"})
+	require.NoError(t, err)
+	require.Equal(t, psrpc.Overflow{
+		Channel:   "claims",
+		RequestID: "late",
+		RemoteID:  "server",
+		Reason:    psrpc.OverflowUnregistered,
+	}, <-overflows)
+}
+
+func TestCodec(t *testing.T) {
+	ts := newTestService(t, "test_codec")
+
+	s := ts.newServer()
+
+	rpc := "codec"
+	s.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{RequestId: req.RequestId}, nil
+	}, nil)
+	require.NoError(t, err)
+
+	counting := &countingCodec{Codec: psrpc.JSONCodec}
+	psrpc.RegisterCodec(counting)
+
+	// clients with different codecs share the server
+	for _, codec := range []psrpc.Codec{counting, psrpc.ProtoCodec} {
+		c := ts.newClient(psrpc.WithClientCodec(codec))
+		c.RegisterMethod(rpc, false, false, true, false)
+
+		res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: codec.Name()})
+		require.NoError(t, err)
+		require.Equal(t, codec.Name(), res.RequestId)
+	}
+	// the request and response are each encoded and decoded once
+	require.Equal(t, int64(4), counting.calls.Load())
+}
+
+func TestCompression(t *testing.T) {
+	ts := newTestService(t, "test_compression")
+
+	s := ts.newServer(psrpc.WithServerCompression(psrpc.CompressionGzip, 1024))
+
+	rpc := "compression"
+	s.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{RequestId: req.RequestId, RawResponse: req.RawRequest}, nil
+	}, nil)
+	require.NoError(t, err)
+
+	payload := bytes.Repeat([]byte("room state "), 1000)
+
+	// compressing and uncompressing clients share the server
+	for _, opts := range [][]psrpc.ClientOption{
+		{psrpc.WithClientCompression(psrpc.CompressionZstd, 1024)},
+		nil,
+	} {
+		c := ts.newClient(opts...)
+		c.RegisterMethod(rpc, false, false, true, false)
+
+		res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RequestId: "a", RawRequest: payload})
+		require.NoError(t, err)
+		require.Equal(t, "a", res.RequestId)
+		require.Equal(t, payload, res.RawResponse)
+	}
+
+	// requests are compressed once the client knows the server accepts compression
+	for _, version := range []uint32{psrpc.ProtocolVersion, 1} {
+		var mu sync.Mutex
+		var compression []string
+		ts := newTestService(t, fmt.Sprintf("test_compression_v%d", version))
+		ts.bus = rewriteBus(func(_ string, msg proto.Message) proto.Message {
+			switch m := msg.(type) {
+			case *internal.Request:
+				mu.Lock()
+				compression = append(compression, m.Compression)
+				mu.Unlock()
+			case *internal.ClaimRequest:
+				m = proto.Clone(m).(*internal.ClaimRequest)
+				m.ProtocolVersion = version
+				return m
+			case *internal.Response:
+				m = proto.Clone(m).(*internal.Response)
+				m.ProtocolVersion = version
+				return m
+			}
+			return msg
+		})
+		s := ts.newServer()
+		c := ts.newClient(psrpc.WithClientCompression(psrpc.CompressionZstd, 1024))
+		s.RegisterMethod(rpc, false, false, true, false)
+		c.RegisterMethod(rpc, false, false, true, false)
+		err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+			return &internal.Response{RawResponse: req.RawRequest}, nil
+		}, nil)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RawRequest: payload})
+			require.NoError(t, err)
+			require.Equal(t, payload, res.RawResponse)
+		}
+
+		mu.Lock()
+		if version == psrpc.ProtocolVersion {
+			require.Equal(t, []string{"", string(psrpc.CompressionZstd)}, compression)
+		} else {
+			require.Equal(t, []string{"", ""}, compression)
+		}
+		mu.Unlock()
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	ts := newTestService(t, "test_max_message_size")
+
+	s := ts.newServer(psrpc.WithServerMaxMessageSize(1024))
+
+	rpc := "echo"
+	s.RegisterMethod(rpc, false, false, true, false)
+	var calls atomic.Int64
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		calls.Inc()
+		return &internal.Response{RawResponse: bytes.Repeat(req.RawRequest, 4)}, nil
+	}, nil)
+	require.NoError(t, err)
+
+	c := ts.newClient(psrpc.WithClientMaxMessageSize(1024))
+	c.RegisterMethod(rpc, false, false, true, false)
+
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RawRequest: make([]byte, 100)})
+	require.NoError(t, err)
+
+	// the response is rejected by the server
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RawRequest: make([]byte, 500)})
+	var e psrpc.Error
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, psrpc.ResourceExhausted, e.Code())
+
+	// the request is rejected before publishing
+	_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RawRequest: make([]byte, 2000)})
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, psrpc.ResourceExhausted, e.Code())
+	require.Equal(t, int64(2), calls.Load())
+}
+
+func TestRawCodec(t *testing.T) {
+	ts := newTestService(t, "test_raw_codec")
+
+	s := ts.newServer()
+
+	rpc := "relay"
+	s.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*wrapperspb.BytesValue, *wrapperspb.BytesValue](s, rpc, nil, func(ctx context.Context, req *wrapperspb.BytesValue) (*wrapperspb.BytesValue, error) {
+		return wrapperspb.Bytes(append(req.Value, 0xff)), nil
+	}, nil)
+	require.NoError(t, err)
+
+	c := ts.newClient(psrpc.WithClientCodec(psrpc.RawCodec))
+	c.RegisterMethod(rpc, false, false, true, false)
+
+	res, err := client.RequestSingle[*wrapperspb.BytesValue](context.Background(), c, rpc, nil, wrapperspb.Bytes([]byte{1, 2, 3}))
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3, 0xff}, res.Value)
+}
+
+func TestStrictUnmarshal(t *testing.T) {
+	ts := newTestService(t, "test_strict_unmarshal")
+	newServer := func(opts ...psrpc.ServerOption) *server.RPCServer {
+		s := ts.newServer(opts...)
+		return s
+	}
+	newClient := func(opts ...psrpc.ClientOption) *client.RPCClient {
+		c := ts.newClient(opts...)
+		c.RegisterMethod("lenient", false, false, true, false)
+		c.RegisterMethod("strict", false, false, true, false)
+		return c
+	}
+
+	// the handlers' types are older schemas missing fields set by the peer
+	handler := func(ctx context.Context, req *internal.Cancel) (*internal.Request, error) {
+		return &internal.Request{RequestId: req.RequestId, Expiry: 1}, nil
+	}
+	for rpc, s := range map[string]*server.RPCServer{
+		"lenient": newServer(),
+		"strict":  newServer(psrpc.WithServerStrictUnmarshal()),
+	} {
+		s.RegisterMethod(rpc, false, false, true, false)
+		err := server.RegisterHandler[*internal.Cancel, *internal.Request](s, rpc, nil, handler, nil)
+		require.NoError(t, err)
+	}
+
+	c := newClient()
+	res, err := client.RequestSingle[*internal.Cancel](context.Background(), c, "lenient", nil, &internal.Request{RequestId: "a", SentAt: 1})
+	require.NoError(t, err)
+	require.Equal(t, "a", res.RequestId)
+
+	_, err = client.RequestSingle[*internal.Cancel](context.Background(), c, "strict", nil, &internal.Request{RequestId: "a", SentAt: 1})
+	var e psrpc.Error
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, psrpc.MalformedRequest, e.Code())
+
+	strictClient := newClient(psrpc.WithClientStrictUnmarshal())
+	_, err = client.RequestSingle[*internal.Cancel](context.Background(), strictClient, "lenient", nil, &internal.Request{RequestId: "a"})
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, psrpc.MalformedResponse, e.Code())
+}
+
+func TestBlobStore(t *testing.T) {
+	ts := newTestService(t, "test_blob_store")
+	store := psrpc.NewLocalBlobStore()
+
+	// payloads over the max message size only get through by reference
+	s := ts.newServer(psrpc.WithServerBlobStore(store, 1024), psrpc.WithServerMaxMessageSize(1024))
+	c := ts.newClient(psrpc.WithClientBlobStore(store, 1024), psrpc.WithClientMaxMessageSize(1024))
+
+	rpc := "echo"
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		return &internal.Response{RawResponse: req.RawRequest}, nil
+	}, nil)
+	require.NoError(t, err)
+
+	payload := bytes.Repeat([]byte{1}, 4096)
+	res, err := client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, &internal.Request{RawRequest: payload})
+	require.NoError(t, err)
+	require.Equal(t, payload, res.RawResponse)
+
+	// peers from before payload references get an error instead of a reference they cannot load
+	for _, legacy := range []string{"Server", "Client"} {
+		t.Run("Legacy"+legacy, func(t *testing.T) {
+			ts := newTestService(t, "test_blob_store_legacy_"+strings.ToLower(legacy))
+			ts.bus = rewriteBus(func(_ string, msg proto.Message) proto.Message {
+				switch m := msg.(type) {
+				case *internal.ClaimRequest:
+					if legacy == "Server" {
+						m = proto.Clone(m).(*internal.ClaimRequest)
+						m.ProtocolVersion = 1
+						return m
+					}
+				case *internal.Request:
+					if legacy == "Client" {
+						m = proto.Clone(m).(*internal.Request)
+						m.ProtocolVersion = 1
+						return m
+					}
+				}
+				return msg
+			})
+			s := ts.newServer(psrpc.WithServerBlobStore(store, 1024))
+			c := ts.newClient(psrpc.WithClientBlobStore(store, 1024))
+			s.RegisterMethod(rpc, false, false, true, false)
+			c.RegisterMethod(rpc, false, false, true, false)
+			err := server.RegisterHandler[*internal.Request, *internal.Response](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+				return &internal.Response{RawResponse: payload}, nil
+			}, nil)
+			require.NoError(t, err)
+
+			req := &internal.Request{}
+			if legacy == "Server" {
+				req.RawRequest = payload
+			}
+			_, err = client.RequestSingle[*internal.Response](context.Background(), c, rpc, nil, req)
+			var e psrpc.Error
+			require.ErrorAs(t, err, &e)
+			require.Equal(t, psrpc.FailedPrecondition, e.Code())
+			require.Contains(t, e.Error(), "protocol version 1")
+		})
+	}
+}
+
+func TestDeterministicMarshal(t *testing.T) {
+	ts := newTestService(t, "test_deterministic_marshal")
+
+	s := ts.newServer(psrpc.WithServerDeterministicMarshal())
+
+	c := ts.newClient(psrpc.WithClientDeterministicMarshal())
+
+	rpc := "echo"
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterHandler[*internal.Request, *internal.Request](s, rpc, nil, func(ctx context.Context, req *internal.Request) (*internal.Request, error) {
+		return req, nil
+	}, nil)
+	require.NoError(t, err)
+
+	req := &internal.Request{Metadata: map[string]string{"a": "1", "b": "2", "c": "3"}}
+	res, err := client.RequestSingle[*internal.Request](context.Background(), c, rpc, nil, req)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(req, res), "expected response to match request")
+}
+
+type countingCodec struct {
+	psrpc.Codec
+	calls atomic.Int64
+}
+
+func (c *countingCodec) Name() string {
+	return "counting"
+}
+
+func (c *countingCodec) Marshal(m proto.Message) ([]byte, error) {
+	c.calls.Inc()
+	return c.Codec.Marshal(m)
+}
+
+func (c *countingCodec) Unmarshal(b []byte, m proto.Message) error {
+	c.calls.Inc()
+	return c.Codec.Unmarshal(b, m)
+}
+
+func TestStreamLegacyServer(t *testing.T) {
+	// claims report the version of a server from before per server stream channels
+	var mu sync.Mutex
+	var channels []string
+	ts := newTestService(t, "test_stream_legacy_server")
+	ts.bus = rewriteBus(func(channel string, msg proto.Message) proto.Message {
+		switch m := msg.(type) {
+		case *internal.ClaimRequest:
+			m = proto.Clone(m).(*internal.ClaimRequest)
+			m.ProtocolVersion = 1
+			return m
+		case *internal.Stream:
+			mu.Lock()
+			channels = append(channels, channel)
+			mu.Unlock()
+		}
+		return msg
+	})
+	s := ts.newServer()
+	c := ts.newStreamClient()
+
+	rpc := "echo"
+	s.RegisterMethod(rpc, false, false, true, false)
+	c.RegisterMethod(rpc, false, false, true, false)
+	err := server.RegisterStreamHandler[*internal.Request, *internal.Response](s, rpc, nil, func(stream psrpc.ServerStream[*internal.Response, *internal.Request]) error {
+		for req := range stream.Channel() {
+			if err := stream.Send(&internal.Response{RequestId: req.RequestId}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil)
+	require.NoError(t, err)
+
+	stream, err := client.OpenStream[*internal.Request, *internal.Response](context.Background(), c, rpc, nil)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&internal.Request{RequestId: "1"}))
+	require.Equal(t, "1", (<-stream.Channel()).RequestId)
+	require.NoError(t, stream.Close(nil))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotContains(t, channels, info.GetStreamChannel(ts.name, s.ID))
+	require.Contains(t, channels, s.GetInfo(rpc, nil).GetStreamServerChannel())
+}
+
+func newTestServerAndClient(t *testing.T, serviceName string, opts ...psrpc.ServerOption) (*server.RPCServer, *client.RPCClient) {
+	ts := newTestService(t, serviceName)
+	return ts.newServer(opts...), ts.newClient()
+}
+
+// testService creates servers and clients for one service on a shared local bus
+type testService struct {
+	t    *testing.T
+	bus  psrpc.MessageBus
+	name string
+}
+
+func newTestService(t *testing.T, name string) *testService {
+	return &testService{
+		t:    t,
+		bus:  psrpc.NewLocalMessageBus(),
+		name: name,
+	}
+}
+
+func (ts *testService) newServer(opts ...psrpc.ServerOption) *server.RPCServer {
+	s := server.NewRPCServer(&info.ServiceDefinition{
+		Name: ts.name,
+		ID:   rand.NewString(),
+	}, ts.bus, opts...)
+	ts.t.Cleanup(func() { s.Close(true) })
+	return s
+}
+
+func (ts *testService) newClient(opts ...psrpc.ClientOption) *client.RPCClient {
+	c, err := client.NewRPCClient(&info.ServiceDefinition{
+		Name: ts.name,
+		ID:   rand.NewString(),
+	}, ts.bus, opts...)
+	require.NoError(ts.t, err)
+	ts.t.Cleanup(c.Close)
+	return c
+}
+
+func (ts *testService) newStreamClient(opts ...psrpc.ClientOption) *client.RPCClient {
+	c, err := client.NewRPCClientWithStreams(&info.ServiceDefinition{
+		Name: ts.name,
+		ID:   rand.NewString(),
+	}, ts.bus, opts...)
+	require.NoError(ts.t, err)
+	ts.t.Cleanup(c.Close)
+	return c
+}
+
+// rewriteBus is a local bus that passes every published message through rewrite, to stand in for peers from
+// other releases
+func rewriteBus(rewrite func(channel string, msg proto.Message) proto.Message) psrpc.MessageBus {
+	return psrpcbus.NewTestBus(psrpc.NewLocalMessageBus(), func(o *psrpcbus.TestBusOpts) {
+		o.PublishInterceptors = append(o.PublishInterceptors, func(next psrpcbus.PublishHandler) psrpcbus.PublishHandler {
+			return func(ctx context.Context, channel string, msg proto.Message) error {
+				return next(ctx, channel, rewrite(channel, msg))
+			}
+		})
+	})
+}
+
+// sibling returns a fixture for another service on the same bus
+func (ts *testService) sibling(name string) *testService {
+	return &testService{
+		t:    ts.t,
+		bus:  ts.bus,
+		name: name,
+	}
+}
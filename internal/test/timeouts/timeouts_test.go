@@ -0,0 +1,63 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timeouts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/psrpc"
+)
+
+type sleeper struct{}
+
+func (sleeper) Sleep(_ context.Context, req *SleepRequest) (*SleepResponse, error) {
+	time.Sleep(time.Duration(req.DurationMs) * time.Millisecond)
+	return &SleepResponse{}, nil
+}
+
+func (s sleeper) SleepDefault(ctx context.Context, req *SleepRequest) (*SleepResponse, error) {
+	return s.Sleep(ctx, req)
+}
+
+func TestMethodTimeouts(t *testing.T) {
+	ctx := context.Background()
+	bus := psrpc.NewLocalMessageBus()
+
+	s, err := NewSleeperServer(sleeper{}, bus)
+	require.NoError(t, err)
+	defer s.Shutdown()
+
+	c, err := NewSleeperClient(bus)
+	require.NoError(t, err)
+
+	_, err = c.Sleep(ctx, &SleepRequest{DurationMs: 200})
+	require.ErrorIs(t, err, psrpc.ErrRequestTimedOut)
+
+	_, err = c.SleepDefault(ctx, &SleepRequest{DurationMs: 200})
+	require.NoError(t, err)
+
+	_, err = c.Sleep(ctx, &SleepRequest{DurationMs: 200}, psrpc.WithRequestTimeout(time.Second))
+	require.NoError(t, err)
+
+	c, err = NewSleeperClient(bus, psrpc.WithClientMethodOptions("Sleep", psrpc.WithRequestTimeout(time.Second)))
+	require.NoError(t, err)
+
+	_, err = c.Sleep(ctx, &SleepRequest{DurationMs: 200})
+	require.NoError(t, err)
+}
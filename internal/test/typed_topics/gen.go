@@ -0,0 +1,17 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package typed_topics
+
+//go:generate protoc --go_out=paths=source_relative:. --psrpc_out=paths=source_relative,mocks=true,grpc=true,http=true,otel=true,partial=true,docs=true,schema=true:. -I ../../../protoc-gen-psrpc/options -I=. typed_topics.proto
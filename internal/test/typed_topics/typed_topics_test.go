@@ -0,0 +1,90 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package typed_topics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/psrpc"
+)
+
+type Region string
+type RoomID string
+
+type roomService struct {
+	room string
+}
+
+func (s *roomService) Join(context.Context, *JoinRequest) (*JoinResponse, error) {
+	return &JoinResponse{Room: s.room}, nil
+}
+
+func (s *roomService) Leave(context.Context, *LeaveRequest) (*LeaveResponse, error) {
+	return &LeaveResponse{}, nil
+}
+
+func TestTypedTopics(t *testing.T) {
+	ctx := context.Background()
+	bus := psrpc.NewLocalMessageBus()
+
+	sA, err := NewRoomServer[Region, RoomID](&roomService{room: "a"}, bus)
+	require.NoError(t, err)
+	defer sA.Shutdown()
+	sB, err := NewRoomServer[Region, RoomID](&roomService{room: "b"}, bus)
+	require.NoError(t, err)
+	defer sB.Shutdown()
+
+	// tokens in different positions must not share a topic
+	require.NoError(t, sA.RegisterAllRoomTopics("us", ""))
+	require.NoError(t, sB.RegisterAllRoomTopics("", "us"))
+	time.Sleep(time.Millisecond * 100)
+
+	c, err := NewRoomClient[Region, RoomID](bus, psrpc.WithClientTimeout(time.Millisecond*100))
+	require.NoError(t, err)
+
+	res, err := c.Join(ctx, "us", "", &JoinRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "a", res.Room)
+
+	res, err = c.Join(ctx, "", "us", &JoinRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "b", res.Room)
+
+	_, err = c.Leave(ctx, "us", "us", &LeaveRequest{})
+	require.Error(t, err)
+
+	sA.DeregisterAllRoomTopics("us", "")
+	time.Sleep(time.Millisecond * 100)
+	_, err = c.Join(ctx, "us", "", &JoinRequest{})
+	require.Error(t, err)
+
+	sub, err := c.SubscribeRoomEvents(ctx, "eu", "r1")
+	require.NoError(t, err)
+	defer sub.Close()
+	time.Sleep(time.Millisecond * 100)
+
+	require.NoError(t, sB.PublishRoomEvents(ctx, "eu", "r2", &RoomEvent{Participant: "p2"}))
+	require.NoError(t, sB.PublishRoomEvents(ctx, "eu", "r1", &RoomEvent{Participant: "p1"}))
+	select {
+	case e := <-sub.Channel():
+		require.Equal(t, "p1", e.Participant)
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+}
@@ -15,11 +15,37 @@
 package psrpc
 
 import (
-	"github.com/go-logr/logr"
+	"context"
 
 	"github.com/livekit/psrpc/internal/logger"
 )
 
-func SetLogger(l logr.Logger) {
+// Logger is implemented by logr.Logger, and by adapters for other key/value loggers such as slog or zap
+type Logger = logger.Logger
+
+// SetLogger sets the logger for internal failures, such as failed bus reads and undeliverable messages.
+// Nothing is logged by default
+func SetLogger(l Logger) {
 	logger.SetLogger(l)
 }
+
+// LogSlowRequest logs slow requests with the logger set by SetLogger
+func LogSlowRequest(_ context.Context, r SlowRequest) {
+	values := []interface{}{
+		"service", r.Service,
+		"method", r.Method,
+		"topic", r.Topic,
+		"requestID", r.RequestID,
+		"remoteID", r.RemoteID,
+		"duration", r.Duration,
+		"publish", r.Publish,
+		"queue", r.Queue,
+		"claim", r.Claim,
+		"handler", r.Handler,
+		"response", r.Response,
+	}
+	if r.Err != nil {
+		values = append(values, "error", r.Err)
+	}
+	logger.Info("slow request", values...)
+}
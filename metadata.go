@@ -0,0 +1,107 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package psrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/livekit/psrpc/pkg/metadata"
+)
+
+type Metadata = metadata.Metadata
+
+func NewOutgoingContext(ctx context.Context, md Metadata) context.Context {
+	return metadata.NewContextWithOutgoingMetadata(ctx, md)
+}
+
+func AppendToOutgoingContext(ctx context.Context, kv ...string) context.Context {
+	return metadata.AppendMetadataToOutgoingContext(ctx, kv...)
+}
+
+func OutgoingMetadata(ctx context.Context) Metadata {
+	return metadata.OutgoingContextMetadata(ctx)
+}
+
+// IncomingMetadata returns the metadata sent with the request being handled
+func IncomingMetadata(ctx context.Context) Metadata {
+	head := metadata.IncomingHeader(ctx)
+	if head == nil {
+		return nil
+	}
+	return head.Metadata
+}
+
+// IncomingClientID returns the id of the client that sent the request being handled
+func IncomingClientID(ctx context.Context) string {
+	head := metadata.IncomingHeader(ctx)
+	if head == nil {
+		return ""
+	}
+	return head.RemoteID
+}
+
+// NewRequestIDContext returns a context recording the id of the last request sent with it, to read with
+// OutgoingRequestID once the request is sent
+func NewRequestIDContext(ctx context.Context) context.Context {
+	return metadata.NewContextWithRequestIDRecorder(ctx)
+}
+
+// OutgoingRequestID returns the id of the last request sent with a context from NewRequestIDContext. Interceptors and
+// response hooks can read it from the context they receive
+func OutgoingRequestID(ctx context.Context) string {
+	return metadata.RecordedRequestID(ctx)
+}
+
+// IncomingRequestID returns the id of the request being handled
+func IncomingRequestID(ctx context.Context) string {
+	head := metadata.IncomingHeader(ctx)
+	if head == nil {
+		return ""
+	}
+	return head.RequestID
+}
+
+// IncomingSentAt returns the time the request being handled was sent, by the client's clock
+func IncomingSentAt(ctx context.Context) time.Time {
+	head := metadata.IncomingHeader(ctx)
+	if head == nil {
+		return time.Time{}
+	}
+	return head.SentAt
+}
+
+// IncomingRPCInfo returns the method and topic of the request being handled
+func IncomingRPCInfo(ctx context.Context) (RPCInfo, bool) {
+	head := metadata.IncomingHeader(ctx)
+	if head == nil {
+		return RPCInfo{}, false
+	}
+	return RPCInfo{
+		Service: head.Service,
+		Method:  head.Method,
+		Topic:   head.Topic,
+		Multi:   head.Multi,
+	}, true
+}
+
+// IncomingServerID returns the id of the server handling the request
+func IncomingServerID(ctx context.Context) string {
+	head := metadata.IncomingHeader(ctx)
+	if head == nil {
+		return ""
+	}
+	return head.ServerID
+}
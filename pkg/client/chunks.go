@@ -0,0 +1,93 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/livekit/psrpc/internal"
+)
+
+type chunkKey struct {
+	requestID string
+	serverID  string
+}
+
+type chunkedResponse struct {
+	count  uint32
+	chunks map[uint32][]byte
+	expiry time.Time
+}
+
+// responseChunks reassembles chunked responses. Incomplete responses are discarded when their request expires
+type responseChunks struct {
+	entries map[chunkKey]*chunkedResponse
+}
+
+func newResponseChunks() *responseChunks {
+	return &responseChunks{
+		entries: make(map[chunkKey]*chunkedResponse),
+	}
+}
+
+// add returns the reassembled response once every chunk has been received. expiry is the request's expiry
+func (c *responseChunks) add(res *internal.Response, expiry time.Time) *internal.Response {
+	c.evictExpired(time.Now())
+
+	key := chunkKey{res.RequestId, res.ServerId}
+	e, ok := c.entries[key]
+	if !ok {
+		e = &chunkedResponse{
+			count:  res.ChunkCount,
+			chunks: make(map[uint32][]byte),
+			expiry: expiry,
+		}
+		c.entries[key] = e
+	}
+	if res.ChunkCount != e.count || res.Chunk >= e.count {
+		return nil
+	}
+
+	e.chunks[res.Chunk] = res.RawResponse
+	if uint32(len(e.chunks)) < e.count {
+		return nil
+	}
+	delete(c.entries, key)
+
+	var buf bytes.Buffer
+	for i := uint32(0); i < e.count; i++ {
+		buf.Write(e.chunks[i])
+	}
+	return &internal.Response{
+		RequestId:       res.RequestId,
+		ServerId:        res.ServerId,
+		SentAt:          res.SentAt,
+		RawResponse:     buf.Bytes(),
+		Codec:           res.Codec,
+		Compression:     res.Compression,
+		HandlerDuration: res.HandlerDuration,
+		ProtocolVersion: res.ProtocolVersion,
+	}
+}
+
+// requests have different timeouts, so every incomplete response is checked
+func (c *responseChunks) evictExpired(now time.Time) {
+	for key, e := range c.entries {
+		if !now.Before(e.expiry) {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -16,16 +16,31 @@ package client
 
 import (
 	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
 	"sync"
+	"time"
 
 	"github.com/frostbyte73/core"
+	"go.uber.org/atomic"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 
 	"github.com/livekit/psrpc"
 	"github.com/livekit/psrpc/internal"
 	"github.com/livekit/psrpc/internal/bus"
+	"github.com/livekit/psrpc/internal/logger"
 	"github.com/livekit/psrpc/pkg/info"
+	"github.com/livekit/psrpc/pkg/metadata"
+	"github.com/livekit/psrpc/pkg/rand"
 )
 
+// how long claims are ignored from servers that shut down without a deadline
+const serverLeavingTTL = time.Minute
+
 type RPCClient struct {
 	*info.ServiceDefinition
 	psrpc.ClientOpts
@@ -35,7 +50,20 @@ type RPCClient struct {
 	mu               sync.RWMutex
 	claimRequests    map[string]chan *internal.ClaimRequest
 	responseChannels map[string]chan *internal.Response
+	multiResponses   map[string]<-chan struct{} // closed when the multi-rpc stops reading responses
 	streamChannels   map[string]chan *internal.Stream
+	pending          map[string]psrpc.InFlightRequest
+	subscriptions    map[string]func() bus.ChannelStats
+	counters         map[string]*channelCounters
+	stats            clientStats
+	leavingServers   map[string]leavingServer
+	versions         *serverVersions
+	shadow           *RPCClient
+	subscribeOnce    sync.Once
+	subscribeErr     error
+	inflight         int
+	drained          chan struct{}
+	draining         core.Fuse
 	closed           core.Fuse
 }
 
@@ -58,19 +86,55 @@ func NewRPCClient(
 		bus:               b,
 		claimRequests:     make(map[string]chan *internal.ClaimRequest),
 		responseChannels:  make(map[string]chan *internal.Response),
+		multiResponses:    make(map[string]<-chan struct{}),
 		streamChannels:    make(map[string]chan *internal.Stream),
+		pending:           make(map[string]psrpc.InFlightRequest),
+		counters:          newChannelCounters(),
+		leavingServers:    make(map[string]leavingServer),
+		versions:          newServerVersions(),
+		drained:           make(chan struct{}),
+		draining:          core.NewFuse(),
 		closed:            core.NewFuse(),
 	}
 	if c.ClientID != "" {
 		c.ID = c.ClientID
 	}
 
+	if c.ShadowService != "" {
+		shadow, err := NewRPCClient(&info.ServiceDefinition{
+			Name: c.ShadowService,
+			ID:   rand.NewClientID(),
+		}, b, append(opts, psrpc.WithClientShadow("", 0))...)
+		if err != nil {
+			return nil, err
+		}
+		c.shadow = shadow
+	}
+
+	if !c.LazySubscriptions {
+		if err := c.subscribe(); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// subscribe opens the response, claim and stream subscriptions on first use
+func (c *RPCClient) subscribe() error {
+	c.subscribeOnce.Do(func() {
+		c.subscribeErr = c.startSubscriptions()
+	})
+	return c.subscribeErr
+}
+
+func (c *RPCClient) startSubscriptions() error {
 	ctx := context.Background()
 	responses, err := bus.Subscribe[*internal.Response](
 		ctx, c.bus, info.GetResponseChannel(c.Name, c.ID), c.ChannelSize,
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	claims, err := bus.Subscribe[*internal.ClaimRequest](
@@ -78,7 +142,16 @@ func NewRPCClient(
 	)
 	if err != nil {
 		_ = responses.Close()
-		return nil, err
+		return err
+	}
+
+	leaving, err := bus.Subscribe[*internal.ServerLeaving](
+		ctx, c.bus, info.GetServerLeavingChannel(c.Name), c.ChannelSize,
+	)
+	if err != nil {
+		_ = responses.Close()
+		_ = claims.Close()
+		return err
 	}
 
 	var streams bus.Subscription[*internal.Stream]
@@ -89,19 +162,31 @@ func NewRPCClient(
 		if err != nil {
 			_ = responses.Close()
 			_ = claims.Close()
-			return nil, err
+			_ = leaving.Close()
+			return err
 		}
 	} else {
 		streams = bus.EmptySubscription[*internal.Stream]{}
 	}
 
+	c.mu.Lock()
+	c.subscriptions = map[string]func() bus.ChannelStats{
+		responsesChannel: func() bus.ChannelStats { return bus.Stats(responses) },
+		claimsChannel:    func() bus.ChannelStats { return bus.Stats(claims) },
+		leavingChannel:   func() bus.ChannelStats { return bus.Stats(leaving) },
+		streamsChannel:   func() bus.ChannelStats { return bus.Stats(streams) },
+	}
+	c.mu.Unlock()
+
 	go func() {
 		closed := c.closed.Watch()
+		chunks := newResponseChunks()
 		for {
 			select {
 			case <-closed:
 				_ = claims.Close()
 				_ = responses.Close()
+				_ = leaving.Close()
 				_ = streams.Close()
 				return
 
@@ -112,21 +197,67 @@ func NewRPCClient(
 				}
 				c.mu.RLock()
 				claimChan, ok := c.claimRequests[claim.RequestId]
+				left, isLeaving := c.leavingServers[claim.ServerId]
 				c.mu.RUnlock()
+				if isLeaving && time.Now().Before(left.until) {
+					if !left.restarted(claim.InstanceId) {
+						continue
+					}
+					c.mu.Lock()
+					delete(c.leavingServers, claim.ServerId)
+					c.mu.Unlock()
+				}
+				c.versions.observe(claim.ServerId, claim.ProtocolVersion)
 				if ok {
-					claimChan <- claim
+					send(claimChan, claim, c.counters[claimsChannel], func() {
+						c.overflow(claimsChannel, claim.RequestId, claim.ServerId, psrpc.OverflowDropped)
+					})
+				} else {
+					c.overflow(claimsChannel, claim.RequestId, claim.ServerId, psrpc.OverflowUnregistered)
 				}
 
+			case msg := <-leaving.Channel():
+				if msg == nil {
+					c.Close()
+					continue
+				}
+				c.serverLeaving(msg)
+
 			case res := <-responses.Channel():
 				if res == nil {
 					c.Close()
 					continue
 				}
+				if res.ChunkCount > 1 {
+					// chunks for unknown requests are kept for the default timeout, and dropped once complete
+					expiry := time.Now().Add(c.Timeout)
+					c.mu.RLock()
+					if p, ok := c.pending[res.RequestId]; ok {
+						expiry = p.Deadline
+					}
+					c.mu.RUnlock()
+					if res = chunks.add(res, expiry); res == nil {
+						continue
+					}
+				}
+				c.versions.observe(res.ServerId, res.ProtocolVersion)
 				c.mu.RLock()
 				resChan, ok := c.responseChannels[res.RequestId]
+				multiDone, multi := c.multiResponses[res.RequestId]
 				c.mu.RUnlock()
-				if ok {
-					resChan <- res
+				if ok && multi {
+					c.stats.responsesReceived.Inc()
+					sendBlocking(resChan, res, c.counters[responsesChannel], multiDone, closed, func() {
+						c.overflow(responsesChannel, res.RequestId, res.ServerId, psrpc.OverflowBlocked)
+					})
+				} else if ok {
+					c.stats.responsesReceived.Inc()
+					send(resChan, res, c.counters[responsesChannel], func() {
+						c.overflow(responsesChannel, res.RequestId, res.ServerId, psrpc.OverflowDropped)
+					})
+				} else {
+					logger.Info("dropping response for unknown request", "requestID", res.RequestId, "serverID", res.ServerId)
+					c.overflow(responsesChannel, res.RequestId, res.ServerId, psrpc.OverflowUnregistered)
 				}
 
 			case msg := <-streams.Channel():
@@ -138,15 +269,345 @@ func NewRPCClient(
 				streamChan, ok := c.streamChannels[msg.StreamId]
 				c.mu.RUnlock()
 				if ok {
-					streamChan <- msg
+					send(streamChan, msg, c.counters[streamsChannel], func() {
+						c.overflow(streamsChannel, msg.StreamId, "", psrpc.OverflowDropped)
+					})
+				} else {
+					logger.Info("dropping message for unknown stream", "streamID", msg.StreamId)
+					c.overflow(streamsChannel, msg.StreamId, "", psrpc.OverflowUnregistered)
 				}
 			}
 		}
 	}()
 
-	return c, nil
+	return nil
+}
+
+const (
+	responsesChannel = "responses"
+	claimsChannel    = "claims"
+	leavingChannel   = "leaving"
+	streamsChannel   = "streams"
+)
+
+var clientChannels = []string{responsesChannel, claimsChannel, leavingChannel, streamsChannel}
+
+// channelCounters count messages for requests and streams that could not be delivered
+type channelCounters struct {
+	blocked atomic.Uint64
+	dropped atomic.Uint64
+}
+
+func newChannelCounters() map[string]*channelCounters {
+	counters := make(map[string]*channelCounters, len(clientChannels))
+	for _, name := range clientChannels {
+		counters[name] = &channelCounters{}
+	}
+	return counters
+}
+
+// send drops msg if ch is full, so one slow request or stream does not stall the dispatch of every other message
+func send[T any](ch chan T, msg T, counters *channelCounters, onDrop func()) {
+	select {
+	case ch <- msg:
+	default:
+		counters.dropped.Inc()
+		onDrop()
+	}
+}
+
+// sendBlocking waits for room in ch until done or closed, counting sends that found ch full. Multi-rpc responses are
+// delivered this way, so the caller's backpressure policy decides what happens to responses it is slow to read
+func sendBlocking[T any](ch chan T, msg T, counters *channelCounters, done, closed <-chan struct{}, onBlocked func()) {
+	select {
+	case ch <- msg:
+		return
+	default:
+	}
+	counters.blocked.Inc()
+	onBlocked()
+	select {
+	case ch <- msg:
+	case <-done:
+	case <-closed:
+	}
+}
+
+func (c *RPCClient) overflow(channel, requestID, remoteID string, reason psrpc.OverflowReason) {
+	if c.OnOverflow != nil {
+		c.OnOverflow(psrpc.Overflow{
+			Channel:   channel,
+			RequestID: requestID,
+			RemoteID:  remoteID,
+			Reason:    reason,
+		})
+	}
+}
+
+func (c *RPCClient) payloadSize(i psrpc.RPCInfo, response bool, raw []byte, ref string) {
+	if c.OnPayloadSize != nil {
+		c.OnPayloadSize(psrpc.PayloadSize{
+			RPCInfo:  i,
+			Response: response,
+			Size:     len(raw) + len(ref),
+		})
+	}
+}
+
+type clientStats struct {
+	requestsSent      atomic.Uint64
+	responsesReceived atomic.Uint64
+	timeouts          atomic.Uint64
+	subscriptions     atomic.Int64
+}
+
+// Stats returns a snapshot of the client's counters
+func (c *RPCClient) Stats() psrpc.ClientStats {
+	c.mu.RLock()
+	pending := len(c.pending)
+	c.mu.RUnlock()
+
+	return psrpc.ClientStats{
+		RequestsSent:        c.stats.requestsSent.Load(),
+		ResponsesReceived:   c.stats.responsesReceived.Load(),
+		Timeouts:            c.stats.timeouts.Load(),
+		PendingRequests:     pending,
+		ActiveSubscriptions: int(c.stats.subscriptions.Load()),
+	}
+}
+
+// StatsVar returns the client's stats as an expvar.Var, to be added with expvar.Publish
+func (c *RPCClient) StatsVar() expvar.Var {
+	return expvar.Func(func() any { return c.Stats() })
+}
+
+// ChannelStats returns the backlog of the response, claim, server leaving and stream subscriptions.
+// Messages blocked or dropped while being delivered to individual requests and streams are included
+func (c *RPCClient) ChannelStats() []psrpc.ChannelStats {
+	c.mu.RLock()
+	subscriptions := c.subscriptions
+	c.mu.RUnlock()
+
+	stats := make([]psrpc.ChannelStats, 0, len(clientChannels))
+	for _, name := range clientChannels {
+		s := psrpc.ChannelStats{
+			Channel: name,
+			Blocked: c.counters[name].blocked.Load(),
+			Dropped: c.counters[name].dropped.Load(),
+		}
+		if fn, ok := subscriptions[name]; ok {
+			b := fn()
+			s.Depth = b.Depth
+			s.Capacity = b.Capacity
+			s.Blocked += b.Blocked
+			s.Dropped += b.Dropped
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+type leavingServer struct {
+	until      time.Time
+	instanceID string
+}
+
+// restarted reports whether a claim was sent by a new server reusing the id of one that is draining. Servers from
+// before instance ids cannot be told apart
+func (l leavingServer) restarted(instanceID string) bool {
+	return instanceID != "" && l.instanceID != "" && instanceID != l.instanceID
+}
+
+// claims from draining servers are ignored until their drain deadline passes, or until a new server with the same id
+// sends a claim
+func (c *RPCClient) serverLeaving(msg *internal.ServerLeaving) {
+	now := time.Now()
+	until := now.Add(serverLeavingTTL)
+	if msg.DrainDeadline != 0 {
+		until = time.Unix(0, msg.DrainDeadline)
+	}
+
+	c.versions.remove(msg.ServerId)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, l := range c.leavingServers {
+		if now.After(l.until) {
+			delete(c.leavingServers, id)
+		}
+	}
+	if now.Before(until) {
+		c.leavingServers[msg.ServerId] = leavingServer{until: until, instanceID: msg.InstanceId}
+	}
+}
+
+// newRequestID generates a request id, and records it for callers reading psrpc.OutgoingRequestID
+func (c *RPCClient) newRequestID(ctx context.Context) string {
+	requestID := c.RequestIDGenerator(ctx)
+	metadata.RecordRequestID(ctx, requestID)
+	return requestID
+}
+
+// PendingRequests returns the requests waiting for responses, oldest first
+func (c *RPCClient) PendingRequests() []psrpc.InFlightRequest {
+	c.mu.RLock()
+	requests := maps.Values(c.pending)
+	c.mu.RUnlock()
+
+	return sortInFlight(requests)
+}
+
+// PendingRequestsHandler writes the pending requests as json, for debugging stuck requests
+func (c *RPCClient) PendingRequestsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.PendingRequests()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func sortInFlight(requests []psrpc.InFlightRequest) []psrpc.InFlightRequest {
+	now := time.Now()
+	for i := range requests {
+		requests[i].Age = now.Sub(requests[i].StartedAt)
+	}
+	slices.SortFunc(requests, func(a, b psrpc.InFlightRequest) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	return requests
+}
+
+// encodePayload encodes request payloads with the client's codec, compressing them above the threshold when the
+// servers a request to serverID can reach are known to accept compression
+func (c *RPCClient) encodePayload(msg proto.Message, serverID string) ([]byte, string, bus.Compression, error) {
+	encode := bus.EncodePayload
+	if c.DeterministicMarshal {
+		encode = bus.EncodePayloadDeterministic
+	}
+	b, codec, err := encode(c.Codec, msg)
+	if err != nil {
+		return nil, "", bus.CompressionNone, psrpc.NewError(psrpc.MalformedRequest, err)
+	}
+	var accepted []string
+	if v, ok := c.versions.get(serverID); ok && bus.SupportsVersion(v, bus.CompressionVersion) {
+		accepted = bus.SupportedCompression
+	}
+	b, compression, err := bus.CompressPayload(c.Compression, c.CompressionThreshold, accepted, b)
+	if err != nil {
+		return nil, "", bus.CompressionNone, psrpc.NewError(psrpc.MalformedRequest, err)
+	}
+	return b, codec, compression, nil
+}
+
+// offloadPayload puts oversized request payloads in the blob store, then enforces the max message size.
+// Payloads are only sent by reference to servers that can load them
+func (c *RPCClient) offloadPayload(ctx context.Context, i *info.RequestInfo, req *internal.Request) error {
+	if bus.Offloads(c.BlobStore, c.BlobThreshold, req.RawRequest) {
+		if v := c.serverVersion(ctx, i, req.TargetServerId); !bus.SupportsVersion(v, bus.PayloadRefVersion) {
+			return psrpc.NewErrorf(psrpc.FailedPrecondition,
+				"request of %d bytes must be sent by reference, which server protocol version %d does not support", len(req.RawRequest), v)
+		}
+	}
+	b, ref, err := bus.OffloadPayload(ctx, c.BlobStore, c.BlobThreshold, bus.BlobKey(req.RequestId, "REQ"), bus.BlobTTL(req.Expiry), req.RawRequest)
+	if err != nil {
+		return psrpc.NewError(psrpc.Internal, err)
+	}
+	if c.MaxMessageSize > 0 && len(b) > c.MaxMessageSize {
+		return psrpc.NewErrorf(psrpc.ResourceExhausted,
+			"request of %d bytes exceeds max message size of %d bytes", len(b), c.MaxMessageSize)
+	}
+	req.RawRequest = b
+	req.PayloadRef = ref
+	return nil
+}
+
+func decodeResponse[ResponseType proto.Message](ctx context.Context, c *RPCClient, res *internal.Response) (ResponseType, error) {
+	var v ResponseType
+	b, err := bus.LoadPayload(ctx, c.BlobStore, res.PayloadRef, res.RawResponse)
+	if err != nil {
+		return v, err
+	}
+	b, err = bus.DecompressPayload(bus.Compression(res.Compression), b)
+	if err != nil {
+		return v, err
+	}
+	if c.StrictUnmarshal {
+		return bus.DecodePayloadStrict[ResponseType](res.Codec, b)
+	}
+	return bus.DecodePayload[ResponseType](res.Codec, b)
+}
+
+func (c *RPCClient) responseError(res *internal.Response) error {
+	details := bus.DeserializeErrorDetails(res.ErrorDetails)
+	if c.StrictUnmarshal {
+		for _, d := range details {
+			if a, ok := d.(*anypb.Any); ok {
+				return psrpc.NewErrorf(psrpc.MalformedResponse, "unknown error detail type %s", a.TypeUrl)
+			}
+		}
+	}
+	return psrpc.NewErrorFromResponse(res.Code, res.Error, details...)
+}
+
+func (c *RPCClient) cancelRequest(i *info.RequestInfo, requestID string) {
+	err := c.bus.Publish(context.Background(), i.GetCancelChannel(), &internal.Cancel{
+		RequestId: requestID,
+		ClientId:  c.ID,
+	})
+	if err != nil {
+		logger.Error(err, "failed to cancel request", "requestID", requestID)
+	}
+}
+
+func (c *RPCClient) startRequest() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.draining.IsBroken() {
+		return false
+	}
+	c.inflight++
+	return true
+}
+
+func (c *RPCClient) finishRequest() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inflight--
+	if c.inflight == 0 && c.draining.IsBroken() {
+		close(c.drained)
+	}
+}
+
+// Shutdown stops accepting new requests and closes the client once in-flight requests complete or ctx is done
+func (c *RPCClient) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	inflight := c.inflight
+	c.draining.Break()
+	c.mu.Unlock()
+
+	defer c.Close()
+
+	if inflight == 0 {
+		return nil
+	}
+	select {
+	case <-c.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (c *RPCClient) Close() {
+	if c.shadow != nil {
+		c.shadow.Close()
+	}
+	c.mu.Lock()
+	c.draining.Break()
+	c.mu.Unlock()
 	c.closed.Break()
 }
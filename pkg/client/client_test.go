@@ -23,6 +23,7 @@ import (
 
 	"github.com/livekit/psrpc"
 	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/pkg/info"
 )
 
 func TestAffinity(t *testing.T) {
@@ -56,6 +57,58 @@ func TestAffinity(t *testing.T) {
 	}, "5")
 }
 
+func TestLabelSelection(t *testing.T) {
+	testAffinity(t, psrpc.SelectionOpts{
+		AcceptFirstAvailable: true,
+		RequiredLabels:       map[string]string{"zone": "b"},
+	}, "2")
+
+	testAffinity(t, psrpc.SelectionOpts{
+		AffinityTimeout: time.Millisecond * 600,
+		PreferredLabels: map[string]string{"zone": "a"},
+	}, "3")
+
+	testAffinity(t, psrpc.SelectionOpts{
+		AcceptFirstAvailable: true,
+		ShortCircuitTimeout:  time.Millisecond * 400,
+		PreferredLabels:      map[string]string{"version": "canary"},
+	}, "3")
+
+	testAffinity(t, psrpc.SelectionOpts{
+		AcceptFirstAvailable: true,
+		PreferredLabels:      map[string]string{"version": "canary"},
+	}, "2")
+
+	// without a preferred server, selection falls back after DefaultAffinityShortCircuit
+	testAffinity(t, psrpc.SelectionOpts{
+		AffinityTimeout: time.Millisecond * 600,
+		PreferredLabels: map[string]string{"version": "missing"},
+	}, "2")
+}
+
+func TestCanarySelection(t *testing.T) {
+	canary := map[string]string{"version": "canary"}
+
+	testAffinity(t, psrpc.SelectionOpts{
+		AcceptFirstAvailable: true,
+		ShortCircuitTimeout:  time.Millisecond * 400,
+		CanaryLabels:         canary,
+		CanaryRatio:          1,
+	}, "3")
+
+	testAffinity(t, psrpc.SelectionOpts{
+		AcceptFirstAvailable: true,
+		CanaryLabels:         canary,
+		CanaryRatio:          0,
+	}, "1")
+
+	testAffinity(t, psrpc.SelectionOpts{
+		AffinityTimeout: time.Millisecond * 600,
+		CanaryLabels:    map[string]string{"version": "missing"},
+		CanaryRatio:     1,
+	}, "2")
+}
+
 func testAffinity(t *testing.T, opts psrpc.SelectionOpts, expectedID string) {
 	c := make(chan *internal.ClaimRequest, 100)
 	go func() {
@@ -63,32 +116,178 @@ func testAffinity(t *testing.T, opts psrpc.SelectionOpts, expectedID string) {
 			RequestId: "1",
 			ServerId:  "1",
 			Affinity:  0.1,
+			Labels:    map[string]string{"zone": "a"},
 		}
 		time.Sleep(time.Millisecond * 100)
 		c <- &internal.ClaimRequest{
 			RequestId: "1",
 			ServerId:  "2",
 			Affinity:  0.5,
+			Labels:    map[string]string{"zone": "b"},
 		}
 		time.Sleep(time.Millisecond * 200)
 		c <- &internal.ClaimRequest{
 			RequestId: "1",
 			ServerId:  "3",
 			Affinity:  0.7,
+			Labels:    map[string]string{"zone": "a", "version": "canary"},
 		}
 		c <- &internal.ClaimRequest{
 			RequestId: "1",
 			ServerId:  "4",
 			Affinity:  0.1,
+			Labels:    map[string]string{"zone": "b"},
 		}
 		time.Sleep(time.Millisecond * 200)
 		c <- &internal.ClaimRequest{
 			RequestId: "1",
 			ServerId:  "5",
 			Affinity:  0.9,
+			Labels:    map[string]string{"zone": "b"},
 		}
 	}()
-	serverID, err := selectServer(context.Background(), c, nil, opts)
+	serverID, _, err := selectServer(context.Background(), c, nil, opts, "")
 	require.NoError(t, err)
 	require.Equal(t, expectedID, serverID)
 }
+
+func TestSelectionStats(t *testing.T) {
+	c := make(chan *internal.ClaimRequest, 100)
+	c <- &internal.ClaimRequest{RequestId: "1", ServerId: "1", Affinity: 0.5}
+	c <- &internal.ClaimRequest{RequestId: "1", ServerId: "2", Affinity: 0.7}
+
+	serverID, stats, err := selectServer(context.Background(), c, nil, psrpc.SelectionOpts{
+		AffinityTimeout: time.Millisecond * 100,
+	}, "")
+	require.NoError(t, err)
+	require.Equal(t, "2", serverID)
+	require.Equal(t, selectionStats{claims: 2, affinityTimedOut: true}, stats)
+
+	c <- &internal.ClaimRequest{RequestId: "1", ServerId: "3", Affinity: 0.5}
+	serverID, stats, err = selectServer(context.Background(), c, nil, psrpc.SelectionOpts{
+		AcceptFirstAvailable: true,
+		AffinityTimeout:      time.Millisecond * 100,
+	}, "")
+	require.NoError(t, err)
+	require.Equal(t, "3", serverID)
+	require.Equal(t, selectionStats{claims: 1}, stats)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, stats, err = selectServer(ctx, c, nil, psrpc.SelectionOpts{}, "")
+	require.ErrorIs(t, err, psrpc.ErrNoResponse)
+	require.Equal(t, selectionStats{}, stats)
+}
+
+func TestRequestOptsDeadline(t *testing.T) {
+	i := &info.RequestInfo{}
+	opts := psrpc.ClientOpts{Timeout: time.Second}
+
+	o := getRequestOpts(context.Background(), i, opts)
+	require.Equal(t, time.Second, o.Timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	o = getRequestOpts(ctx, i, opts)
+	require.LessOrEqual(t, o.Timeout, time.Millisecond*100)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	o = getRequestOpts(ctx, i, opts, psrpc.WithRequestTimeout(time.Millisecond*200))
+	require.Equal(t, time.Millisecond*200, o.Timeout)
+}
+
+func TestRequestOptsMethodDefaults(t *testing.T) {
+	opts := getClientOpts(psrpc.WithClientMethodOptions("slow", psrpc.WithRequestTimeout(time.Minute)))
+
+	o := getRequestOpts(context.Background(), &info.RequestInfo{RPCInfo: psrpc.RPCInfo{Method: "slow"}}, opts)
+	require.Equal(t, time.Minute, o.Timeout)
+
+	o = getRequestOpts(context.Background(), &info.RequestInfo{RPCInfo: psrpc.RPCInfo{Method: "slow"}}, opts, psrpc.WithRequestTimeout(time.Second))
+	require.Equal(t, time.Second, o.Timeout)
+
+	o = getRequestOpts(context.Background(), &info.RequestInfo{RPCInfo: psrpc.RPCInfo{Method: "fast"}}, opts)
+	require.Equal(t, psrpc.DefaultClientTimeout, o.Timeout)
+}
+
+func TestServerLeaving(t *testing.T) {
+	bus := psrpc.NewLocalMessageBus()
+	c, err := NewRPCClient(&info.ServiceDefinition{Name: "test_server_leaving", ID: "client"}, bus)
+	require.NoError(t, err)
+	defer c.Close()
+
+	claimChan := make(chan *internal.ClaimRequest, 2)
+	c.mu.Lock()
+	c.claimRequests["request"] = claimChan
+	c.mu.Unlock()
+
+	ctx := context.Background()
+	err = bus.Publish(ctx, info.GetServerLeavingChannel(c.Name), &internal.ServerLeaving{
+		ServerId:      "leaving",
+		DrainDeadline: time.Now().Add(time.Minute).UnixNano(),
+		InstanceId:    "draining",
+	})
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	claims := []*internal.ClaimRequest{
+		{RequestId: "request", ServerId: "leaving", InstanceId: "draining"},
+		{RequestId: "request", ServerId: "active"},
+		// a server restarted with the same id
+		{RequestId: "request", ServerId: "leaving", InstanceId: "restarted"},
+	}
+	for _, claim := range claims {
+		err = bus.Publish(ctx, info.GetClaimRequestChannel(c.Name, c.ID), claim)
+		require.NoError(t, err)
+	}
+
+	for _, instanceID := range []string{"", "restarted"} {
+		select {
+		case claim := <-claimChan:
+			require.Equal(t, instanceID, claim.InstanceId)
+		case <-time.After(time.Second):
+			t.Fatal("claim not received")
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	require.Empty(t, c.leavingServers)
+}
+
+func TestServerVersions(t *testing.T) {
+	v := newServerVersions()
+	_, ok := v.get("")
+	require.False(t, ok)
+
+	v.observe("a", 2)
+	v.observe("b", 1)
+	version, ok := v.get("a")
+	require.True(t, ok)
+	require.Equal(t, uint32(2), version)
+
+	// requests that are not directed can reach the oldest server
+	version, _ = v.get("")
+	require.Equal(t, uint32(1), version)
+	version, _ = v.get("c")
+	require.Equal(t, uint32(1), version)
+
+	v.remove("b")
+	version, _ = v.get("")
+	require.Equal(t, uint32(2), version)
+}
+
+func TestResponseChunks(t *testing.T) {
+	chunks := newResponseChunks()
+	now := time.Now()
+
+	// incomplete responses are discarded once their own request expires
+	require.Nil(t, chunks.add(&internal.Response{RequestId: "short", Chunk: 0, ChunkCount: 2, RawResponse: []byte("a")}, now.Add(10*time.Millisecond)))
+	require.Nil(t, chunks.add(&internal.Response{RequestId: "long", Chunk: 0, ChunkCount: 2, RawResponse: []byte("a")}, now.Add(time.Minute)))
+	time.Sleep(20 * time.Millisecond)
+
+	require.Nil(t, chunks.add(&internal.Response{RequestId: "short", Chunk: 1, ChunkCount: 2, RawResponse: []byte("b")}, now.Add(time.Minute)))
+	res := chunks.add(&internal.Response{RequestId: "long", Chunk: 1, ChunkCount: 2, RawResponse: []byte("b")}, now.Add(time.Minute))
+	require.NotNil(t, res)
+	require.Equal(t, []byte("ab"), res.RawResponse)
+}
@@ -0,0 +1,51 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/pkg/info"
+)
+
+// GetServerHealth asks every server for the service to report its status. Servers that reject the health check are
+// listed with the error.
+// It returns once the request times out, unless the number of servers is set with psrpc.WithExpectedServers.
+func GetServerHealth(ctx context.Context, c *RPCClient, opts ...psrpc.RequestOption) ([]*psrpc.ServerHealth, error) {
+	c.RegisterMethod(info.HealthMethod, false, true, false, false)
+
+	responses, err := RequestAll[*internal.HealthResponse](ctx, c, info.HealthMethod, nil, &internal.HealthRequest{}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	health := make([]*psrpc.ServerHealth, 0, len(responses))
+	for _, res := range responses {
+		if res.Err != nil {
+			health = append(health, &psrpc.ServerHealth{ServerID: res.ServerID, Err: res.Err})
+			continue
+		}
+		health = append(health, &psrpc.ServerHealth{
+			ServerID: res.Result.ServerId,
+			Handlers: res.Result.Handlers,
+			InFlight: int(res.Result.InFlight),
+			Uptime:   time.Duration(res.Result.Uptime),
+		})
+	}
+	return health, nil
+}
@@ -16,6 +16,7 @@ package client
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"google.golang.org/protobuf/proto"
@@ -26,7 +27,6 @@ import (
 	"github.com/livekit/psrpc/internal/interceptors"
 	"github.com/livekit/psrpc/pkg/info"
 	"github.com/livekit/psrpc/pkg/metadata"
-	"github.com/livekit/psrpc/pkg/rand"
 )
 
 func RequestMulti[ResponseType proto.Message](
@@ -37,11 +37,22 @@ func RequestMulti[ResponseType proto.Message](
 	request proto.Message,
 	opts ...psrpc.RequestOption,
 ) (rChan <-chan *psrpc.Response[ResponseType], err error) {
-	if c.closed.IsBroken() {
+	ctx = metadata.NewContextWithRequestIDRecorder(ctx)
+	if c.draining.IsBroken() {
 		return nil, psrpc.ErrClientClosed
 	}
+	if err = c.subscribe(); err != nil {
+		return nil, psrpc.NewError(psrpc.Internal, err)
+	}
 
 	i := c.GetInfo(rpc, topic)
+	o := getRequestOpts(ctx, i, c.ClientOpts, opts...)
+	if o.TargetServerID != "" {
+		return nil, psrpc.NewErrorf(psrpc.InvalidArgument, "%s is a multi rpc and cannot target a single server", rpc)
+	}
+	if err = checkSelection(rpc, false, o); err != nil {
+		return nil, err
+	}
 
 	// request hooks
 	for _, hook := range c.RequestHooks {
@@ -52,14 +63,13 @@ func RequestMulti[ResponseType proto.Message](
 	m := &multiRPC[ResponseType]{
 		c:         c,
 		i:         i,
-		requestID: rand.NewRequestID(),
+		requestID: c.newRequestID(ctx),
 		resChan:   resChan,
+		done:      ctx.Done(),
+		finished:  make(chan struct{}),
 	}
 
-	reqInterceptors := getRequestInterceptors(
-		c.MultiRPCInterceptors,
-		getRequestOpts(i, c.ClientOpts, opts...).Interceptors,
-	)
+	reqInterceptors := getRequestInterceptors(c.MultiRPCInterceptors, o.Interceptors)
 	m.handler = interceptors.ChainClientInterceptors[psrpc.ClientMultiRPCHandler](
 		reqInterceptors, i, m,
 	)
@@ -74,44 +84,123 @@ func RequestMulti[ResponseType proto.Message](
 	return resChan, nil
 }
 
+// RequestMultiWithCancel is RequestMulti with a cancel func that closes the response channel immediately
+func RequestMultiWithCancel[ResponseType proto.Message](
+	ctx context.Context,
+	c *RPCClient,
+	rpc string,
+	topic []string,
+	request proto.Message,
+	opts ...psrpc.RequestOption,
+) (<-chan *psrpc.Response[ResponseType], context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	rChan, err := RequestMulti[ResponseType](ctx, c, rpc, topic, request, opts...)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return rChan, cancel, nil
+}
+
+// RequestAll collects every response to a multi RPC
+func RequestAll[ResponseType proto.Message](
+	ctx context.Context,
+	c *RPCClient,
+	rpc string,
+	topic []string,
+	request proto.Message,
+	opts ...psrpc.RequestOption,
+) ([]*psrpc.Response[ResponseType], error) {
+	rChan, err := RequestMulti[ResponseType](ctx, c, rpc, topic, request, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	failFast := getRequestOpts(ctx, c.GetInfo(rpc, topic), c.ClientOpts, opts...).FailFast
+
+	var responses []*psrpc.Response[ResponseType]
+	for res := range rChan {
+		if failFast && res.Err != nil {
+			// drain late responses so the request can complete
+			go func() {
+				for range rChan {
+				}
+			}()
+			return responses, res.Err
+		}
+		responses = append(responses, res)
+	}
+	return responses, nil
+}
+
 type multiRPC[ResponseType proto.Message] struct {
 	c         *RPCClient
 	i         *info.RequestInfo
 	requestID string
 	handler   psrpc.ClientMultiRPCHandler
-	resChan   chan<- *psrpc.Response[ResponseType]
+	resChan   chan *psrpc.Response[ResponseType]
+	done      <-chan struct{}
+	finished  chan struct{}
+	failed    bool
+	serverID  string // sender of the response being received
 }
 
 func (m *multiRPC[ResponseType]) Send(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) error {
-	o := getRequestOpts(m.i, m.c.ClientOpts, opts...)
+	o := getRequestOpts(ctx, m.i, m.c.ClientOpts, opts...)
 
-	b, err := bus.SerializePayload(req)
+	b, codec, compression, err := m.c.encodePayload(req, "")
 	if err != nil {
-		return psrpc.NewError(psrpc.MalformedRequest, err)
+		return err
 	}
 
 	now := time.Now()
 	ir := &internal.Request{
-		RequestId:  m.requestID,
-		ClientId:   m.c.ID,
-		SentAt:     now.UnixNano(),
-		Expiry:     now.Add(o.Timeout).UnixNano(),
-		Multi:      true,
-		RawRequest: b,
-		Metadata:   metadata.OutgoingContextMetadata(ctx),
+		RequestId:         m.requestID,
+		ClientId:          m.c.ID,
+		SentAt:            now.UnixNano(),
+		Expiry:            now.Add(o.Timeout).UnixNano(),
+		Multi:             true,
+		RawRequest:        b,
+		Codec:             codec,
+		Compression:       string(compression),
+		AcceptCompression: bus.SupportedCompression,
+		Metadata:          metadata.OutgoingContextMetadata(ctx),
+		Priority:          o.Priority,
+		ProtocolVersion:   bus.ProtocolVersion,
+		Topic:             m.i.Topic,
+		RequiredLabels:    o.SelectionOpts.RequiredLabels,
+	}
+	if err = m.c.offloadPayload(ctx, m.i, ir); err != nil {
+		return err
+	}
+
+	if !m.c.startRequest() {
+		return psrpc.ErrClientClosed
 	}
 
 	resChan := make(chan *internal.Response, m.c.ChannelSize)
 
 	m.c.mu.Lock()
 	m.c.responseChannels[m.requestID] = resChan
+	m.c.multiResponses[m.requestID] = m.finished
+	m.c.pending[m.requestID] = psrpc.InFlightRequest{
+		RPCInfo:   m.i.RPCInfo,
+		RequestID: m.requestID,
+		StartedAt: now,
+		Deadline:  now.Add(o.Timeout),
+	}
 	m.c.mu.Unlock()
 
-	go m.handleResponses(ctx, req, resChan, o)
-
 	if err = m.c.bus.Publish(ctx, m.i.GetRPCChannel(), ir); err != nil {
+		m.Close()
+		m.c.finishRequest()
 		return psrpc.NewError(psrpc.Internal, err)
 	}
+	m.c.stats.requestsSent.Inc()
+	m.c.payloadSize(m.i.RPCInfo, false, ir.RawRequest, ir.PayloadRef)
+
+	// responses arriving before the handler starts wait in resChan
+	go m.handleResponses(ctx, req, resChan, o)
 
 	return nil
 }
@@ -122,16 +211,20 @@ func (m *multiRPC[ResponseType]) handleResponses(
 	resChan chan *internal.Response,
 	opts psrpc.RequestOpts,
 ) {
+	defer m.c.finishRequest()
+
 	timer := time.NewTimer(opts.Timeout)
+	var responses, successes int
 	for {
 		select {
 		case res := <-resChan:
 			var v ResponseType
 			var err error
 			if res.Error != "" {
-				err = psrpc.NewErrorFromResponse(res.Code, res.Error)
+				err = m.c.responseError(res)
 			} else {
-				v, err = bus.DeserializePayload[ResponseType](res.RawResponse)
+				m.c.payloadSize(m.i.RPCInfo, true, res.RawResponse, res.PayloadRef)
+				v, err = decodeResponse[ResponseType](ctx, m.c, res)
 				if err != nil {
 					err = psrpc.NewError(psrpc.MalformedResponse, err)
 				}
@@ -142,13 +235,34 @@ func (m *multiRPC[ResponseType]) handleResponses(
 				hook(ctx, req, m.i.RPCInfo, v, err)
 			}
 
+			// interceptors pass responses on synchronously, so Recv sees the sender
+			m.serverID = res.ServerId
 			m.handler.Recv(v, err)
+			if m.failed {
+				timer.Stop()
+				m.handler.Close()
+				return
+			}
+
+			responses++
+			if err == nil {
+				successes++
+			}
+			if (opts.Quorum > 0 && successes >= opts.Quorum) ||
+				(opts.ExpectedServers > 0 && responses >= opts.ExpectedServers) {
+				timer.Stop()
+				m.handler.Close()
+				return
+			}
 
 		case <-timer.C:
 			m.handler.Close()
 			return
 
 		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				m.c.cancelRequest(m.i, m.requestID)
+			}
 			m.handler.Close()
 			return
 		}
@@ -156,15 +270,71 @@ func (m *multiRPC[ResponseType]) handleResponses(
 }
 
 func (m *multiRPC[ResponseType]) Recv(msg proto.Message, err error) {
-	m.resChan <- &psrpc.Response[ResponseType]{
-		Result: msg.(ResponseType),
-		Err:    err,
+	res := &psrpc.Response[ResponseType]{
+		Result:   msg.(ResponseType),
+		Err:      err,
+		ServerID: m.serverID,
+	}
+
+	switch m.c.Backpressure {
+	case psrpc.BackpressureDropOldest:
+		for {
+			select {
+			case m.resChan <- res:
+				return
+			default:
+			}
+			select {
+			case <-m.resChan:
+				m.dropped()
+			default:
+			}
+		}
+
+	case psrpc.BackpressureDropNewest:
+		select {
+		case m.resChan <- res:
+		default:
+			m.dropped()
+		}
+
+	case psrpc.BackpressureFail:
+		select {
+		case m.resChan <- res:
+		default:
+			// make room for the error so the caller sees why the request ended
+			m.dropped()
+			select {
+			case <-m.resChan:
+				m.dropped()
+			default:
+			}
+			select {
+			case m.resChan <- &psrpc.Response[ResponseType]{Err: psrpc.ErrSlowConsumer}:
+			case <-m.done:
+			}
+			m.failed = true
+		}
+
+	default:
+		// responses are dropped once the caller's context is done, even if the channel is not being read
+		select {
+		case m.resChan <- res:
+		case <-m.done:
+		}
 	}
 }
 
+func (m *multiRPC[ResponseType]) dropped() {
+	m.c.counters[responsesChannel].dropped.Inc()
+}
+
 func (m *multiRPC[ResponseType]) Close() {
 	m.c.mu.Lock()
 	delete(m.c.responseChannels, m.requestID)
+	delete(m.c.multiResponses, m.requestID)
+	delete(m.c.pending, m.requestID)
 	m.c.mu.Unlock()
+	close(m.finished)
 	close(m.resChan)
 }
@@ -0,0 +1,125 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/internal/bus"
+	"github.com/livekit/psrpc/internal/interceptors"
+	"github.com/livekit/psrpc/pkg/info"
+	"github.com/livekit/psrpc/pkg/metadata"
+)
+
+// RequestNone publishes a request without waiting for claims or a response.
+// Affinity routed RPCs need a claim round trip to pick a server, so they are not supported.
+func RequestNone(
+	ctx context.Context,
+	c *RPCClient,
+	rpc string,
+	topic []string,
+	request proto.Message,
+	opts ...psrpc.RequestOption,
+) (err error) {
+	ctx = metadata.NewContextWithRequestIDRecorder(ctx)
+	if c.draining.IsBroken() {
+		return psrpc.ErrClientClosed
+	}
+
+	i := c.GetInfo(rpc, topic)
+
+	// response hooks
+	defer func() {
+		for _, hook := range c.ResponseHooks {
+			hook(ctx, request, i.RPCInfo, nil, err)
+		}
+	}()
+
+	// request hooks
+	for _, hook := range c.RequestHooks {
+		hook(ctx, request, i.RPCInfo)
+	}
+
+	if i.AffinityEnabled {
+		return psrpc.NewErrorf(psrpc.InvalidArgument, "%s requires affinity and cannot be sent without a response", rpc)
+	}
+
+	o := getRequestOpts(ctx, i, c.ClientOpts, opts...)
+	if o.TargetServerID != "" && i.Multi {
+		return psrpc.NewErrorf(psrpc.InvalidArgument, "%s is a multi rpc and cannot target a single server", rpc)
+	}
+	if err = checkSelection(rpc, false, o); err != nil {
+		return err
+	}
+
+	reqInterceptors := getRequestInterceptors(c.RpcInterceptors, o.Interceptors)
+	handler := interceptors.ChainClientInterceptors[psrpc.ClientRPCHandler](
+		reqInterceptors, i, newNotification(c, i),
+	)
+
+	_, err = handler(ctx, request, opts...)
+	return
+}
+
+func newNotification(c *RPCClient, i *info.RequestInfo) psrpc.ClientRPCHandler {
+	return func(ctx context.Context, request proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+		o := getRequestOpts(ctx, i, c.ClientOpts, opts...)
+
+		b, codec, compression, err := c.encodePayload(request, o.TargetServerID)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		req := &internal.Request{
+			RequestId:       c.newRequestID(ctx),
+			ClientId:        c.ID,
+			SentAt:          now.UnixNano(),
+			Expiry:          now.Add(o.Timeout).UnixNano(),
+			Multi:           i.Multi,
+			RawRequest:      b,
+			Codec:           codec,
+			Compression:     string(compression),
+			Metadata:        metadata.OutgoingContextMetadata(ctx),
+			IdempotencyKey:  o.IdempotencyKey,
+			NoResponse:      true,
+			Priority:        o.Priority,
+			ProtocolVersion: bus.ProtocolVersion,
+			Topic:           i.Topic,
+			RequiredLabels:  o.SelectionOpts.RequiredLabels,
+		}
+		if err = c.offloadPayload(ctx, i, req); err != nil {
+			return nil, err
+		}
+
+		channel := i.GetRPCChannel()
+		if o.TargetServerID != "" {
+			req.TargetServerId = o.TargetServerID
+			channel = i.GetServerRPCChannel(o.TargetServerID)
+		}
+
+		if err = c.bus.Publish(ctx, channel, req); err != nil {
+			return nil, psrpc.NewError(psrpc.Internal, err)
+		}
+		c.stats.requestsSent.Inc()
+		c.payloadSize(i.RPCInfo, false, req.RawRequest, req.PayloadRef)
+		return nil, nil
+	}
+}
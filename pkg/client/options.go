@@ -15,11 +15,14 @@
 package client
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/livekit/psrpc"
 	"github.com/livekit/psrpc/internal/bus"
 	"github.com/livekit/psrpc/pkg/info"
+	"github.com/livekit/psrpc/pkg/rand"
 )
 
 func withStreams() psrpc.ClientOption {
@@ -37,10 +40,15 @@ func getClientOpts(opts ...psrpc.ClientOption) psrpc.ClientOpts {
 	for _, opt := range opts {
 		opt(o)
 	}
+	if o.RequestIDGenerator == nil {
+		o.RequestIDGenerator = func(context.Context) string {
+			return rand.NewRequestID()
+		}
+	}
 	return *o
 }
 
-func getRequestOpts(i *info.RequestInfo, options psrpc.ClientOpts, opts ...psrpc.RequestOption) psrpc.RequestOpts {
+func getRequestOpts(ctx context.Context, i *info.RequestInfo, options psrpc.ClientOpts, opts ...psrpc.RequestOption) psrpc.RequestOpts {
 	o := &psrpc.RequestOpts{
 		Timeout: options.Timeout,
 	}
@@ -56,13 +64,38 @@ func getRequestOpts(i *info.RequestInfo, options psrpc.ClientOpts, opts ...psrpc
 		}
 	}
 
+	for _, opt := range options.MethodOptions[i.Method] {
+		opt(o)
+	}
 	for _, opt := range opts {
 		opt(o)
 	}
 
+	// the caller's deadline takes precedence when it is earlier than the request timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if timeout := time.Until(deadline); timeout < o.Timeout {
+			o.Timeout = timeout
+		}
+	}
+
 	return *o
 }
 
+// preferred and canary servers are chosen among the servers claiming a request, so requests that are not claimed
+// cannot be sent to them. Required labels are also checked by servers
+func checkSelection(rpc string, claimed bool, o psrpc.RequestOpts) error {
+	if claimed {
+		return nil
+	}
+	if len(o.SelectionOpts.CanaryLabels) != 0 {
+		return psrpc.NewErrorf(psrpc.InvalidArgument, "%s is not claimed by servers and cannot be sent to canaries", rpc)
+	}
+	if len(o.SelectionOpts.PreferredLabels) != 0 {
+		return psrpc.NewErrorf(psrpc.InvalidArgument, "%s is not claimed by servers and cannot prefer labels", rpc)
+	}
+	return nil
+}
+
 func getRequestInterceptors[T psrpc.RequestInterceptor](base []T, as []any) []T {
 	if as == nil {
 		return base
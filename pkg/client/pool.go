@@ -0,0 +1,74 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"go.uber.org/atomic"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal/bus"
+	"github.com/livekit/psrpc/pkg/info"
+	"github.com/livekit/psrpc/pkg/rand"
+)
+
+// ClientPool spreads requests over several clients, each with its own response subscription
+type ClientPool struct {
+	clients []*RPCClient
+	next    atomic.Uint32
+}
+
+func NewClientPool(
+	serviceName string,
+	b bus.MessageBus,
+	size int,
+	opts ...psrpc.ClientOption,
+) (*ClientPool, error) {
+	if size < 1 {
+		size = 1
+	}
+
+	p := &ClientPool{
+		clients: make([]*RPCClient, 0, size),
+	}
+	for len(p.clients) < size {
+		c, err := NewRPCClient(&info.ServiceDefinition{
+			Name: serviceName,
+			ID:   rand.NewClientID(),
+		}, b, opts...)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.clients = append(p.clients, c)
+	}
+	return p, nil
+}
+
+func (p *ClientPool) RegisterMethod(name string, affinityEnabled, multi, requireClaim, queue bool) {
+	for _, c := range p.clients {
+		c.RegisterMethod(name, affinityEnabled, multi, requireClaim, queue)
+	}
+}
+
+// Next returns the clients in round robin order
+func (p *ClientPool) Next() *RPCClient {
+	return p.clients[int(p.next.Inc()-1)%len(p.clients)]
+}
+
+func (p *ClientPool) Close() {
+	for _, c := range p.clients {
+		c.Close()
+	}
+}
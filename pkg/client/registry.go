@@ -0,0 +1,206 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frostbyte73/core"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/internal/bus"
+	"github.com/livekit/psrpc/pkg/info"
+)
+
+// ServerRegistry tracks live servers from the heartbeats they publish with psrpc.WithServerHeartbeat.
+// Heartbeats are off by default, so servers started without the option are never listed
+type ServerRegistry struct {
+	mu      sync.RWMutex
+	servers map[registryKey]*registryEntry
+	closed  core.Fuse
+}
+
+type registryKey struct {
+	service  string
+	serverID string
+}
+
+type registryEntry struct {
+	info   *psrpc.ServerInfo
+	expiry time.Time
+}
+
+// NewServerRegistry tracks the servers for one service
+func NewServerRegistry(service string, b bus.MessageBus) (*ServerRegistry, error) {
+	ctx := context.Background()
+	heartbeats, err := bus.Subscribe[*internal.ServerHeartbeat](ctx, b, info.GetHeartbeatChannel(service), bus.DefaultChannelSize)
+	if err != nil {
+		return nil, err
+	}
+	leaving, err := bus.Subscribe[*internal.ServerLeaving](ctx, b, info.GetServerLeavingChannel(service), bus.DefaultChannelSize)
+	if err != nil {
+		_ = heartbeats.Close()
+		return nil, err
+	}
+	return newServerRegistry(heartbeats, leaving), nil
+}
+
+// NewDeploymentRegistry tracks the servers for every service sharing the bus
+func NewDeploymentRegistry(b bus.MessageBus) (*ServerRegistry, error) {
+	heartbeats, err := bus.Subscribe[*internal.ServerHeartbeat](context.Background(), b, info.GetDeploymentHeartbeatChannel(), bus.DefaultChannelSize)
+	if err != nil {
+		return nil, err
+	}
+	return newServerRegistry(heartbeats, bus.EmptySubscription[*internal.ServerLeaving]{}), nil
+}
+
+func newServerRegistry(
+	heartbeats bus.Subscription[*internal.ServerHeartbeat],
+	leaving bus.Subscription[*internal.ServerLeaving],
+) *ServerRegistry {
+	r := &ServerRegistry{
+		servers: make(map[registryKey]*registryEntry),
+		closed:  core.NewFuse(),
+	}
+
+	go func() {
+		closed := r.closed.Watch()
+		for {
+			select {
+			case <-closed:
+				_ = heartbeats.Close()
+				_ = leaving.Close()
+				return
+
+			case hb := <-heartbeats.Channel():
+				if hb == nil {
+					r.Close()
+					continue
+				}
+				r.update(hb)
+
+			case msg := <-leaving.Channel():
+				if msg == nil {
+					r.Close()
+					continue
+				}
+				r.mu.Lock()
+				maps.DeleteFunc(r.servers, func(k registryKey, _ *registryEntry) bool {
+					return k.serverID == msg.ServerId
+				})
+				r.mu.Unlock()
+			}
+		}
+	}()
+
+	return r
+}
+
+func (r *ServerRegistry) update(hb *internal.ServerHeartbeat) {
+	key := registryKey{service: hb.Service, serverID: hb.ServerId}
+
+	// heartbeats expire relative to when they are received, so servers with skewed clocks are tracked correctly.
+	// the ttl is the difference between the server's own timestamps
+	now := time.Now()
+	ttl := time.Duration(hb.Expiry - hb.SentAt)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if ttl <= 0 {
+		delete(r.servers, key)
+		return
+	}
+
+	si := &psrpc.ServerInfo{
+		ServerID: hb.ServerId,
+		Service:  hb.Service,
+		Handlers: hb.Handlers,
+		Labels:   hb.Labels,
+		InFlight: int(hb.InFlight),
+		LastSeen: now,
+	}
+	for _, s := range hb.HandlerStats {
+		si.Metrics = append(si.Metrics, psrpc.HandlerMetrics{
+			RPCInfo: psrpc.RPCInfo{
+				Service: hb.Service,
+				Method:  s.Method,
+				Topic:   s.Topic,
+				Multi:   s.Multi,
+			},
+			Active:    int(s.Active),
+			Completed: s.Completed,
+			Errored:   s.Errored,
+			Latency:   time.Duration(s.Latency),
+		})
+	}
+	r.servers[key] = &registryEntry{info: si, expiry: now.Add(ttl)}
+}
+
+// Server returns the latest heartbeat from a live server for service. Servers handling several services send a heartbeat
+// for each of them
+func (r *ServerRegistry) Server(service, serverID string) (*psrpc.ServerInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.servers[registryKey{service: service, serverID: serverID}]
+	if !ok || !time.Now().Before(e.expiry) {
+		return nil, false
+	}
+	return e.info, true
+}
+
+// Servers returns the latest heartbeat from each live server, sorted by service and server id
+func (r *ServerRegistry) Servers() []*psrpc.ServerInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	maps.DeleteFunc(r.servers, func(_ registryKey, e *registryEntry) bool {
+		return !now.Before(e.expiry)
+	})
+
+	servers := make([]*psrpc.ServerInfo, 0, len(r.servers))
+	for _, e := range r.servers {
+		servers = append(servers, e.info)
+	}
+	slices.SortFunc(servers, func(a, b *psrpc.ServerInfo) int {
+		if a.Service != b.Service {
+			return strings.Compare(a.Service, b.Service)
+		}
+		return strings.Compare(a.ServerID, b.ServerID)
+	})
+	return servers
+}
+
+// ServeHTTP writes the live servers as json, for operational tooling
+func (r *ServerRegistry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(r.Servers()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (r *ServerRegistry) Close() {
+	r.closed.Break()
+}
@@ -17,8 +17,10 @@ package client
 import (
 	"context"
 	"errors"
+	"math/rand"
 	"time"
 
+	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/livekit/psrpc"
@@ -27,7 +29,6 @@ import (
 	"github.com/livekit/psrpc/internal/interceptors"
 	"github.com/livekit/psrpc/pkg/info"
 	"github.com/livekit/psrpc/pkg/metadata"
-	"github.com/livekit/psrpc/pkg/rand"
 )
 
 func RequestSingle[ResponseType proto.Message](
@@ -38,10 +39,16 @@ func RequestSingle[ResponseType proto.Message](
 	request proto.Message,
 	opts ...psrpc.RequestOption,
 ) (response ResponseType, err error) {
-	if c.closed.IsBroken() {
+	ctx = metadata.NewContextWithRequestIDRecorder(ctx)
+	if !c.startRequest() {
 		err = psrpc.ErrClientClosed
 		return
 	}
+	defer c.finishRequest()
+	if err = c.subscribe(); err != nil {
+		err = psrpc.NewError(psrpc.Internal, err)
+		return
+	}
 
 	i := c.GetInfo(rpc, topic)
 
@@ -57,10 +64,21 @@ func RequestSingle[ResponseType proto.Message](
 		hook(ctx, request, i.RPCInfo)
 	}
 
-	reqInterceptors := getRequestInterceptors(
-		c.RpcInterceptors,
-		getRequestOpts(i, c.ClientOpts, opts...).Interceptors,
-	)
+	o := getRequestOpts(ctx, i, c.ClientOpts, opts...)
+	if err = checkSelection(rpc, i.RequireClaim && !i.Queue, o); err != nil {
+		return
+	}
+	if o.ResponseInfo != nil {
+		*o.ResponseInfo = psrpc.ResponseInfo{}
+		start := time.Now()
+		defer func() {
+			o.ResponseInfo.Latency = time.Since(start)
+		}()
+	}
+
+	shadowRequest[ResponseType](ctx, c, i, request, opts...)
+
+	reqInterceptors := getRequestInterceptors(c.RpcInterceptors, o.Interceptors)
 	handler := interceptors.ChainClientInterceptors[psrpc.ClientRPCHandler](
 		reqInterceptors, i, newRPC[ResponseType](c, i),
 	)
@@ -75,63 +93,111 @@ func RequestSingle[ResponseType proto.Message](
 
 func newRPC[ResponseType proto.Message](c *RPCClient, i *info.RequestInfo) psrpc.ClientRPCHandler {
 	return func(ctx context.Context, request proto.Message, opts ...psrpc.RequestOption) (response proto.Message, err error) {
-		o := getRequestOpts(i, c.ClientOpts, opts...)
+		o := getRequestOpts(ctx, i, c.ClientOpts, opts...)
+		if o.ResponseInfo != nil {
+			o.ResponseInfo.Attempts++
+		}
 
-		b, err := bus.SerializePayload(request)
+		b, codec, compression, err := c.encodePayload(request, o.TargetServerID)
 		if err != nil {
-			err = psrpc.NewError(psrpc.MalformedRequest, err)
 			return
 		}
 
-		requestID := rand.NewRequestID()
+		requestID := c.newRequestID(ctx)
+		if o.ResponseInfo != nil {
+			o.ResponseInfo.RequestID = requestID
+		}
 		now := time.Now()
+		var phases requestPhases
+		if c.SlowRequestThreshold > 0 {
+			defer c.reportSlowRequest(ctx, i, requestID, now, &phases, &err)
+		}
 		req := &internal.Request{
-			RequestId:  requestID,
-			ClientId:   c.ID,
-			SentAt:     now.UnixNano(),
-			Expiry:     now.Add(o.Timeout).UnixNano(),
-			Multi:      false,
-			RawRequest: b,
-			Metadata:   metadata.OutgoingContextMetadata(ctx),
+			RequestId:         requestID,
+			ClientId:          c.ID,
+			SentAt:            now.UnixNano(),
+			Expiry:            now.Add(o.Timeout).UnixNano(),
+			Multi:             false,
+			RawRequest:        b,
+			Codec:             codec,
+			Compression:       string(compression),
+			AcceptCompression: bus.SupportedCompression,
+			Metadata:          metadata.OutgoingContextMetadata(ctx),
+			IdempotencyKey:    o.IdempotencyKey,
+			TargetServerId:    o.TargetServerID,
+			Priority:          o.Priority,
+			SessionKey:        o.SessionKey,
+			ProtocolVersion:   bus.ProtocolVersion,
+			Topic:             i.Topic,
+			RequiredLabels:    o.SelectionOpts.RequiredLabels,
+		}
+		if err = c.offloadPayload(ctx, i, req); err != nil {
+			return
+		}
+
+		// directed requests skip the claim round trip
+		requireClaim := i.RequireClaim && o.TargetServerID == ""
+		channel := i.GetRPCChannel()
+		if o.TargetServerID != "" {
+			channel = i.GetServerRPCChannel(o.TargetServerID)
 		}
 
 		var claimChan chan *internal.ClaimRequest
 		resChan := make(chan *internal.Response, 1)
 
 		c.mu.Lock()
-		if i.RequireClaim {
+		if requireClaim {
 			claimChan = make(chan *internal.ClaimRequest, c.ChannelSize)
 			c.claimRequests[requestID] = claimChan
 		}
 		c.responseChannels[requestID] = resChan
+		c.pending[requestID] = psrpc.InFlightRequest{
+			RPCInfo:   i.RPCInfo,
+			RequestID: requestID,
+			RemoteID:  o.TargetServerID,
+			StartedAt: now,
+			Deadline:  now.Add(o.Timeout),
+		}
 		c.mu.Unlock()
 
 		defer func() {
 			c.mu.Lock()
-			if i.RequireClaim {
+			if requireClaim {
 				delete(c.claimRequests, requestID)
 			}
 			delete(c.responseChannels, requestID)
+			delete(c.pending, requestID)
 			c.mu.Unlock()
 		}()
 
-		if err = c.bus.Publish(ctx, i.GetRPCChannel(), req); err != nil {
+		if err = c.bus.Publish(ctx, channel, req); err != nil {
 			err = psrpc.NewError(psrpc.Internal, err)
 			return
 		}
+		c.stats.requestsSent.Inc()
+		c.payloadSize(i.RPCInfo, false, req.RawRequest, req.PayloadRef)
+		phases.publish = time.Since(now)
 
 		ctx, cancel := context.WithTimeout(ctx, o.Timeout)
 		defer cancel()
 
-		if i.RequireClaim {
-			serverID, err := selectServer(ctx, claimChan, resChan, o.SelectionOpts)
+		if requireClaim {
+			serverID, stats, err := selectServer(ctx, claimChan, resChan, o.SelectionOpts, o.SessionKey)
+			phases.serverID = serverID
+			phases.claim = time.Since(now) - phases.publish
+			if o.ResponseInfo != nil {
+				o.ResponseInfo.ClaimLatency = time.Since(now)
+				o.ResponseInfo.Claims = stats.claims
+				o.ResponseInfo.AffinityTimedOut = stats.affinityTimedOut
+			}
 			if err != nil {
 				return nil, err
 			}
 
 			if err = c.bus.Publish(ctx, i.GetClaimResponseChannel(), &internal.ClaimResponse{
-				RequestId: requestID,
-				ServerId:  serverID,
+				RequestId:       requestID,
+				ServerId:        serverID,
+				ProtocolVersion: bus.ProtocolVersion,
 			}); err != nil {
 				err = psrpc.NewError(psrpc.Internal, err)
 				return nil, err
@@ -140,10 +206,17 @@ func newRPC[ResponseType proto.Message](c *RPCClient, i *info.RequestInfo) psrpc
 
 		select {
 		case res := <-resChan:
+			phases.serverID = res.ServerId
+			phases.handler = time.Duration(res.HandlerDuration)
+			if o.ResponseInfo != nil {
+				o.ResponseInfo.ServerID = res.ServerId
+				o.ResponseInfo.ProtocolVersion = bus.NegotiateProtocolVersion(res.ProtocolVersion)
+			}
 			if res.Error != "" {
-				err = psrpc.NewErrorFromResponse(res.Code, res.Error)
+				err = c.responseError(res)
 			} else {
-				response, err = bus.DeserializePayload[ResponseType](res.RawResponse)
+				c.payloadSize(i.RPCInfo, true, res.RawResponse, res.PayloadRef)
+				response, err = decodeResponse[ResponseType](ctx, c, res)
 				if err != nil {
 					err = psrpc.NewError(psrpc.MalformedResponse, err)
 				}
@@ -152,8 +225,10 @@ func newRPC[ResponseType proto.Message](c *RPCClient, i *info.RequestInfo) psrpc
 		case <-ctx.Done():
 			err = ctx.Err()
 			if errors.Is(err, context.Canceled) {
+				c.cancelRequest(i, requestID)
 				err = psrpc.ErrRequestCanceled
 			} else if errors.Is(err, context.DeadlineExceeded) {
+				c.stats.timeouts.Inc()
 				err = psrpc.ErrRequestTimedOut
 			}
 		}
@@ -162,22 +237,82 @@ func newRPC[ResponseType proto.Message](c *RPCClient, i *info.RequestInfo) psrpc
 	}
 }
 
+type requestPhases struct {
+	serverID string
+	publish  time.Duration
+	claim    time.Duration
+	handler  time.Duration
+}
+
+func (c *RPCClient) reportSlowRequest(
+	ctx context.Context,
+	i *info.RequestInfo,
+	requestID string,
+	start time.Time,
+	phases *requestPhases,
+	err *error,
+) {
+	d := time.Since(start)
+	if d < c.SlowRequestThreshold {
+		return
+	}
+
+	r := psrpc.SlowRequest{
+		RPCInfo:   i.RPCInfo,
+		RequestID: requestID,
+		RemoteID:  phases.serverID,
+		Duration:  d,
+		Publish:   phases.publish,
+		Claim:     phases.claim,
+		Handler:   phases.handler,
+		Err:       *err,
+	}
+	// the response phase includes delivery both ways and time the server held the request before the handler ran
+	if rest := d - phases.publish - phases.claim - phases.handler; rest > 0 {
+		r.Response = rest
+	}
+	c.OnSlowRequest(ctx, r)
+}
+
+type selectionStats struct {
+	claims           int
+	affinityTimedOut bool   // selection ended when the affinity timeout expired
+	protocolVersion  uint32 // sent in the selected server's claim
+}
+
 func selectServer(
 	ctx context.Context,
 	claimChan chan *internal.ClaimRequest,
 	resChan chan *internal.Response,
 	opts psrpc.SelectionOpts,
-) (string, error) {
+	sessionKey string,
+) (string, selectionStats, error) {
+
+	if sessionKey != "" {
+		// keyed requests go to the server with the highest hashed affinity, so every claim is collected
+		opts.AcceptFirstAvailable = false
+		opts.MaximumAffinity = 0
+		if opts.ShortCircuitTimeout == 0 {
+			opts.ShortCircuitTimeout = psrpc.DefaultAffinityShortCircuit
+		}
+	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	var affinityTimedOut atomic.Bool
 	if opts.AffinityTimeout > 0 {
-		time.AfterFunc(opts.AffinityTimeout, cancel)
+		time.AfterFunc(opts.AffinityTimeout, func() {
+			affinityTimedOut.Store(true)
+			cancel()
+		})
 	}
 
 	serverID := ""
+	var version uint32
 	best := float32(0)
+	bestPreferred := false
+	canary := opts.CanaryRatio > 0 && rand.Float64() < opts.CanaryRatio
 	shorted := false
 	claims := 0
 	var resErr error
@@ -185,38 +320,57 @@ func selectServer(
 	for {
 		select {
 		case <-ctx.Done():
+			stats := selectionStats{claims: claims, affinityTimedOut: affinityTimedOut.Load(), protocolVersion: version}
 			if best > 0 {
-				return serverID, nil
+				return serverID, stats, nil
 			}
 			if resErr != nil {
-				return "", resErr
+				return "", stats, resErr
 			}
 			if claims == 0 {
-				return "", psrpc.ErrNoResponse
+				return "", stats, psrpc.ErrNoResponse
 			}
-			return "", psrpc.NewErrorf(psrpc.Unavailable, "no servers available (received %d responses)", claims)
+			return "", stats, psrpc.NewErrorf(psrpc.Unavailable, "no servers available (received %d responses)", claims)
 
 		case claim := <-claimChan:
 			claims++
-			if (opts.MinimumAffinity > 0 && claim.Affinity >= opts.MinimumAffinity && claim.Affinity > best) ||
-				(opts.MinimumAffinity <= 0 && claim.Affinity > best) {
-				if opts.AcceptFirstAvailable || opts.MaximumAffinity > 0 && claim.Affinity >= opts.MaximumAffinity {
-					return claim.ServerId, nil
+			if !info.HasLabels(claim.Labels, opts.RequiredLabels) {
+				continue
+			}
+
+			// preferred servers are selected over any other server, regardless of affinity
+			preferred := info.HasLabels(claim.Labels, opts.PreferredLabels)
+			if len(opts.CanaryLabels) != 0 {
+				preferred = preferred && info.HasLabels(claim.Labels, opts.CanaryLabels) == canary
+			}
+			eligible := claim.Affinity > 0 && (opts.MinimumAffinity <= 0 || claim.Affinity >= opts.MinimumAffinity)
+			better := preferred && !bestPreferred || preferred == bestPreferred && claim.Affinity > best
+			if eligible && better {
+				if preferred && (opts.AcceptFirstAvailable || opts.MaximumAffinity > 0 && claim.Affinity >= opts.MaximumAffinity) {
+					return claim.ServerId, selectionStats{claims: claims, protocolVersion: claim.ProtocolVersion}, nil
 				}
 
 				serverID = claim.ServerId
+				version = claim.ProtocolVersion
 				best = claim.Affinity
+				bestPreferred = preferred
 
-				if opts.ShortCircuitTimeout > 0 && !shorted {
+				// without a short circuit timeout, servers lacking the preferred labels are still only waited on
+				// for DefaultAffinityShortCircuit, rather than until the affinity timeout
+				shortCircuit := opts.ShortCircuitTimeout
+				if shortCircuit == 0 && !preferred {
+					shortCircuit = psrpc.DefaultAffinityShortCircuit
+				}
+				if shortCircuit > 0 && !shorted {
 					shorted = true
-					time.AfterFunc(opts.ShortCircuitTimeout, cancel)
+					time.AfterFunc(shortCircuit, cancel)
 				}
 			}
 
 		case res := <-resChan:
-			// will only happen with malformed requests
+			// will only happen with malformed or rejected requests
 			if res.Error != "" {
-				resErr = psrpc.NewErrorf(psrpc.ErrorCode(res.Code), res.Error)
+				resErr = psrpc.NewErrorFromResponse(res.Code, res.Error, bus.DeserializeErrorDetails(res.ErrorDetails)...)
 			}
 		}
 	}
@@ -0,0 +1,59 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"math/rand"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/pkg/info"
+	"github.com/livekit/psrpc/pkg/metadata"
+)
+
+// shadowRequest mirrors a sample of requests to the shadow service in the background
+func shadowRequest[ResponseType proto.Message](
+	ctx context.Context,
+	c *RPCClient,
+	i *info.RequestInfo,
+	request proto.Message,
+	opts ...psrpc.RequestOption,
+) {
+	if c.shadow == nil || rand.Float64() >= c.ShadowRatio {
+		return
+	}
+
+	si := *i
+	si.Service = c.shadow.Name
+
+	// the shadow request outlives the caller's context
+	ctx = metadata.NewContextWithOutgoingMetadata(context.Background(), metadata.OutgoingContextMetadata(ctx))
+
+	// options that refer to the primary request must not apply to the shadow
+	opts = append(opts[:len(opts):len(opts)], psrpc.WithTargetServer(""), psrpc.WithResponseInfo(nil))
+
+	go func() {
+		if err := c.shadow.subscribe(); err != nil {
+			return
+		}
+
+		res, err := newRPC[ResponseType](c.shadow, &si)(ctx, request, opts...)
+		for _, hook := range c.ResponseHooks {
+			hook(ctx, request, si.RPCInfo, res, err)
+		}
+	}()
+}
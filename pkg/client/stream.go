@@ -19,10 +19,12 @@ import (
 	"errors"
 	"time"
 
+	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/livekit/psrpc"
 	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/internal/bus"
 	"github.com/livekit/psrpc/internal/logger"
 	"github.com/livekit/psrpc/internal/stream"
 	"github.com/livekit/psrpc/pkg/info"
@@ -30,6 +32,59 @@ import (
 	"github.com/livekit/psrpc/pkg/rand"
 )
 
+// OpenServerStream sends req to a handler registered with server.RegisterServerStreamHandler and returns its responses
+func OpenServerStream[RequestType, ResponseType proto.Message](
+	ctx context.Context,
+	c *RPCClient,
+	rpc string,
+	topic []string,
+	req RequestType,
+	opts ...psrpc.RequestOption,
+) (psrpc.ResponseStream[ResponseType], error) {
+	stream, err := OpenStream[RequestType, ResponseType](ctx, c, rpc, topic, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = stream.Send(req); err != nil {
+		_ = stream.Close(err)
+		return nil, err
+	}
+	return stream, nil
+}
+
+// OpenClientStream opens a stream to a handler registered with server.RegisterClientStreamHandler
+func OpenClientStream[RequestType, ResponseType proto.Message](
+	ctx context.Context,
+	c *RPCClient,
+	rpc string,
+	topic []string,
+	opts ...psrpc.RequestOption,
+) (psrpc.RequestStream[RequestType, ResponseType], error) {
+	stream, err := OpenStream[RequestType, ResponseType](ctx, c, rpc, topic, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &requestStream[RequestType, ResponseType]{stream}, nil
+}
+
+type requestStream[RequestType, ResponseType proto.Message] struct {
+	psrpc.ClientStream[RequestType, ResponseType]
+}
+
+func (s *requestStream[RequestType, ResponseType]) CloseAndRecv() (ResponseType, error) {
+	var res ResponseType
+	if err := s.CloseSend(); err != nil {
+		_ = s.Close(err)
+		return res, err
+	}
+
+	res, ok := <-s.Channel()
+	if !ok {
+		return res, s.Err()
+	}
+	return res, nil
+}
+
 func OpenStream[SendType, RecvType proto.Message](
 	ctx context.Context,
 	c *RPCClient,
@@ -37,12 +92,24 @@ func OpenStream[SendType, RecvType proto.Message](
 	topic []string,
 	opts ...psrpc.RequestOption,
 ) (psrpc.ClientStream[SendType, RecvType], error) {
+	if err := c.subscribe(); err != nil {
+		return nil, psrpc.NewError(psrpc.Internal, err)
+	}
 
 	i := c.GetInfo(rpc, topic)
-	o := getRequestOpts(i, c.ClientOpts, opts...)
+	o := getRequestOpts(ctx, i, c.ClientOpts, opts...)
+
+	for _, hook := range c.StreamOpenHooks {
+		hctx, err := hook(ctx, i.RPCInfo)
+		if err != nil {
+			return nil, err
+		}
+		ctx = hctx
+	}
 
 	streamID := rand.NewStreamID()
-	requestID := rand.NewRequestID()
+	ctx = metadata.NewContextWithRequestIDRecorder(ctx)
+	requestID := c.newRequestID(ctx)
 	now := time.Now()
 	req := &internal.Stream{
 		StreamId:  streamID,
@@ -51,8 +118,10 @@ func OpenStream[SendType, RecvType proto.Message](
 		Expiry:    now.Add(o.Timeout).UnixNano(),
 		Body: &internal.Stream_Open{
 			Open: &internal.StreamOpen{
-				NodeId:   c.ID,
-				Metadata: metadata.OutgoingContextMetadata(ctx),
+				NodeId:     c.ID,
+				WindowSize: int32(c.StreamWindowSize),
+				Codec:      bus.CodecName(c.Codec),
+				Metadata:   metadata.OutgoingContextMetadata(ctx),
 			},
 		},
 	}
@@ -72,12 +141,22 @@ func OpenStream[SendType, RecvType proto.Message](
 	}()
 
 	ackChan := make(chan struct{})
+	adapter := &clientStream{c: c, i: i}
 	cs := stream.NewStream[SendType, RecvType](
 		ctx,
 		i,
 		streamID,
-		c.Timeout,
-		&clientStream{c: c, i: i},
+		stream.Options{
+			Timeout:           c.Timeout,
+			RecvWindow:        c.StreamWindowSize,
+			RetryInterval:     c.StreamRetryInterval,
+			KeepaliveInterval: c.StreamPingInterval,
+			KeepaliveTimeout:  c.StreamPingTimeout,
+			Backpressure:      c.Backpressure,
+			OnDrop:            func() { c.counters[streamsChannel].dropped.Inc() },
+			Codec:             c.Codec,
+		},
+		adapter,
 		getRequestInterceptors(c.StreamInterceptors, o.Interceptors),
 		make(chan RecvType, c.ChannelSize),
 		map[string]chan struct{}{requestID: ackChan},
@@ -94,15 +173,22 @@ func OpenStream[SendType, RecvType proto.Message](
 	}
 
 	if i.RequireClaim {
-		serverID, err := selectServer(ctx, claimChan, nil, o.SelectionOpts)
+		serverID, stats, err := selectServer(ctx, claimChan, nil, o.SelectionOpts, o.SessionKey)
 		if err != nil {
 			_ = cs.Close(err)
 			return nil, err
 		}
 
+		// once claimed, messages go only to the server handling the stream. Servers from before per server stream
+		// channels only receive them on the shared channel
+		if bus.SupportsVersion(stats.protocolVersion, bus.ServerStreamChannelVersion) {
+			adapter.serverID.Store(serverID)
+		}
+
 		if err = c.bus.Publish(ctx, i.GetClaimResponseChannel(), &internal.ClaimResponse{
-			RequestId: requestID,
-			ServerId:  serverID,
+			RequestId:       requestID,
+			ServerId:        serverID,
+			ProtocolVersion: bus.ProtocolVersion,
 		}); err != nil {
 			_ = cs.Close(err)
 			return nil, psrpc.NewError(psrpc.Internal, err)
@@ -154,12 +240,17 @@ func runClientStream[SendType, RecvType proto.Message](
 }
 
 type clientStream struct {
-	c *RPCClient
-	i *info.RequestInfo
+	c        *RPCClient
+	i        *info.RequestInfo
+	serverID atomic.String
 }
 
 func (s *clientStream) Send(ctx context.Context, msg *internal.Stream) (err error) {
-	if err = s.c.bus.Publish(ctx, s.i.GetStreamServerChannel(), msg); err != nil {
+	channel := s.i.GetStreamServerChannel()
+	if serverID := s.serverID.Load(); serverID != "" {
+		channel = info.GetStreamChannel(s.i.Service, serverID)
+	}
+	if err = s.c.bus.Publish(ctx, channel, msg); err != nil {
 		err = psrpc.NewError(psrpc.Internal, err)
 	}
 	return
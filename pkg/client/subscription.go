@@ -29,7 +29,7 @@ func Join[ResponseType proto.Message](
 	rpc string,
 	topic []string,
 ) (bus.Subscription[ResponseType], error) {
-	if c.closed.IsBroken() {
+	if c.draining.IsBroken() {
 		return nil, psrpc.ErrClientClosed
 	}
 
@@ -38,7 +38,7 @@ func Join[ResponseType proto.Message](
 	if err != nil {
 		return nil, psrpc.NewError(psrpc.Internal, err)
 	}
-	return sub, nil
+	return trackSubscription(c, sub), nil
 }
 
 func JoinQueue[ResponseType proto.Message](
@@ -47,7 +47,7 @@ func JoinQueue[ResponseType proto.Message](
 	rpc string,
 	topic []string,
 ) (bus.Subscription[ResponseType], error) {
-	if c.closed.IsBroken() {
+	if c.draining.IsBroken() {
 		return nil, psrpc.ErrClientClosed
 	}
 
@@ -56,5 +56,29 @@ func JoinQueue[ResponseType proto.Message](
 	if err != nil {
 		return nil, psrpc.NewError(psrpc.Internal, err)
 	}
-	return sub, nil
+	return trackSubscription(c, sub), nil
+}
+
+func trackSubscription[ResponseType proto.Message](c *RPCClient, sub bus.Subscription[ResponseType]) bus.Subscription[ResponseType] {
+	c.stats.subscriptions.Inc()
+	return bus.WithCloseHook(sub, func() { c.stats.subscriptions.Dec() })
+}
+
+// Publish sends a notification to the servers subscribed with server.Join or server.JoinQueue
+func Publish(
+	ctx context.Context,
+	c *RPCClient,
+	rpc string,
+	topic []string,
+	msg proto.Message,
+) error {
+	if c.draining.IsBroken() {
+		return psrpc.ErrClientClosed
+	}
+
+	i := c.GetInfo(rpc, topic)
+	if err := c.bus.Publish(ctx, i.GetRPCChannel(), msg); err != nil {
+		return psrpc.NewError(psrpc.Internal, err)
+	}
+	return nil
 }
@@ -0,0 +1,122 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/pkg/info"
+)
+
+// how long a server's protocol version is trusted after its last claim or response
+const serverVersionTTL = 5 * time.Minute
+
+// serverVersions records the protocol versions servers send in claims and responses
+type serverVersions struct {
+	mu       sync.Mutex
+	versions map[string]serverVersion
+}
+
+type serverVersion struct {
+	version uint32
+	seenAt  time.Time
+}
+
+func newServerVersions() *serverVersions {
+	return &serverVersions{versions: make(map[string]serverVersion)}
+}
+
+func (v *serverVersions) observe(serverID string, version uint32) {
+	if serverID == "" {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.versions[serverID] = serverVersion{version: version, seenAt: time.Now()}
+}
+
+func (v *serverVersions) remove(serverID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.versions, serverID)
+}
+
+// get returns the version of serverID, or the lowest version of any server seen recently when serverID is unknown,
+// since requests that are not directed can be handled by any of them
+func (v *serverVersions) get(serverID string) (uint32, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	if s, ok := v.versions[serverID]; ok && now.Sub(s.seenAt) < serverVersionTTL {
+		return s.version, true
+	}
+
+	var lowest uint32
+	found := false
+	for id, s := range v.versions {
+		if now.Sub(s.seenAt) >= serverVersionTTL {
+			delete(v.versions, id)
+			continue
+		}
+		if !found || s.version < lowest {
+			lowest = s.version
+			found = true
+		}
+	}
+	return lowest, found
+}
+
+// serverVersion returns the protocol version of the servers a request to serverID can reach, probing servers for i
+// when none are known. It returns 0 if no server answers
+func (c *RPCClient) serverVersion(ctx context.Context, i *info.RequestInfo, serverID string) uint32 {
+	if v, ok := c.versions.get(serverID); ok {
+		return v
+	}
+	c.probeServerVersions(ctx, i)
+	v, _ := c.versions.get(serverID)
+	return v
+}
+
+// probeServerVersions sends a probe and waits for the first server to answer, whose claim records its version
+func (c *RPCClient) probeServerVersions(ctx context.Context, i *info.RequestInfo) {
+	requestID := c.RequestIDGenerator(ctx)
+	claimChan := make(chan *internal.ClaimRequest, c.ChannelSize)
+	c.mu.Lock()
+	c.claimRequests[requestID] = claimChan
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.claimRequests, requestID)
+		c.mu.Unlock()
+	}()
+
+	if err := c.publishProbe(ctx, i, requestID); err != nil {
+		return
+	}
+
+	timeout := time.NewTimer(c.SelectionTimeout)
+	defer timeout.Stop()
+
+	select {
+	case <-claimChan:
+	case <-timeout.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/internal/bus"
+	"github.com/livekit/psrpc/pkg/info"
+)
+
+// WaitForServer blocks until a server handling rpc on topic answers a probe, or ctx is done.
+func WaitForServer(ctx context.Context, c *RPCClient, rpc string, topic []string) error {
+	if c.draining.IsBroken() {
+		return psrpc.ErrClientClosed
+	}
+	if err := c.subscribe(); err != nil {
+		return psrpc.NewError(psrpc.Internal, err)
+	}
+
+	i := c.GetInfo(rpc, topic)
+	requestID := c.RequestIDGenerator(ctx)
+
+	claimChan := make(chan *internal.ClaimRequest, c.ChannelSize)
+	c.mu.Lock()
+	c.claimRequests[requestID] = claimChan
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.claimRequests, requestID)
+		c.mu.Unlock()
+	}()
+
+	// probes are resent until a server comes up. They have their own channel, so servers that predate probes never
+	// mistake them for requests
+	ticker := time.NewTicker(c.SelectionTimeout)
+	defer ticker.Stop()
+
+	for {
+		if err := c.publishProbe(ctx, i, requestID); err != nil {
+			return psrpc.NewError(psrpc.Internal, err)
+		}
+
+		select {
+		case <-claimChan:
+			return nil
+
+		case <-ticker.C:
+
+		case <-ctx.Done():
+			err := ctx.Err()
+			if errors.Is(err, context.Canceled) {
+				return psrpc.ErrRequestCanceled
+			}
+			return psrpc.ErrRequestTimedOut
+		}
+	}
+}
+
+// publishProbe asks servers handling i to answer with a claim for requestID
+func (c *RPCClient) publishProbe(ctx context.Context, i *info.RequestInfo, requestID string) error {
+	now := time.Now()
+	return c.bus.Publish(ctx, i.GetProbeChannel(), &internal.Request{
+		RequestId:       requestID,
+		ClientId:        c.ID,
+		SentAt:          now.UnixNano(),
+		Expiry:          now.Add(c.SelectionTimeout).UnixNano(),
+		Probe:           true,
+		ProtocolVersion: bus.ProtocolVersion,
+		Topic:           i.Topic,
+	})
+}
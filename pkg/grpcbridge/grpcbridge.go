@@ -0,0 +1,327 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grpcbridge holds the helpers used by generated gRPC bridges, enabled with the grpc=true generator option
+package grpcbridge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+)
+
+// TopicMetadataKey is the gRPC metadata key holding the topics of bridged requests, one value per topic param
+const TopicMetadataKey = "psrpc-topic"
+
+// WithTopics adds topics to the outgoing gRPC metadata, for calling bridged rpcs that use topics
+func WithTopics(ctx context.Context, topics ...string) context.Context {
+	kv := make([]string, 0, len(topics)*2)
+	for _, t := range topics {
+		kv = append(kv, TopicMetadataKey, t)
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// IncomingTopics returns the topics of a gRPC request, which must have exactly n
+func IncomingTopics(ctx context.Context, n int) ([]string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	topics := md.Get(TopicMetadataKey)
+	if len(topics) != n {
+		return nil, psrpc.NewErrorf(psrpc.InvalidArgument, "expected %d %s metadata values, got %d", n, TopicMetadataKey, len(topics))
+	}
+	return topics, nil
+}
+
+// ForwardGRPCMetadata copies the incoming gRPC metadata to the outgoing psrpc metadata. Only the first value
+// of each key is kept, and reserved gRPC headers and topics are dropped
+func ForwardGRPCMetadata(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	kv := make([]string, 0, len(md)*2)
+	for k, v := range md {
+		if len(v) == 0 || k == TopicMetadataKey || k == "content-type" || k == "user-agent" ||
+			strings.HasPrefix(k, ":") || strings.HasPrefix(k, "grpc-") {
+			continue
+		}
+		kv = append(kv, k, v[0])
+	}
+	return psrpc.AppendToOutgoingContext(ctx, kv...)
+}
+
+// ForwardPSRPCMetadata copies the incoming psrpc metadata to the outgoing gRPC metadata
+func ForwardPSRPCMetadata(ctx context.Context) context.Context {
+	md := psrpc.IncomingMetadata(ctx)
+	if len(md) == 0 {
+		return ctx
+	}
+	return metadata.NewOutgoingContext(ctx, metadata.New(md))
+}
+
+// FromGRPCError converts errors returned by gRPC clients to psrpc errors
+func FromGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, _ := status.FromError(err)
+	return psrpc.NewErrorFromGRPCStatus(st)
+}
+
+// ServeUnary handles a gRPC request with a psrpc client
+func ServeUnary[RequestType, ResponseType proto.Message](
+	srv any,
+	ctx context.Context,
+	dec func(any) error,
+	interceptor grpc.UnaryServerInterceptor,
+	method string,
+	call func(context.Context, RequestType) (ResponseType, error),
+) (any, error) {
+	req := newMessage[RequestType]()
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+
+	handler := func(ctx context.Context, req any) (any, error) {
+		return call(ForwardGRPCMetadata(ctx), req.(RequestType))
+	}
+	if interceptor == nil {
+		return handler(ctx, req)
+	}
+	return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: method}, handler)
+}
+
+// ServeServerStream handles a server-streaming gRPC request with a psrpc client
+func ServeServerStream[RequestType, ResponseType proto.Message](
+	stream grpc.ServerStream,
+	open func(context.Context, RequestType) (psrpc.ResponseStream[ResponseType], error),
+) error {
+	req := newMessage[RequestType]()
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+
+	rs, err := open(ForwardGRPCMetadata(stream.Context()), req)
+	if err != nil {
+		return err
+	}
+	for res := range rs.Channel() {
+		if err := stream.SendMsg(res); err != nil {
+			_ = rs.Close(err)
+			return err
+		}
+	}
+	return streamErr(rs.Err())
+}
+
+// ServeClientStream handles a client-streaming gRPC request with a psrpc client
+func ServeClientStream[RequestType, ResponseType proto.Message](
+	stream grpc.ServerStream,
+	open func(context.Context) (psrpc.RequestStream[RequestType, ResponseType], error),
+) error {
+	rs, err := open(ForwardGRPCMetadata(stream.Context()))
+	if err != nil {
+		return err
+	}
+	for {
+		req := newMessage[RequestType]()
+		if err := stream.RecvMsg(req); err == io.EOF {
+			break
+		} else if err != nil {
+			_ = rs.Close(err)
+			return err
+		}
+		if err := rs.Send(req); err != nil {
+			return err
+		}
+	}
+
+	res, err := rs.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	return stream.SendMsg(res)
+}
+
+// ServeBidiStream handles a bidirectional gRPC stream with a psrpc client
+func ServeBidiStream[RequestType, ResponseType proto.Message](
+	stream grpc.ServerStream,
+	open func(context.Context) (psrpc.ClientStream[RequestType, ResponseType], error),
+) error {
+	cs, err := open(ForwardGRPCMetadata(stream.Context()))
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			req := newMessage[RequestType]()
+			if err := stream.RecvMsg(req); err == io.EOF {
+				_ = cs.CloseSend()
+				return
+			} else if err != nil {
+				_ = cs.Close(err)
+				return
+			}
+			if err := cs.Send(req); err != nil {
+				return
+			}
+		}
+	}()
+
+	for res := range cs.Channel() {
+		if err := stream.SendMsg(res); err != nil {
+			_ = cs.Close(err)
+			return err
+		}
+	}
+	return streamErr(cs.Err())
+}
+
+// Invoke forwards a psrpc request to a gRPC server
+func Invoke[RequestType, ResponseType proto.Message](
+	ctx context.Context,
+	cc grpc.ClientConnInterface,
+	method string,
+	req RequestType,
+) (ResponseType, error) {
+	res := newMessage[ResponseType]()
+	if err := cc.Invoke(ForwardPSRPCMetadata(ctx), method, req, res); err != nil {
+		var zero ResponseType
+		return zero, FromGRPCError(err)
+	}
+	return res, nil
+}
+
+// InvokeServerStream forwards a server-streaming psrpc request to a gRPC server
+func InvokeServerStream[RequestType, ResponseType proto.Message](
+	ctx context.Context,
+	cc grpc.ClientConnInterface,
+	method string,
+	req RequestType,
+	w psrpc.StreamWriter[ResponseType],
+) error {
+	ctx, cancel := context.WithCancel(ForwardPSRPCMetadata(ctx))
+	defer cancel()
+
+	cs, err := cc.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, method)
+	if err != nil {
+		return FromGRPCError(err)
+	}
+	// io.EOF means the server failed the stream, the status is returned by RecvMsg
+	if err := cs.SendMsg(req); err != nil && err != io.EOF {
+		return FromGRPCError(err)
+	}
+	if err := cs.CloseSend(); err != nil {
+		return FromGRPCError(err)
+	}
+	return recvResponses(cs, w.Send)
+}
+
+// InvokeClientStream forwards a client-streaming psrpc request to a gRPC server
+func InvokeClientStream[RequestType, ResponseType proto.Message](
+	ctx context.Context,
+	cc grpc.ClientConnInterface,
+	method string,
+	r psrpc.StreamReader[RequestType],
+) (ResponseType, error) {
+	ctx, cancel := context.WithCancel(ForwardPSRPCMetadata(ctx))
+	defer cancel()
+
+	var zero ResponseType
+	cs, err := cc.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true}, method)
+	if err != nil {
+		return zero, FromGRPCError(err)
+	}
+	for req := range r.Channel() {
+		if err := cs.SendMsg(req); err != nil {
+			break
+		}
+	}
+	if err := streamErr(r.Err()); err != nil {
+		return zero, err
+	}
+	if err := cs.CloseSend(); err != nil {
+		return zero, FromGRPCError(err)
+	}
+
+	res := newMessage[ResponseType]()
+	if err := cs.RecvMsg(res); err != nil {
+		return zero, FromGRPCError(err)
+	}
+	return res, nil
+}
+
+// InvokeBidiStream forwards a bidirectional psrpc stream to a gRPC server
+func InvokeBidiStream[RequestType, ResponseType proto.Message](
+	cc grpc.ClientConnInterface,
+	method string,
+	s psrpc.ServerStream[ResponseType, RequestType],
+) error {
+	ctx, cancel := context.WithCancel(ForwardPSRPCMetadata(s.Context()))
+	defer cancel()
+
+	cs, err := cc.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, method)
+	if err != nil {
+		return FromGRPCError(err)
+	}
+
+	go func() {
+		for req := range s.Channel() {
+			if err := cs.SendMsg(req); err != nil {
+				return
+			}
+		}
+		_ = cs.CloseSend()
+	}()
+
+	return recvResponses(cs, func(res ResponseType, opts ...psrpc.StreamOption) error {
+		return s.Send(res, opts...)
+	})
+}
+
+func recvResponses[ResponseType proto.Message](cs grpc.ClientStream, send func(ResponseType, ...psrpc.StreamOption) error) error {
+	for {
+		res := newMessage[ResponseType]()
+		if err := cs.RecvMsg(res); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return FromGRPCError(err)
+		}
+		if err := send(res); err != nil {
+			return err
+		}
+	}
+}
+
+// streamErr drops the error of streams closed by a successful handler, or by the peer without an error
+func streamErr(err error) error {
+	if errors.Is(err, psrpc.ErrStreamEOF) || errors.Is(err, psrpc.ErrStreamClosed) {
+		return nil
+	}
+	return err
+}
+
+func newMessage[T proto.Message]() T {
+	var m T
+	return m.ProtoReflect().New().Interface().(T)
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpgateway holds the helpers used by generated HTTP handlers, enabled with the http=true generator option
+package httpgateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+)
+
+// TopicParam is the query param holding the topics of requests, repeated once per topic param
+const TopicParam = "topic"
+
+type errorBody struct {
+	Code psrpc.ErrorCode `json:"code"`
+	Msg  string          `json:"msg"`
+}
+
+type multiResponse struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *errorBody      `json:"error,omitempty"`
+}
+
+type routes map[string]http.Handler
+
+// NewHandler serves the routes, keyed by path. Requests to other paths fail with NotFound
+func NewHandler(r map[string]http.Handler) http.Handler {
+	return routes(r)
+}
+
+func (r routes) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h, ok := r[req.URL.Path]
+	if !ok {
+		writeError(w, psrpc.NewErrorf(psrpc.NotFound, "no rpc at %s", req.URL.Path))
+		return
+	}
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeErrorStatus(w, http.StatusMethodNotAllowed, psrpc.NewErrorf(psrpc.InvalidArgument, "rpcs must be called with POST"))
+		return
+	}
+	h.ServeHTTP(w, req)
+}
+
+// Unary serves an rpc returning a single response
+func Unary[RequestType, ResponseType proto.Message](
+	topics int,
+	call func(context.Context, []string, RequestType) (ResponseType, error),
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, req, err := readRequest[RequestType](r, topics)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		res, err := call(r.Context(), t, req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		b, err := protojson.Marshal(res)
+		if err != nil {
+			writeError(w, psrpc.NewError(psrpc.MalformedResponse, err))
+			return
+		}
+		writeJSON(w, http.StatusOK, b)
+	})
+}
+
+// Multi serves a multi-rpc. The response is a JSON array with a result or an error from each server
+func Multi[RequestType, ResponseType proto.Message](
+	topics int,
+	call func(context.Context, []string, RequestType) (<-chan *psrpc.Response[ResponseType], error),
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t, req, err := readRequest[RequestType](r, topics)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		resChan, err := call(r.Context(), t, req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		responses := make([]multiResponse, 0)
+		for res := range resChan {
+			if res.Err != nil {
+				responses = append(responses, multiResponse{Error: newErrorBody(res.Err)})
+				continue
+			}
+			b, err := protojson.Marshal(res.Result)
+			if err != nil {
+				responses = append(responses, multiResponse{Error: newErrorBody(psrpc.NewError(psrpc.MalformedResponse, err))})
+				continue
+			}
+			responses = append(responses, multiResponse{Result: b})
+		}
+
+		b, _ := json.Marshal(responses)
+		writeJSON(w, http.StatusOK, b)
+	})
+}
+
+func readRequest[RequestType proto.Message](r *http.Request, topics int) ([]string, RequestType, error) {
+	var req RequestType
+	t := r.URL.Query()[TopicParam]
+	if len(t) != topics {
+		return nil, req, psrpc.NewErrorf(psrpc.InvalidArgument, "expected %d %s query params, got %d", topics, TopicParam, len(t))
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, req, psrpc.NewError(psrpc.MalformedRequest, err)
+	}
+	req = req.ProtoReflect().New().Interface().(RequestType)
+	if len(b) != 0 {
+		if err = protojson.Unmarshal(b, req); err != nil {
+			return nil, req, psrpc.NewError(psrpc.MalformedRequest, err)
+		}
+	}
+	return t, req, nil
+}
+
+func toError(err error) psrpc.Error {
+	var e psrpc.Error
+	if !errors.As(err, &e) {
+		e = psrpc.NewError(psrpc.Unknown, err)
+	}
+	return e
+}
+
+func newErrorBody(err error) *errorBody {
+	e := toError(err)
+	return &errorBody{Code: e.Code(), Msg: e.Error()}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e := toError(err)
+	writeErrorStatus(w, e.ToHttp(), e)
+}
+
+func writeErrorStatus(w http.ResponseWriter, status int, e psrpc.Error) {
+	b, _ := json.Marshal(newErrorBody(e))
+	writeJSON(w, status, b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, b []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
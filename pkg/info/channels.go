@@ -15,7 +15,10 @@
 package info
 
 import (
+	"strings"
 	"unicode"
+
+	"github.com/livekit/psrpc/internal/bus"
 )
 
 const lowerHex = "0123456789abcdef"
@@ -38,24 +41,90 @@ func GetStreamChannel(service, nodeID string) string {
 	return formatChannel(service, nodeID, "STR")
 }
 
+func GetServerLeavingChannel(service string) string {
+	return formatChannel(service, "LEAVE")
+}
+
+func GetHeartbeatChannel(service string) string {
+	return formatChannel(service, "HEARTBEAT")
+}
+
+// servers publish heartbeats for every service to the deployment channel as well
+func GetDeploymentHeartbeatChannel() string {
+	return formatChannel("psrpc", "HEARTBEAT")
+}
+
 func GetResponseChannel(service, clientID string) string {
 	return formatChannel(service, clientID, "RES")
 }
 
 func (i *RequestInfo) GetRPCChannel() string {
-	return formatChannel(i.Service, i.Method, i.Topic, "REQ")
+	return formatChannel(i.Service, i.Method, i.topic(), "REQ")
+}
+
+func (i *RequestInfo) GetServerRPCChannel(serverID string) string {
+	return formatChannel(i.Service, i.Method, i.topic(), serverID, "SREQ")
 }
 
 func (i *RequestInfo) GetHandlerKey() string {
-	return formatChannel(i.Method, i.Topic)
+	return formatChannel(i.Method, i.topicKey())
+}
+
+func (i *RequestInfo) GetServiceHandlerKey() string {
+	return formatChannel(i.Service, i.Method, i.topicKey())
 }
 
 func (i *RequestInfo) GetClaimResponseChannel() string {
-	return formatChannel(i.Service, i.Method, i.Topic, "RCLAIM")
+	return formatChannel(i.Service, i.Method, i.topic(), "RCLAIM")
+}
+
+func (i *RequestInfo) GetCancelChannel() string {
+	return formatChannel(i.Service, i.Method, i.topic(), "CANCEL")
+}
+
+func (i *RequestInfo) GetProbeChannel() string {
+	return formatChannel(i.Service, i.Method, i.topic(), "PROBE")
 }
 
 func (i *RequestInfo) GetStreamServerChannel() string {
-	return formatChannel(i.Service, i.Method, i.Topic, "STR")
+	return formatChannel(i.Service, i.Method, i.topic(), "STR")
+}
+
+// topicPattern is formatted like a topic, keeping * in its tokens as a wildcard
+type topicPattern []string
+
+// topicKey is formatted like a topic, keeping empty tokens so topics with tokens in different positions differ
+type topicKey []string
+
+func (i *RequestInfo) topic() any {
+	if i.TopicPattern {
+		return topicPattern(i.Topic)
+	}
+	return i.Topic
+}
+
+func (i *RequestInfo) topicKey() any {
+	if i.TopicPattern {
+		return topicPattern(i.Topic)
+	}
+	return topicKey(i.Topic)
+}
+
+// MatchTopic reports whether a request sent to topic is handled by i. Each * in a pattern token matches any
+// characters within that token
+func (i *RequestInfo) MatchTopic(topic []string) bool {
+	if !i.TopicPattern {
+		return formatChannel(topicKey(i.Topic)) == formatChannel(topicKey(topic))
+	}
+	if len(i.Topic) != len(topic) {
+		return false
+	}
+	for j, t := range i.Topic {
+		if !bus.MatchPattern(t, topic[j]) {
+			return false
+		}
+	}
+	return true
 }
 
 func formatChannel(parts ...any) string {
@@ -71,6 +140,10 @@ func channelPartsLen[T any](parts ...T) int {
 			n += len(v) + 1
 		case []string:
 			n += channelPartsLen(v...)
+		case topicPattern:
+			n += channelPartsLen(v...)
+		case topicKey:
+			n += channelPartsLen(v...)
 		}
 	}
 	return n
@@ -87,13 +160,48 @@ func appendChannelParts[T any](buf []byte, parts ...T) []byte {
 		case string:
 			buf = appendSanitizedChannelPart(buf, v)
 		case []string:
-			buf = appendChannelParts(buf, v...)
+			buf = appendTopicTokens(buf, v, appendSanitizedChannelPart)
+		case topicPattern:
+			buf = appendTopicTokens(buf, v, appendPatternChannelPart)
+		case topicKey:
+			for j, t := range v {
+				if j > 0 {
+					buf = append(buf, '|')
+				}
+				buf = appendSanitizedChannelPart(buf, t)
+			}
 		}
 		prefix = len(buf) > l
 	}
 	return buf
 }
 
+// appendTopicTokens leaves out empty tokens, so channel names match every release. Topics with tokens in different
+// positions can share a channel, and servers tell them apart by the topic sent in requests
+func appendTopicTokens(buf []byte, tokens []string, appendPart func([]byte, string) []byte) []byte {
+	var prefix bool
+	for _, t := range tokens {
+		if prefix {
+			buf = append(buf, '|')
+		}
+		l := len(buf)
+		buf = appendPart(buf, t)
+		prefix = len(buf) > l
+	}
+	return buf
+}
+
+// appendPatternChannelPart sanitizes the text between wildcards, which never contains bus pattern syntax
+func appendPatternChannelPart(buf []byte, s string) []byte {
+	for i, part := range strings.Split(s, "*") {
+		if i > 0 {
+			buf = append(buf, '*')
+		}
+		buf = appendSanitizedChannelPart(buf, part)
+	}
+	return buf
+}
+
 func appendSanitizedChannelPart(buf []byte, s string) []byte {
 	for _, r := range s {
 		if unicode.Is(channelChar, r) {
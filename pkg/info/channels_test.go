@@ -46,5 +46,39 @@ func TestChannelFormatters(t *testing.T) {
 	require.Equal(t, "foo|bar|a|b|c|RCLAIM", i.GetClaimResponseChannel())
 	require.Equal(t, "foo|bar|a|b|c|STR", i.GetStreamServerChannel())
 
+	// channels leave out empty tokens, handler keys keep them
+	i.Topic = []string{"a", "", ""}
+	require.Equal(t, "foo|bar|a||REQ", i.GetRPCChannel())
+	require.Equal(t, "bar|a||", i.GetHandlerKey())
+	i.Topic = []string{"", "a", ""}
+	require.Equal(t, "foo|bar|a||REQ", i.GetRPCChannel())
+	require.Equal(t, "bar||a|", i.GetHandlerKey())
+	require.False(t, i.MatchTopic([]string{"a", "", ""}))
+	require.True(t, i.MatchTopic([]string{"", "a", ""}))
+
 	require.Equal(t, "U+0001f680_u+00c9|U+0001f6f0_bar|u+8f6fu+4ef6|END", formatChannel("🚀_É", "🛰_bar", []string{"软件"}, "END"))
+
+	i.Topic = []string{"room.*"}
+	i.TopicPattern = true
+	require.Equal(t, "foo|bar|roomu+002e*|REQ", i.GetRPCChannel())
+	require.Equal(t, "bar|roomu+002e*", i.GetHandlerKey())
+	i.Topic = []string{"a", "*"}
+	require.Equal(t, "foo|bar|a|*|srv|SREQ", i.GetServerRPCChannel("srv"))
+}
+
+func TestMatchTopic(t *testing.T) {
+	i := &RequestInfo{RPCInfo: psrpc.RPCInfo{Topic: []string{"room.*"}}, TopicPattern: true}
+	require.True(t, i.MatchTopic([]string{"room.a"}))
+	require.True(t, i.MatchTopic([]string{"room."}))
+	require.False(t, i.MatchTopic([]string{"room"}))
+	require.False(t, i.MatchTopic([]string{"room.a", "b"}))
+	require.False(t, i.MatchTopic(nil))
+
+	i.Topic = []string{"*", "b"}
+	require.True(t, i.MatchTopic([]string{"a", "b"}))
+	require.False(t, i.MatchTopic([]string{"a", "c"}))
+
+	i.TopicPattern = false
+	require.False(t, i.MatchTopic([]string{"a", "b"}))
+	require.True(t, i.MatchTopic([]string{"*", "b"}))
 }
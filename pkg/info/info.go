@@ -20,6 +20,9 @@ import (
 	"github.com/livekit/psrpc"
 )
 
+// HealthMethod is registered by every server to report its status
+const HealthMethod = "psrpc_health"
+
 type ServiceDefinition struct {
 	Name    string
 	ID      string
@@ -38,6 +41,17 @@ type RequestInfo struct {
 	AffinityEnabled bool
 	RequireClaim    bool
 	Queue           bool
+	TopicPattern    bool // Topic tokens may contain * wildcards
+}
+
+// HasLabels reports whether labels include every label in selector
+func HasLabels(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if l, ok := labels[k]; !ok || l != v {
+			return false
+		}
+	}
+	return true
 }
 
 func (s *ServiceDefinition) RegisterMethod(name string, affinityEnabled, multi, requireClaim, queue bool) {
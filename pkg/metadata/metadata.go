@@ -18,15 +18,24 @@ import (
 	"context"
 	"time"
 
+	"go.uber.org/atomic"
 	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 )
 
 type Metadata map[string]string
 
+// Header describes the request being handled by a server
 type Header struct {
-	RemoteID string
-	SentAt   time.Time
-	Metadata Metadata
+	RemoteID  string
+	RequestID string
+	SentAt    time.Time
+	Metadata  Metadata
+	ServerID  string
+	Service   string
+	Method    string
+	Topic     []string
+	Multi     bool
 }
 
 type ctxMD struct {
@@ -36,6 +45,7 @@ type ctxMD struct {
 
 type headerKey struct{}
 type metadataKey struct{}
+type requestIDKey struct{}
 
 func NewContextWithIncomingHeader(ctx context.Context, head *Header) context.Context {
 	return context.WithValue(ctx, headerKey{}, head)
@@ -47,9 +57,15 @@ func IncomingHeader(ctx context.Context) *Header {
 		return nil
 	}
 	return &Header{
-		RemoteID: head.RemoteID,
-		SentAt:   head.SentAt,
-		Metadata: maps.Clone(head.Metadata),
+		RemoteID:  head.RemoteID,
+		RequestID: head.RequestID,
+		SentAt:    head.SentAt,
+		Metadata:  maps.Clone(head.Metadata),
+		ServerID:  head.ServerID,
+		Service:   head.Service,
+		Method:    head.Method,
+		Topic:     slices.Clone(head.Topic),
+		Multi:     head.Multi,
 	}
 }
 
@@ -82,3 +98,25 @@ func OutgoingContextMetadata(ctx context.Context) Metadata {
 	}
 	return clone
 }
+
+// NewContextWithRequestIDRecorder returns a context recording the id of the last request sent with it.
+// Contexts already recording ids are returned unchanged
+func NewContextWithRequestIDRecorder(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(requestIDKey{}).(*atomic.String); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, atomic.NewString(""))
+}
+
+func RecordRequestID(ctx context.Context, requestID string) {
+	if r, ok := ctx.Value(requestIDKey{}).(*atomic.String); ok {
+		r.Store(requestID)
+	}
+}
+
+func RecordedRequestID(ctx context.Context) string {
+	if r, ok := ctx.Value(requestIDKey{}).(*atomic.String); ok {
+		return r.Load()
+	}
+	return ""
+}
@@ -0,0 +1,528 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+)
+
+type Option func(*options)
+
+type options struct {
+	registerer  prometheus.Registerer
+	namespace   string
+	buckets     []float64
+	constLabels prometheus.Labels
+	topics      bool
+	topicLabel  func(topic []string) string
+	topicLimit  int
+	payloadRate float64
+}
+
+// WithRegisterer sets the registry metrics are added to, the default registerer is used by default
+func WithRegisterer(r prometheus.Registerer) Option {
+	return func(o *options) {
+		o.registerer = r
+	}
+}
+
+// WithNamespace sets the prefix of metric names, psrpc by default
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithBuckets sets the histogram buckets for latencies, in seconds
+func WithBuckets(buckets []float64) Option {
+	return func(o *options) {
+		o.buckets = buckets
+	}
+}
+
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(o *options) {
+		o.constLabels = labels
+	}
+}
+
+// WithTopicLabel enables the topic label, which is on by default. Disable it when topics have high cardinality
+func WithTopicLabel(enabled bool) Option {
+	return func(o *options) {
+		o.topics = enabled
+	}
+}
+
+// WithTopicNormalizer sets the topic label value for each request's topic, so related topics such as per-room topics
+// can share a label. It is not called for requests without a topic. The topic is joined with "." by default
+func WithTopicNormalizer(normalize func(topic []string) string) Option {
+	return func(o *options) {
+		o.topicLabel = normalize
+	}
+}
+
+// OtherTopic labels topics seen after the topic limit is reached
+const OtherTopic = "other"
+
+// WithTopicLimit caps the number of topic label values, after normalization. Later topics are labeled OtherTopic
+func WithTopicLimit(limit int) Option {
+	return func(o *options) {
+		o.topicLimit = limit
+	}
+}
+
+// WithPayloadSampling records the serialized size of requests and responses for the fraction rate of requests
+func WithPayloadSampling(rate float64) Option {
+	return func(o *options) {
+		o.payloadRate = rate
+	}
+}
+
+// WithClientMetrics records requests, latencies, claims, in-flight requests and stream messages sent by the client
+func WithClientMetrics(opts ...Option) psrpc.ClientOption {
+	m := newMetrics(opts)
+	return psrpc.WithClientOptions(
+		psrpc.WithClientRPCInterceptors(m.clientRPCInterceptor),
+		psrpc.WithClientMultiRPCInterceptors(m.clientMultiRPCInterceptor),
+		psrpc.WithClientStreamInterceptors(m.streamInterceptor(clientRole)),
+	)
+}
+
+// WithServerMetrics records requests, latencies, in-flight requests and stream messages handled by the server
+func WithServerMetrics(opts ...Option) psrpc.ServerOption {
+	m := newMetrics(opts)
+	return psrpc.WithServerOptions(
+		psrpc.WithServerRPCInterceptors(m.serverRPCInterceptor),
+		psrpc.WithServerStreamInterceptors(m.streamInterceptor(serverRole)),
+	)
+}
+
+const (
+	clientRole = "client"
+	serverRole = "server"
+)
+
+type metrics struct {
+	topics      bool
+	topicLabel  func(topic []string) string
+	topicLimit  int
+	payloadRate float64
+
+	mu         sync.Mutex
+	seenTopics map[string]struct{}
+
+	requests         *prometheus.CounterVec
+	latency          *prometheus.HistogramVec
+	inFlight         *prometheus.GaugeVec
+	claimLatency     *prometheus.HistogramVec
+	claims           *prometheus.HistogramVec
+	affinityTimeouts *prometheus.CounterVec
+	multiResponses   *prometheus.CounterVec
+	streams          *prometheus.GaugeVec
+	streamMessages   *prometheus.CounterVec
+	payloadBytes     *prometheus.HistogramVec
+}
+
+func newMetrics(opts []Option) *metrics {
+	o := &options{
+		registerer: prometheus.DefaultRegisterer,
+		namespace:  "psrpc",
+		buckets:    prometheus.DefBuckets,
+		topics:     true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	labels := []string{"role", "service", "method", "topic"}
+	m := &metrics{
+		topics:      o.topics,
+		topicLabel:  o.topicLabel,
+		topicLimit:  o.topicLimit,
+		payloadRate: o.payloadRate,
+		seenTopics:  make(map[string]struct{}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.namespace,
+			Name:        "requests_total",
+			Help:        "Completed requests by error code.",
+			ConstLabels: o.constLabels,
+		}, append(labels, "code")),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   o.namespace,
+			Name:        "request_duration_seconds",
+			Help:        "Time until a request completed.",
+			ConstLabels: o.constLabels,
+			Buckets:     o.buckets,
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   o.namespace,
+			Name:        "requests_in_flight",
+			Help:        "Requests sent or being handled.",
+			ConstLabels: o.constLabels,
+		}, labels),
+		claimLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   o.namespace,
+			Name:        "claim_duration_seconds",
+			Help:        "Time spent selecting a server from the claims.",
+			ConstLabels: o.constLabels,
+			Buckets:     o.buckets,
+		}, labels),
+		claims: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   o.namespace,
+			Name:        "claims_received",
+			Help:        "Claims received while selecting a server.",
+			ConstLabels: o.constLabels,
+			Buckets:     []float64{0, 1, 2, 3, 5, 10, 20, 50},
+		}, labels),
+		affinityTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.namespace,
+			Name:        "claim_affinity_timeouts_total",
+			Help:        "Server selections that ended when the affinity timeout expired.",
+			ConstLabels: o.constLabels,
+		}, labels),
+		multiResponses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.namespace,
+			Name:        "multi_responses_total",
+			Help:        "Responses received for multi-rpcs by error code.",
+			ConstLabels: o.constLabels,
+		}, append(labels, "code")),
+		streams: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   o.namespace,
+			Name:        "streams_open",
+			Help:        "Open streams.",
+			ConstLabels: o.constLabels,
+		}, labels),
+		streamMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.namespace,
+			Name:        "stream_messages_total",
+			Help:        "Stream messages sent and received.",
+			ConstLabels: o.constLabels,
+		}, append(labels, "direction")),
+		payloadBytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   o.namespace,
+			Name:        "payload_bytes",
+			Help:        "Serialized size of sampled requests and responses.",
+			ConstLabels: o.constLabels,
+			Buckets:     prometheus.ExponentialBuckets(64, 4, 9),
+		}, append(labels, "payload")),
+	}
+
+	// clients and servers created with the same registerer share collectors
+	m.requests = register(o.registerer, m.requests)
+	m.latency = register(o.registerer, m.latency)
+	m.inFlight = register(o.registerer, m.inFlight)
+	m.claimLatency = register(o.registerer, m.claimLatency)
+	m.claims = register(o.registerer, m.claims)
+	m.affinityTimeouts = register(o.registerer, m.affinityTimeouts)
+	m.multiResponses = register(o.registerer, m.multiResponses)
+	m.streams = register(o.registerer, m.streams)
+	m.streamMessages = register(o.registerer, m.streamMessages)
+	m.payloadBytes = register(o.registerer, m.payloadBytes)
+	return m
+}
+
+func register[T prometheus.Collector](r prometheus.Registerer, c T) T {
+	if err := r.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+func (m *metrics) labels(role string, info psrpc.RPCInfo) prometheus.Labels {
+	return prometheus.Labels{
+		"role":    role,
+		"service": info.Service,
+		"method":  info.Method,
+		"topic":   m.topic(info.Topic),
+	}
+}
+
+func (m *metrics) topic(topic []string) string {
+	if !m.topics || len(topic) == 0 {
+		return ""
+	}
+
+	var label string
+	if m.topicLabel != nil {
+		label = m.topicLabel(topic)
+	} else {
+		label = strings.Join(topic, ".")
+	}
+	if m.topicLimit <= 0 || label == "" {
+		return label
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.seenTopics[label]; ok {
+		return label
+	}
+	if len(m.seenTopics) >= m.topicLimit {
+		return OtherTopic
+	}
+	m.seenTopics[label] = struct{}{}
+	return label
+}
+
+func withLabel(labels prometheus.Labels, name, value string) prometheus.Labels {
+	l := make(prometheus.Labels, len(labels)+1)
+	for k, v := range labels {
+		l[k] = v
+	}
+	l[name] = value
+	return l
+}
+
+func errorCode(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	var e psrpc.Error
+	if errors.As(err, &e) {
+		return string(e.Code())
+	}
+	return string(psrpc.Unknown)
+}
+
+func (m *metrics) clientRPCInterceptor(info psrpc.RPCInfo, next psrpc.ClientRPCHandler) psrpc.ClientRPCHandler {
+	labels := m.labels(clientRole, info)
+	return func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+		// the response info reports the claim phase. it is shared with the caller if they requested it
+		ri := responseInfo(opts)
+		if ri == nil {
+			ri = &psrpc.ResponseInfo{}
+			opts = append(opts[:len(opts):len(opts)], psrpc.WithResponseInfo(ri))
+		}
+
+		inFlight := m.inFlight.With(labels)
+		inFlight.Inc()
+		defer inFlight.Dec()
+		sampled := m.samplePayload()
+		if sampled {
+			m.observePayload(labels, "request", req)
+		}
+
+		start := time.Now()
+		res, err := next(ctx, req, opts...)
+		m.latency.With(labels).Observe(time.Since(start).Seconds())
+		m.requests.With(withLabel(labels, "code", errorCode(err))).Inc()
+		if sampled && err == nil {
+			m.observePayload(labels, "response", res)
+		}
+		if ri.ClaimLatency > 0 {
+			m.claimLatency.With(labels).Observe(ri.ClaimLatency.Seconds())
+			m.claims.With(labels).Observe(float64(ri.Claims))
+			if ri.AffinityTimedOut {
+				m.affinityTimeouts.With(labels).Inc()
+			}
+		}
+		return res, err
+	}
+}
+
+func (m *metrics) samplePayload() bool {
+	return m.payloadRate >= 1 || m.payloadRate > 0 && rand.Float64() < m.payloadRate
+}
+
+func (m *metrics) observePayload(labels prometheus.Labels, payload string, msg proto.Message) {
+	if msg != nil {
+		m.payloadBytes.With(withLabel(labels, "payload", payload)).Observe(float64(proto.Size(msg)))
+	}
+}
+
+func responseInfo(opts []psrpc.RequestOption) *psrpc.ResponseInfo {
+	o := &psrpc.RequestOpts{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o.ResponseInfo
+}
+
+func (m *metrics) clientMultiRPCInterceptor(info psrpc.RPCInfo, next psrpc.ClientMultiRPCHandler) psrpc.ClientMultiRPCHandler {
+	return &multiRPCMetrics{
+		ClientMultiRPCHandler: next,
+		m:                     m,
+		labels:                m.labels(clientRole, info),
+	}
+}
+
+type multiRPCMetrics struct {
+	psrpc.ClientMultiRPCHandler
+	m       *metrics
+	labels  prometheus.Labels
+	start   time.Time
+	sent    bool
+	sampled bool
+}
+
+func (r *multiRPCMetrics) Send(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) error {
+	r.start = time.Now()
+	if r.sampled = r.m.samplePayload(); r.sampled {
+		r.m.observePayload(r.labels, "request", req)
+	}
+	err := r.ClientMultiRPCHandler.Send(ctx, req, opts...)
+	if err != nil {
+		r.m.requests.With(withLabel(r.labels, "code", errorCode(err))).Inc()
+	} else {
+		r.sent = true
+		r.m.inFlight.With(r.labels).Inc()
+	}
+	return err
+}
+
+func (r *multiRPCMetrics) Recv(msg proto.Message, err error) {
+	r.m.multiResponses.With(withLabel(r.labels, "code", errorCode(err))).Inc()
+	if r.sampled && err == nil {
+		r.m.observePayload(r.labels, "response", msg)
+	}
+	r.ClientMultiRPCHandler.Recv(msg, err)
+}
+
+func (r *multiRPCMetrics) Close() {
+	if r.sent {
+		r.m.inFlight.With(r.labels).Dec()
+		r.m.latency.With(r.labels).Observe(time.Since(r.start).Seconds())
+		r.m.requests.With(withLabel(r.labels, "code", "ok")).Inc()
+	}
+	r.ClientMultiRPCHandler.Close()
+}
+
+func (m *metrics) serverRPCInterceptor(ctx context.Context, req proto.Message, info psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+	labels := m.labels(serverRole, info)
+	inFlight := m.inFlight.With(labels)
+	inFlight.Inc()
+	defer inFlight.Dec()
+	sampled := m.samplePayload()
+	if sampled {
+		m.observePayload(labels, "request", req)
+	}
+
+	start := time.Now()
+	res, err := handler(ctx, req)
+	m.latency.With(labels).Observe(time.Since(start).Seconds())
+	m.requests.With(withLabel(labels, "code", errorCode(err))).Inc()
+	if sampled && err == nil {
+		m.observePayload(labels, "response", res)
+	}
+	return res, err
+}
+
+func (m *metrics) streamInterceptor(role string) psrpc.StreamInterceptor {
+	return func(info psrpc.RPCInfo, next psrpc.StreamHandler) psrpc.StreamHandler {
+		labels := m.labels(role, info)
+		m.streams.With(labels).Inc()
+		return &streamMetrics{
+			StreamHandler: next,
+			m:             m,
+			labels:        labels,
+		}
+	}
+}
+
+type streamMetrics struct {
+	psrpc.StreamHandler
+	m      *metrics
+	labels prometheus.Labels
+	once   sync.Once
+}
+
+func (s *streamMetrics) Recv(msg proto.Message) error {
+	s.m.streamMessages.With(withLabel(s.labels, "direction", "recv")).Inc()
+	return s.StreamHandler.Recv(msg)
+}
+
+func (s *streamMetrics) Send(msg proto.Message, opts ...psrpc.StreamOption) error {
+	err := s.StreamHandler.Send(msg, opts...)
+	if err == nil {
+		s.m.streamMessages.With(withLabel(s.labels, "direction", "send")).Inc()
+	}
+	return err
+}
+
+func (s *streamMetrics) Close(cause error) error {
+	err := s.StreamHandler.Close(cause)
+	s.once.Do(func() {
+		s.m.streams.With(s.labels).Dec()
+	})
+	return err
+}
+
+// ChannelStatsSource is implemented by clients and servers
+type ChannelStatsSource interface {
+	ChannelStats() []psrpc.ChannelStats
+}
+
+// RegisterChannelStats exports the depth, capacity, blocked and dropped message counts of the buffers between the bus
+// and a client or server, labeled by channel. Use WithConstLabels to tell apart clients and servers in one process
+func RegisterChannelStats(src ChannelStatsSource, opts ...Option) error {
+	o := &options{
+		registerer: prometheus.DefaultRegisterer,
+		namespace:  "psrpc",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(o.namespace, "channel", name), help, []string{"channel"}, o.constLabels)
+	}
+	return o.registerer.Register(&channelCollector{
+		src:      src,
+		depth:    desc("depth", "Messages waiting to be read."),
+		capacity: desc("capacity", "Channel buffer size."),
+		blocked:  desc("blocked_total", "Messages that waited for room in a full buffer."),
+		dropped:  desc("dropped_total", "Messages discarded because a buffer was full."),
+	})
+}
+
+type channelCollector struct {
+	src      ChannelStatsSource
+	depth    *prometheus.Desc
+	capacity *prometheus.Desc
+	blocked  *prometheus.Desc
+	dropped  *prometheus.Desc
+}
+
+func (c *channelCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.depth
+	ch <- c.capacity
+	ch <- c.blocked
+	ch <- c.dropped
+}
+
+func (c *channelCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.src.ChannelStats() {
+		ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(s.Depth), s.Channel)
+		ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, float64(s.Capacity), s.Channel)
+		ch <- prometheus.MustNewConstMetric(c.blocked, prometheus.CounterValue, float64(s.Blocked), s.Channel)
+		ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(s.Dropped), s.Channel)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/pkg/client"
+	"github.com/livekit/psrpc/pkg/info"
+	"github.com/livekit/psrpc/pkg/server"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	bus := psrpc.NewLocalMessageBus()
+	s := server.NewRPCServer(&info.ServiceDefinition{Name: "test", ID: "server"}, bus, WithServerMetrics(WithRegisterer(reg), WithPayloadSampling(1)))
+	t.Cleanup(func() { s.Close(true) })
+
+	c, err := client.NewRPCClient(&info.ServiceDefinition{Name: "test", ID: "client"}, bus, WithClientMetrics(WithRegisterer(reg), WithPayloadSampling(1)))
+	require.NoError(t, err)
+
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		if req.RequestId == "fail" {
+			return nil, psrpc.NewErrorf(psrpc.NotFound, "missing")
+		}
+		return &internal.Response{}, nil
+	}
+	s.RegisterMethod("unary", false, false, true, false)
+	c.RegisterMethod("unary", false, false, true, false)
+	require.NoError(t, server.RegisterHandler[*internal.Request, *internal.Response](s, "unary", []string{"a"}, handler, nil))
+	s.RegisterMethod("multi", false, true, false, false)
+	c.RegisterMethod("multi", false, true, false, false)
+	require.NoError(t, server.RegisterHandler[*internal.Request, *internal.Response](s, "multi", nil, handler, nil))
+
+	t.Run("Unary", func(t *testing.T) {
+		_, err := client.RequestSingle[*internal.Response](context.Background(), c, "unary", []string{"a"}, &internal.Request{})
+		require.NoError(t, err)
+		_, err = client.RequestSingle[*internal.Response](context.Background(), c, "unary", []string{"a"}, &internal.Request{RequestId: "fail"})
+		require.Error(t, err)
+
+		for _, role := range []string{clientRole, serverRole} {
+			labels := prometheus.Labels{"role": role, "service": "test", "method": "unary", "topic": "a"}
+			require.Equal(t, float64(1), testutil.ToFloat64(m(t, reg).requests.With(withLabel(labels, "code", "ok"))))
+			require.Equal(t, float64(1), testutil.ToFloat64(m(t, reg).requests.With(withLabel(labels, "code", string(psrpc.NotFound)))))
+			require.Equal(t, float64(0), testutil.ToFloat64(m(t, reg).inFlight.With(labels)))
+		}
+		require.Equal(t, 1, testutil.CollectAndCount(m(t, reg).claimLatency))
+		require.Equal(t, 1, testutil.CollectAndCount(m(t, reg).claims))
+		require.Equal(t, 0, testutil.CollectAndCount(m(t, reg).affinityTimeouts))
+		// request and response sizes for each role. failed requests have no response
+		require.Equal(t, 4, testutil.CollectAndCount(m(t, reg).payloadBytes))
+	})
+
+	t.Run("Multi", func(t *testing.T) {
+		resChan, err := client.RequestMulti[*internal.Response](context.Background(), c, "multi", nil, &internal.Request{}, psrpc.WithRequestTimeout(100*time.Millisecond))
+		require.NoError(t, err)
+		for range resChan {
+		}
+
+		labels := prometheus.Labels{"role": clientRole, "service": "test", "method": "multi", "topic": ""}
+		require.Equal(t, float64(1), testutil.ToFloat64(m(t, reg).multiResponses.With(withLabel(labels, "code", "ok"))))
+		require.Equal(t, float64(1), testutil.ToFloat64(m(t, reg).requests.With(withLabel(labels, "code", "ok"))))
+	})
+}
+
+func TestChannelStats(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	bus := psrpc.NewLocalMessageBus()
+	c, err := client.NewRPCClient(&info.ServiceDefinition{Name: "test", ID: "client"}, bus)
+	require.NoError(t, err)
+	t.Cleanup(c.Close)
+
+	require.NoError(t, RegisterChannelStats(c, WithRegisterer(reg)))
+	require.Equal(t, 16, testutil.CollectAndCount(reg))
+	require.NoError(t, testutil.CollectAndCompare(reg, strings.NewReader(`
+# HELP psrpc_channel_capacity Channel buffer size.
+# TYPE psrpc_channel_capacity gauge
+psrpc_channel_capacity{channel="claims"} 100
+psrpc_channel_capacity{channel="leaving"} 100
+psrpc_channel_capacity{channel="responses"} 100
+psrpc_channel_capacity{channel="streams"} 0
+`), "psrpc_channel_capacity"))
+}
+
+func TestTopicLabels(t *testing.T) {
+	mt := newMetrics([]Option{
+		WithRegisterer(prometheus.NewRegistry()),
+		WithTopicNormalizer(func(topic []string) string {
+			// label by tenant, dropping the room
+			return topic[0]
+		}),
+		WithTopicLimit(2),
+	})
+
+	require.Equal(t, "a", mt.topic([]string{"a", "room1"}))
+	require.Equal(t, "a", mt.topic([]string{"a", "room2"}))
+	require.Equal(t, "b", mt.topic([]string{"b", "room1"}))
+	require.Equal(t, OtherTopic, mt.topic([]string{"c", "room1"}))
+	require.Equal(t, "b", mt.topic([]string{"b", "room3"}))
+
+	mt = newMetrics([]Option{WithRegisterer(prometheus.NewRegistry()), WithTopicLabel(false)})
+	require.Equal(t, "", mt.topic([]string{"a", "room1"}))
+}
+
+// m returns the metrics registered with reg, which are shared with the client and server
+func m(t *testing.T, reg *prometheus.Registry) *metrics {
+	t.Helper()
+	return newMetrics([]Option{WithRegisterer(reg)})
+}
@@ -0,0 +1,124 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/pkg/metadata"
+)
+
+// AuthorizationKey is the request metadata key holding client credentials
+const AuthorizationKey = "authorization"
+
+// AuthVerifier checks the credentials sent with a request. The returned context is passed to the handler,
+// so verifiers can attach the caller's identity
+type AuthVerifier func(ctx context.Context, credentials string, info psrpc.RPCInfo) (context.Context, error)
+
+// Reject requests that are missing credentials or fail verification with Unauthenticated. Streams are not covered,
+// authenticate them with WithServerStreamAuth
+func WithServerAuth(verify AuthVerifier) psrpc.ServerRPCInterceptor {
+	return func(ctx context.Context, req proto.Message, info psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+		ctx, err := authenticate(ctx, verify, info)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Close streams that are missing credentials or fail verification with Unauthenticated
+func WithServerStreamAuth(verify AuthVerifier) psrpc.ServerOption {
+	return psrpc.WithServerStreamOpenHooks(func(ctx context.Context, info psrpc.RPCInfo) (context.Context, error) {
+		return authenticate(ctx, verify, info)
+	})
+}
+
+func authenticate(ctx context.Context, verify AuthVerifier, info psrpc.RPCInfo) (context.Context, error) {
+	var credentials string
+	if head := metadata.IncomingHeader(ctx); head != nil {
+		credentials = head.Metadata[AuthorizationKey]
+	}
+	if credentials == "" {
+		return nil, psrpc.NewErrorf(psrpc.Unauthenticated, "missing credentials")
+	}
+
+	ctx, err := verify(ctx, credentials, info)
+	if err != nil {
+		var e psrpc.Error
+		if errors.As(err, &e) {
+			return nil, err
+		}
+		return nil, psrpc.NewError(psrpc.Unauthenticated, err)
+	}
+	return ctx, nil
+}
+
+// Attach credentials to outgoing requests and streams. getCredentials is called for every request so tokens can be refreshed
+func WithClientAuth(getCredentials func(ctx context.Context) (string, error)) psrpc.ClientOption {
+	return psrpc.WithClientOptions(
+		psrpc.WithClientRPCInterceptors(newClientRPCAuthInterceptor(getCredentials)),
+		psrpc.WithClientMultiRPCInterceptors(newMultiRPCAuthInterceptor(getCredentials)),
+		psrpc.WithClientStreamOpenHooks(newStreamAuthHook(getCredentials)),
+	)
+}
+
+func newStreamAuthHook(getCredentials func(ctx context.Context) (string, error)) psrpc.StreamOpenHook {
+	return func(ctx context.Context, info psrpc.RPCInfo) (context.Context, error) {
+		credentials, err := getCredentials(ctx)
+		if err != nil {
+			return nil, psrpc.NewError(psrpc.Unauthenticated, err)
+		}
+		return metadata.AppendMetadataToOutgoingContext(ctx, AuthorizationKey, credentials), nil
+	}
+}
+
+func newClientRPCAuthInterceptor(getCredentials func(ctx context.Context) (string, error)) psrpc.ClientRPCInterceptor {
+	return func(rpcInfo psrpc.RPCInfo, next psrpc.ClientRPCHandler) psrpc.ClientRPCHandler {
+		return func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+			credentials, err := getCredentials(ctx)
+			if err != nil {
+				return nil, psrpc.NewError(psrpc.Unauthenticated, err)
+			}
+			return next(metadata.AppendMetadataToOutgoingContext(ctx, AuthorizationKey, credentials), req, opts...)
+		}
+	}
+}
+
+func newMultiRPCAuthInterceptor(getCredentials func(ctx context.Context) (string, error)) psrpc.ClientMultiRPCInterceptor {
+	return func(rpcInfo psrpc.RPCInfo, next psrpc.ClientMultiRPCHandler) psrpc.ClientMultiRPCHandler {
+		return &multiRPCAuthInterceptor{
+			ClientMultiRPCHandler: next,
+			getCredentials:        getCredentials,
+		}
+	}
+}
+
+type multiRPCAuthInterceptor struct {
+	psrpc.ClientMultiRPCHandler
+	getCredentials func(ctx context.Context) (string, error)
+}
+
+func (r *multiRPCAuthInterceptor) Send(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) error {
+	credentials, err := r.getCredentials(ctx)
+	if err != nil {
+		return psrpc.NewError(psrpc.Unauthenticated, err)
+	}
+	return r.ClientMultiRPCHandler.Send(metadata.AppendMetadataToOutgoingContext(ctx, AuthorizationKey, credentials), req, opts...)
+}
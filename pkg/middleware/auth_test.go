@@ -0,0 +1,76 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/pkg/metadata"
+)
+
+type testIdentityKey struct{}
+
+func TestServerAuth(t *testing.T) {
+	ai := WithServerAuth(func(ctx context.Context, credentials string, info psrpc.RPCInfo) (context.Context, error) {
+		if credentials != "secret" {
+			return nil, errors.New("invalid credentials")
+		}
+		return context.WithValue(ctx, testIdentityKey{}, "user"), nil
+	})
+
+	var identity any
+	handler := func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		identity = ctx.Value(testIdentityKey{})
+		return nil, nil
+	}
+	withCredentials := func(credentials string) context.Context {
+		return metadata.NewContextWithIncomingHeader(context.Background(), &metadata.Header{
+			Metadata: metadata.Metadata{AuthorizationKey: credentials},
+		})
+	}
+
+	_, err := ai(context.Background(), nil, psrpc.RPCInfo{}, handler)
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.Unauthenticated))
+
+	_, err = ai(withCredentials("wrong"), nil, psrpc.RPCInfo{}, handler)
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.Unauthenticated))
+	require.Nil(t, identity)
+
+	_, err = ai(withCredentials("secret"), nil, psrpc.RPCInfo{}, handler)
+	require.NoError(t, err)
+	require.Equal(t, "user", identity)
+}
+
+func TestClientAuth(t *testing.T) {
+	ai := newClientRPCAuthInterceptor(func(ctx context.Context) (string, error) {
+		return "secret", nil
+	})
+
+	var md metadata.Metadata
+	handler := ai(psrpc.RPCInfo{}, func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+		md = metadata.OutgoingContextMetadata(ctx)
+		return nil, nil
+	})
+
+	_, err := handler(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, "secret", md[AuthorizationKey])
+}
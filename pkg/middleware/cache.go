@@ -0,0 +1,212 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/gammazero/deque"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/pkg/metadata"
+)
+
+type CacheOptions struct {
+	Methods    []string      // rpcs to cache, responses for other rpcs are never cached
+	TTL        time.Duration // how long responses are cached
+	MaxEntries int           // if > 0, the oldest entries are evicted when the cache is full
+	OnLookup   func(rpcInfo psrpc.RPCInfo, hit bool, stats CacheStats)
+}
+
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// WithRPCCache caches responses keyed by method, topic, outgoing metadata and request content. Register it after
+// interceptors that attach credentials, such as WithClientAuth, so responses are never shared between callers
+func WithRPCCache(opt CacheOptions) psrpc.ClientOption {
+	return psrpc.WithClientRPCInterceptors(NewRPCCacheInterceptor(opt))
+}
+
+func NewRPCCacheInterceptor(opt CacheOptions) psrpc.ClientRPCInterceptor {
+	c := &responseCache{
+		CacheOptions: opt,
+		entries:      make(map[cacheKey]*cacheEntry),
+	}
+
+	return func(rpcInfo psrpc.RPCInfo, next psrpc.ClientRPCHandler) psrpc.ClientRPCHandler {
+		if !slices.Contains(opt.Methods, rpcInfo.Method) {
+			return next
+		}
+
+		return func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+			b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+			if err != nil {
+				return next(ctx, req, opts...)
+			}
+			key := newCacheKey(rpcInfo, metadata.OutgoingContextMetadata(ctx), b)
+
+			if res, ok := c.get(rpcInfo, key); ok {
+				return res, nil
+			}
+
+			res, err := next(ctx, req, opts...)
+			if err == nil && res != nil {
+				c.set(key, res)
+			}
+			return res, err
+		}
+	}
+}
+
+func WithServerRPCCache(opt CacheOptions) psrpc.ServerOption {
+	return psrpc.WithServerRPCInterceptors(NewServerRPCCacheInterceptor(opt))
+}
+
+// NewServerRPCCacheInterceptor caches handler responses, so repeated identical requests skip the handler. Responses
+// are keyed on request metadata as well as content, but cached responses skip every interceptor chained after the
+// cache, so it must be chained after WithServerAuth
+func NewServerRPCCacheInterceptor(opt CacheOptions) psrpc.ServerRPCInterceptor {
+	c := &responseCache{
+		CacheOptions: opt,
+		entries:      make(map[cacheKey]*cacheEntry),
+	}
+
+	return func(ctx context.Context, req proto.Message, rpcInfo psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+		if !slices.Contains(opt.Methods, rpcInfo.Method) {
+			return handler(ctx, req)
+		}
+
+		b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+		if err != nil {
+			return handler(ctx, req)
+		}
+		var md metadata.Metadata
+		if head := metadata.IncomingHeader(ctx); head != nil {
+			md = head.Metadata
+		}
+		key := newCacheKey(rpcInfo, md, b)
+
+		if res, ok := c.get(rpcInfo, key); ok {
+			return res, nil
+		}
+
+		res, err := handler(ctx, req)
+		if err == nil && res != nil {
+			c.set(key, res)
+		}
+		return res, err
+	}
+}
+
+type cacheKey [sha256.Size]byte
+
+func newCacheKey(rpcInfo psrpc.RPCInfo, md metadata.Metadata, req []byte) cacheKey {
+	h := sha256.New()
+	h.Write([]byte(rpcInfo.Method))
+	for _, t := range rpcInfo.Topic {
+		h.Write([]byte{0})
+		h.Write([]byte(t))
+	}
+	h.Write([]byte{1})
+	keys := maps.Keys(md)
+	slices.Sort(keys)
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(md[k]))
+		h.Write([]byte{0})
+	}
+	h.Write([]byte{1})
+	h.Write(req)
+
+	var k cacheKey
+	h.Sum(k[:0])
+	return k
+}
+
+type cacheEntry struct {
+	key    cacheKey
+	res    proto.Message
+	expiry time.Time
+}
+
+type responseCache struct {
+	CacheOptions
+
+	mu      sync.Mutex
+	entries map[cacheKey]*cacheEntry
+	order   deque.Deque[*cacheEntry]
+	stats   CacheStats
+}
+
+func (c *responseCache) get(rpcInfo psrpc.RPCInfo, key cacheKey) (proto.Message, bool) {
+	c.mu.Lock()
+	c.evictExpired(time.Now())
+	e, hit := c.entries[key]
+	if hit {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	stats := c.stats
+	c.mu.Unlock()
+
+	if c.OnLookup != nil {
+		c.OnLookup(rpcInfo, hit, stats)
+	}
+
+	if !hit {
+		return nil, false
+	}
+	return proto.Clone(e.res), true
+}
+
+func (c *responseCache) set(key cacheKey, res proto.Message) {
+	e := &cacheEntry{
+		key:    key,
+		res:    proto.Clone(res),
+		expiry: time.Now().Add(c.TTL),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = e
+	c.order.PushBack(e)
+	for c.MaxEntries > 0 && len(c.entries) > c.MaxEntries {
+		c.evict(c.order.PopFront())
+	}
+}
+
+func (c *responseCache) evictExpired(now time.Time) {
+	for c.order.Len() > 0 && !now.Before(c.order.Front().expiry) {
+		c.evict(c.order.PopFront())
+	}
+}
+
+func (c *responseCache) evict(e *cacheEntry) {
+	// entries replaced by a newer response for the same key are already unreachable
+	if c.entries[e.key] == e {
+		delete(c.entries, e.key)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/pkg/metadata"
+)
+
+func TestRPCCache(t *testing.T) {
+	var calls int
+	var fail bool
+	handler := func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+		calls++
+		if fail {
+			return nil, errors.New("test error")
+		}
+		return &internal.Response{RequestId: req.(*internal.Request).RequestId}, nil
+	}
+
+	var stats CacheStats
+	ci := NewRPCCacheInterceptor(CacheOptions{
+		Methods:    []string{"cached"},
+		TTL:        100 * time.Millisecond,
+		MaxEntries: 1,
+		OnLookup: func(rpcInfo psrpc.RPCInfo, hit bool, s CacheStats) {
+			stats = s
+		},
+	})
+	cached := ci(psrpc.RPCInfo{Method: "cached"}, handler)
+
+	t.Run("TestHit", func(t *testing.T) {
+		res, err := cached(context.Background(), &internal.Request{RequestId: "a"})
+		require.NoError(t, err)
+		require.Equal(t, "a", res.(*internal.Response).RequestId)
+
+		res, err = cached(context.Background(), &internal.Request{RequestId: "a"})
+		require.NoError(t, err)
+		require.Equal(t, "a", res.(*internal.Response).RequestId)
+		require.Equal(t, 1, calls)
+		require.Equal(t, CacheStats{Hits: 1, Misses: 1}, stats)
+	})
+
+	t.Run("TestMaxEntries", func(t *testing.T) {
+		_, err := cached(context.Background(), &internal.Request{RequestId: "b"})
+		require.NoError(t, err)
+		_, err = cached(context.Background(), &internal.Request{RequestId: "a"})
+		require.NoError(t, err)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("TestExpiry", func(t *testing.T) {
+		time.Sleep(150 * time.Millisecond)
+		_, err := cached(context.Background(), &internal.Request{RequestId: "a"})
+		require.NoError(t, err)
+		require.Equal(t, 4, calls)
+	})
+
+	t.Run("TestErrorsNotCached", func(t *testing.T) {
+		fail = true
+		_, err := cached(context.Background(), &internal.Request{RequestId: "c"})
+		require.Error(t, err)
+		fail = false
+		_, err = cached(context.Background(), &internal.Request{RequestId: "c"})
+		require.NoError(t, err)
+		require.Equal(t, 6, calls)
+	})
+
+	t.Run("TestUncachedMethod", func(t *testing.T) {
+		uncached := ci(psrpc.RPCInfo{Method: "uncached"}, handler)
+		_, err := uncached(context.Background(), &internal.Request{RequestId: "c"})
+		require.NoError(t, err)
+		require.Equal(t, 7, calls)
+	})
+
+	t.Run("TestMetadata", func(t *testing.T) {
+		for _, tenant := range []string{"a", "a", "b"} {
+			ctx := psrpc.NewOutgoingContext(context.Background(), psrpc.Metadata{"tenant": tenant})
+			_, err := cached(ctx, &internal.Request{RequestId: "d"})
+			require.NoError(t, err)
+		}
+		require.Equal(t, 9, calls)
+	})
+}
+
+func TestServerRPCCache(t *testing.T) {
+	var calls int
+	handler := func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		calls++
+		return &internal.Response{RequestId: req.(*internal.Request).RequestId}, nil
+	}
+
+	ci := NewServerRPCCacheInterceptor(CacheOptions{
+		Methods: []string{"cached"},
+		TTL:     time.Minute,
+	})
+
+	for i := 0; i < 2; i++ {
+		res, err := ci(context.Background(), &internal.Request{RequestId: "a"}, psrpc.RPCInfo{Method: "cached"}, handler)
+		require.NoError(t, err)
+		require.Equal(t, "a", res.(*internal.Response).RequestId)
+	}
+	require.Equal(t, 1, calls)
+
+	_, err := ci(context.Background(), &internal.Request{RequestId: "a"}, psrpc.RPCInfo{Method: "cached", Topic: []string{"b"}}, handler)
+	require.NoError(t, err)
+	_, err = ci(context.Background(), &internal.Request{RequestId: "a"}, psrpc.RPCInfo{Method: "uncached"}, handler)
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+	ctx := metadata.NewContextWithIncomingHeader(context.Background(), &metadata.Header{
+		Metadata: metadata.Metadata{AuthorizationKey: "other"},
+	})
+	_, err = ci(ctx, &internal.Request{RequestId: "a"}, psrpc.RPCInfo{Method: "cached"}, handler)
+	require.NoError(t, err)
+	require.Equal(t, 4, calls)
+}
@@ -0,0 +1,37 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+)
+
+// ChainServerRPCInterceptors combines interceptors into one, so a bundle such as auth, logging
+// and validation can be shared between services as a single option
+func ChainServerRPCInterceptors(chain ...psrpc.ServerRPCInterceptor) psrpc.ServerRPCInterceptor {
+	return func(ctx context.Context, req proto.Message, info psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+		for i := len(chain) - 1; i >= 0; i-- {
+			interceptor, next := chain[i], handler
+			handler = func(ctx context.Context, req proto.Message) (proto.Message, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
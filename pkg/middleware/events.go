@@ -0,0 +1,159 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gammazero/deque"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+)
+
+type RPCEventType string
+
+const (
+	RequestEvent   RPCEventType = "request"
+	SelectionEvent RPCEventType = "selection" // server selection finished, with the claims received and the server selected
+	ResponseEvent  RPCEventType = "response"
+	ErrorEvent     RPCEventType = "error"
+)
+
+type RPCEvent struct {
+	Time      time.Time
+	Type      RPCEventType
+	Role      MetricRole
+	RPCInfo   psrpc.RPCInfo
+	RequestID string
+	RemoteID  string // selected server on clients, calling client on servers
+	Claims    int
+	Duration  time.Duration // time since the request event
+	Err       error
+}
+
+// EventLog keeps the most recent rpc events, to be dumped while debugging. Add its interceptors to clients and servers
+type EventLog struct {
+	size int
+
+	mu     sync.Mutex
+	events deque.Deque[RPCEvent]
+}
+
+func NewEventLog(size int) *EventLog {
+	return &EventLog{size: size}
+}
+
+func WithRPCEventLog(l *EventLog) psrpc.ClientOption {
+	return psrpc.WithClientRPCInterceptors(l.ClientInterceptor())
+}
+
+func WithServerRPCEventLog(l *EventLog) psrpc.ServerOption {
+	return psrpc.WithServerRPCInterceptors(l.ServerInterceptor())
+}
+
+// Events returns the logged events, oldest first
+func (l *EventLog) Events() []RPCEvent {
+	l.mu.Lock()
+	events := make([]RPCEvent, 0, l.events.Len())
+	for i := 0; i < l.events.Len(); i++ {
+		events = append(events, l.events.At(i))
+	}
+	l.mu.Unlock()
+
+	// events for a request are added when it completes
+	slices.SortStableFunc(events, func(a, b RPCEvent) int {
+		return a.Time.Compare(b.Time)
+	})
+	return events
+}
+
+func (l *EventLog) ClientInterceptor() psrpc.ClientRPCInterceptor {
+	return func(rpcInfo psrpc.RPCInfo, next psrpc.ClientRPCHandler) psrpc.ClientRPCHandler {
+		return func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+			ri, opts := withResponseInfo(opts)
+
+			start := time.Now()
+			res, err := next(ctx, req, opts...)
+			duration := time.Since(start)
+
+			e := RPCEvent{
+				Time:      start,
+				Type:      RequestEvent,
+				Role:      ClientRole,
+				RPCInfo:   rpcInfo,
+				RequestID: ri.RequestID,
+			}
+			events := []RPCEvent{e}
+			if ri.ClaimLatency > 0 || ri.Claims > 0 {
+				selection := e.next(SelectionEvent, ri.ClaimLatency, ri.ServerID, nil)
+				selection.Claims = ri.Claims
+				events = append(events, selection)
+			}
+			events = append(events, e.result(duration, ri.ServerID, err))
+			l.add(events...)
+			return res, err
+		}
+	}
+}
+
+func (l *EventLog) ServerInterceptor() psrpc.ServerRPCInterceptor {
+	return func(ctx context.Context, req proto.Message, rpcInfo psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+
+		e := RPCEvent{
+			Time:      start,
+			Type:      RequestEvent,
+			Role:      ServerRole,
+			RPCInfo:   rpcInfo,
+			RequestID: psrpc.IncomingRequestID(ctx),
+			RemoteID:  psrpc.IncomingClientID(ctx),
+		}
+		l.add(e, e.result(time.Since(start), e.RemoteID, err))
+		return res, err
+	}
+}
+
+func (e RPCEvent) next(t RPCEventType, d time.Duration, remoteID string, err error) RPCEvent {
+	e.Time = e.Time.Add(d)
+	e.Type = t
+	e.RemoteID = remoteID
+	e.Duration = d
+	e.Err = err
+	return e
+}
+
+func (e RPCEvent) result(d time.Duration, remoteID string, err error) RPCEvent {
+	if err != nil {
+		return e.next(ErrorEvent, d, remoteID, err)
+	}
+	return e.next(ResponseEvent, d, remoteID, nil)
+}
+
+func (l *EventLog) add(events ...RPCEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, e := range events {
+		l.events.PushBack(e)
+	}
+	for l.events.Len() > l.size {
+		l.events.PopFront()
+	}
+}
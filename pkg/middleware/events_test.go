@@ -0,0 +1,79 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+)
+
+func TestEventLog(t *testing.T) {
+	handler := func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+		o := &psrpc.RequestOpts{}
+		for _, opt := range opts {
+			opt(o)
+		}
+		o.ResponseInfo.RequestID = req.(*internal.Request).RequestId
+		o.ResponseInfo.ServerID = "server"
+		o.ResponseInfo.ClaimLatency = time.Millisecond
+		o.ResponseInfo.Claims = 2
+		time.Sleep(2 * time.Millisecond)
+		if o.ResponseInfo.RequestID == "fail" {
+			return nil, psrpc.NewErrorf(psrpc.NotFound, "missing")
+		}
+		return &internal.Response{}, nil
+	}
+
+	l := NewEventLog(5)
+	logged := l.ClientInterceptor()(psrpc.RPCInfo{Method: "logged"}, handler)
+
+	_, err := logged(context.Background(), &internal.Request{RequestId: "ok"})
+	require.NoError(t, err)
+
+	events := l.Events()
+	require.Len(t, events, 3)
+	require.Equal(t, RequestEvent, events[0].Type)
+	require.Equal(t, "ok", events[0].RequestID)
+	require.Equal(t, SelectionEvent, events[1].Type)
+	require.Equal(t, 2, events[1].Claims)
+	require.Equal(t, "server", events[1].RemoteID)
+	require.Equal(t, ResponseEvent, events[2].Type)
+
+	_, err = logged(context.Background(), &internal.Request{RequestId: "fail"})
+	require.Error(t, err)
+
+	// the oldest events are dropped
+	events = l.Events()
+	require.Len(t, events, 5)
+	require.Equal(t, SelectionEvent, events[0].Type)
+	require.Equal(t, "ok", events[1].RequestID)
+	require.Equal(t, ErrorEvent, events[4].Type)
+	require.ErrorIs(t, events[4].Err, psrpc.NotFound)
+
+	_, err = l.ServerInterceptor()(context.Background(), &internal.Request{}, psrpc.RPCInfo{Method: "logged"}, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return &internal.Response{}, nil
+	})
+	require.NoError(t, err)
+	events = l.Events()
+	require.Equal(t, ServerRole, events[4].Role)
+	require.Equal(t, ResponseEvent, events[4].Type)
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/livekit/psrpc"
+)
+
+type Logger = psrpc.Logger
+
+type LoggingOptions struct {
+	Logger      Logger
+	LogPayloads bool                                  // include requests and responses, which may contain sensitive data
+	Redact      func(msg proto.Message) proto.Message // applied to payloads before they are logged
+}
+
+func WithRPCLogging(opt LoggingOptions) psrpc.ClientOption {
+	return psrpc.WithClientRPCInterceptors(NewRPCLoggingInterceptor(opt))
+}
+
+// NewRPCLoggingInterceptor logs when requests are sent and when their response or error is returned
+func NewRPCLoggingInterceptor(opt LoggingOptions) psrpc.ClientRPCInterceptor {
+	return func(rpcInfo psrpc.RPCInfo, next psrpc.ClientRPCHandler) psrpc.ClientRPCHandler {
+		return func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+			// the response info reports the request and server ids
+			ri, opts := withResponseInfo(opts)
+
+			values := rpcLogValues(rpcInfo)
+			opt.Logger.Info("sending request", opt.appendPayload(values, "request", req)...)
+
+			start := time.Now()
+			res, err := next(ctx, req, opts...)
+
+			values = append(values, "requestID", ri.RequestID, "serverID", ri.ServerID, "duration", time.Since(start))
+			opt.logResult(values, res, err)
+			return res, err
+		}
+	}
+}
+
+func WithServerRPCLogging(opt LoggingOptions) psrpc.ServerOption {
+	return psrpc.WithServerRPCInterceptors(NewServerRPCLoggingInterceptor(opt))
+}
+
+// NewServerRPCLoggingInterceptor logs when handlers start and when they return
+func NewServerRPCLoggingInterceptor(opt LoggingOptions) psrpc.ServerRPCInterceptor {
+	return func(ctx context.Context, req proto.Message, rpcInfo psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+		values := append(rpcLogValues(rpcInfo), "requestID", psrpc.IncomingRequestID(ctx), "clientID", psrpc.IncomingClientID(ctx))
+		opt.Logger.Info("handling request", opt.appendPayload(values, "request", req)...)
+
+		start := time.Now()
+		res, err := handler(ctx, req)
+
+		opt.logResult(append(values, "duration", time.Since(start)), res, err)
+		return res, err
+	}
+}
+
+func rpcLogValues(rpcInfo psrpc.RPCInfo) []any {
+	values := []any{"service", rpcInfo.Service, "method", rpcInfo.Method}
+	if len(rpcInfo.Topic) != 0 {
+		values = append(values, "topic", strings.Join(rpcInfo.Topic, "."))
+	}
+	return values
+}
+
+func (o LoggingOptions) appendPayload(values []any, key string, msg proto.Message) []any {
+	if !o.LogPayloads || msg == nil {
+		return values
+	}
+	if o.Redact != nil {
+		msg = o.Redact(msg)
+	}
+	return append(values[:len(values):len(values)], key, msg)
+}
+
+func (o LoggingOptions) logResult(values []any, res proto.Message, err error) {
+	if err != nil {
+		code := psrpc.Unknown
+		var e psrpc.Error
+		if errors.As(err, &e) {
+			code = e.Code()
+		}
+		o.Logger.Error(err, "request failed", append(values, "code", code)...)
+		return
+	}
+	o.Logger.Info("request completed", o.appendPayload(values, "response", res)...)
+}
+
+// RedactFields returns a Redact func clearing the named fields from top level and nested messages
+func RedactFields(names ...string) func(msg proto.Message) proto.Message {
+	return func(msg proto.Message) proto.Message {
+		msg = proto.Clone(msg)
+		redactFields(msg.ProtoReflect(), names)
+		return msg
+	}
+}
+
+func redactFields(m protoreflect.Message, names []string) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		for _, name := range names {
+			if string(fd.Name()) == name {
+				m.Clear(fd)
+				return true
+			}
+		}
+
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				redactFields(list.Get(i).Message(), names)
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				redactFields(mv.Message(), names)
+				return true
+			})
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			redactFields(v.Message(), names)
+		}
+		return true
+	})
+}
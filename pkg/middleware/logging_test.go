@@ -0,0 +1,113 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+)
+
+type logEntry struct {
+	err    error
+	msg    string
+	values map[string]any
+}
+
+type testLogger struct {
+	entries []logEntry
+}
+
+func (l *testLogger) Info(msg string, keysAndValues ...any) {
+	l.Error(nil, msg, keysAndValues...)
+}
+
+func (l *testLogger) Error(err error, msg string, keysAndValues ...any) {
+	values := make(map[string]any)
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		values[keysAndValues[i].(string)] = keysAndValues[i+1]
+	}
+	l.entries = append(l.entries, logEntry{err, msg, values})
+}
+
+func TestRPCLogging(t *testing.T) {
+	handler := func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+		if req.(*internal.Request).RequestId == "fail" {
+			return nil, psrpc.NewErrorf(psrpc.NotFound, "missing")
+		}
+		return &internal.Response{ServerId: "server"}, nil
+	}
+
+	l := &testLogger{}
+	li := NewRPCLoggingInterceptor(LoggingOptions{
+		Logger:      l,
+		LogPayloads: true,
+		Redact:      RedactFields("client_id"),
+	})
+	logged := li(psrpc.RPCInfo{Service: "test", Method: "logged", Topic: []string{"a"}}, handler)
+
+	_, err := logged(context.Background(), &internal.Request{RequestId: "ok", ClientId: "secret"})
+	require.NoError(t, err)
+	require.Len(t, l.entries, 2)
+	require.Equal(t, "sending request", l.entries[0].msg)
+	require.Equal(t, "a", l.entries[0].values["topic"])
+	require.Equal(t, "", l.entries[0].values["request"].(*internal.Request).ClientId)
+	require.Equal(t, "request completed", l.entries[1].msg)
+	require.Contains(t, l.entries[1].values, "duration")
+	require.Equal(t, "server", l.entries[1].values["response"].(*internal.Response).ServerId)
+
+	_, err = logged(context.Background(), &internal.Request{RequestId: "fail"})
+	require.Error(t, err)
+	require.Len(t, l.entries, 4)
+	require.Equal(t, "request failed", l.entries[3].msg)
+	require.Equal(t, psrpc.NotFound, l.entries[3].values["code"])
+	require.True(t, errors.Is(l.entries[3].err, psrpc.NotFound))
+}
+
+func TestServerRPCLogging(t *testing.T) {
+	handler := func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return &internal.Response{}, nil
+	}
+
+	l := &testLogger{}
+	li := NewServerRPCLoggingInterceptor(LoggingOptions{Logger: l})
+	_, err := li(context.Background(), &internal.Request{}, psrpc.RPCInfo{Service: "test", Method: "logged"}, handler)
+	require.NoError(t, err)
+	require.Len(t, l.entries, 2)
+	require.Equal(t, "handling request", l.entries[0].msg)
+	require.NotContains(t, l.entries[0].values, "request")
+	require.NotContains(t, l.entries[0].values, "topic")
+	require.Equal(t, "request completed", l.entries[1].msg)
+}
+
+func TestRedactFields(t *testing.T) {
+	req := &internal.Stream{
+		StreamId: "stream",
+		Body: &internal.Stream_Open{
+			Open: &internal.StreamOpen{NodeId: "node", Metadata: map[string]string{"token": "secret"}},
+		},
+	}
+	redacted := RedactFields("metadata", "stream_id")(req).(*internal.Stream)
+	require.Empty(t, redacted.StreamId)
+	require.Equal(t, "node", redacted.GetOpen().NodeId)
+	require.Empty(t, redacted.GetOpen().Metadata)
+	require.Equal(t, "stream", req.StreamId)
+}
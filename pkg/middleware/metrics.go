@@ -16,6 +16,7 @@ package middleware
 
 import (
 	"context"
+	"math/rand"
 	"time"
 
 	"google.golang.org/protobuf/proto"
@@ -51,31 +52,98 @@ type MetricsObserver interface {
 	OnStreamClose(role MetricRole, rpcInfo psrpc.RPCInfo)
 }
 
+// ClaimObserver can be implemented by a MetricsObserver to record server selection for client requests that require a claim
+type ClaimObserver interface {
+	OnClaim(rpcInfo psrpc.RPCInfo, duration time.Duration, claims int, affinityTimedOut bool)
+}
+
+// PayloadObserver can be implemented by a MetricsObserver to record the size in bytes of request and response payloads
+// as published. Sizes are recorded for the fraction of payloads returned by PayloadSampleRate
+type PayloadObserver interface {
+	PayloadSampleRate() float64
+	OnRequestSize(role MetricRole, rpcInfo psrpc.RPCInfo, size int)
+	OnResponseSize(role MetricRole, rpcInfo psrpc.RPCInfo, size int)
+}
+
+// HandlerObserver can be implemented by a MetricsObserver to record the counters of each server handler, which are
+// reported each time one of its requests starts or completes
+type HandlerObserver interface {
+	OnHandlerMetrics(metrics psrpc.HandlerMetrics)
+}
+
 func WithClientMetrics(observer MetricsObserver) psrpc.ClientOption {
-	return psrpc.WithClientOptions(
+	opts := []psrpc.ClientOption{
 		psrpc.WithClientRPCInterceptors(newClientRPCMetricsInterceptor(observer)),
 		psrpc.WithClientMultiRPCInterceptors(newMultiRPCMetricsInterceptor(observer)),
 		psrpc.WithClientStreamInterceptors(newStreamMetricsInterceptor(observer, ClientRole)),
-	)
+	}
+	if payloadObserver, ok := observer.(PayloadObserver); ok {
+		opts = append(opts, psrpc.WithClientPayloadSizes(newPayloadSizeHandler(payloadObserver, ClientRole)))
+	}
+	return psrpc.WithClientOptions(opts...)
 }
 
 func WithServerMetrics(observer MetricsObserver) psrpc.ServerOption {
-	return psrpc.WithServerOptions(
+	opts := []psrpc.ServerOption{
 		psrpc.WithServerRPCInterceptors(newServerRPCMetricsInterceptor(observer)),
 		psrpc.WithServerStreamInterceptors(newStreamMetricsInterceptor(observer, ServerRole)),
-	)
+	}
+	if handlerObserver, ok := observer.(HandlerObserver); ok {
+		opts = append(opts, psrpc.WithServerHandlerMetrics(handlerObserver.OnHandlerMetrics))
+	}
+	if payloadObserver, ok := observer.(PayloadObserver); ok {
+		opts = append(opts, psrpc.WithServerPayloadSizes(newPayloadSizeHandler(payloadObserver, ServerRole)))
+	}
+	return psrpc.WithServerOptions(opts...)
 }
 
 func newClientRPCMetricsInterceptor(observer MetricsObserver) psrpc.ClientRPCInterceptor {
+	claimObserver, _ := observer.(ClaimObserver)
 	return func(rpcInfo psrpc.RPCInfo, next psrpc.ClientRPCHandler) psrpc.ClientRPCHandler {
 		return func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (res proto.Message, err error) {
+			var ri *psrpc.ResponseInfo
+			if claimObserver != nil {
+				ri, opts = withResponseInfo(opts)
+			}
 			start := time.Now()
-			defer func() { observer.OnUnaryRequest(ClientRole, rpcInfo, time.Since(start), err) }()
+			defer func() {
+				observer.OnUnaryRequest(ClientRole, rpcInfo, time.Since(start), err)
+				if ri != nil && ri.ClaimLatency > 0 {
+					claimObserver.OnClaim(rpcInfo, ri.ClaimLatency, ri.Claims, ri.AffinityTimedOut)
+				}
+			}()
 			return next(ctx, req, opts...)
 		}
 	}
 }
 
+func newPayloadSizeHandler(observer PayloadObserver, role MetricRole) psrpc.PayloadSizeHandler {
+	return func(p psrpc.PayloadSize) {
+		rate := observer.PayloadSampleRate()
+		if rate < 1 && (rate <= 0 || rand.Float64() >= rate) {
+			return
+		}
+		if p.Response {
+			observer.OnResponseSize(role, p.RPCInfo, p.Size)
+		} else {
+			observer.OnRequestSize(role, p.RPCInfo, p.Size)
+		}
+	}
+}
+
+// withResponseInfo returns the response info passed with opts, adding one if the caller did not request it
+func withResponseInfo(opts []psrpc.RequestOption) (*psrpc.ResponseInfo, []psrpc.RequestOption) {
+	o := &psrpc.RequestOpts{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.ResponseInfo != nil {
+		return o.ResponseInfo, opts
+	}
+	ri := &psrpc.ResponseInfo{}
+	return ri, append(opts[:len(opts):len(opts)], psrpc.WithResponseInfo(ri))
+}
+
 func newServerRPCMetricsInterceptor(observer MetricsObserver) psrpc.ServerRPCInterceptor {
 	return func(ctx context.Context, req proto.Message, rpcInfo psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (res proto.Message, err error) {
 		start := time.Now()
@@ -36,3 +36,20 @@ func WithServerRecovery() psrpc.ServerRPCInterceptor {
 		return
 	}
 }
+
+type PanicHandler func(ctx context.Context, req proto.Message, info psrpc.RPCInfo, recovered any, stack []byte)
+
+// Recover from server panics, passing the stack trace to onPanic instead of the client. Should always be the last interceptor
+func WithServerRecoveryHandler(onPanic PanicHandler) psrpc.ServerRPCInterceptor {
+	return func(ctx context.Context, req proto.Message, info psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (resp proto.Message, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				onPanic(ctx, req, info, r, debug.Stack())
+				err = psrpc.NewErrorf(psrpc.Internal, "Caught server panic: %v", r)
+			}
+		}()
+
+		resp, err = handler(ctx, req)
+		return
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+)
+
+func TestServerRecoveryHandler(t *testing.T) {
+	var recovered any
+	var stack []byte
+	ri := WithServerRecoveryHandler(func(ctx context.Context, req proto.Message, info psrpc.RPCInfo, r any, s []byte) {
+		recovered = r
+		stack = s
+	})
+
+	_, err := ri(context.Background(), nil, psrpc.RPCInfo{}, func(context.Context, proto.Message) (proto.Message, error) {
+		panic("test panic")
+	})
+	require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.Internal))
+	require.Equal(t, "test panic", recovered)
+	require.NotEmpty(t, stack)
+	require.NotContains(t, err.Error(), "goroutine")
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+)
+
+// Validator checks request messages. *protovalidate.Validator satisfies this interface
+type Validator interface {
+	Validate(msg proto.Message) error
+}
+
+type validateAller interface {
+	ValidateAll() error
+}
+
+type validater interface {
+	Validate() error
+}
+
+// Reject invalid requests with InvalidArgument before the handler runs. If v is nil requests are checked
+// with the ValidateAll or Validate methods generated by protoc-gen-validate.
+// The error message includes the field-level violations
+func WithServerValidation(v Validator) psrpc.ServerRPCInterceptor {
+	return func(ctx context.Context, req proto.Message, _ psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+		if err := validate(v, req); err != nil {
+			return nil, psrpc.NewError(psrpc.InvalidArgument, err)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func validate(v Validator, req proto.Message) error {
+	if v != nil {
+		return v.Validate(req)
+	}
+	switch r := req.(type) {
+	case validateAller:
+		return r.ValidateAll()
+	case validater:
+		return r.Validate()
+	}
+	return nil
+}
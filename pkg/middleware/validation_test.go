@@ -0,0 +1,76 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+)
+
+type testValidator struct{}
+
+func (testValidator) Validate(msg proto.Message) error {
+	if msg.(*internal.Request).RequestId == "" {
+		return errors.New("request_id: value is required")
+	}
+	return nil
+}
+
+type testValidatedRequest struct {
+	*internal.Request
+}
+
+func (r testValidatedRequest) ValidateAll() error {
+	return testValidator{}.Validate(r.Request)
+}
+
+func TestServerValidation(t *testing.T) {
+	var calls int
+	handler := func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		calls++
+		return nil, nil
+	}
+
+	t.Run("TestValidator", func(t *testing.T) {
+		vi := WithServerValidation(testValidator{})
+
+		_, err := vi(context.Background(), &internal.Request{}, psrpc.RPCInfo{}, handler)
+		require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.InvalidArgument))
+		require.Contains(t, err.Error(), "request_id")
+		require.Equal(t, 0, calls)
+
+		_, err = vi(context.Background(), &internal.Request{RequestId: "a"}, psrpc.RPCInfo{}, handler)
+		require.NoError(t, err)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("TestGeneratedMethods", func(t *testing.T) {
+		vi := WithServerValidation(nil)
+
+		_, err := vi(context.Background(), testValidatedRequest{&internal.Request{}}, psrpc.RPCInfo{}, handler)
+		require.ErrorIs(t, err, psrpc.ErrorCode(psrpc.InvalidArgument))
+
+		_, err = vi(context.Background(), &internal.Request{}, psrpc.RPCInfo{}, handler)
+		require.NoError(t, err)
+		require.Equal(t, 2, calls)
+	})
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"runtime/metrics"
+
+	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+)
+
+// InFlightTracker counts running handlers. Add its interceptor to the server to use InFlightAffinity
+type InFlightTracker struct {
+	inflight atomic.Int64
+}
+
+func NewInFlightTracker() *InFlightTracker {
+	return &InFlightTracker{}
+}
+
+func (t *InFlightTracker) Interceptor() psrpc.ServerRPCInterceptor {
+	return func(ctx context.Context, req proto.Message, info psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+		t.inflight.Inc()
+		defer t.inflight.Dec()
+		return handler(ctx, req)
+	}
+}
+
+func (t *InFlightTracker) InFlight() int {
+	return int(t.inflight.Load())
+}
+
+// InFlightAffinity scales affinity down as the number of running handlers approaches limit, and stops claiming at limit
+func InFlightAffinity[RequestType proto.Message](t *InFlightTracker, limit int) AffinityFunc[RequestType] {
+	return LoadAffinity[RequestType](func() float64 {
+		return float64(t.InFlight()) / float64(limit)
+	})
+}
+
+// MemoryAffinity scales affinity down as memory held by the runtime approaches limit bytes, and stops claiming at limit
+func MemoryAffinity[RequestType proto.Message](limit uint64) AffinityFunc[RequestType] {
+	return LoadAffinity[RequestType](func() float64 {
+		samples := []metrics.Sample{
+			{Name: "/memory/classes/total:bytes"},
+			{Name: "/memory/classes/heap/released:bytes"},
+		}
+		metrics.Read(samples)
+		used := samples[0].Value.Uint64() - samples[1].Value.Uint64()
+		return float64(used) / float64(limit)
+	})
+}
+
+// LoadAffinity converts a load between 0 (idle) and 1 (saturated), such as cpu utilization, to an affinity
+func LoadAffinity[RequestType proto.Message](load func() float64) AffinityFunc[RequestType] {
+	return func(context.Context, RequestType) float32 {
+		l := load()
+		if l >= 1 {
+			return -1
+		}
+		if l < 0 {
+			l = 0
+		}
+		return float32(1 - l)
+	}
+}
+
+// CombineAffinity multiplies affinities. If any func declines the request it is not claimed
+func CombineAffinity[RequestType proto.Message](fns ...AffinityFunc[RequestType]) AffinityFunc[RequestType] {
+	return func(ctx context.Context, req RequestType) float32 {
+		affinity := float32(1)
+		for _, fn := range fns {
+			a := fn(ctx, req)
+			if a < 0 {
+				return -1
+			}
+			affinity *= a
+		}
+		return affinity
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+)
+
+func TestInFlightAffinity(t *testing.T) {
+	tracker := NewInFlightTracker()
+	affinity := InFlightAffinity[*internal.Request](tracker, 2)
+	interceptor := tracker.Interceptor()
+
+	ctx := context.Background()
+	require.Equal(t, float32(1), affinity(ctx, nil))
+
+	release := make(chan struct{})
+	running := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, _ = interceptor(ctx, nil, psrpc.RPCInfo{}, func(context.Context, proto.Message) (proto.Message, error) {
+				running <- struct{}{}
+				<-release
+				return nil, nil
+			})
+		}()
+	}
+
+	<-running
+	require.Equal(t, float32(0.5), affinity(ctx, nil))
+	<-running
+	require.Equal(t, float32(-1), affinity(ctx, nil))
+	close(release)
+}
+
+func TestCombineAffinity(t *testing.T) {
+	half := LoadAffinity[*internal.Request](func() float64 { return 0.5 })
+	full := LoadAffinity[*internal.Request](func() float64 { return 1 })
+
+	ctx := context.Background()
+	require.Equal(t, float32(0.25), CombineAffinity(half, half)(ctx, nil))
+	require.Equal(t, float32(-1), CombineAffinity(half, full)(ctx, nil))
+	require.Greater(t, MemoryAffinity[*internal.Request](1<<40)(ctx, nil), float32(0))
+}
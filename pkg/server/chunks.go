@@ -0,0 +1,61 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/internal/bus"
+)
+
+// responseChunkSize returns the size responses to ir are split at, or 0 if they are sent whole. Clients from before
+// chunked responses cannot reassemble them
+func responseChunkSize(s *RPCServer, ir *internal.Request) int {
+	if !bus.SupportsVersion(ir.ProtocolVersion, bus.ChunkedResponseVersion) {
+		return 0
+	}
+	return s.ResponseChunkSize
+}
+
+// publishChunks splits the serialized response into chunks of at most size bytes, which the client reassembles by index
+func publishChunks(ctx context.Context, b bus.MessageBus, channel string, res *internal.Response, size int) error {
+	raw := res.RawResponse
+	count := uint32((len(raw) + size - 1) / size)
+
+	for i := uint32(0); i < count; i++ {
+		chunk := raw[int(i)*size:]
+		if len(chunk) > size {
+			chunk = chunk[:size]
+		}
+
+		msg := &internal.Response{
+			RequestId:       res.RequestId,
+			ServerId:        res.ServerId,
+			SentAt:          res.SentAt,
+			RawResponse:     chunk,
+			Codec:           res.Codec,
+			Compression:     res.Compression,
+			Chunk:           i,
+			ChunkCount:      count,
+			HandlerDuration: res.HandlerDuration,
+			ProtocolVersion: res.ProtocolVersion,
+		}
+		if err := b.Publish(ctx, channel, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
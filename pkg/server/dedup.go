@@ -0,0 +1,78 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gammazero/deque"
+)
+
+// dedupWindow remembers request ids for window so redelivered requests are handled once
+type dedupWindow struct {
+	window time.Duration
+
+	mu    sync.Mutex
+	seen  map[string]struct{}
+	order deque.Deque[dedupEntry]
+}
+
+type dedupEntry struct {
+	requestID string
+	expiry    time.Time
+}
+
+func newDedupWindow(window time.Duration) *dedupWindow {
+	return &dedupWindow{
+		window: window,
+		seen:   make(map[string]struct{}),
+	}
+}
+
+// observe returns true if the request id was already observed within the window
+func (d *dedupWindow) observe(requestID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	d.evictExpired(now)
+	if _, ok := d.seen[requestID]; ok {
+		return true
+	}
+	d.add(now, requestID)
+	return false
+}
+
+// contains returns true if the request id was observed within the window, without recording it
+func (d *dedupWindow) contains(requestID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.evictExpired(time.Now())
+	_, ok := d.seen[requestID]
+	return ok
+}
+
+func (d *dedupWindow) add(now time.Time, requestID string) {
+	d.seen[requestID] = struct{}{}
+	d.order.PushBack(dedupEntry{requestID: requestID, expiry: now.Add(d.window)})
+}
+
+func (d *dedupWindow) evictExpired(now time.Time) {
+	for d.order.Len() > 0 && !now.Before(d.order.Front().expiry) {
+		delete(d.seen, d.order.PopFront().requestID)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/internal/logger"
+	"github.com/livekit/psrpc/pkg/info"
+)
+
+func (s *RPCServer) registerHealthHandler() {
+	s.RegisterMethod(info.HealthMethod, false, true, false, false)
+
+	startedAt := time.Now()
+
+	err := RegisterHandler[*internal.HealthRequest, *internal.HealthResponse](s, info.HealthMethod, nil, func(context.Context, *internal.HealthRequest) (*internal.HealthResponse, error) {
+		return &internal.HealthResponse{
+			ServerId: s.ID,
+			Handlers: s.handlerKeys(),
+			InFlight: s.inflight.Load(), // health checks are not counted
+			Uptime:   int64(time.Since(startedAt)),
+		}, nil
+	}, nil)
+	if err != nil {
+		logger.Error(err, "failed to register health handler")
+	}
+}
+
+// handlerKeys returns the sorted keys of registered handlers, excluding the health handler
+func (s *RPCServer) handlerKeys() []string {
+	healthKey := s.GetInfo(info.HealthMethod, nil).GetHandlerKey()
+
+	s.mu.RLock()
+	handlers := maps.Keys(s.handlers)
+	s.mu.RUnlock()
+
+	handlers = slices.DeleteFunc(handlers, func(key string) bool { return key == healthKey })
+	slices.Sort(handlers)
+	return handlers
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/exp/maps"
+
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/internal/logger"
+	"github.com/livekit/psrpc/pkg/info"
+)
+
+// servers missing this many heartbeats are considered gone
+const heartbeatTTLIntervals = 3
+
+func (s *RPCServer) sendHeartbeats() {
+	ticker := time.NewTicker(s.HeartbeatInterval)
+	defer ticker.Stop()
+
+	closed := s.shutdown.Watch()
+	for {
+		s.sendHeartbeat(heartbeatTTLIntervals * s.HeartbeatInterval)
+
+		select {
+		case <-closed:
+			// an expired heartbeat removes the server from registries
+			s.sendHeartbeat(0)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendHeartbeat publishes the server's status for each service. Registries drop the server after ttl
+func (s *RPCServer) sendHeartbeat(ttl time.Duration) {
+	s.mu.RLock()
+	services := append(maps.Keys(s.services), s.Name)
+	s.mu.RUnlock()
+
+	now := time.Now()
+	handlers := s.handlerKeys()
+	metrics := s.handlerMetrics()
+	for _, service := range services {
+		hb := &internal.ServerHeartbeat{
+			ServerId: s.ID,
+			Service:  service,
+			Handlers: handlers,
+			Labels:   s.Labels,
+			InFlight: s.inflight.Load(),
+			SentAt:   now.UnixNano(),
+			Expiry:   now.Add(ttl).UnixNano(),
+		}
+		for _, m := range metrics {
+			if m.Service == service {
+				hb.HandlerStats = append(hb.HandlerStats, &internal.HandlerStats{
+					Method:    m.Method,
+					Topic:     m.Topic,
+					Multi:     m.Multi,
+					Active:    int64(m.Active),
+					Completed: m.Completed,
+					Errored:   m.Errored,
+					Latency:   int64(m.Latency),
+				})
+			}
+		}
+
+		for _, channel := range []string{info.GetHeartbeatChannel(service), info.GetDeploymentHeartbeatChannel()} {
+			if err := s.bus.Publish(context.Background(), channel, hb); err != nil {
+				logger.Error(err, "failed to publish heartbeat", "service", service)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gammazero/deque"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+)
+
+type idempotencyCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	order   deque.Deque[*idempotencyEntry] // completed entries, oldest first
+}
+
+type idempotencyEntry struct {
+	key    string
+	done   chan struct{}
+	expiry time.Time
+	res    proto.Message
+	err    error
+}
+
+func newIdempotencyCache(ttl time.Duration, maxEntries int) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*idempotencyEntry),
+	}
+}
+
+// loadOrStore returns the existing entry for key, or stores and returns a new pending entry.
+// loaded is true when another request with the same key has already been accepted.
+func (c *idempotencyCache) loadOrStore(key string) (e *idempotencyEntry, loaded bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictExpired(time.Now())
+
+	if e, ok := c.entries[key]; ok {
+		return e, true
+	}
+
+	e = &idempotencyEntry{
+		key:  key,
+		done: make(chan struct{}),
+	}
+	c.entries[key] = e
+	return e, false
+}
+
+// complete releases requests waiting on e. Transient errors are not cached, so a retry runs the handler again
+func (c *idempotencyCache) complete(e *idempotencyEntry, res proto.Message, err error) {
+	c.mu.Lock()
+	e.res = res
+	e.err = err
+	if isTransient(err) {
+		delete(c.entries, e.key)
+	} else {
+		e.expiry = time.Now().Add(c.ttl)
+		c.order.PushBack(e)
+		for c.maxEntries > 0 && len(c.entries) > c.maxEntries && c.order.Len() > 0 {
+			c.evict(c.order.PopFront())
+		}
+	}
+	c.mu.Unlock()
+
+	close(e.done)
+}
+
+func (c *idempotencyCache) evictExpired(now time.Time) {
+	for c.order.Len() > 0 && !now.Before(c.order.Front().expiry) {
+		c.evict(c.order.PopFront())
+	}
+}
+
+func (c *idempotencyCache) evict(e *idempotencyEntry) {
+	if c.entries[e.key] == e {
+		delete(c.entries, e.key)
+	}
+}
+
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var e psrpc.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.Code() {
+	case psrpc.Canceled, psrpc.DeadlineExceeded, psrpc.Unavailable, psrpc.ResourceExhausted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/psrpc"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	t.Run("PendingEntriesDoNotBlockEviction", func(t *testing.T) {
+		c := newIdempotencyCache(10*time.Millisecond, 0)
+		pending, _ := c.loadOrStore("pending")
+		done, _ := c.loadOrStore("done")
+		c.complete(done, nil, nil)
+
+		time.Sleep(20 * time.Millisecond)
+		_, loaded := c.loadOrStore("other")
+		require.False(t, loaded)
+		require.NotContains(t, c.entries, "done")
+		require.Contains(t, c.entries, "pending")
+		c.complete(pending, nil, nil)
+	})
+
+	t.Run("MaxEntries", func(t *testing.T) {
+		c := newIdempotencyCache(time.Minute, 2)
+		for _, key := range []string{"a", "b", "c"} {
+			e, _ := c.loadOrStore(key)
+			c.complete(e, nil, nil)
+		}
+		require.Len(t, c.entries, 2)
+		require.NotContains(t, c.entries, "a")
+	})
+
+	t.Run("TransientErrors", func(t *testing.T) {
+		c := newIdempotencyCache(time.Minute, 0)
+		e, _ := c.loadOrStore("timeout")
+		c.complete(e, nil, psrpc.ErrRequestTimedOut)
+		_, loaded := c.loadOrStore("timeout")
+		require.False(t, loaded)
+
+		e, _ = c.loadOrStore("failed")
+		c.complete(e, nil, errors.New("invalid room"))
+		_, loaded = c.loadOrStore("failed")
+		require.True(t, loaded)
+	})
+}
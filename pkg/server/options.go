@@ -15,6 +15,8 @@
 package server
 
 import (
+	"golang.org/x/exp/maps"
+
 	"github.com/livekit/psrpc"
 	"github.com/livekit/psrpc/internal/bus"
 	"github.com/livekit/psrpc/internal/interceptors"
@@ -22,13 +24,24 @@ import (
 
 func getServerOpts(opts ...psrpc.ServerOption) psrpc.ServerOpts {
 	o := &psrpc.ServerOpts{
-		Timeout:     psrpc.DefaultServerTimeout,
-		ChannelSize: bus.DefaultChannelSize,
+		Timeout:               psrpc.DefaultServerTimeout,
+		ChannelSize:           bus.DefaultChannelSize,
+		IdempotencyTTL:        psrpc.DefaultIdempotencyTTL,
+		IdempotencyMaxEntries: psrpc.DefaultIdempotencyMaxEntries,
 	}
 	for _, opt := range opts {
 		opt(o)
 	}
 
+	if o.Version != "" {
+		labels := maps.Clone(o.Labels)
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[psrpc.VersionLabel] = o.Version
+		o.Labels = labels
+	}
+
 	o.ChainedInterceptor = interceptors.ChainServerInterceptors(o.Interceptors)
 	return *o
 }
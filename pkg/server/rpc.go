@@ -17,10 +17,17 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
 	"sync"
 	"time"
 
+	"go.uber.org/atomic"
+	"golang.org/x/exp/maps"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/livekit/psrpc"
 	"github.com/livekit/psrpc/internal"
@@ -30,6 +37,9 @@ import (
 	"github.com/livekit/psrpc/pkg/metadata"
 )
 
+// canceledRequestWindow is how long cancels are kept for requests that are still queued
+const canceledRequestWindow = 30 * time.Second
+
 type AffinityFunc[RequestType proto.Message] func(context.Context, RequestType) float32
 
 type rpcHandlerImpl[RequestType proto.Message, ResponseType proto.Message] struct {
@@ -37,12 +47,23 @@ type rpcHandlerImpl[RequestType proto.Message, ResponseType proto.Message] struc
 
 	handler      func(context.Context, RequestType) (ResponseType, error)
 	affinityFunc AffinityFunc[RequestType]
+	idempotency  *idempotencyCache
+	dedup        *dedupWindow
+	stats        handlerStats
+	tasks        *scheduler
 
 	mu          sync.RWMutex
 	requestSub  bus.Subscription[*internal.Request]
+	directSub   bus.Subscription[*internal.Request]
+	probeSub    bus.Subscription[*internal.Request]
 	claimSub    bus.Subscription[*internal.ClaimResponse]
 	claims      map[string]chan *internal.ClaimResponse
+	cancelSub   bus.Subscription[*internal.Cancel]
+	cancels     map[string]context.CancelFunc
+	canceled    *dedupWindow
+	active      map[string]psrpc.InFlightRequest
 	handling    sync.WaitGroup
+	stopOnce    sync.Once
 	closeOnce   sync.Once
 	complete    chan struct{}
 	onCompleted func()
@@ -58,42 +79,74 @@ func newRPCHandler[RequestType proto.Message, ResponseType proto.Message](
 
 	ctx := context.Background()
 
-	var requestSub bus.Subscription[*internal.Request]
+	var requestSub, directSub, probeSub bus.Subscription[*internal.Request]
 	var claimSub bus.Subscription[*internal.ClaimResponse]
+	var cancelSub bus.Subscription[*internal.Cancel]
 	var err error
 
-	if i.Queue {
-		requestSub, err = bus.SubscribeQueue[*internal.Request](
-			ctx, s.bus, i.GetRPCChannel(), s.ChannelSize,
-		)
-	} else {
-		requestSub, err = bus.Subscribe[*internal.Request](
-			ctx, s.bus, i.GetRPCChannel(), s.ChannelSize,
-		)
-	}
+	requestSub, err = subscribeTopic[*internal.Request](ctx, s, i, i.GetRPCChannel(), i.Queue)
 	if err != nil {
 		return nil, err
 	}
 
+	if !i.Multi {
+		directSub, err = subscribeTopic[*internal.Request](ctx, s, i, i.GetServerRPCChannel(s.ID), false)
+		if err != nil {
+			_ = requestSub.Close()
+			return nil, err
+		}
+	} else {
+		directSub = bus.EmptySubscription[*internal.Request]{}
+	}
+
 	if i.RequireClaim {
-		claimSub, err = bus.Subscribe[*internal.ClaimResponse](
-			ctx, s.bus, i.GetClaimResponseChannel(), s.ChannelSize,
-		)
+		claimSub, err = subscribeTopic[*internal.ClaimResponse](ctx, s, i, i.GetClaimResponseChannel(), false)
 		if err != nil {
 			_ = requestSub.Close()
+			_ = directSub.Close()
 			return nil, err
 		}
 	} else {
 		claimSub = bus.EmptySubscription[*internal.ClaimResponse]{}
 	}
 
+	cancelSub, err = subscribeTopic[*internal.Cancel](ctx, s, i, i.GetCancelChannel(), false)
+	if err != nil {
+		_ = requestSub.Close()
+		_ = directSub.Close()
+		_ = claimSub.Close()
+		return nil, err
+	}
+
+	probeSub, err = subscribeTopic[*internal.Request](ctx, s, i, i.GetProbeChannel(), false)
+	if err != nil {
+		_ = requestSub.Close()
+		_ = directSub.Close()
+		_ = claimSub.Close()
+		_ = cancelSub.Close()
+		return nil, err
+	}
+
 	h := &rpcHandlerImpl[RequestType, ResponseType]{
 		i:            i,
 		requestSub:   requestSub,
+		directSub:    directSub,
+		probeSub:     probeSub,
 		claimSub:     claimSub,
 		claims:       make(map[string]chan *internal.ClaimResponse),
+		cancelSub:    cancelSub,
+		cancels:      make(map[string]context.CancelFunc),
+		canceled:     newDedupWindow(canceledRequestWindow),
+		active:       make(map[string]psrpc.InFlightRequest),
 		affinityFunc: affinityFunc,
 		complete:     make(chan struct{}),
+		tasks:        newScheduler(s.HandlerConcurrency[i.Method], s.RejectExcess),
+	}
+	if s.IdempotencyTTL > 0 {
+		h.idempotency = newIdempotencyCache(s.IdempotencyTTL, s.IdempotencyMaxEntries)
+	}
+	if s.DedupWindow > 0 {
+		h.dedup = newDedupWindow(s.DedupWindow)
 	}
 
 	if interceptor == nil {
@@ -101,7 +154,9 @@ func newRPCHandler[RequestType proto.Message, ResponseType proto.Message](
 	} else {
 		h.handler = func(ctx context.Context, req RequestType) (ResponseType, error) {
 			var response ResponseType
-			res, err := interceptor(ctx, req, i.RPCInfo, func(context.Context, proto.Message) (proto.Message, error) {
+			// handlers see values added to the context by interceptors, such as restored span contexts
+			ri, _ := psrpc.IncomingRPCInfo(ctx)
+			res, err := interceptor(ctx, req, ri, func(ctx context.Context, _ proto.Message) (proto.Message, error) {
 				return svcImpl(ctx, req)
 			})
 			if res != nil {
@@ -114,27 +169,78 @@ func newRPCHandler[RequestType proto.Message, ResponseType proto.Message](
 	return h, nil
 }
 
+// subscribeTopic subscribes to every channel matching the handler's pattern when it has one
+func subscribeTopic[MessageType proto.Message](
+	ctx context.Context,
+	s *RPCServer,
+	i *info.RequestInfo,
+	channel string,
+	queue bool,
+) (bus.Subscription[MessageType], error) {
+	switch {
+	case i.TopicPattern && queue:
+		return bus.SubscribeQueuePattern[MessageType](ctx, s.bus, channel, s.ChannelSize)
+	case i.TopicPattern:
+		return bus.SubscribePattern[MessageType](ctx, s.bus, channel, s.ChannelSize)
+	case queue:
+		return bus.SubscribeQueue[MessageType](ctx, s.bus, channel, s.ChannelSize)
+	default:
+		return bus.Subscribe[MessageType](ctx, s.bus, channel, s.ChannelSize)
+	}
+}
+
+// rpcInfo returns the handler's info with the topic the request was sent to
+func (h *rpcHandlerImpl[RequestType, ResponseType]) rpcInfo(ir *internal.Request) psrpc.RPCInfo {
+	i := h.i.RPCInfo
+	if h.i.TopicPattern {
+		i.Topic = ir.Topic
+	}
+	return i
+}
+
+// topics with empty tokens in different positions share channels, as do topics matching a pattern, so requests are
+// checked against the topic they carry. Requests from clients that do not send their topic never match patterns
+func (h *rpcHandlerImpl[RequestType, ResponseType]) handlesTopic(ir *internal.Request) bool {
+	if !bus.SupportsVersion(ir.ProtocolVersion, bus.RequestTopicVersion) {
+		return !h.i.TopicPattern
+	}
+	return h.i.MatchTopic(ir.Topic)
+}
+
 func (h *rpcHandlerImpl[RequestType, ResponseType]) run(s *RPCServer) {
 	go func() {
 		requests := h.requestSub.Channel()
+		direct := h.directSub.Channel()
 		claims := h.claimSub.Channel()
+		cancels := h.cancelSub.Channel()
+		probes := h.probeSub.Channel()
 
 		for {
 			select {
 			case <-h.complete:
 				return
 
-			case ir := <-requests:
-				if ir == nil {
+			// closed request subscriptions are disabled while in-flight requests drain
+			case ir, ok := <-requests:
+				if !ok {
+					requests = nil
 					continue
 				}
-				if time.Now().UnixNano() < ir.Expiry {
-					go func() {
-						if err := h.handleRequest(s, ir); err != nil {
-							logger.Error(err, "failed to handle request", "requestID", ir.RequestId)
-						}
-					}()
+				h.dispatchRequest(s, ir)
+
+			case ir, ok := <-direct:
+				if !ok {
+					direct = nil
+					continue
+				}
+				h.dispatchRequest(s, ir)
+
+			case ir, ok := <-probes:
+				if !ok {
+					probes = nil
+					continue
 				}
+				h.dispatchProbe(s, ir)
 
 			case claim := <-claims:
 				if claim == nil {
@@ -144,49 +250,378 @@ func (h *rpcHandlerImpl[RequestType, ResponseType]) run(s *RPCServer) {
 				claimChan, ok := h.claims[claim.RequestId]
 				h.mu.RUnlock()
 				if ok {
-					claimChan <- claim
+					select {
+					case claimChan <- claim:
+					default:
+						s.overflow("claims", claim.RequestId, psrpc.OverflowDropped)
+					}
 				}
+
+			case c := <-cancels:
+				if c == nil {
+					continue
+				}
+				// requests that have not started yet are canceled when they reach the handler
+				h.mu.Lock()
+				if cancel, ok := h.cancels[c.RequestId]; ok {
+					cancel()
+				} else {
+					h.canceled.observe(c.RequestId)
+				}
+				h.mu.Unlock()
 			}
 		}
 	}()
 }
 
+func (h *rpcHandlerImpl[RequestType, ResponseType]) dispatchRequest(s *RPCServer, ir *internal.Request) {
+	if ir == nil || !h.handlesTopic(ir) {
+		return
+	}
+	if time.Now().UnixNano() >= ir.Expiry {
+		h.dropExpired(s, ir)
+		return
+	}
+
+	// probes from older clients arrive on the request channel, and never reach the handler
+	if ir.Probe {
+		h.dispatchProbe(s, ir)
+		return
+	}
+	s.stats.requestsReceived.Inc()
+
+	// requests for servers with other labels are left to them. Requests that are not claimed, such as queue rpcs,
+	// are rejected so the client does not wait for a response
+	if !info.HasLabels(s.Labels, ir.RequiredLabels) {
+		if !ir.Multi && !ir.NoResponse && !h.requiresClaim(ir) {
+			h.rejectLabels(s, ir)
+		}
+		return
+	}
+
+	if h.dedup != nil && h.dedup.observe(ir.RequestId) {
+		return
+	}
+
+	// health checks report overloaded servers instead of being shed with their requests
+	if h.i.Method == info.HealthMethod {
+		h.handling.Add(1)
+		go func() {
+			defer h.handling.Done()
+			if err := h.handleRequest(s, ir, time.Now()); err != nil {
+				logger.Error(err, "failed to handle request", "requestID", ir.RequestId)
+			}
+		}()
+		return
+	}
+
+	// overloaded servers leave claimable requests to their peers and reject the rest
+	if s.MaxInFlight > 0 && int(s.inflight.Load()) >= s.MaxInFlight {
+		if !h.requiresClaim(ir) {
+			h.rejectRequest(s, ir)
+		}
+		return
+	}
+
+	// queued requests would likely time out before they are handled, claimable requests are left to peers
+	if s.MaxQueueDepth > 0 && int(s.inflight.Load()-s.running.Load()) >= s.MaxQueueDepth {
+		if !h.requiresClaim(ir) {
+			h.rejectQueueFull(s, ir)
+		}
+		return
+	}
+
+	received := time.Now()
+	h.handling.Add(1)
+	s.inflight.Inc()
+	finish := func() {
+		s.inflight.Dec()
+		h.handling.Done()
+	}
+
+	run := func() {
+		s.running.Inc()
+		defer s.running.Dec()
+
+		// the request may have expired while queued
+		if time.Now().UnixNano() >= ir.Expiry {
+			h.dropExpired(s, ir)
+			return
+		}
+		if err := h.handleRequest(s, ir, received); err != nil {
+			logger.Error(err, "failed to handle request", "requestID", ir.RequestId)
+		}
+	}
+
+	// requests hold a handler slot while waiting for a server slot
+	accepted := h.tasks.schedule(ir.Priority, func() {
+		defer finish()
+
+		done := make(chan struct{})
+		if !s.tasks.schedule(ir.Priority, func() {
+			defer close(done)
+			run()
+		}) {
+			h.rejectExcess(s, ir)
+			return
+		}
+		<-done
+	})
+	if !accepted {
+		h.rejectExcess(s, ir)
+		finish()
+	}
+}
+
+// rejectExcess rejects requests over the concurrency limits, claimable requests are left to peers
+func (h *rpcHandlerImpl[RequestType, ResponseType]) rejectExcess(s *RPCServer, ir *internal.Request) {
+	if !h.requiresClaim(ir) {
+		h.rejectRequest(s, ir)
+	}
+}
+
+type handlerStats struct {
+	active    atomic.Int64
+	completed atomic.Uint64
+	errored   atomic.Uint64
+	latency   atomic.Int64
+}
+
+func (st *handlerStats) done(latency time.Duration, err error) {
+	st.latency.Add(int64(latency))
+	if err != nil {
+		st.errored.Inc()
+	}
+	st.completed.Inc()
+	st.active.Dec()
+}
+
+func (h *rpcHandlerImpl[RequestType, ResponseType]) reportMetrics(s *RPCServer) {
+	if s.OnHandlerMetrics != nil && h.i.Method != info.HealthMethod {
+		s.OnHandlerMetrics(h.metrics())
+	}
+}
+
+func (h *rpcHandlerImpl[RequestType, ResponseType]) metrics() psrpc.HandlerMetrics {
+	return psrpc.HandlerMetrics{
+		RPCInfo:   h.i.RPCInfo,
+		Active:    int(h.stats.active.Load()),
+		Completed: h.stats.completed.Load(),
+		Errored:   h.stats.errored.Load(),
+		Latency:   time.Duration(h.stats.latency.Load()),
+	}
+}
+
+func (h *rpcHandlerImpl[RequestType, ResponseType]) channelStats() []psrpc.ChannelStats {
+	stats := []psrpc.ChannelStats{channelStats("requests", bus.Stats(h.requestSub))}
+	if !h.i.Multi {
+		stats = append(stats, channelStats("direct", bus.Stats(h.directSub)))
+	}
+	if h.i.RequireClaim {
+		stats = append(stats, channelStats("claims", bus.Stats(h.claimSub)))
+	}
+	return append(stats,
+		channelStats("cancels", bus.Stats(h.cancelSub)),
+		channelStats("probes", bus.Stats(h.probeSub)),
+	)
+}
+
+func (h *rpcHandlerImpl[RequestType, ResponseType]) activeRequests() []psrpc.InFlightRequest {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return maps.Values(h.active)
+}
+
+// requests sent without a response or directed to this server skip the claim handshake
+func (h *rpcHandlerImpl[RequestType, ResponseType]) requiresClaim(ir *internal.Request) bool {
+	return h.i.RequireClaim && !ir.NoResponse && ir.TargetServerId == ""
+}
+
+func (h *rpcHandlerImpl[RequestType, ResponseType]) dropExpired(s *RPCServer, ir *internal.Request) {
+	s.stats.expired.Inc()
+	if s.OnExpired != nil {
+		s.OnExpired(h.rpcInfo(ir), ir.RequestId, time.Unix(0, ir.Expiry))
+	}
+}
+
+func (h *rpcHandlerImpl[RequestType, ResponseType]) rejectRequest(s *RPCServer, ir *internal.Request) {
+	var res ResponseType
+	err := psrpc.NewErrorf(psrpc.ResourceExhausted, "server %s is at capacity", s.ID)
+	if err := h.sendResponse(s, context.Background(), ir, res, err, 0); err != nil {
+		logger.Error(err, "failed to reject request", "requestID", ir.RequestId)
+	}
+}
+
+func (h *rpcHandlerImpl[RequestType, ResponseType]) rejectLabels(s *RPCServer, ir *internal.Request) {
+	var res ResponseType
+	err := psrpc.NewErrorf(psrpc.FailedPrecondition, "server %s does not have the required labels", s.ID)
+	if err := h.sendResponse(s, context.Background(), ir, res, err, 0); err != nil {
+		logger.Error(err, "failed to reject request", "requestID", ir.RequestId)
+	}
+}
+
+func (h *rpcHandlerImpl[RequestType, ResponseType]) rejectQueueFull(s *RPCServer, ir *internal.Request) {
+	var res ResponseType
+	err := psrpc.NewErrorWithDetails(
+		psrpc.Unavailable,
+		fmt.Errorf("server %s queue is full", s.ID),
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(s.QueueRetryAfter)},
+	)
+	if err := h.sendResponse(s, context.Background(), ir, res, err, 0); err != nil {
+		logger.Error(err, "failed to reject request", "requestID", ir.RequestId)
+	}
+}
+
+// probes only check that a server is available
+func (h *rpcHandlerImpl[RequestType, ResponseType]) dispatchProbe(s *RPCServer, ir *internal.Request) {
+	if ir == nil || !h.handlesTopic(ir) || time.Now().UnixNano() >= ir.Expiry {
+		return
+	}
+	go func() {
+		if err := h.answerProbe(s, ir); err != nil {
+			logger.Error(err, "failed to answer probe", "requestID", ir.RequestId)
+		}
+	}()
+}
+
+func (h *rpcHandlerImpl[RequestType, ResponseType]) answerProbe(s *RPCServer, ir *internal.Request) error {
+	return s.bus.Publish(context.Background(), info.GetClaimRequestChannel(h.i.Service, ir.ClientId), &internal.ClaimRequest{
+		RequestId:       ir.RequestId,
+		ServerId:        s.ID,
+		Affinity:        1,
+		Labels:          s.Labels,
+		ProtocolVersion: bus.ProtocolVersion,
+		InstanceId:      s.instanceID,
+	})
+}
+
 func (h *rpcHandlerImpl[RequestType, ResponseType]) handleRequest(
 	s *RPCServer,
 	ir *internal.Request,
+	received time.Time,
 ) error {
-	h.handling.Add(1)
-	defer h.handling.Done()
+	queue := time.Since(received)
+	ri := h.rpcInfo(ir)
+	s.payloadSize(ri, false, ir.RawRequest, ir.PayloadRef)
 
 	head := &metadata.Header{
-		RemoteID: ir.ClientId,
-		SentAt:   time.Unix(0, ir.SentAt),
-		Metadata: ir.Metadata,
+		RemoteID:  ir.ClientId,
+		RequestID: ir.RequestId,
+		SentAt:    time.Unix(0, ir.SentAt),
+		Metadata:  ir.Metadata,
+		ServerID:  s.ID,
+		Service:   ri.Service,
+		Method:    ri.Method,
+		Topic:     ri.Topic,
+		Multi:     ri.Multi,
 	}
 	ctx := metadata.NewContextWithIncomingHeader(context.Background(), head)
 	ctx, cancel := context.WithDeadline(ctx, time.Unix(0, ir.Expiry))
 	defer cancel()
 
-	req, err := bus.DeserializePayload[RequestType](ir.RawRequest)
+	// the client publishes a cancel message when its caller gives up on the request
+	h.mu.Lock()
+	if h.canceled.contains(ir.RequestId) {
+		h.mu.Unlock()
+		return nil
+	}
+	h.cancels[ir.RequestId] = cancel
+	h.active[ir.RequestId] = psrpc.InFlightRequest{
+		RPCInfo:   h.rpcInfo(ir),
+		RequestID: ir.RequestId,
+		RemoteID:  ir.ClientId,
+		StartedAt: time.Now(),
+		Deadline:  time.Unix(0, ir.Expiry),
+	}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.cancels, ir.RequestId)
+		delete(h.active, ir.RequestId)
+		h.mu.Unlock()
+	}()
+
+	req, err := decodeRequest[RequestType](ctx, s, ir)
 	if err != nil {
 		var res ResponseType
 		err = psrpc.NewError(psrpc.MalformedRequest, err)
-		_ = h.sendResponse(s, ctx, ir, res, err)
+		_ = h.sendResponse(s, ctx, ir, res, err, 0)
 		return err
 	}
 
-	if h.i.RequireClaim {
+	var claimTime time.Duration
+	if h.requiresClaim(ir) {
+		claimStart := time.Now()
 		claimed, err := h.claimRequest(s, ctx, ir, req)
 		if err != nil {
 			return err
 		} else if !claimed {
 			return nil
 		}
+		claimTime = time.Since(claimStart)
 	}
 
 	// call handler function and return response
-	response, err := h.handler(ctx, req)
-	return h.sendResponse(s, ctx, ir, response, err)
+	h.stats.active.Inc()
+	h.reportMetrics(s)
+	start := time.Now()
+	response, err := h.callHandler(ctx, ir, req)
+	handlerTime := time.Since(start)
+	h.stats.done(handlerTime, err)
+	h.reportMetrics(s)
+
+	if s.SlowRequestThreshold > 0 {
+		defer func() {
+			if d := time.Since(received); d >= s.SlowRequestThreshold {
+				s.OnSlowRequest(ctx, psrpc.SlowRequest{
+					RPCInfo:   h.rpcInfo(ir),
+					RequestID: ir.RequestId,
+					RemoteID:  ir.ClientId,
+					Duration:  d,
+					Queue:     queue,
+					Claim:     claimTime,
+					Handler:   handlerTime,
+					Response:  time.Since(start.Add(handlerTime)),
+					Err:       err,
+				})
+			}
+		}()
+	}
+
+	// the client stops waiting at the request expiry or when it cancels, so late responses are never consumed
+	if ctx.Err() != nil {
+		return nil
+	}
+	return h.sendResponse(s, ctx, ir, response, err, handlerTime)
+}
+
+func (h *rpcHandlerImpl[RequestType, ResponseType]) callHandler(
+	ctx context.Context,
+	ir *internal.Request,
+	req RequestType,
+) (res proto.Message, err error) {
+	if h.idempotency == nil || ir.IdempotencyKey == "" {
+		return h.handler(ctx, req)
+	}
+
+	e, loaded := h.idempotency.loadOrStore(ir.IdempotencyKey)
+	if loaded {
+		select {
+		case <-e.done:
+			return e.res, e.err
+		case <-ctx.Done():
+			return nil, psrpc.ErrRequestTimedOut
+		}
+	}
+
+	// release duplicates even if the handler panics
+	err = psrpc.NewErrorf(psrpc.Internal, "handler failed")
+	defer func() {
+		h.idempotency.complete(e, res, err)
+	}()
+
+	res, err = h.handler(ctx, req)
+	return
 }
 
 func (h *rpcHandlerImpl[RequestType, ResponseType]) claimRequest(
@@ -198,13 +633,27 @@ func (h *rpcHandlerImpl[RequestType, ResponseType]) claimRequest(
 
 	var affinity float32
 	if h.affinityFunc != nil {
-		affinity = h.affinityFunc(ctx, req)
-		if affinity < 0 {
+		var ok bool
+		affinity, ok = h.getAffinity(s, ctx, req)
+		if !ok || affinity < 0 {
 			return false, nil
 		}
 	} else {
 		affinity = 1
 	}
+	if ir.SessionKey != "" {
+		affinity = sessionAffinity(s.ID, ir.SessionKey)
+	} else if s.ClaimLoadCapacity > 0 {
+		var delay time.Duration
+		affinity, delay = claimBackoff(s, affinity)
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return false, nil
+			}
+		}
+	}
 
 	claimResponseChan := make(chan *internal.ClaimResponse, 1)
 
@@ -218,14 +667,18 @@ func (h *rpcHandlerImpl[RequestType, ResponseType]) claimRequest(
 		h.mu.Unlock()
 	}()
 
-	err := s.bus.Publish(ctx, info.GetClaimRequestChannel(s.Name, ir.ClientId), &internal.ClaimRequest{
-		RequestId: ir.RequestId,
-		ServerId:  s.ID,
-		Affinity:  affinity,
+	err := s.bus.Publish(ctx, info.GetClaimRequestChannel(h.i.Service, ir.ClientId), &internal.ClaimRequest{
+		RequestId:       ir.RequestId,
+		ServerId:        s.ID,
+		Affinity:        affinity,
+		Labels:          s.Labels,
+		ProtocolVersion: bus.ProtocolVersion,
+		InstanceId:      s.instanceID,
 	})
 	if err != nil {
 		return false, err
 	}
+	s.stats.claimsSent.Inc()
 
 	timeout := time.NewTimer(time.Duration(ir.Expiry - time.Now().UnixNano()))
 	defer timeout.Stop()
@@ -235,6 +688,7 @@ func (h *rpcHandlerImpl[RequestType, ResponseType]) claimRequest(
 		if claim.ServerId == s.ID {
 			return true, nil
 		} else {
+			s.stats.claimRacesLost.Inc()
 			return false, nil
 		}
 
@@ -243,17 +697,65 @@ func (h *rpcHandlerImpl[RequestType, ResponseType]) claimRequest(
 	}
 }
 
+// getAffinity returns false if the affinity func did not return within the claim timeout
+func (h *rpcHandlerImpl[RequestType, ResponseType]) getAffinity(s *RPCServer, ctx context.Context, req RequestType) (float32, bool) {
+	if s.ClaimTimeout <= 0 {
+		return h.affinityFunc(ctx, req), true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.ClaimTimeout)
+	defer cancel()
+
+	affinity := make(chan float32, 1)
+	go func() {
+		affinity <- h.affinityFunc(ctx, req)
+	}()
+
+	select {
+	case a := <-affinity:
+		return a, true
+	case <-ctx.Done():
+		return 0, false
+	}
+}
+
+// claimBackoff scales affinity by 1/(1+load), where load is the ratio of other in-flight requests to capacity
+func claimBackoff(s *RPCServer, affinity float32) (float32, time.Duration) {
+	load := float64(s.inflight.Load()-1) / float64(s.ClaimLoadCapacity)
+	if load <= 0 {
+		return affinity, 0
+	}
+	delay := time.Duration(math.Min(load, 1) * float64(s.ClaimMaxDelay))
+	return affinity / float32(1+load), delay
+}
+
+// sessionAffinity is the rendezvous hash weight of the session key for the server, in (0, 1]
+func sessionAffinity(serverID, key string) float32 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(serverID))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	return float32(h.Sum64()>>40+1) / (1 << 24)
+}
+
 func (h *rpcHandlerImpl[RequestType, ResponseType]) sendResponse(
 	s *RPCServer,
 	ctx context.Context,
 	ir *internal.Request,
 	response proto.Message,
 	err error,
+	handlerTime time.Duration,
 ) error {
+	if ir.NoResponse {
+		return err
+	}
+
 	res := &internal.Response{
-		RequestId: ir.RequestId,
-		ServerId:  s.ID,
-		SentAt:    time.Now().UnixNano(),
+		RequestId:       ir.RequestId,
+		ServerId:        s.ID,
+		SentAt:          time.Now().UnixNano(),
+		HandlerDuration: int64(handlerTime),
+		ProtocolVersion: bus.ProtocolVersion,
 	}
 
 	if err != nil {
@@ -266,28 +768,118 @@ func (h *rpcHandlerImpl[RequestType, ResponseType]) sendResponse(
 			res.Error = err.Error()
 			res.Code = string(psrpc.Unknown)
 		}
+		var d psrpc.ErrorWithDetails
+		if errors.As(err, &d) {
+			res.ErrorDetails = bus.SerializeErrorDetails(d.Details())
+		}
 	} else if response != nil {
-		b, err := bus.SerializePayload(response)
+		// responses use the request's codec
+		codec, _ := bus.GetCodec(ir.Codec)
+		encode := bus.EncodePayload
+		if s.DeterministicMarshal {
+			encode = bus.EncodePayloadDeterministic
+		}
+		b, name, err := encode(codec, response)
+		var compression bus.Compression
+		if err == nil {
+			b, compression, err = bus.CompressPayload(s.Compression, s.CompressionThreshold, ir.AcceptCompression, b)
+		}
+		// clients from before payload references cannot load them
+		offload := err == nil && bus.Offloads(s.BlobStore, s.BlobThreshold, b)
+		refUnsupported := offload && !bus.SupportsVersion(ir.ProtocolVersion, bus.PayloadRefVersion)
+		var ref string
+		var offloadErr error
+		if offload && !refUnsupported {
+			key := bus.BlobKey(ir.RequestId, s.ID, "RES")
+			b, ref, offloadErr = bus.OffloadPayload(ctx, s.BlobStore, s.BlobThreshold, key, bus.BlobTTL(ir.Expiry), b)
+		}
 		if err != nil {
 			res.Error = err.Error()
 			res.Code = string(psrpc.MalformedResponse)
+		} else if refUnsupported {
+			res.Error = fmt.Sprintf("response of %d bytes must be sent by reference, which client protocol version %d does not support", len(b), ir.ProtocolVersion)
+			res.Code = string(psrpc.FailedPrecondition)
+		} else if offloadErr != nil {
+			res.Error = offloadErr.Error()
+			res.Code = string(psrpc.Internal)
+		} else if chunkSize := responseChunkSize(s, ir); s.MaxMessageSize > 0 && len(b) > s.MaxMessageSize &&
+			(chunkSize <= 0 || chunkSize > s.MaxMessageSize) {
+			res.Error = fmt.Sprintf("response of %d bytes exceeds max message size of %d bytes", len(b), s.MaxMessageSize)
+			res.Code = string(psrpc.ResourceExhausted)
 		} else {
 			res.RawResponse = b
+			res.Codec = name
+			res.Compression = string(compression)
+			res.PayloadRef = ref
 		}
 	}
 
-	return s.bus.Publish(ctx, info.GetResponseChannel(s.Name, ir.ClientId), res)
+	channel := info.GetResponseChannel(h.i.Service, ir.ClientId)
+	var sendErr error
+	if chunkSize := responseChunkSize(s, ir); chunkSize <= 0 || len(res.RawResponse) <= chunkSize {
+		sendErr = s.bus.Publish(ctx, channel, res)
+	} else {
+		sendErr = publishChunks(ctx, s.bus, channel, res, chunkSize)
+	}
+	if sendErr == nil {
+		s.stats.responsesSent.Inc()
+		if res.Error == "" {
+			s.payloadSize(h.rpcInfo(ir), true, res.RawResponse, res.PayloadRef)
+		}
+	}
+	return sendErr
+}
+
+func (h *rpcHandlerImpl[RequestType, ResponseType]) stopRequests() {
+	h.stopOnce.Do(func() {
+		_ = h.requestSub.Close()
+		_ = h.directSub.Close()
+		_ = h.probeSub.Close()
+	})
+}
+
+// drain stops receiving requests and waits for in-flight requests to complete, or for ctx to be done
+func (h *rpcHandlerImpl[RequestType, ResponseType]) drain(ctx context.Context) {
+	h.stopRequests()
+
+	done := make(chan struct{})
+	go func() {
+		h.handling.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
 }
 
 func (h *rpcHandlerImpl[RequestType, ResponseType]) close(force bool) {
 	h.closeOnce.Do(func() {
-		_ = h.requestSub.Close()
+		h.stopRequests()
 		if !force {
 			h.handling.Wait()
 		}
 		_ = h.claimSub.Close()
+		_ = h.cancelSub.Close()
 		h.onCompleted()
 		close(h.complete)
 	})
 	<-h.complete
 }
+
+func decodeRequest[RequestType proto.Message](ctx context.Context, s *RPCServer, ir *internal.Request) (RequestType, error) {
+	var v RequestType
+	b, err := bus.LoadPayload(ctx, s.BlobStore, ir.PayloadRef, ir.RawRequest)
+	if err != nil {
+		return v, err
+	}
+	b, err = bus.DecompressPayload(bus.Compression(ir.Compression), b)
+	if err != nil {
+		return v, err
+	}
+	if s.StrictUnmarshal {
+		return bus.DecodePayloadStrict[RequestType](ir.Codec, b)
+	}
+	return bus.DecodePayload[RequestType](ir.Codec, b)
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// scheduler runs at most limit tasks at once, queueing the rest by priority or rejecting them
+type scheduler struct {
+	limit  int
+	reject bool
+
+	mu     sync.Mutex
+	active int
+	seq    uint64
+	queue  taskQueue
+}
+
+type task struct {
+	priority int32
+	seq      uint64
+	run      func()
+}
+
+func newScheduler(limit int, reject bool) *scheduler {
+	return &scheduler{limit: limit, reject: reject}
+}
+
+// schedule returns false if the task was rejected
+func (s *scheduler) schedule(priority int32, run func()) bool {
+	if s.limit <= 0 {
+		go run()
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reject && s.active >= s.limit {
+		return false
+	}
+	s.seq++
+	heap.Push(&s.queue, &task{priority: priority, seq: s.seq, run: run})
+	s.startTasks()
+	return true
+}
+
+func (s *scheduler) startTasks() {
+	for s.active < s.limit && s.queue.Len() > 0 {
+		t := heap.Pop(&s.queue).(*task)
+		s.active++
+		go func() {
+			t.run()
+
+			s.mu.Lock()
+			s.active--
+			s.startTasks()
+			s.mu.Unlock()
+		}()
+	}
+}
+
+type taskQueue []*task
+
+func (q taskQueue) Len() int { return len(q) }
+
+func (q taskQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q taskQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *taskQueue) Push(x any) { *q = append(*q, x.(*task)) }
+
+func (q *taskQueue) Pop() any {
+	old := *q
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return t
+}
@@ -16,30 +16,65 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"expvar"
+	"net/http"
 	"sync"
+	"time"
 
 	"github.com/frostbyte73/core"
+	"go.uber.org/atomic"
 	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
 	"github.com/livekit/psrpc/internal/bus"
+	"github.com/livekit/psrpc/internal/logger"
 	"github.com/livekit/psrpc/pkg/info"
+	"github.com/livekit/psrpc/pkg/rand"
+)
+
+var (
+	errHandlerExists     = errors.New("handler already exists")
+	errServiceNotAdded   = errors.New("service not added to server")
+	errServiceNameExists = errors.New("service already added to server")
 )
 
 type rpcHandler interface {
+	drain(ctx context.Context)
 	close(force bool)
+	channelStats() []psrpc.ChannelStats
+}
+
+// implemented by unary and multi handlers
+type metricsHandler interface {
+	metrics() psrpc.HandlerMetrics
+	activeRequests() []psrpc.InFlightRequest
 }
 
 type RPCServer struct {
 	*info.ServiceDefinition
 	psrpc.ServerOpts
 
-	bus bus.MessageBus
+	bus        bus.MessageBus
+	instanceID string // distinguishes servers restarted with the same id
+
+	mu           sync.RWMutex
+	services     map[string]*info.ServiceDefinition
+	handlers     map[string]rpcHandler
+	reserved     map[string]struct{}
+	streamRoutes map[string]*streamRoute
+	tasks        *scheduler
+
+	streamMu   sync.Mutex
+	streamSubs map[string]bus.Subscription[*internal.Stream]
 
-	mu       sync.RWMutex
-	handlers map[string]rpcHandler
+	inflight atomic.Int64
+	running  atomic.Int64
+	stats    serverStats
 	active   sync.WaitGroup
 	shutdown core.Fuse
 }
@@ -49,12 +84,22 @@ func NewRPCServer(sd *info.ServiceDefinition, b bus.MessageBus, opts ...psrpc.Se
 		ServiceDefinition: sd,
 		ServerOpts:        getServerOpts(opts...),
 		bus:               b,
+		instanceID:        rand.NewString(),
+		services:          make(map[string]*info.ServiceDefinition),
 		handlers:          make(map[string]rpcHandler),
+		reserved:          make(map[string]struct{}),
+		streamSubs:        make(map[string]bus.Subscription[*internal.Stream]),
+		streamRoutes:      make(map[string]*streamRoute),
 		shutdown:          core.NewFuse(),
 	}
+	s.tasks = newScheduler(s.MaxConcurrency, s.RejectExcess)
 	if s.ServerID != "" {
 		s.ID = s.ServerID
 	}
+	s.registerHealthHandler()
+	if s.HeartbeatInterval > 0 {
+		go s.sendHeartbeats()
+	}
 
 	return s
 }
@@ -65,89 +110,294 @@ func RegisterHandler[RequestType proto.Message, ResponseType proto.Message](
 	topic []string,
 	svcImpl func(context.Context, RequestType) (ResponseType, error),
 	affinityFunc AffinityFunc[RequestType],
+) error {
+	return registerHandler(s, s.GetInfo(rpc, topic), svcImpl, affinityFunc)
+}
+
+// RegisterServiceHandler registers a handler for a service added to the server with AddService
+func RegisterServiceHandler[RequestType proto.Message, ResponseType proto.Message](
+	s *RPCServer,
+	service string,
+	rpc string,
+	topic []string,
+	svcImpl func(context.Context, RequestType) (ResponseType, error),
+	affinityFunc AffinityFunc[RequestType],
+) error {
+	s.mu.RLock()
+	sd, ok := s.services[service]
+	s.mu.RUnlock()
+	if !ok {
+		return errServiceNotAdded
+	}
+	return registerHandler(s, sd.GetInfo(rpc, topic), svcImpl, affinityFunc)
+}
+
+func registerHandler[RequestType proto.Message, ResponseType proto.Message](
+	s *RPCServer,
+	i *info.RequestInfo,
+	svcImpl func(context.Context, RequestType) (ResponseType, error),
+	affinityFunc AffinityFunc[RequestType],
 ) error {
 	if s.shutdown.IsBroken() {
 		return psrpc.ErrServerClosed
 	}
 
-	i := s.GetInfo(rpc, topic)
-
-	key := i.GetHandlerKey()
-	s.mu.RLock()
-	_, ok := s.handlers[key]
-	s.mu.RUnlock()
-	if ok {
-		return errors.New("handler already exists")
+	// concurrent registrations for the same topic fail before subscribing, so they never consume requests
+	key := s.handlerKey(i)
+	if !s.reserveHandler(key) {
+		return errHandlerExists
 	}
 
 	// create handler
 	h, err := newRPCHandler(s, i, svcImpl, s.ChainedInterceptor, affinityFunc)
 	if err != nil {
+		s.releaseHandler(key)
 		return err
 	}
 
-	s.active.Add(1)
 	h.onCompleted = func() {
-		s.active.Done()
-		s.mu.Lock()
-		delete(s.handlers, key)
-		s.mu.Unlock()
+		s.removeHandler(key, h)
 	}
-
-	s.mu.Lock()
-	s.handlers[key] = h
-	s.mu.Unlock()
+	s.storeHandler(key, h)
 
 	h.run(s)
 	return nil
 }
 
+// RegisterTopicsHandler registers svcImpl for each topic. Handlers can look up the topic a request was sent to with psrpc.IncomingRPCInfo.
+// Each topic has its own subscriptions, RegisterTopicPatternHandler shares them between every matching topic.
+// If any registration fails the handlers registered so far are removed
+func RegisterTopicsHandler[RequestType proto.Message, ResponseType proto.Message](
+	s *RPCServer,
+	rpc string,
+	topics [][]string,
+	svcImpl func(context.Context, RequestType) (ResponseType, error),
+	affinityFunc AffinityFunc[RequestType],
+) error {
+	for i, topic := range topics {
+		if err := RegisterHandler(s, rpc, topic, svcImpl, affinityFunc); err != nil {
+			for _, registered := range topics[:i] {
+				s.DeregisterHandler(rpc, registered)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterTopicPatternHandler registers svcImpl for every topic matching pattern, with a single set of subscriptions.
+// Each * in a pattern token matches any characters within that token, so {"room.*"} matches {"room.a"} but not
+// {"room.a", "b"}. Handlers can look up the topic a request was sent to with psrpc.IncomingRPCInfo.
+//
+// Only requests from clients that send their topic are handled, and the bus must support patterns. The local and
+// redis buses do, nats returns psrpc.ErrPatternsUnsupported
+func RegisterTopicPatternHandler[RequestType proto.Message, ResponseType proto.Message](
+	s *RPCServer,
+	rpc string,
+	pattern []string,
+	svcImpl func(context.Context, RequestType) (ResponseType, error),
+	affinityFunc AffinityFunc[RequestType],
+) error {
+	i := s.GetInfo(rpc, pattern)
+	i.TopicPattern = true
+	return registerHandler(s, i, svcImpl, affinityFunc)
+}
+
 func RegisterStreamHandler[RequestType proto.Message, ResponseType proto.Message](
 	s *RPCServer,
 	rpc string,
 	topic []string,
 	svcImpl func(psrpc.ServerStream[ResponseType, RequestType]) error,
 	affinityFunc StreamAffinityFunc,
+) error {
+	return registerStreamHandler(s, s.GetInfo(rpc, topic), svcImpl, affinityFunc)
+}
+
+// RegisterServiceStreamHandler registers a stream handler for a service added to the server with AddService
+func RegisterServiceStreamHandler[RequestType proto.Message, ResponseType proto.Message](
+	s *RPCServer,
+	service string,
+	rpc string,
+	topic []string,
+	svcImpl func(psrpc.ServerStream[ResponseType, RequestType]) error,
+	affinityFunc StreamAffinityFunc,
+) error {
+	s.mu.RLock()
+	sd, ok := s.services[service]
+	s.mu.RUnlock()
+	if !ok {
+		return errServiceNotAdded
+	}
+	return registerStreamHandler(s, sd.GetInfo(rpc, topic), svcImpl, affinityFunc)
+}
+
+func registerStreamHandler[RequestType proto.Message, ResponseType proto.Message](
+	s *RPCServer,
+	i *info.RequestInfo,
+	svcImpl func(psrpc.ServerStream[ResponseType, RequestType]) error,
+	affinityFunc StreamAffinityFunc,
 ) error {
 	if s.shutdown.IsBroken() {
 		return psrpc.ErrServerClosed
 	}
 
-	i := s.GetInfo(rpc, topic)
-
-	key := i.GetHandlerKey()
-	s.mu.RLock()
-	_, ok := s.handlers[key]
-	s.mu.RUnlock()
-	if ok {
-		return errors.New("handler already exists")
+	key := s.handlerKey(i)
+	if !s.reserveHandler(key) {
+		return errHandlerExists
 	}
 
 	// create handler
 	h, err := newStreamRPCHandler(s, i, svcImpl, affinityFunc)
 	if err != nil {
+		s.releaseHandler(key)
 		return err
 	}
 
-	s.active.Add(1)
 	h.onCompleted = func() {
-		s.active.Done()
-		s.mu.Lock()
-		delete(s.handlers, key)
-		s.mu.Unlock()
+		s.removeHandler(key, h)
+	}
+	s.storeHandler(key, h)
+
+	h.run(s)
+	return nil
+}
+
+// RegisterServerStreamHandler registers a handler that sends any number of responses to a single request.
+// The stream is closed with psrpc.ErrStreamEOF when svcImpl returns nil
+func RegisterServerStreamHandler[RequestType proto.Message, ResponseType proto.Message](
+	s *RPCServer,
+	rpc string,
+	topic []string,
+	svcImpl func(context.Context, RequestType, psrpc.StreamWriter[ResponseType]) error,
+	affinityFunc StreamAffinityFunc,
+) error {
+	return RegisterStreamHandler(s, rpc, topic, func(stream psrpc.ServerStream[ResponseType, RequestType]) error {
+		timeout := time.NewTimer(s.Timeout)
+		defer timeout.Stop()
+
+		select {
+		case req, ok := <-stream.Channel():
+			if !ok {
+				return stream.Err()
+			}
+			if err := svcImpl(stream.Context(), req, stream); err != nil {
+				return err
+			}
+			return psrpc.ErrStreamEOF
+
+		case <-timeout.C:
+			return psrpc.ErrRequestTimedOut
+		}
+	}, affinityFunc)
+}
+
+// RegisterClientStreamHandler registers a handler that receives any number of requests and sends a single response
+func RegisterClientStreamHandler[RequestType proto.Message, ResponseType proto.Message](
+	s *RPCServer,
+	rpc string,
+	topic []string,
+	svcImpl func(context.Context, psrpc.StreamReader[RequestType]) (ResponseType, error),
+	affinityFunc StreamAffinityFunc,
+) error {
+	return RegisterStreamHandler(s, rpc, topic, func(stream psrpc.ServerStream[ResponseType, RequestType]) error {
+		res, err := svcImpl(stream.Context(), stream)
+		if err != nil {
+			return err
+		}
+		if err = stream.Send(res); err != nil {
+			return err
+		}
+		return psrpc.ErrStreamEOF
+	}, affinityFunc)
+}
+
+// reserveHandler returns false if a handler is registered or being registered for key
+func (s *RPCServer) reserveHandler(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.handlers[key]; ok {
+		return false
+	}
+	if _, ok := s.reserved[key]; ok {
+		return false
 	}
+	s.reserved[key] = struct{}{}
+	return true
+}
+
+func (s *RPCServer) releaseHandler(key string) {
+	s.mu.Lock()
+	delete(s.reserved, key)
+	s.mu.Unlock()
+}
 
+func (s *RPCServer) storeHandler(key string, h rpcHandler) {
 	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.reserved, key)
 	s.handlers[key] = h
+	s.active.Add(1)
+}
+
+func (s *RPCServer) removeHandler(key string, h rpcHandler) {
+	s.mu.Lock()
+	if s.handlers[key] == h {
+		delete(s.handlers, key)
+	}
 	s.mu.Unlock()
+	s.active.Done()
+}
 
-	h.run(s)
+// AddService lets one server handle requests for several services, sharing its bus and limits.
+// The definition is copied, so its methods are registered before it is added. Handlers for the service are
+// registered with RegisterServiceHandler
+func (s *RPCServer) AddService(sd *info.ServiceDefinition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.services[sd.Name]; ok || sd.Name == s.Name {
+		return errServiceNameExists
+	}
+	added := &info.ServiceDefinition{Name: sd.Name, ID: s.ID}
+	sd.Methods.Range(func(key, value any) bool {
+		added.Methods.Store(key, value)
+		return true
+	})
+	s.services[sd.Name] = added
 	return nil
 }
 
+func (s *RPCServer) DeregisterServiceHandler(service, rpc string, topic []string) {
+	s.mu.RLock()
+	sd, ok := s.services[service]
+	s.mu.RUnlock()
+	if ok {
+		s.deregisterHandler(sd.GetInfo(rpc, topic))
+	}
+}
+
+// handlers for added services are keyed by service, since their methods may share names with the server's own
+func (s *RPCServer) handlerKey(i *info.RequestInfo) string {
+	if i.Service != s.Name {
+		return i.GetServiceHandlerKey()
+	}
+	return i.GetHandlerKey()
+}
+
 func (s *RPCServer) DeregisterHandler(rpc string, topic []string) {
-	i := s.GetInfo(rpc, topic)
-	key := i.GetHandlerKey()
+	s.deregisterHandler(s.GetInfo(rpc, topic))
+}
+
+func (s *RPCServer) DeregisterTopicPatternHandler(rpc string, pattern []string) {
+	i := s.GetInfo(rpc, pattern)
+	i.TopicPattern = true
+	s.deregisterHandler(i)
+}
+
+func (s *RPCServer) deregisterHandler(i *info.RequestInfo) {
+	key := s.handlerKey(i)
 	s.mu.RLock()
 	h, ok := s.handlers[key]
 	s.mu.RUnlock()
@@ -161,6 +411,174 @@ func (s *RPCServer) Publish(ctx context.Context, rpc string, topic []string, msg
 	return s.bus.Publish(ctx, i.GetRPCChannel(), msg)
 }
 
+// handlerMetrics returns counters for each unary and multi handler, sorted by method and topic
+func (s *RPCServer) handlerMetrics() []psrpc.HandlerMetrics {
+	s.mu.RLock()
+	keys := maps.Keys(s.handlers)
+	slices.Sort(keys)
+	var metrics []psrpc.HandlerMetrics
+	for _, key := range keys {
+		if h, ok := s.handlers[key].(metricsHandler); ok {
+			if m := h.metrics(); m.Method != info.HealthMethod {
+				metrics = append(metrics, m)
+			}
+		}
+	}
+	s.mu.RUnlock()
+	return metrics
+}
+
+func (s *RPCServer) overflow(channel, requestID string, reason psrpc.OverflowReason) {
+	if s.OnOverflow != nil {
+		s.OnOverflow(psrpc.Overflow{
+			Channel:   channel,
+			RequestID: requestID,
+			Reason:    reason,
+		})
+	}
+}
+
+func (s *RPCServer) payloadSize(i psrpc.RPCInfo, response bool, raw []byte, ref string) {
+	if s.OnPayloadSize != nil {
+		s.OnPayloadSize(psrpc.PayloadSize{
+			RPCInfo:  i,
+			Response: response,
+			Size:     len(raw) + len(ref),
+		})
+	}
+}
+
+type serverStats struct {
+	requestsReceived atomic.Uint64
+	responsesSent    atomic.Uint64
+	expired          atomic.Uint64
+	claimsSent       atomic.Uint64
+	claimRacesLost   atomic.Uint64
+	subscriptions    atomic.Int64
+}
+
+// Stats returns a snapshot of the server's counters
+func (s *RPCServer) Stats() psrpc.ServerStats {
+	return psrpc.ServerStats{
+		RequestsReceived:    s.stats.requestsReceived.Load(),
+		ResponsesSent:       s.stats.responsesSent.Load(),
+		Expired:             s.stats.expired.Load(),
+		ClaimsSent:          s.stats.claimsSent.Load(),
+		ClaimRacesLost:      s.stats.claimRacesLost.Load(),
+		ActiveRequests:      int(s.inflight.Load()),
+		ActiveSubscriptions: int(s.stats.subscriptions.Load()),
+	}
+}
+
+// StatsVar returns the server's stats as an expvar.Var, to be added with expvar.Publish
+func (s *RPCServer) StatsVar() expvar.Var {
+	return expvar.Func(func() any { return s.Stats() })
+}
+
+// ChannelStats returns the backlog of each handler's subscriptions, sorted by handler. Channels are named by the
+// handler key and the subscription, such as requests or claims
+func (s *RPCServer) ChannelStats() []psrpc.ChannelStats {
+	s.mu.RLock()
+	keys := maps.Keys(s.handlers)
+	slices.Sort(keys)
+	var stats []psrpc.ChannelStats
+	for _, key := range keys {
+		for _, c := range s.handlers[key].channelStats() {
+			c.Channel = key + ":" + c.Channel
+			stats = append(stats, c)
+		}
+	}
+	s.mu.RUnlock()
+	return stats
+}
+
+func channelStats(name string, b bus.ChannelStats) psrpc.ChannelStats {
+	return psrpc.ChannelStats{
+		Channel:  name,
+		Depth:    b.Depth,
+		Capacity: b.Capacity,
+		Blocked:  b.Blocked,
+		Dropped:  b.Dropped,
+	}
+}
+
+// ActiveRequests returns the requests being handled by unary and multi handlers, oldest first
+func (s *RPCServer) ActiveRequests() []psrpc.InFlightRequest {
+	s.mu.RLock()
+	handlers := maps.Values(s.handlers)
+	s.mu.RUnlock()
+
+	var requests []psrpc.InFlightRequest
+	for _, h := range handlers {
+		if h, ok := h.(metricsHandler); ok {
+			for _, r := range h.activeRequests() {
+				if r.Method != info.HealthMethod {
+					requests = append(requests, r)
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	for i := range requests {
+		requests[i].Age = now.Sub(requests[i].StartedAt)
+	}
+	slices.SortFunc(requests, func(a, b psrpc.InFlightRequest) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	return requests
+}
+
+// ActiveRequestsHandler writes the active requests as json, for debugging stuck handlers
+func (s *RPCServer) ActiveRequestsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(s.ActiveRequests()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Shutdown stops accepting new requests and waits for in-flight handlers before closing the server.
+// Handlers still running when ctx is done or the grace period expires are abandoned.
+func (s *RPCServer) Shutdown(ctx context.Context) error {
+	// tell clients to stop sending requests to this server while it drains
+	leaving := &internal.ServerLeaving{ServerId: s.ID, InstanceId: s.instanceID}
+	if s.ShutdownGracePeriod > 0 {
+		leaving.DrainDeadline = time.Now().Add(s.ShutdownGracePeriod).UnixNano()
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.ShutdownGracePeriod)
+		defer cancel()
+	}
+
+	s.mu.RLock()
+	handlers := maps.Values(s.handlers)
+	services := append(maps.Keys(s.services), s.Name)
+	s.mu.RUnlock()
+
+	for _, service := range services {
+		if err := s.bus.Publish(ctx, info.GetServerLeavingChannel(service), leaving); err != nil {
+			logger.Error(err, "failed to publish server leaving", "service", service)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, h := range handlers {
+		wg.Add(1)
+		h := h
+		go func() {
+			h.drain(ctx)
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	err := ctx.Err()
+	s.Close(true)
+	return err
+}
+
 func (s *RPCServer) Close(force bool) {
 	s.shutdown.Once(func() {
 		s.mu.RLock()
@@ -47,6 +47,7 @@ type streamHandler[RecvType, SendType proto.Message] struct {
 	streams     map[string]stream.Stream[SendType, RecvType]
 	claims      map[string]chan *internal.ClaimResponse
 	draining    atomic.Bool
+	dropped     atomic.Uint64
 	closeOnce   sync.Once
 	complete    chan struct{}
 	onCompleted func()
@@ -69,6 +70,12 @@ func newStreamRPCHandler[RecvType, SendType proto.Message](
 
 	var claimSub bus.Subscription[*internal.ClaimResponse]
 	if i.RequireClaim {
+		// clients send messages for claimed streams to the claiming server
+		if err = s.subscribeStreams(i.Service); err != nil {
+			_ = streamSub.Close()
+			return nil, err
+		}
+
 		claimSub, err = bus.Subscribe[*internal.ClaimResponse](
 			ctx, s.bus, i.GetClaimResponseChannel(), s.ChannelSize,
 		)
@@ -104,14 +111,7 @@ func (h *streamHandler[RecvType, SendType]) run(s *RPCServer) {
 				return
 
 			case is := <-requests:
-				if is == nil {
-					continue
-				}
-				if time.Now().UnixNano() < is.Expiry {
-					if err := h.handleRequest(s, is); err != nil {
-						logger.Error(err, "failed to handle request", "requestID", is.RequestId)
-					}
-				}
+				h.handleStreamMessage(s, is)
 
 			case claim := <-claims:
 				if claim == nil {
@@ -121,13 +121,28 @@ func (h *streamHandler[RecvType, SendType]) run(s *RPCServer) {
 				claimChan, ok := h.claims[claim.RequestId]
 				h.mu.RUnlock()
 				if ok {
-					claimChan <- claim
+					select {
+					case claimChan <- claim:
+					default:
+						s.overflow("claims", claim.RequestId, psrpc.OverflowDropped)
+					}
 				}
 			}
 		}
 	}()
 }
 
+func (h *streamHandler[RecvType, SendType]) handleStreamMessage(s *RPCServer, is *internal.Stream) {
+	if is == nil {
+		return
+	}
+	if time.Now().UnixNano() < is.Expiry {
+		if err := h.handleRequest(s, is); err != nil {
+			logger.Error(err, "failed to handle request", "requestID", is.RequestId)
+		}
+	}
+}
+
 func (h *streamHandler[RecvType, SendType]) handleRequest(
 	s *RPCServer,
 	is *internal.Stream,
@@ -143,13 +158,7 @@ func (h *streamHandler[RecvType, SendType]) handleRequest(
 			}
 		}()
 	} else {
-		h.mu.Lock()
-		ss, ok := h.streams[is.StreamId]
-		h.mu.Unlock()
-
-		if ok {
-			return ss.HandleStream(is)
-		}
+		s.routeStreamMessage(is)
 	}
 	return nil
 }
@@ -160,14 +169,31 @@ func (h *streamHandler[RecvType, SendType]) handleOpenRequest(
 	open *internal.StreamOpen,
 ) error {
 	head := &metadata.Header{
-		RemoteID: open.NodeId,
-		SentAt:   time.Unix(0, is.SentAt),
-		Metadata: open.Metadata,
+		RemoteID:  open.NodeId,
+		RequestID: is.RequestId,
+		SentAt:    time.Unix(0, is.SentAt),
+		Metadata:  open.Metadata,
+		ServerID:  s.ID,
+		Service:   h.i.Service,
+		Method:    h.i.Method,
+		Topic:     h.i.Topic,
+		Multi:     h.i.Multi,
 	}
 	ctx := metadata.NewContextWithIncomingHeader(context.Background(), head)
 	octx, cancel := context.WithDeadline(ctx, time.Unix(0, is.Expiry))
 	defer cancel()
 
+	// streams rejected by a hook are still claimed, so the client receives the error instead of timing out
+	var openErr error
+	for _, hook := range s.StreamOpenHooks {
+		hctx, err := hook(ctx, h.i.RPCInfo)
+		if err != nil {
+			openErr = err
+			break
+		}
+		ctx = hctx
+	}
+
 	if h.i.RequireClaim {
 		claimed, err := h.claimRequest(s, octx, is)
 		if !claimed {
@@ -175,11 +201,22 @@ func (h *streamHandler[RecvType, SendType]) handleOpenRequest(
 		}
 	}
 
+	// streams use the codec of the client that opened them
+	codec, _ := bus.GetCodec(open.Codec)
 	ss := stream.NewStream[SendType, RecvType](
 		ctx,
 		h.i,
 		is.StreamId,
-		s.Timeout,
+		stream.Options{
+			Timeout:           s.Timeout,
+			RecvWindow:        s.StreamWindowSize,
+			RetryInterval:     s.StreamRetryInterval,
+			KeepaliveInterval: s.StreamPingInterval,
+			KeepaliveTimeout:  s.StreamPingTimeout,
+			Backpressure:      s.Backpressure,
+			OnDrop:            func() { h.dropped.Inc() },
+			Codec:             codec,
+		},
 		&serverStream[RecvType, SendType]{
 			h:      h,
 			s:      s,
@@ -189,15 +226,21 @@ func (h *streamHandler[RecvType, SendType]) handleOpenRequest(
 		make(chan RecvType, s.ChannelSize),
 		make(map[string]chan struct{}),
 	)
+	ss.SetSendWindow(int(open.WindowSize))
 
 	h.mu.Lock()
 	h.streams[is.StreamId] = ss
 	h.mu.Unlock()
+	s.addStreamRoute(is.StreamId, ss, func() { h.dropped.Inc() })
 
 	if err := ss.Ack(octx, is); err != nil {
 		_ = ss.Close(err)
 		return err
 	}
+	if openErr != nil {
+		_ = ss.Close(openErr)
+		return nil
+	}
 
 	err := h.handler(ss)
 	if !ss.Hijacked() {
@@ -235,14 +278,18 @@ func (h *streamHandler[RecvType, SendType]) claimRequest(
 		h.mu.Unlock()
 	}()
 
-	err := s.bus.Publish(ctx, info.GetClaimRequestChannel(s.Name, is.GetOpen().NodeId), &internal.ClaimRequest{
-		RequestId: is.RequestId,
-		ServerId:  s.ID,
-		Affinity:  affinity,
+	err := s.bus.Publish(ctx, info.GetClaimRequestChannel(h.i.Service, is.GetOpen().NodeId), &internal.ClaimRequest{
+		RequestId:       is.RequestId,
+		ServerId:        s.ID,
+		Affinity:        affinity,
+		Labels:          s.Labels,
+		ProtocolVersion: bus.ProtocolVersion,
+		InstanceId:      s.instanceID,
 	})
 	if err != nil {
 		return false, err
 	}
+	s.stats.claimsSent.Inc()
 
 	timeout := time.NewTimer(time.Duration(is.Expiry - time.Now().UnixNano()))
 	defer timeout.Stop()
@@ -252,6 +299,7 @@ func (h *streamHandler[RecvType, SendType]) claimRequest(
 		if claim.ServerId == s.ID {
 			return true, nil
 		} else {
+			s.stats.claimRacesLost.Inc()
 			return false, nil
 		}
 
@@ -260,6 +308,21 @@ func (h *streamHandler[RecvType, SendType]) claimRequest(
 	}
 }
 
+func (h *streamHandler[RecvType, SendType]) channelStats() []psrpc.ChannelStats {
+	streams := channelStats("streams", bus.Stats(h.streamSub))
+	streams.Dropped += h.dropped.Load()
+	stats := []psrpc.ChannelStats{streams}
+	if h.i.RequireClaim {
+		stats = append(stats, channelStats("claims", bus.Stats(h.claimSub)))
+	}
+	return stats
+}
+
+// drain stops accepting new streams. open streams are closed with the handler
+func (h *streamHandler[RecvType, SendType]) drain(context.Context) {
+	h.draining.Store(true)
+}
+
 func (h *streamHandler[RecvType, SendType]) close(force bool) {
 	h.closeOnce.Do(func() {
 		h.draining.Store(true)
@@ -294,7 +357,7 @@ type serverStream[SendType, RecvType proto.Message] struct {
 }
 
 func (s *serverStream[RequestType, ResponseType]) Send(ctx context.Context, msg *internal.Stream) (err error) {
-	if err = s.s.bus.Publish(ctx, info.GetStreamChannel(s.s.Name, s.nodeID), msg); err != nil {
+	if err = s.s.bus.Publish(ctx, info.GetStreamChannel(s.h.i.Service, s.nodeID), msg); err != nil {
 		err = psrpc.NewError(psrpc.Internal, err)
 	}
 	return
@@ -304,4 +367,115 @@ func (s *serverStream[RequestType, ResponseType]) Close(streamID string) {
 	s.h.mu.Lock()
 	delete(s.h.streams, streamID)
 	s.h.mu.Unlock()
+	s.s.removeStreamRoute(streamID)
+}
+
+type streamReceiver interface {
+	HandleStream(is *internal.Stream) error
+}
+
+// streamRoute delivers messages for one open stream in order. Each stream has its own queue, so a stream that is slow
+// to handle its messages does not hold up the others
+type streamRoute struct {
+	stream streamReceiver
+	msgs   chan *internal.Stream
+	done   chan struct{}
+	onDrop func()
+}
+
+func (r *streamRoute) run() {
+	for {
+		select {
+		case <-r.done:
+			return
+
+		case is := <-r.msgs:
+			if time.Now().UnixNano() < is.Expiry {
+				if err := r.stream.HandleStream(is); err != nil {
+					logger.Error(err, "failed to handle request", "requestID", is.RequestId)
+				}
+			}
+		}
+	}
+}
+
+// subscribeStreams opens one subscription per service for messages to every claimed stream on the server.
+// streams are multiplexed by stream id, so subscriptions do not grow with the number of handlers or streams
+func (s *RPCServer) subscribeStreams(service string) error {
+	s.streamMu.Lock()
+	defer s.streamMu.Unlock()
+
+	if _, ok := s.streamSubs[service]; ok {
+		return nil
+	}
+
+	sub, err := bus.Subscribe[*internal.Stream](
+		context.Background(), s.bus, info.GetStreamChannel(service, s.ID), s.ChannelSize,
+	)
+	if err != nil {
+		return err
+	}
+	s.streamSubs[service] = sub
+
+	go func() {
+		closed := s.shutdown.Watch()
+		for {
+			select {
+			case <-closed:
+				_ = sub.Close()
+				return
+
+			case is := <-sub.Channel():
+				if is != nil {
+					s.routeStreamMessage(is)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// routeStreamMessage queues a message for its stream. Messages for a stream whose queue is full are dropped, senders
+// waiting for an ack time out or resend them
+func (s *RPCServer) routeStreamMessage(is *internal.Stream) {
+	s.mu.RLock()
+	route, ok := s.streamRoutes[is.StreamId]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case route.msgs <- is:
+	default:
+		route.onDrop()
+		s.overflow("streams", is.StreamId, psrpc.OverflowDropped)
+	}
+}
+
+func (s *RPCServer) addStreamRoute(streamID string, ss streamReceiver, onDrop func()) {
+	// the queue has room for a full window, so senders respecting it are never dropped
+	route := &streamRoute{
+		stream: ss,
+		msgs:   make(chan *internal.Stream, s.ChannelSize+s.StreamWindowSize),
+		done:   make(chan struct{}),
+		onDrop: onDrop,
+	}
+
+	s.mu.Lock()
+	s.streamRoutes[streamID] = route
+	s.mu.Unlock()
+
+	go route.run()
+}
+
+func (s *RPCServer) removeStreamRoute(streamID string) {
+	s.mu.Lock()
+	route, ok := s.streamRoutes[streamID]
+	delete(s.streamRoutes, streamID)
+	s.mu.Unlock()
+
+	if ok {
+		close(route.done)
+	}
 }
@@ -0,0 +1,68 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal/bus"
+)
+
+// Join subscribes to the notifications sent by client.Publish. Every subscribed server receives each notification.
+// Subscriptions are not closed with the server
+func Join[RequestType proto.Message](
+	ctx context.Context,
+	s *RPCServer,
+	rpc string,
+	topic []string,
+) (bus.Subscription[RequestType], error) {
+	if s.shutdown.IsBroken() {
+		return nil, psrpc.ErrServerClosed
+	}
+
+	i := s.GetInfo(rpc, topic)
+	sub, err := bus.Subscribe[RequestType](ctx, s.bus, i.GetRPCChannel(), s.ChannelSize)
+	if err != nil {
+		return nil, psrpc.NewError(psrpc.Internal, err)
+	}
+	return trackSubscription(s, sub), nil
+}
+
+// JoinQueue subscribes to the notifications sent by client.Publish. Each notification is received by one server
+func JoinQueue[RequestType proto.Message](
+	ctx context.Context,
+	s *RPCServer,
+	rpc string,
+	topic []string,
+) (bus.Subscription[RequestType], error) {
+	if s.shutdown.IsBroken() {
+		return nil, psrpc.ErrServerClosed
+	}
+
+	i := s.GetInfo(rpc, topic)
+	sub, err := bus.SubscribeQueue[RequestType](ctx, s.bus, i.GetRPCChannel(), s.ChannelSize)
+	if err != nil {
+		return nil, psrpc.NewError(psrpc.Internal, err)
+	}
+	return trackSubscription(s, sub), nil
+}
+
+func trackSubscription[RequestType proto.Message](s *RPCServer, sub bus.Subscription[RequestType]) bus.Subscription[RequestType] {
+	s.stats.subscriptions.Inc()
+	return bus.WithCloseHook(sub, func() { s.stats.subscriptions.Dec() })
+}
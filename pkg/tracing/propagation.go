@@ -0,0 +1,70 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+)
+
+// W3C trace context and baggage, used by the propagation options unless WithPropagator is set
+var defaultPropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// WithClientPropagation sends the caller's span context and baggage in request metadata without recording spans,
+// for callers whose spans are already recorded by other instrumentation
+func WithClientPropagation(opts ...Option) psrpc.ClientOption {
+	t := newPropagationTracer(opts)
+	return psrpc.WithClientOptions(
+		psrpc.WithClientRPCInterceptors(func(info psrpc.RPCInfo, next psrpc.ClientRPCHandler) psrpc.ClientRPCHandler {
+			return func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+				return next(t.inject(ctx), req, opts...)
+			}
+		}),
+		psrpc.WithClientMultiRPCInterceptors(func(info psrpc.RPCInfo, next psrpc.ClientMultiRPCHandler) psrpc.ClientMultiRPCHandler {
+			return &multiRPCPropagation{ClientMultiRPCHandler: next, t: t}
+		}),
+	)
+}
+
+// WithServerPropagation restores the span context and baggage sent by WithClientPropagation or WithClientTracing to
+// the handler's context, so spans started by the handler join the caller's trace
+func WithServerPropagation(opts ...Option) psrpc.ServerOption {
+	t := newPropagationTracer(opts)
+	return psrpc.WithServerRPCInterceptors(func(ctx context.Context, req proto.Message, info psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+		return handler(t.extract(ctx), req)
+	})
+}
+
+func newPropagationTracer(opts []Option) *tracer {
+	return newTracer(append([]Option{WithPropagator(defaultPropagator)}, opts...))
+}
+
+// extract adds the span context and baggage from the incoming metadata
+func (t *tracer) extract(ctx context.Context) context.Context {
+	return t.propagator.Extract(ctx, metadataCarrier(psrpc.IncomingMetadata(ctx)))
+}
+
+type multiRPCPropagation struct {
+	psrpc.ClientMultiRPCHandler
+	t *tracer
+}
+
+func (m *multiRPCPropagation) Send(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) error {
+	return m.ClientMultiRPCHandler.Send(m.t.inject(ctx), req, opts...)
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/pkg/client"
+	"github.com/livekit/psrpc/pkg/info"
+	"github.com/livekit/psrpc/pkg/server"
+)
+
+func TestPropagation(t *testing.T) {
+	bus := psrpc.NewLocalMessageBus()
+	s := server.NewRPCServer(&info.ServiceDefinition{Name: "test", ID: "server"}, bus, WithServerPropagation())
+	t.Cleanup(func() { s.Close(true) })
+
+	c, err := client.NewRPCClient(&info.ServiceDefinition{Name: "test", ID: "client"}, bus, WithClientPropagation())
+	require.NoError(t, err)
+
+	type received struct {
+		sc     trace.SpanContext
+		tenant string
+	}
+	handled := make(chan received, 1)
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		handled <- received{trace.SpanContextFromContext(ctx), baggage.FromContext(ctx).Member("tenant").Value()}
+		return &internal.Response{}, nil
+	}
+	s.RegisterMethod("unary", false, false, true, false)
+	c.RegisterMethod("unary", false, false, true, false)
+	require.NoError(t, server.RegisterHandler[*internal.Request, *internal.Response](s, "unary", nil, handler, nil))
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "caller")
+	defer span.End()
+	member, err := baggage.NewMember("tenant", "a")
+	require.NoError(t, err)
+	b, err := baggage.New(member)
+	require.NoError(t, err)
+	ctx = baggage.ContextWithBaggage(ctx, b)
+
+	_, err = client.RequestSingle[*internal.Response](ctx, c, "unary", nil, &internal.Request{})
+	require.NoError(t, err)
+
+	r := <-handled
+	require.Equal(t, span.SpanContext().TraceID(), r.sc.TraceID())
+	require.Equal(t, span.SpanContext().SpanID(), r.sc.SpanID())
+	require.True(t, r.sc.IsRemote())
+	require.Equal(t, "a", r.tenant)
+}
@@ -0,0 +1,310 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/psrpc"
+)
+
+const instrumentationName = "github.com/livekit/psrpc/pkg/tracing"
+
+const (
+	ServiceKey       = attribute.Key("rpc.service")
+	MethodKey        = attribute.Key("rpc.method")
+	TopicKey         = attribute.Key("psrpc.topic")
+	ServerIDKey      = attribute.Key("psrpc.server_id")
+	ErrorCodeKey     = attribute.Key("psrpc.error_code")
+	ResponseCountKey = attribute.Key("psrpc.response_count")
+	ErrorCountKey    = attribute.Key("psrpc.error_count")
+	AttemptsKey      = attribute.Key("psrpc.attempts")
+)
+
+// ClaimedEvent is added to client spans when a server is selected from the claims
+const ClaimedEvent = "claimed"
+
+var systemAttribute = attribute.String("rpc.system", "psrpc")
+
+type Option func(*options)
+
+type options struct {
+	tracerProvider trace.TracerProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// WithTracerProvider sets the provider used to create spans, the global provider is used by default
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracerProvider = tp
+	}
+}
+
+// WithPropagator sets the propagator used to send span contexts in request metadata, the global propagator is used
+// by default
+func WithPropagator(p propagation.TextMapPropagator) Option {
+	return func(o *options) {
+		o.propagator = p
+	}
+}
+
+// WithClientTracing records a client span for each request, and sends its span context to the server
+func WithClientTracing(opts ...Option) psrpc.ClientOption {
+	t := newTracer(opts)
+	return psrpc.WithClientOptions(
+		psrpc.WithClientRPCInterceptors(t.clientRPCInterceptor),
+		psrpc.WithClientMultiRPCInterceptors(t.clientMultiRPCInterceptor),
+		psrpc.WithClientStreamInterceptors(t.streamInterceptor(trace.SpanKindClient)),
+	)
+}
+
+// WithServerTracing records a server span for each handled request, as a child of the client span
+func WithServerTracing(opts ...Option) psrpc.ServerOption {
+	t := newTracer(opts)
+	return psrpc.WithServerOptions(
+		psrpc.WithServerRPCInterceptors(t.serverRPCInterceptor),
+		psrpc.WithServerStreamInterceptors(t.streamInterceptor(trace.SpanKindServer)),
+	)
+}
+
+type tracer struct {
+	trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+func newTracer(opts []Option) *tracer {
+	o := &options{
+		tracerProvider: otel.GetTracerProvider(),
+		propagator:     otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return &tracer{
+		Tracer:     o.tracerProvider.Tracer(instrumentationName),
+		propagator: o.propagator,
+	}
+}
+
+func (t *tracer) start(ctx context.Context, info psrpc.RPCInfo, kind trace.SpanKind) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		systemAttribute,
+		ServiceKey.String(info.Service),
+		MethodKey.String(info.Method),
+	}
+	if len(info.Topic) != 0 {
+		attrs = append(attrs, TopicKey.StringSlice(info.Topic))
+	}
+	return t.Start(ctx, info.Service+"/"+info.Method, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// inject adds the span context to the outgoing metadata
+func (t *tracer) inject(ctx context.Context) context.Context {
+	carrier := metadataCarrier{}
+	t.propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return ctx
+	}
+
+	kv := make([]string, 0, 2*len(carrier))
+	for k, v := range carrier {
+		kv = append(kv, k, v)
+	}
+	return psrpc.AppendToOutgoingContext(ctx, kv...)
+}
+
+func (t *tracer) clientRPCInterceptor(info psrpc.RPCInfo, next psrpc.ClientRPCHandler) psrpc.ClientRPCHandler {
+	return func(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) (proto.Message, error) {
+		ctx, span := t.start(ctx, info, trace.SpanKindClient)
+		defer span.End()
+
+		// the response info reports the selected server. it is shared with the caller if they requested it
+		ri := responseInfo(opts)
+		if ri == nil {
+			ri = &psrpc.ResponseInfo{}
+			opts = append(opts[:len(opts):len(opts)], psrpc.WithResponseInfo(ri))
+		}
+
+		start := time.Now()
+		res, err := next(t.inject(ctx), req, opts...)
+		recordClaim(span, start, ri)
+		recordError(span, err)
+		return res, err
+	}
+}
+
+func responseInfo(opts []psrpc.RequestOption) *psrpc.ResponseInfo {
+	o := &psrpc.RequestOpts{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o.ResponseInfo
+}
+
+func recordClaim(span trace.Span, start time.Time, ri *psrpc.ResponseInfo) {
+	if ri.ServerID != "" {
+		span.SetAttributes(ServerIDKey.String(ri.ServerID))
+	}
+	if ri.Attempts > 1 {
+		span.SetAttributes(AttemptsKey.Int(ri.Attempts))
+	}
+	// failed selections also report a claim latency, but nothing was claimed
+	if ri.ClaimLatency > 0 && ri.ServerID != "" {
+		eventOpts := []trace.EventOption{trace.WithAttributes(ServerIDKey.String(ri.ServerID))}
+		// claim latency is measured from the last attempt, so only the first attempt's claim time is known
+		if ri.Attempts <= 1 {
+			eventOpts = append(eventOpts, trace.WithTimestamp(start.Add(ri.ClaimLatency)))
+		}
+		span.AddEvent(ClaimedEvent, eventOpts...)
+	}
+}
+
+func (t *tracer) clientMultiRPCInterceptor(info psrpc.RPCInfo, next psrpc.ClientMultiRPCHandler) psrpc.ClientMultiRPCHandler {
+	return &multiRPCSpan{
+		ClientMultiRPCHandler: next,
+		t:                     t,
+		info:                  info,
+	}
+}
+
+type multiRPCSpan struct {
+	psrpc.ClientMultiRPCHandler
+	t    *tracer
+	info psrpc.RPCInfo
+
+	span          trace.Span
+	responseCount int
+	errorCount    int
+}
+
+func (m *multiRPCSpan) Send(ctx context.Context, req proto.Message, opts ...psrpc.RequestOption) error {
+	ctx, m.span = m.t.start(ctx, m.info, trace.SpanKindClient)
+	err := m.ClientMultiRPCHandler.Send(m.t.inject(ctx), req, opts...)
+	recordError(m.span, err)
+	return err
+}
+
+func (m *multiRPCSpan) Recv(msg proto.Message, err error) {
+	if err == nil {
+		m.responseCount++
+	} else {
+		m.errorCount++
+	}
+	m.ClientMultiRPCHandler.Recv(msg, err)
+}
+
+func (m *multiRPCSpan) Close() {
+	if m.span != nil {
+		m.span.SetAttributes(ResponseCountKey.Int(m.responseCount), ErrorCountKey.Int(m.errorCount))
+		m.span.End()
+	}
+	m.ClientMultiRPCHandler.Close()
+}
+
+func (t *tracer) serverRPCInterceptor(ctx context.Context, req proto.Message, info psrpc.RPCInfo, handler psrpc.ServerRPCHandler) (proto.Message, error) {
+	ctx, span := t.start(t.extract(ctx), info, trace.SpanKindServer)
+	defer span.End()
+	if id := psrpc.IncomingServerID(ctx); id != "" {
+		span.SetAttributes(ServerIDKey.String(id))
+	}
+
+	res, err := handler(ctx, req)
+	recordError(span, err)
+	return res, err
+}
+
+// stream spans end when the stream is closed locally, or when a send fails because the peer closed it
+func (t *tracer) streamInterceptor(kind trace.SpanKind) psrpc.StreamInterceptor {
+	return func(info psrpc.RPCInfo, next psrpc.StreamHandler) psrpc.StreamHandler {
+		_, span := t.start(context.Background(), info, kind)
+		return &streamSpan{
+			StreamHandler: next,
+			span:          span,
+		}
+	}
+}
+
+type streamSpan struct {
+	psrpc.StreamHandler
+	span trace.Span
+	once sync.Once
+}
+
+func (s *streamSpan) Send(msg proto.Message, opts ...psrpc.StreamOption) error {
+	err := s.StreamHandler.Send(msg, opts...)
+	if err != nil {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *streamSpan) Close(cause error) error {
+	err := s.StreamHandler.Close(cause)
+	s.end(cause)
+	return err
+}
+
+func (s *streamSpan) end(cause error) {
+	s.once.Do(func() {
+		if !errors.Is(cause, psrpc.ErrStreamEOF) && !errors.Is(cause, psrpc.ErrStreamClosed) {
+			recordError(s.span, cause)
+		}
+		s.span.End()
+	})
+}
+
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	code := psrpc.Unknown
+	var e psrpc.Error
+	if errors.As(err, &e) {
+		code = e.Code()
+	}
+	span.SetAttributes(ErrorCodeKey.String(string(code)))
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// metadataCarrier adapts request metadata for propagators
+type metadataCarrier psrpc.Metadata
+
+func (c metadataCarrier) Get(key string) string {
+	return c[key]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/livekit/psrpc"
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/pkg/client"
+	"github.com/livekit/psrpc/pkg/info"
+	"github.com/livekit/psrpc/pkg/server"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	opts := []Option{
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))),
+		WithPropagator(propagation.TraceContext{}),
+	}
+
+	bus := psrpc.NewLocalMessageBus()
+	s := server.NewRPCServer(&info.ServiceDefinition{Name: "test", ID: "server"}, bus, WithServerTracing(opts...))
+	t.Cleanup(func() { s.Close(true) })
+
+	c, err := client.NewRPCClient(&info.ServiceDefinition{Name: "test", ID: "client"}, bus, WithClientTracing(opts...))
+	require.NoError(t, err)
+
+	handler := func(ctx context.Context, req *internal.Request) (*internal.Response, error) {
+		if req.RequestId == "fail" {
+			return nil, psrpc.NewErrorf(psrpc.NotFound, "missing")
+		}
+		return &internal.Response{}, nil
+	}
+	s.RegisterMethod("unary", false, false, true, false)
+	c.RegisterMethod("unary", false, false, true, false)
+	require.NoError(t, server.RegisterHandler[*internal.Request, *internal.Response](s, "unary", nil, handler, nil))
+	s.RegisterMethod("multi", false, true, false, false)
+	c.RegisterMethod("multi", false, true, false, false)
+	require.NoError(t, server.RegisterHandler[*internal.Request, *internal.Response](s, "multi", nil, handler, nil))
+
+	attrs := func(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+		m := make(map[attribute.Key]attribute.Value)
+		for _, a := range span.Attributes {
+			m[a.Key] = a.Value
+		}
+		return m
+	}
+	ended := func() tracetest.SpanStubs {
+		spans := exporter.GetSpans()
+		exporter.Reset()
+		return spans
+	}
+
+	t.Run("Unary", func(t *testing.T) {
+		_, err := client.RequestSingle[*internal.Response](context.Background(), c, "unary", nil, &internal.Request{})
+		require.NoError(t, err)
+
+		spans := ended()
+		require.Len(t, spans, 2)
+		serverSpan, clientSpan := spans[0], spans[1]
+		require.Equal(t, trace.SpanKindServer, serverSpan.SpanKind)
+		require.Equal(t, trace.SpanKindClient, clientSpan.SpanKind)
+		require.Equal(t, "test/unary", clientSpan.Name)
+		require.Equal(t, clientSpan.SpanContext.SpanID(), serverSpan.Parent.SpanID())
+		require.Equal(t, "unary", attrs(clientSpan)[MethodKey].AsString())
+		require.Equal(t, "server", attrs(serverSpan)[ServerIDKey].AsString())
+		require.Equal(t, "server", attrs(clientSpan)[ServerIDKey].AsString())
+		require.Len(t, clientSpan.Events, 1)
+		require.Equal(t, ClaimedEvent, clientSpan.Events[0].Name)
+	})
+
+	t.Run("ResponseInfo", func(t *testing.T) {
+		var ri psrpc.ResponseInfo
+		_, err := client.RequestSingle[*internal.Response](context.Background(), c, "unary", nil, &internal.Request{}, psrpc.WithResponseInfo(&ri))
+		require.NoError(t, err)
+		require.Equal(t, "server", ri.ServerID)
+
+		spans := ended()
+		require.Len(t, spans, 2)
+		require.Len(t, spans[1].Events, 1)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := client.RequestSingle[*internal.Response](context.Background(), c, "unary", nil, &internal.Request{RequestId: "fail"})
+		require.Error(t, err)
+
+		spans := ended()
+		require.Len(t, spans, 2)
+		for _, span := range spans {
+			require.Equal(t, codes.Error, span.Status.Code)
+			require.Equal(t, string(psrpc.NotFound), attrs(span)[ErrorCodeKey].AsString())
+		}
+	})
+
+	t.Run("Multi", func(t *testing.T) {
+		resChan, err := client.RequestMulti[*internal.Response](context.Background(), c, "multi", nil, &internal.Request{}, psrpc.WithRequestTimeout(100*time.Millisecond))
+		require.NoError(t, err)
+		for range resChan {
+		}
+
+		spans := ended()
+		require.Len(t, spans, 2)
+		clientSpan := spans[1]
+		require.Equal(t, int64(1), attrs(clientSpan)[ResponseCountKey].AsInt64())
+		require.Equal(t, int64(0), attrs(clientSpan)[ErrorCountKey].AsInt64())
+	})
+}
@@ -15,6 +15,7 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -23,6 +24,13 @@ type commandLineParams struct {
 	paths        string            // paths flag, used to control file output directory.
 	module       string            // module flag, Go import path prefix that is removed from the output filename.
 	importPrefix string            // prefix added to imported package file names.
+	mocks        bool              // mocks flag, generate mock clients in a separate file.
+	grpc         bool              // grpc flag, generate gRPC bridges in a separate file.
+	http         bool              // http flag, generate HTTP handlers in a separate file.
+	otel         bool              // otel flag, generate traced constructors in a separate file.
+	partial      bool              // partial flag, generate servers with per-method registration in a separate file.
+	docs         bool              // docs flag, generate markdown documentation of the services.
+	schema       bool              // schema flag, generate a json description of the wire protocol and message schemas.
 }
 
 // parseCommandLineParams breaks the comma-separated list of key=value pairs
@@ -76,6 +84,55 @@ func parseCommandLineParams(parameter string) (*commandLineParams, error) {
 		case k == "import_prefix":
 			clp.importPrefix = v
 
+		case k == "mocks":
+			mocks, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid command line flag %s=%s", k, v)
+			}
+			clp.mocks = mocks
+
+		case k == "grpc":
+			grpc, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid command line flag %s=%s", k, v)
+			}
+			clp.grpc = grpc
+
+		case k == "http":
+			http, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid command line flag %s=%s", k, v)
+			}
+			clp.http = http
+
+		case k == "otel":
+			otel, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid command line flag %s=%s", k, v)
+			}
+			clp.otel = otel
+
+		case k == "partial":
+			partial, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid command line flag %s=%s", k, v)
+			}
+			clp.partial = partial
+
+		case k == "docs":
+			docs, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid command line flag %s=%s", k, v)
+			}
+			clp.docs = docs
+
+		case k == "schema":
+			schema, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid command line flag %s=%s", k, v)
+			}
+			clp.schema = schema
+
 		default:
 			return nil, fmt.Errorf("invalid command line flag %s=%s", k, v)
 		}
@@ -52,6 +52,75 @@ func TestParseCommandLineParams(t *testing.T) {
 			nil,
 			errors.New(`invalid parameter "import_prefix": expected format of parameter to be k=v`),
 		},
+		{
+			"mocks parameter",
+			"mocks=true",
+			&commandLineParams{
+				importMap: map[string]string{},
+				mocks:     true,
+			},
+			nil,
+		},
+		{
+			"invalid mocks parameter",
+			"mocks=yes",
+			nil,
+			errors.New(`invalid command line flag mocks=yes`),
+		},
+		{
+			"grpc parameter",
+			"grpc=true",
+			&commandLineParams{
+				importMap: map[string]string{},
+				grpc:      true,
+			},
+			nil,
+		},
+		{
+			"http parameter",
+			"http=true",
+			&commandLineParams{
+				importMap: map[string]string{},
+				http:      true,
+			},
+			nil,
+		},
+		{
+			"otel parameter",
+			"otel=true",
+			&commandLineParams{
+				importMap: map[string]string{},
+				otel:      true,
+			},
+			nil,
+		},
+		{
+			"partial parameter",
+			"partial=true",
+			&commandLineParams{
+				importMap: map[string]string{},
+				partial:   true,
+			},
+			nil,
+		},
+		{
+			"docs parameter",
+			"docs=true",
+			&commandLineParams{
+				importMap: map[string]string{},
+				docs:      true,
+			},
+			nil,
+		},
+		{
+			"schema parameter",
+			"schema=true",
+			&commandLineParams{
+				importMap: map[string]string{},
+				schema:    true,
+			},
+			nil,
+		},
 		{
 			"import_prefix parameter",
 			"import_prefix=github.com/example/repo",
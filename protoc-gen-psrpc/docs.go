@@ -0,0 +1,293 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	descriptor "google.golang.org/protobuf/types/descriptorpb"
+	plugin "google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/livekit/psrpc/protoc-gen-psrpc/internal/gen/typemap"
+	"github.com/livekit/psrpc/protoc-gen-psrpc/options"
+	"github.com/livekit/psrpc/version"
+)
+
+func (t *psrpc) generateDocs(file *descriptor.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
+	if len(file.Service) == 0 {
+		return nil
+	}
+
+	t.P(`<!-- Code generated by protoc-gen-psrpc `, version.Version, `, DO NOT EDIT. -->`)
+	t.P()
+	t.P(`# `, file.GetName())
+	if comments, err := t.reg.FileComments(file); err == nil {
+		t.printDocComments(comments)
+	}
+
+	var messages []*typemap.MessageDefinition
+	seen := make(map[string]bool)
+	for _, service := range file.Service {
+		t.generateServiceDocs(file, service)
+
+		for _, method := range service.Method {
+			for _, name := range documentedTypes(method, t.getOptions(method)) {
+				if name == "" || seen[name] {
+					continue
+				}
+				if def := t.reg.MessageDefinition(name); def != nil {
+					seen[name] = true
+					messages = append(messages, def)
+				}
+			}
+		}
+	}
+
+	t.P()
+	t.P(`## Messages`)
+	for _, def := range messages {
+		t.generateMessageDocs(def)
+	}
+
+	resp := &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(strings.TrimSuffix(t.goFileName(file), ".psrpc.go") + ".psrpc.md"),
+		Content: proto.String(t.output.String()),
+	}
+	t.output.Reset()
+	return resp
+}
+
+func (t *psrpc) generateServiceDocs(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) {
+	servName := serviceNameCamelCased(service)
+
+	t.P()
+	t.P(`## `, servName)
+	if comments, err := t.reg.ServiceComments(file, service); err == nil {
+		t.printDocComments(comments)
+	}
+	if len(service.Method) == 0 {
+		return
+	}
+
+	t.P()
+	t.P(`| Method | Kind | Request | Response | Topics | Timeout |`)
+	t.P(`| --- | --- | --- | --- | --- | --- |`)
+	for _, method := range service.Method {
+		opts := t.getOptions(method)
+		methName := methodNameCamelCased(method)
+		types := documentedTypes(method, opts)
+		t.P(`| [`, methName, `](#`, docAnchor(servName+"."+methName), `) | `, methodKind(method, opts), ` | `,
+			messageLink(types[0]), ` | `, messageLink(types[1]), ` | `,
+			t.formatTopicDocs(method), ` | `, formatTimeoutDocs(opts), ` |`)
+	}
+
+	for _, method := range service.Method {
+		opts := t.getOptions(method)
+		methName := methodNameCamelCased(method)
+
+		t.P()
+		t.P(`### `, servName, `.`, methName)
+		if comments, err := t.reg.MethodComments(file, service, method); err == nil {
+			t.printDocComments(comments)
+		}
+		t.P()
+		t.P(`- **Kind:** `, methodKind(method, opts))
+		t.P(`- **Delivery:** `, methodDelivery(method, opts))
+		switch {
+		case opts.Subscription:
+			t.P(`- **Message:** `, messageLink(method.GetOutputType()))
+		case opts.Notification:
+			t.P(`- **Message:** `, messageLink(method.GetInputType()))
+		default:
+			t.P(`- **Request:** `, messageLink(method.GetInputType()))
+			t.P(`- **Response:** `, messageLink(method.GetOutputType()))
+		}
+		t.P(`- **Topics:** `, t.formatTopicDocs(method))
+		if !opts.Subscription && !opts.Notification {
+			t.P(`- **Timeout:** `, formatTimeoutDocs(opts))
+		}
+	}
+}
+
+func (t *psrpc) generateMessageDocs(def *typemap.MessageDefinition) {
+	name := strings.TrimPrefix(def.ProtoName(), ".")
+
+	t.P()
+	t.P(`### `, name)
+	t.printDocComments(def.Comments)
+	if len(def.Descriptor.Field) == 0 {
+		t.P()
+		t.P(`This message has no fields.`)
+		return
+	}
+
+	t.P()
+	t.P(`| Field | Type | Number |`)
+	t.P(`| --- | --- | --- |`)
+	for _, field := range def.Descriptor.Field {
+		t.P("| `", field.GetName(), "` | ", formatFieldType(field), ` | `, strconv.Itoa(int(field.GetNumber())), ` |`)
+	}
+}
+
+func (t *psrpc) printDocComments(comments typemap.DefinitionComments) {
+	text := strings.TrimSpace(comments.Leading)
+	if text == "" {
+		return
+	}
+
+	t.P()
+	for _, line := range strings.Split(text, "\n") {
+		t.P(strings.TrimPrefix(line, " "))
+	}
+}
+
+// documentedTypes returns the request and response types, leaving out the ignored side of subscriptions and notifications
+func documentedTypes(method *descriptor.MethodDescriptorProto, opts *options.Options) [2]string {
+	switch {
+	case opts.Subscription:
+		return [2]string{"", method.GetOutputType()}
+	case opts.Notification:
+		return [2]string{method.GetInputType(), ""}
+	default:
+		return [2]string{method.GetInputType(), method.GetOutputType()}
+	}
+}
+
+// messageLink links a message to its entry in the Messages section
+func messageLink(protoName string) string {
+	if protoName == "" {
+		return "-"
+	}
+	name := strings.TrimPrefix(protoName, ".")
+	return "[`" + name + "`](#" + docAnchor(name) + ")"
+}
+
+func (t *psrpc) formatTopicDocs(method *descriptor.MethodDescriptorProto) string {
+	opts := t.getOptions(method)
+	if !opts.Topics {
+		return "-"
+	}
+	if opts.TopicParams == nil || len(opts.TopicParams.Names) == 0 {
+		return "`topic`"
+	}
+
+	names := make([]string, 0, len(opts.TopicParams.Names))
+	for _, name := range opts.TopicParams.Names {
+		names = append(names, "`"+name+"`")
+	}
+	s := strings.Join(names, ", ")
+	if opts.TopicParams.Group != "" {
+		s += " (group `" + opts.TopicParams.Group + "`)"
+	}
+	return s
+}
+
+func formatTimeoutDocs(opts *options.Options) string {
+	switch {
+	case opts.Subscription || opts.Notification:
+		return "-"
+	case opts.Timeout != "":
+		return opts.Timeout
+	default:
+		return "client default"
+	}
+}
+
+func methodKind(method *descriptor.MethodDescriptorProto, opts *options.Options) string {
+	switch {
+	case opts.Subscription:
+		return "subscription"
+	case opts.Notification:
+		return "notification"
+	}
+
+	switch streamTypeForMethod(method, opts) {
+	case bidiStream:
+		return "bidirectional stream"
+	case serverStream:
+		return "server stream"
+	case clientStream:
+		return "client stream"
+	}
+
+	if opts.Type == options.Routing_MULTI {
+		return "multi-rpc"
+	}
+	return "rpc"
+}
+
+func methodDelivery(method *descriptor.MethodDescriptorProto, opts *options.Options) string {
+	var s string
+	switch {
+	case opts.Subscription && opts.Type == options.Routing_MULTI:
+		s = "Servers publish updates, and every subscribed client receives each update."
+	case opts.Subscription:
+		s = "Servers publish updates, and one subscribed client receives each update."
+	case opts.Notification && opts.Type == options.Routing_MULTI:
+		s = "Clients publish notifications, and every subscribed server receives each notification."
+	case opts.Notification:
+		s = "Clients publish notifications, and one subscribed server receives each notification."
+	case opts.Type == options.Routing_MULTI:
+		s = "Every server responds to each request, and the client receives all responses."
+	case opts.Type == options.Routing_AFFINITY:
+		s = "The server with the highest affinity handles each request."
+	default:
+		s = "The first available server handles each request."
+	}
+
+	switch streamTypeForMethod(method, opts) {
+	case bidiStream:
+		s += " The client and server both send messages until either closes the stream."
+	case serverStream:
+		s += " The server sends any number of responses until its handler returns."
+	case clientStream:
+		s += " The client sends any number of requests, and the server responds once the client closes the stream."
+	}
+
+	if opts.TopicParams != nil && opts.TopicParams.SingleServer {
+		s += " At most one server is registered for each topic."
+	}
+	return s
+}
+
+func formatFieldType(field *descriptor.FieldDescriptorProto) string {
+	var s string
+	switch field.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_ENUM:
+		s = "`" + strings.TrimPrefix(field.GetTypeName(), ".") + "`"
+	default:
+		s = "`" + strings.ToLower(strings.TrimPrefix(field.GetType().String(), "TYPE_")) + "`"
+	}
+	if field.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED {
+		s = "repeated " + s
+	}
+	return s
+}
+
+// docAnchor converts a heading to the anchor markdown renderers link it with
+func docAnchor(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
@@ -26,11 +26,14 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/exp/slices"
 	"golang.org/x/mod/semver"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
 	descriptor "google.golang.org/protobuf/types/descriptorpb"
 	plugin "google.golang.org/protobuf/types/pluginpb"
 
@@ -44,7 +47,8 @@ import (
 type psrpc struct {
 	filesHandled int
 
-	reg *typemap.Registry
+	reg   *typemap.Registry
+	files *protoregistry.Files // descriptors for the json schema, only loaded with the schema flag
 
 	// Map to record whether we've built each package
 	pkgs          map[string]string
@@ -56,6 +60,13 @@ type psrpc struct {
 	// Package output:
 	sourceRelativePaths bool // instruction on where to write output files
 	modulePrefix        string
+	mocks               bool // also write a file with mock clients
+	grpc                bool // also write a file with gRPC bridges
+	http                bool // also write a file with HTTP handlers
+	otel                bool // also write a file with traced constructors
+	partial             bool // also write a file with partial servers
+	docs                bool // also write markdown documentation
+	schema              bool // also write a json description of the wire protocol
 
 	// Package naming:
 	genPkgName          string // Name of the package that we're generating
@@ -91,19 +102,47 @@ func (t *psrpc) Generate(in *plugin.CodeGeneratorRequest) *plugin.CodeGeneratorR
 	t.importMap = params.importMap
 	t.sourceRelativePaths = params.paths == "source_relative"
 	t.modulePrefix = params.module
+	t.mocks = params.mocks
+	t.grpc = params.grpc
+	t.http = params.http
+	t.otel = params.otel
+	t.partial = params.partial
+	t.docs = params.docs
+	t.schema = params.schema
+
+	// import mappings take precedence over go_package, so they also set the package and path of generated files
+	for _, f := range in.ProtoFile {
+		if importPath, ok := t.importMap[f.GetName()]; ok {
+			if f.Options == nil {
+				f.Options = &descriptor.FileOptions{}
+			}
+			f.Options.GoPackage = proto.String(importPath)
+		}
+	}
 
 	t.genFiles = gen.FilesToGenerate(in)
 
 	// Collect information on types.
 	t.reg = typemap.New(in.ProtoFile)
+	if t.schema {
+		if t.files, err = protodesc.NewFiles(&descriptor.FileDescriptorSet{File: in.ProtoFile}); err != nil {
+			gen.Fail("could not load descriptors", err.Error())
+		}
+	}
 
 	// Register names of packages that we import.
 	t.registerPackageName("client")
 	t.registerPackageName("context")
+	t.registerPackageName("grpc")
+	t.registerPackageName("grpcbridge")
+	t.registerPackageName("http")
+	t.registerPackageName("httpgateway")
 	t.registerPackageName("info")
 	t.registerPackageName("psrpc")
 	t.registerPackageName("rand")
 	t.registerPackageName("server")
+	t.registerPackageName("time")
+	t.registerPackageName("tracing")
 	t.registerPackageName("version")
 
 	// Time to figure out package names of objects defined in protobuf. First,
@@ -162,6 +201,41 @@ func (t *psrpc) Generate(in *plugin.CodeGeneratorRequest) *plugin.CodeGeneratorR
 		if respFile != nil {
 			resp.File = append(resp.File, respFile)
 		}
+		if t.mocks {
+			if mockFile := t.generateMocks(f); mockFile != nil {
+				resp.File = append(resp.File, mockFile)
+			}
+		}
+		if t.grpc {
+			if bridgeFile := t.generateGRPCBridges(f); bridgeFile != nil {
+				resp.File = append(resp.File, bridgeFile)
+			}
+		}
+		if t.http {
+			if httpFile := t.generateHTTPHandlers(f); httpFile != nil {
+				resp.File = append(resp.File, httpFile)
+			}
+		}
+		if t.otel {
+			if otelFile := t.generateTracedConstructors(f); otelFile != nil {
+				resp.File = append(resp.File, otelFile)
+			}
+		}
+		if t.partial {
+			if partialFile := t.generatePartialServers(f); partialFile != nil {
+				resp.File = append(resp.File, partialFile)
+			}
+		}
+		if t.docs {
+			if docsFile := t.generateDocs(f); docsFile != nil {
+				resp.File = append(resp.File, docsFile)
+			}
+		}
+		if t.schema {
+			if schemaFile := t.generateSchema(f); schemaFile != nil {
+				resp.File = append(resp.File, schemaFile)
+			}
+		}
 	}
 	return resp
 }
@@ -270,6 +344,9 @@ func (t *psrpc) generateImports(file *descriptor.FileDescriptorProto) {
 	for _, service := range file.Service {
 		if len(service.Method) > 0 {
 			t.P(`  "context"`)
+			if t.hasTimeouts(file) {
+				t.P(`  "time"`)
+			}
 			t.P()
 			break
 		}
@@ -284,6 +361,10 @@ func (t *psrpc) generateImports(file *descriptor.FileDescriptorProto) {
 	t.P(`  "github.com/livekit/psrpc/version"`)
 	t.P(`)`)
 
+	t.generateMessageImports(file)
+}
+
+func (t *psrpc) generateMessageImports(file *descriptor.FileDescriptorProto) {
 	// It's legal to import a message and use it as an input or output for a
 	// method. Make sure to import the package of any such message. First, dedupe
 	// them.
@@ -304,9 +385,6 @@ func (t *psrpc) generateImports(file *descriptor.FileDescriptorProto) {
 					}
 				}
 
-				if substitution, ok := t.importMap[def.File.GetName()]; ok {
-					importPath = substitution
-				}
 				importPath = t.importPrefix + importPath
 
 				pkg := t.goPackageName(def.File)
@@ -362,6 +440,12 @@ const (
 func (t *psrpc) generateService(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) {
 	servName := serviceNameCamelCased(service)
 
+	for _, method := range service.Method {
+		if err := validateOptions(method, t.getOptions(method)); err != nil {
+			gen.Fail(fmt.Sprintf("invalid options for %s.%s:", servName, methodNameCamelCased(method)), err.Error())
+		}
+	}
+
 	t.sectionComment(servName + ` Client Interface`)
 	t.generateInterface(file, service, client)
 
@@ -394,7 +478,8 @@ func (t *psrpc) generateInterface(file *descriptor.FileDescriptorProto, service
 	t.P(`type `, servName, iface, topics.FormatTypeParamConstraints(), ` interface {`)
 	for _, method := range service.Method {
 		opts := t.getOptions(method)
-		if (iface == serverImpl && opts.Subscription) || (iface == server && !opts.Subscription && !opts.Topics) {
+		if (iface == serverImpl && (opts.Subscription || opts.Notification)) ||
+			(iface == server && !opts.Subscription && !opts.Notification && !opts.Topics) {
 			continue
 		}
 
@@ -428,29 +513,88 @@ func (t *psrpc) generateInterface(file *descriptor.FileDescriptorProto, service
 }
 
 func (t *psrpc) generateClientSignature(method *descriptor.MethodDescriptorProto, opts *options.Options) {
-	methName := methodNameCamelCased(method)
+	for _, sig := range t.clientMethodSignatures(method, opts) {
+		if sig.queue {
+			t.P(`  // `, sig.name, ` shares updates between its subscribers, so each update is received by one of them.`)
+		}
+		t.P(`  `, sig.name, `(`, sig.params, `) `, sig.results)
+		t.P()
+	}
+}
+
+// clientSignature is shared by the client interface, the client and its mock
+type clientSignature struct {
+	name    string
+	params  string
+	args    string // the params, passed on to another func
+	results string
+	queue   bool // the queue variant of a multi subscription
+}
+
+// clientMethodSignatures adds a queue variant for multi subscriptions
+func (t *psrpc) clientMethodSignatures(method *descriptor.MethodDescriptorProto, opts *options.Options) []clientSignature {
+	sig := t.clientMethodSignature(method, opts)
+	if !opts.Subscription || opts.Type != options.Routing_MULTI {
+		return []clientSignature{sig}
+	}
+
+	queue := sig
+	queue.name += `Queue`
+	queue.queue = true
+	return []clientSignature{sig, queue}
+}
+
+func (t *psrpc) clientMethodSignature(method *descriptor.MethodDescriptorProto, opts *options.Options) clientSignature {
 	inputType := t.goTypeName(method.GetInputType())
 	outputType := t.goTypeName(method.GetOutputType())
 
+	sig := clientSignature{
+		name:   methodNameCamelCased(method),
+		params: `ctx ` + t.pkgs["context"] + `.Context`,
+		args:   `ctx`,
+	}
 	if opts.Subscription {
-		t.W(`  Subscribe`)
-	} else {
-		t.W(`  `)
+		sig.name = `Subscribe` + sig.name
+	} else if opts.Notification {
+		sig.name = `Publish` + sig.name
 	}
-	t.W(methName, `(ctx `, t.pkgs["context"], `.Context`)
 	if opts.Topics {
-		t.W(`, `, t.topicsForMethod(method).FormatParams())
+		topics := t.topicsForMethod(method)
+		sig.params += `, ` + topics.FormatParams()
+		sig.args += `, ` + strings.Join(topics.VarNames(), `, `)
 	}
 	if opts.Subscription {
-		t.P(`) (`, t.pkgs["psrpc"], `.Subscription[*`, outputType, `], error)`)
-	} else if opts.Stream {
-		t.P(`, opts ...`, t.pkgs["psrpc"], `.RequestOption) (`, t.pkgs["psrpc"], `.ClientStream[*`, inputType, `, *`, outputType, `], error)`)
-	} else if opts.Type == options.Routing_MULTI {
-		t.P(`, req *`, inputType, `, opts ...`, t.pkgs["psrpc"], `.RequestOption) (<-chan *`, t.pkgs["psrpc"], `.Response[*`, outputType, `], error)`)
-	} else {
-		t.P(`, req *`, inputType, `, opts ...`, t.pkgs["psrpc"], `.RequestOption) (*`, outputType, `, error)`)
+		sig.results = `(` + t.pkgs["psrpc"] + `.Subscription[*` + outputType + `], error)`
+		return sig
 	}
-	t.P()
+	if opts.Notification {
+		sig.params += `, msg *` + inputType
+		sig.args += `, msg`
+		sig.results = `error`
+		return sig
+	}
+
+	streamType := streamTypeForMethod(method, opts)
+	if streamType == noStream || streamType == serverStream {
+		sig.params += `, req *` + inputType
+		sig.args += `, req`
+	}
+	sig.params += `, opts ...` + t.pkgs["psrpc"] + `.RequestOption`
+	sig.args += `, opts...`
+
+	switch {
+	case streamType == bidiStream:
+		sig.results = `(` + t.pkgs["psrpc"] + `.ClientStream[*` + inputType + `, *` + outputType + `], error)`
+	case streamType == serverStream:
+		sig.results = `(` + t.pkgs["psrpc"] + `.ResponseStream[*` + outputType + `], error)`
+	case streamType == clientStream:
+		sig.results = `(` + t.pkgs["psrpc"] + `.RequestStream[*` + inputType + `, *` + outputType + `], error)`
+	case opts.Type == options.Routing_MULTI:
+		sig.results = `(<-chan *` + t.pkgs["psrpc"] + `.Response[*` + outputType + `], error)`
+	default:
+		sig.results = `(*` + outputType + `, error)`
+	}
+	return sig
 }
 
 func (t *psrpc) generateClient(service *descriptor.ServiceDescriptorProto) {
@@ -484,9 +628,24 @@ func (t *psrpc) generateClient(service *descriptor.ServiceDescriptorProto) {
 		)
 	}
 
+	var timeouts bool
+	for _, method := range service.Method {
+		if timeout, _ := parseTimeout(t.getOptions(method)); timeout > 0 {
+			if !timeouts {
+				t.P()
+				t.P(`  opts = append([]`, t.pkgs["psrpc"], `.ClientOption{`)
+				timeouts = true
+			}
+			t.P(`    `, t.pkgs["psrpc"], `.WithClientMethodOptions("`, methodNameCamelCased(method), `", `, t.pkgs["psrpc"], `.WithRequestTimeout(`, t.formatDuration(timeout), `)),`)
+		}
+	}
+	if timeouts {
+		t.P(`  }, opts...)`)
+	}
+
 	clientConstructor := `NewRPCClient`
 	for _, method := range service.Method {
-		if t.getOptions(method).Stream {
+		if streamTypeForMethod(method, t.getOptions(method)) != noStream {
 			clientConstructor = `NewRPCClientWithStreams`
 		}
 	}
@@ -509,39 +668,34 @@ func (t *psrpc) generateClient(service *descriptor.ServiceDescriptorProto) {
 		opts := t.getOptions(method)
 		topics := t.topicsForMethod(method)
 
-		t.W(`func (c *`, structName, servTopics.FormatTypeParams())
-		if opts.Subscription {
-			t.W(`) Subscribe`)
-		} else {
-			t.W(`) `)
-		}
-		t.W(methName, `(ctx `, t.pkgs["context"], `.Context`)
-		if opts.Topics {
-			t.W(`, `, topics.FormatParams())
-		}
 		if opts.Subscription {
-			t.P(`) (`, t.pkgs["psrpc"], `.Subscription[*`, outputType, `], error) {`)
-		} else if opts.Stream {
-			t.P(`, opts ...`, t.pkgs["psrpc"], `.RequestOption) (`, t.pkgs["psrpc"], `.ClientStream[*`, inputType, `, *`, outputType, `], error) {`)
-		} else {
-			t.W(`, req *`, inputType, `, opts ...`, t.pkgs["psrpc"], `.RequestOption`)
-			if opts.Type == options.Routing_MULTI {
-				t.P(`) (<-chan *`, t.pkgs["psrpc"], `.Response[*`, outputType, `], error) {`)
-			} else {
-				t.P(`) (*`, outputType, `, error) {`)
+			for _, sig := range t.clientMethodSignatures(method, opts) {
+				t.P(`func (c *`, structName, servTopics.FormatTypeParams(), `) `, sig.name, `(`, sig.params, `) `, sig.results, ` {`)
+				if opts.Type == options.Routing_MULTI && !sig.queue {
+					t.W(`  return `, t.pkgs["client"], `.Join[*`)
+				} else {
+					t.W(`  return `, t.pkgs["client"], `.JoinQueue[*`)
+				}
+				t.P(outputType, `](ctx, c.client, "`, methName, `", `, topics.FormatCastToStringSlice(), `)`)
+				t.P(`}`)
+				t.P()
 			}
+			continue
 		}
 
+		sig := t.clientMethodSignature(method, opts)
+		t.P(`func (c *`, structName, servTopics.FormatTypeParams(), `) `, sig.name, `(`, sig.params, `) `, sig.results, ` {`)
+		streamType := streamTypeForMethod(method, opts)
+
 		t.W(`  return `, t.pkgs["client"])
-		if opts.Subscription {
-			if opts.Type == options.Routing_MULTI {
-				t.W(`.Join[*`)
-			} else {
-				t.W(`.JoinQueue[*`)
-			}
-			t.P(outputType, `](ctx, c.client, "`, methName, `", `, topics.FormatCastToStringSlice(), `)`)
-		} else if opts.Stream {
+		if opts.Notification {
+			t.P(`.Publish(ctx, c.client, "`, methName, `", `, topics.FormatCastToStringSlice(), `, msg)`)
+		} else if streamType == bidiStream {
 			t.P(`.OpenStream[*`, inputType, `, *`, outputType, `](ctx, c.client, "`, methName, `", `, topics.FormatCastToStringSlice(), `, opts...)`)
+		} else if streamType == serverStream {
+			t.P(`.OpenServerStream[*`, inputType, `, *`, outputType, `](ctx, c.client, "`, methName, `", `, topics.FormatCastToStringSlice(), `, req, opts...)`)
+		} else if streamType == clientStream {
+			t.P(`.OpenClientStream[*`, inputType, `, *`, outputType, `](ctx, c.client, "`, methName, `", `, topics.FormatCastToStringSlice(), `, opts...)`)
 		} else {
 			if opts.Type == options.Routing_MULTI {
 				t.W(`.RequestMulti[*`)
@@ -556,13 +710,652 @@ func (t *psrpc) generateClient(service *descriptor.ServiceDescriptorProto) {
 	}
 }
 
+func (t *psrpc) generateMocks(file *descriptor.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
+	if len(file.Service) == 0 {
+		return nil
+	}
+
+	t.P("// Code generated by protoc-gen-psrpc ", version.Version, ", DO NOT EDIT.")
+	t.P("// source: ", file.GetName())
+	t.P()
+	t.P(`package `, t.genPkgName)
+	t.P()
+
+	for _, service := range file.Service {
+		if len(service.Method) > 0 {
+			t.P(`import (`)
+			t.P(`  "context"`)
+			t.P()
+			t.P(`  "github.com/livekit/psrpc"`)
+			t.P(`)`)
+			t.generateMessageImports(file)
+			break
+		}
+	}
+
+	for _, service := range file.Service {
+		t.sectionComment(serviceNameCamelCased(service) + ` Client Mock`)
+		t.generateClientMock(service)
+	}
+
+	resp := &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(strings.TrimSuffix(t.goFileName(file), ".psrpc.go") + "_mock.psrpc.go"),
+		Content: proto.String(t.formattedOutput()),
+	}
+	t.output.Reset()
+	return resp
+}
+
+func (t *psrpc) generateClientMock(service *descriptor.ServiceDescriptorProto) {
+	servName := serviceNameCamelCased(service)
+	servTopics := t.typedTopicsForService(service)
+	structName := servName + "ClientMock"
+
+	t.P(`// `, structName, ` implements `, servName, `Client for tests. Each method calls the func with the`)
+	t.P(`// same name and a Func suffix, or returns an Unimplemented error if it is nil.`)
+	t.P(`type `, structName, servTopics.FormatTypeParamConstraints(), ` struct {`)
+	for _, method := range service.Method {
+		for _, sig := range t.clientMethodSignatures(method, t.getOptions(method)) {
+			t.P(`  `, sig.name, `Func func(`, sig.params, `) `, sig.results)
+		}
+	}
+	t.P(`}`)
+	t.P()
+
+	if len(servTopics) == 0 {
+		t.P(`var _ `, servName, `Client = (*`, structName, `)(nil)`)
+		t.P()
+	}
+
+	for _, method := range service.Method {
+		for _, sig := range t.clientMethodSignatures(method, t.getOptions(method)) {
+			t.P(`func (m *`, structName, servTopics.FormatTypeParams(), `) `, sig.name, `(`, sig.params, `) `, sig.results, ` {`)
+			t.P(`  if m.`, sig.name, `Func == nil {`)
+			t.W(`    return `)
+			if !t.getOptions(method).Notification {
+				t.W(`nil, `)
+			}
+			t.P(t.pkgs["psrpc"], `.NewErrorf(`, t.pkgs["psrpc"], `.Unimplemented, "`, structName, `.`, sig.name, `Func is nil")`)
+			t.P(`  }`)
+			t.P(`  return m.`, sig.name, `Func(`, sig.args, `)`)
+			t.P(`}`)
+			t.P()
+		}
+	}
+}
+
+func (t *psrpc) generateGRPCBridges(file *descriptor.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
+	if len(file.Service) == 0 {
+		return nil
+	}
+
+	t.P("// Code generated by protoc-gen-psrpc ", version.Version, ", DO NOT EDIT.")
+	t.P("// source: ", file.GetName())
+	t.P()
+	t.P(`package `, t.genPkgName)
+	t.P()
+	var rpcs, streams bool
+	for _, service := range file.Service {
+		for _, method := range service.Method {
+			opts := t.getOptions(method)
+			rpcs = rpcs || !opts.Subscription && !opts.Notification
+			streams = streams || streamTypeForMethod(method, opts) != noStream
+		}
+	}
+
+	t.P(`import (`)
+	if rpcs {
+		t.P(`  "context"`)
+		t.P()
+	}
+	t.P(`  "google.golang.org/grpc"`)
+	t.P()
+	if streams {
+		t.P(`  "github.com/livekit/psrpc"`)
+	}
+	if rpcs {
+		t.P(`  "github.com/livekit/psrpc/pkg/grpcbridge"`)
+	}
+	t.P(`)`)
+	t.generateMessageImports(file)
+
+	for _, service := range file.Service {
+		t.sectionComment(serviceNameCamelCased(service) + ` gRPC Bridge`)
+		t.generateGRPCServerBridge(file, service)
+		t.generateGRPCClientBridge(file, service)
+	}
+
+	resp := &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(strings.TrimSuffix(t.goFileName(file), ".psrpc.go") + "_grpc.psrpc.go"),
+		Content: proto.String(t.formattedOutput()),
+	}
+	t.output.Reset()
+	return resp
+}
+
+func fullServiceName(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) string {
+	if file.GetPackage() == "" {
+		return service.GetName()
+	}
+	return file.GetPackage() + "." + service.GetName()
+}
+
+// generateGRPCServerBridge serves psrpc clients on a gRPC server. Multi-rpcs and subscriptions have no gRPC equivalent
+func (t *psrpc) generateGRPCServerBridge(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) {
+	servName := serviceNameCamelCased(service)
+	servTopics := t.typedTopicsForService(service)
+	grpcName := fullServiceName(file, service)
+	funcName := `Register` + servName + `GRPCBridge`
+
+	t.P(`// `, funcName, ` serves `, servName, ` on a gRPC server by forwarding requests to the psrpc client.`)
+	t.P(`// Topics are read from the psrpc-topic metadata. Multi-rpcs and subscriptions are not served.`)
+	t.P(`func `, funcName, servTopics.FormatTypeParamConstraints(), `(s `, t.pkgs["grpc"], `.ServiceRegistrar, c `, servName, `Client`, servTopics.FormatTypeParams(), `) {`)
+	t.P(`  s.RegisterService(&`, t.pkgs["grpc"], `.ServiceDesc{`)
+	t.P(`    ServiceName: "`, grpcName, `",`)
+	t.P(`    HandlerType: (*`, servName, `Client`, servTopics.FormatTypeParams(), `)(nil),`)
+
+	var unary, streams []*descriptor.MethodDescriptorProto
+	for _, method := range service.Method {
+		opts := t.getOptions(method)
+		switch {
+		case opts.Subscription || opts.Notification || opts.Type == options.Routing_MULTI:
+		case streamTypeForMethod(method, opts) == noStream:
+			unary = append(unary, method)
+		default:
+			streams = append(streams, method)
+		}
+	}
+
+	t.P(`    Methods: []`, t.pkgs["grpc"], `.MethodDesc{`)
+	for _, method := range unary {
+		inputType := t.goTypeName(method.GetInputType())
+		outputType := t.goTypeName(method.GetOutputType())
+
+		t.P(`      {`)
+		t.P(`        MethodName: "`, method.GetName(), `",`)
+		t.P(`        Handler: func(srv any, ctx `, t.pkgs["context"], `.Context, dec func(any) error, interceptor `, t.pkgs["grpc"], `.UnaryServerInterceptor) (any, error) {`)
+		t.P(`          return `, t.pkgs["grpcbridge"], `.ServeUnary(srv, ctx, dec, interceptor, "/`, grpcName, `/`, method.GetName(), `", func(ctx `, t.pkgs["context"], `.Context, req *`, inputType, `) (*`, outputType, `, error) {`)
+		t.generateGRPCBridgeCall(method, true)
+		t.P(`          })`)
+		t.P(`        },`)
+		t.P(`      },`)
+	}
+	t.P(`    },`)
+
+	t.P(`    Streams: []`, t.pkgs["grpc"], `.StreamDesc{`)
+	for _, method := range streams {
+		inputType := t.goTypeName(method.GetInputType())
+		outputType := t.goTypeName(method.GetOutputType())
+		streamType := streamTypeForMethod(method, t.getOptions(method))
+
+		t.P(`      {`)
+		t.P(`        StreamName: "`, method.GetName(), `",`)
+		t.P(`        Handler: func(srv any, stream `, t.pkgs["grpc"], `.ServerStream) error {`)
+		switch streamType {
+		case serverStream:
+			t.P(`          return `, t.pkgs["grpcbridge"], `.ServeServerStream(stream, func(ctx `, t.pkgs["context"], `.Context, req *`, inputType, `) (`, t.pkgs["psrpc"], `.ResponseStream[*`, outputType, `], error) {`)
+			t.generateGRPCBridgeCall(method, true)
+		case clientStream:
+			t.P(`          return `, t.pkgs["grpcbridge"], `.ServeClientStream(stream, func(ctx `, t.pkgs["context"], `.Context) (`, t.pkgs["psrpc"], `.RequestStream[*`, inputType, `, *`, outputType, `], error) {`)
+			t.generateGRPCBridgeCall(method, false)
+		case bidiStream:
+			t.P(`          return `, t.pkgs["grpcbridge"], `.ServeBidiStream(stream, func(ctx `, t.pkgs["context"], `.Context) (`, t.pkgs["psrpc"], `.ClientStream[*`, inputType, `, *`, outputType, `], error) {`)
+			t.generateGRPCBridgeCall(method, false)
+		}
+		t.P(`          })`)
+		t.P(`        },`)
+		t.P(`        ServerStreams: `, fmt.Sprint(streamType != clientStream), `,`)
+		t.P(`        ClientStreams: `, fmt.Sprint(streamType != serverStream), `,`)
+		t.P(`      },`)
+	}
+	t.P(`    },`)
+	t.P(`    Metadata: "`, file.GetName(), `",`)
+	t.P(`  }, c)`)
+	t.P(`}`)
+	t.P()
+}
+
+// generateGRPCBridgeCall prints the body of a func calling the psrpc client, reading topics from the gRPC metadata
+func (t *psrpc) generateGRPCBridgeCall(method *descriptor.MethodDescriptorProto, withReq bool) {
+	topics := t.topicsForMethod(method)
+	args := []string{`ctx`}
+	if len(topics) > 0 {
+		t.P(`            topics, err := `, t.pkgs["grpcbridge"], `.IncomingTopics(ctx, `, strconv.Itoa(len(topics)), `)`)
+		t.P(`            if err != nil {`)
+		t.P(`              return nil, err`)
+		t.P(`            }`)
+		for i, topic := range topics {
+			arg := fmt.Sprintf(`topics[%d]`, i)
+			if topic.typed {
+				arg = topic.typeName + `(` + arg + `)`
+			}
+			args = append(args, arg)
+		}
+	}
+	if withReq {
+		args = append(args, `req`)
+	}
+	t.P(`            return c.`, methodNameCamelCased(method), `(`, strings.Join(args, `, `), `)`)
+}
+
+// generateGRPCClientBridge implements the ServerImpl by forwarding requests to a gRPC server
+func (t *psrpc) generateGRPCClientBridge(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) {
+	servName := serviceNameCamelCased(service)
+	grpcName := fullServiceName(file, service)
+	structName := unexported(servName) + "GRPCBridge"
+	newFuncName := `New` + servName + `GRPCBridge`
+
+	t.P(`type `, structName, ` struct {`)
+	t.P(`  cc `, t.pkgs["grpc"], `.ClientConnInterface`)
+	t.P(`}`)
+	t.P()
+	t.P(`// `, newFuncName, ` implements `, servName, `ServerImpl by forwarding requests to a `, servName, ` gRPC server.`)
+	t.P(`// Affinity funcs always return 1.`)
+	t.P(`func `, newFuncName, `(cc `, t.pkgs["grpc"], `.ClientConnInterface) `, servName, `ServerImpl {`)
+	t.P(`  return &`, structName, `{cc: cc}`)
+	t.P(`}`)
+	t.P()
+
+	for _, method := range service.Method {
+		opts := t.getOptions(method)
+		if opts.Subscription || opts.Notification {
+			continue
+		}
+
+		methName := methodNameCamelCased(method)
+		inputType := t.goTypeName(method.GetInputType())
+		outputType := t.goTypeName(method.GetOutputType())
+		fullName := `"/` + grpcName + `/` + method.GetName() + `"`
+		streamType := streamTypeForMethod(method, opts)
+
+		t.W(`func (b *`, structName, `) `, methName)
+		switch streamType {
+		case noStream:
+			t.P(`(ctx `, t.pkgs["context"], `.Context, req *`, inputType, `) (*`, outputType, `, error) {`)
+			t.P(`  return `, t.pkgs["grpcbridge"], `.Invoke[*`, inputType, `, *`, outputType, `](ctx, b.cc, `, fullName, `, req)`)
+		case serverStream:
+			t.P(`(ctx `, t.pkgs["context"], `.Context, req *`, inputType, `, w `, t.pkgs["psrpc"], `.StreamWriter[*`, outputType, `]) error {`)
+			t.P(`  return `, t.pkgs["grpcbridge"], `.InvokeServerStream(ctx, b.cc, `, fullName, `, req, w)`)
+		case clientStream:
+			t.P(`(ctx `, t.pkgs["context"], `.Context, r `, t.pkgs["psrpc"], `.StreamReader[*`, inputType, `]) (*`, outputType, `, error) {`)
+			t.P(`  return `, t.pkgs["grpcbridge"], `.InvokeClientStream[*`, inputType, `, *`, outputType, `](ctx, b.cc, `, fullName, `, r)`)
+		case bidiStream:
+			t.P(`(s `, t.pkgs["psrpc"], `.ServerStream[*`, outputType, `, *`, inputType, `]) error {`)
+			t.P(`  return `, t.pkgs["grpcbridge"], `.InvokeBidiStream[*`, inputType, `, *`, outputType, `](b.cc, `, fullName, `, s)`)
+		}
+		t.P(`}`)
+		t.P()
+
+		if opts.Type == options.Routing_AFFINITY {
+			if streamType == noStream {
+				t.P(`func (b *`, structName, `) `, methName, `Affinity(`, t.pkgs["context"], `.Context, *`, inputType, `) float32 {`)
+			} else {
+				t.P(`func (b *`, structName, `) `, methName, `Affinity(`, t.pkgs["context"], `.Context) float32 {`)
+			}
+			t.P(`  return 1`)
+			t.P(`}`)
+			t.P()
+		}
+	}
+}
+
+func (t *psrpc) generateHTTPHandlers(file *descriptor.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
+	if len(file.Service) == 0 {
+		return nil
+	}
+
+	t.P("// Code generated by protoc-gen-psrpc ", version.Version, ", DO NOT EDIT.")
+	t.P("// source: ", file.GetName())
+	t.P()
+	t.P(`package `, t.genPkgName)
+	t.P()
+
+	var rpcs, multi bool
+	for _, service := range file.Service {
+		for _, method := range service.Method {
+			if t.servedOverHTTP(method) {
+				rpcs = true
+				multi = multi || t.getOptions(method).Type == options.Routing_MULTI
+			}
+		}
+	}
+
+	t.P(`import (`)
+	if rpcs {
+		t.P(`  "context"`)
+	}
+	t.P(`  "net/http"`)
+	t.P()
+	if multi {
+		t.P(`  "github.com/livekit/psrpc"`)
+	}
+	t.P(`  "github.com/livekit/psrpc/pkg/httpgateway"`)
+	t.P(`)`)
+	t.generateMessageImports(file)
+
+	for _, service := range file.Service {
+		t.sectionComment(serviceNameCamelCased(service) + ` HTTP Handler`)
+		t.generateHTTPHandler(file, service)
+	}
+
+	resp := &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(strings.TrimSuffix(t.goFileName(file), ".psrpc.go") + "_http.psrpc.go"),
+		Content: proto.String(t.formattedOutput()),
+	}
+	t.output.Reset()
+	return resp
+}
+
+// servedOverHTTP is true for rpcs with a single request, which can be read from a JSON body
+func (t *psrpc) servedOverHTTP(method *descriptor.MethodDescriptorProto) bool {
+	opts := t.getOptions(method)
+	return !opts.Subscription && !opts.Notification && streamTypeForMethod(method, opts) == noStream
+}
+
+func (t *psrpc) generateHTTPHandler(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) {
+	servName := serviceNameCamelCased(service)
+	servTopics := t.typedTopicsForService(service)
+	fullName := fullServiceName(file, service)
+	funcName := `New` + servName + `HTTPHandler`
+
+	t.P(`// `, funcName, ` serves `, servName, ` rpcs as JSON. Requests are POSTed to`)
+	t.P(`// /`, fullName, `/<Method>, with topics in topic query params. Errors are returned`)
+	t.P(`// with the HTTP status for their code. Streams and subscriptions are not served.`)
+	t.P(`func `, funcName, servTopics.FormatTypeParamConstraints(), `(c `, servName, `Client`, servTopics.FormatTypeParams(), `) `, t.pkgs["http"], `.Handler {`)
+	t.P(`  return `, t.pkgs["httpgateway"], `.NewHandler(map[string]`, t.pkgs["http"], `.Handler{`)
+	for _, method := range service.Method {
+		if !t.servedOverHTTP(method) {
+			continue
+		}
+
+		inputType := t.goTypeName(method.GetInputType())
+		outputType := t.goTypeName(method.GetOutputType())
+		topics := t.topicsForMethod(method)
+
+		t.W(`    "/`, fullName, `/`, method.GetName(), `": `, t.pkgs["httpgateway"])
+		if t.getOptions(method).Type == options.Routing_MULTI {
+			t.W(`.Multi(`, strconv.Itoa(len(topics)), `, func(ctx `, t.pkgs["context"], `.Context, topics []string, req *`, inputType, `) (<-chan *`, t.pkgs["psrpc"], `.Response[*`, outputType, `], error) {`)
+		} else {
+			t.W(`.Unary(`, strconv.Itoa(len(topics)), `, func(ctx `, t.pkgs["context"], `.Context, topics []string, req *`, inputType, `) (*`, outputType, `, error) {`)
+		}
+		t.P()
+
+		args := []string{`ctx`}
+		for i, topic := range topics {
+			arg := fmt.Sprintf(`topics[%d]`, i)
+			if topic.typed {
+				arg = topic.typeName + `(` + arg + `)`
+			}
+			args = append(args, arg)
+		}
+		args = append(args, `req`)
+		t.P(`      return c.`, methodNameCamelCased(method), `(`, strings.Join(args, `, `), `)`)
+		t.P(`    }),`)
+	}
+	t.P(`  })`)
+	t.P(`}`)
+	t.P()
+}
+
+func (t *psrpc) generateTracedConstructors(file *descriptor.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
+	if len(file.Service) == 0 {
+		return nil
+	}
+
+	t.P("// Code generated by protoc-gen-psrpc ", version.Version, ", DO NOT EDIT.")
+	t.P("// source: ", file.GetName())
+	t.P()
+	t.P(`package `, t.genPkgName)
+	t.P()
+	t.P(`import (`)
+	t.P(`  "github.com/livekit/psrpc"`)
+	t.P(`  "github.com/livekit/psrpc/pkg/tracing"`)
+	t.P(`)`)
+	t.P()
+
+	for _, service := range file.Service {
+		t.sectionComment(serviceNameCamelCased(service) + ` Tracing`)
+		t.generateTracedConstructor(service)
+	}
+
+	resp := &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(strings.TrimSuffix(t.goFileName(file), ".psrpc.go") + "_otel.psrpc.go"),
+		Content: proto.String(t.formattedOutput()),
+	}
+	t.output.Reset()
+	return resp
+}
+
+// generateTracedConstructor wraps the client and server constructors with OpenTelemetry interceptors
+func (t *psrpc) generateTracedConstructor(service *descriptor.ServiceDescriptorProto) {
+	servName := serviceNameCamelCased(service)
+	servTopics := t.typedTopicsForService(service)
+	constraints := servTopics.FormatTypeParamConstraints()
+	params := servTopics.FormatTypeParams()
+
+	t.P(`// New`, servName, `TracedClient creates a `, servName, `Client recording an OpenTelemetry span for each request.`)
+	t.P(`func New`, servName, `TracedClient`, constraints, `(bus `, t.pkgs["psrpc"], `.MessageBus, opts ...`, t.pkgs["psrpc"], `.ClientOption) (`, servName, `Client`, params, `, error) {`)
+	t.P(`  return New`, servName, `Client`, params, `(bus, append([]`, t.pkgs["psrpc"], `.ClientOption{`, t.pkgs["tracing"], `.WithClientTracing()}, opts...)...)`)
+	t.P(`}`)
+	t.P()
+
+	t.P(`// New`, servName, `TracedServer creates a `, servName, `Server recording an OpenTelemetry span for each handled request.`)
+	t.P(`func New`, servName, `TracedServer`, constraints, `(svc `, servName, `ServerImpl, bus `, t.pkgs["psrpc"], `.MessageBus, opts ...`, t.pkgs["psrpc"], `.ServerOption) (`, servName, `Server`, params, `, error) {`)
+	t.P(`  return New`, servName, `Server`, params, `(svc, bus, append([]`, t.pkgs["psrpc"], `.ServerOption{`, t.pkgs["tracing"], `.WithServerTracing()}, opts...)...)`)
+	t.P(`}`)
+	t.P()
+}
+
+func (t *psrpc) generatePartialServers(file *descriptor.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
+	if len(file.Service) == 0 {
+		return nil
+	}
+
+	t.P("// Code generated by protoc-gen-psrpc ", version.Version, ", DO NOT EDIT.")
+	t.P("// source: ", file.GetName())
+	t.P()
+	t.P(`package `, t.genPkgName)
+	t.P()
+
+	var ctx bool
+	for _, service := range file.Service {
+		for _, method := range service.Method {
+			opts := t.getOptions(method)
+			ctx = ctx || opts.Subscription || opts.Notification || streamTypeForMethod(method, opts) != bidiStream
+		}
+	}
+
+	t.P(`import (`)
+	if ctx {
+		t.P(`  "context"`)
+		t.P()
+	}
+	t.P(`  "github.com/livekit/psrpc"`)
+	t.P(`  "github.com/livekit/psrpc/pkg/info"`)
+	t.P(`  "github.com/livekit/psrpc/pkg/rand"`)
+	t.P(`  "github.com/livekit/psrpc/pkg/server"`)
+	t.P(`)`)
+	t.generateMessageImports(file)
+
+	for _, service := range file.Service {
+		t.sectionComment(serviceNameCamelCased(service) + ` Partial Server`)
+		t.generatePartialServer(file, service)
+	}
+
+	resp := &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(strings.TrimSuffix(t.goFileName(file), ".psrpc.go") + "_partial.psrpc.go"),
+		Content: proto.String(t.formattedOutput()),
+	}
+	t.output.Reset()
+	return resp
+}
+
+// generatePartialServer writes a server with a register func for each rpc, so processes can serve a subset of a service
+func (t *psrpc) generatePartialServer(file *descriptor.FileDescriptorProto, service *descriptor.ServiceDescriptorProto) {
+	servName := serviceNameCamelCased(service)
+	servTopics := t.typedTopicsForService(service)
+	ifaceName := servName + `PartialServer`
+	structName := unexported(servName) + `PartialServer`
+	recv := `func (s *` + structName + servTopics.FormatTypeParams() + `) `
+
+	t.P(`// `, ifaceName, ` serves the `, servName, ` rpcs registered with its Register<Method>Handler funcs.`)
+	t.P(`type `, ifaceName, servTopics.FormatTypeParamConstraints(), ` interface {`)
+	for _, method := range service.Method {
+		opts := t.getOptions(method)
+		if comments, err := t.reg.MethodComments(file, service, method); err == nil {
+			t.printComments(comments)
+		}
+		if opts.Subscription || opts.Notification {
+			t.generateServerSignature(method, opts)
+			continue
+		}
+		methName := methodNameCamelCased(method)
+		t.P(`  Register`, methName, `Handler(`, t.partialHandlerParams(method, opts), `) error`)
+		t.P(`  Deregister`, methName, `Handler(`, t.topicsForMethod(method).FormatParams(), `)`)
+		t.P()
+	}
+	t.P(`  // Close and wait for pending RPCs to complete`)
+	t.P(`  Shutdown()`)
+	t.P()
+	t.P(`  // Close immediately, without waiting for pending RPCs`)
+	t.P(`  Kill()`)
+	t.P(`}`)
+	t.P()
+
+	t.P(`type `, structName, servTopics.FormatTypeParamConstraints(), ` struct {`)
+	t.P(`  rpc *`, t.pkgs["server"], `.RPCServer`)
+	t.P(`}`)
+	t.P()
+
+	t.P(`// New`, ifaceName, ` builds a RPCServer without handlers. Rpcs are served once their handlers are registered.`)
+	t.P(`func New`, ifaceName, servTopics.FormatTypeParamConstraints(), `(bus `, t.pkgs["psrpc"], `.MessageBus, opts ...`, t.pkgs["psrpc"], `.ServerOption) (`, ifaceName, servTopics.FormatTypeParams(), `, error) {`)
+	t.P(`  sd := &`, t.pkgs["info"], `.ServiceDefinition{`)
+	t.P(`    Name: "`, servName, `",`)
+	t.P(`    ID:   `, t.pkgs["rand"], `.NewServerID(),`)
+	t.P(`  }`)
+	t.P()
+	t.P(`  s := `, t.pkgs["server"], `.NewRPCServer(sd, bus, opts...)`)
+	t.P()
+	for _, method := range service.Method {
+		opts := t.getOptions(method)
+		t.P(`  sd.RegisterMethod("`, methodNameCamelCased(method), `", `,
+			fmt.Sprint(opts.Type == options.Routing_AFFINITY), `, `,
+			fmt.Sprint(opts.Type == options.Routing_MULTI), `, `,
+			fmt.Sprint(t.getRequireClaim(opts)), `, `,
+			fmt.Sprint(opts.Type == options.Routing_QUEUE), `)`,
+		)
+	}
+	t.P()
+	t.P(`  return &`, structName, servTopics.FormatTypeParams(), `{`)
+	t.P(`    rpc: s,`)
+	t.P(`  }, nil`)
+	t.P(`}`)
+	t.P()
+
+	for _, method := range service.Method {
+		opts := t.getOptions(method)
+		methName := methodNameCamelCased(method)
+		inputType := t.goTypeName(method.GetInputType())
+		outputType := t.goTypeName(method.GetOutputType())
+		topics := t.topicsForMethod(method)
+
+		switch {
+		case opts.Notification:
+			t.W(recv, `Subscribe`, methName, `(ctx `, t.pkgs["context"], `.Context`)
+			if opts.Topics {
+				t.W(`, `, topics.FormatParams())
+			}
+			t.P(`) (`, t.pkgs["psrpc"], `.Subscription[*`, inputType, `], error) {`)
+			if opts.Type == options.Routing_MULTI {
+				t.W(`  return `, t.pkgs["server"], `.Join[*`)
+			} else {
+				t.W(`  return `, t.pkgs["server"], `.JoinQueue[*`)
+			}
+			t.P(inputType, `](ctx, s.rpc, "`, methName, `", `, topics.FormatCastToStringSlice(), `)`)
+			t.P(`}`)
+			t.P()
+
+		case opts.Subscription:
+			t.W(recv, `Publish`, methName, `(ctx `, t.pkgs["context"], `.Context`)
+			if opts.Topics {
+				t.W(`, `, topics.FormatParams())
+			}
+			t.P(`, msg *`, outputType, `) error {`)
+			t.P(`  return s.rpc.Publish(ctx, "`, methName, `", `, topics.FormatCastToStringSlice(), `, msg)`)
+			t.P(`}`)
+			t.P()
+
+		default:
+			affinityFunc := `nil`
+			if opts.Type == options.Routing_AFFINITY {
+				affinityFunc = `affinityFunc`
+			}
+			t.P(recv, `Register`, methName, `Handler(`, t.partialHandlerParams(method, opts), `) error {`)
+			t.P(`  return `, t.pkgs["server"], `.`, streamTypeForMethod(method, opts).registerFuncName(), `(s.rpc, "`, methName, `", `, topics.FormatCastToStringSlice(), `, handler, `, affinityFunc, `)`)
+			t.P(`}`)
+			t.P()
+			t.P(recv, `Deregister`, methName, `Handler(`, topics.FormatParams(), `) {`)
+			t.P(`  s.rpc.DeregisterHandler("`, methName, `", `, topics.FormatCastToStringSlice(), `)`)
+			t.P(`}`)
+			t.P()
+		}
+	}
+
+	t.P(recv, `Shutdown() {`)
+	t.P(`  s.rpc.Close(false)`)
+	t.P(`}`)
+	t.P()
+	t.P(recv, `Kill() {`)
+	t.P(`  s.rpc.Close(true)`)
+	t.P(`}`)
+	t.P()
+}
+
+// partialHandlerParams lists the handler, the affinity func for affinity rpcs, and the topics
+func (t *psrpc) partialHandlerParams(method *descriptor.MethodDescriptorProto, opts *options.Options) string {
+	inputType := t.goTypeName(method.GetInputType())
+	outputType := t.goTypeName(method.GetOutputType())
+
+	var params string
+	streamType := streamTypeForMethod(method, opts)
+	switch streamType {
+	case bidiStream:
+		params = `handler func(` + t.pkgs["psrpc"] + `.ServerStream[*` + outputType + `, *` + inputType + `]) error`
+	case serverStream:
+		params = `handler func(` + t.pkgs["context"] + `.Context, *` + inputType + `, ` + t.pkgs["psrpc"] + `.StreamWriter[*` + outputType + `]) error`
+	case clientStream:
+		params = `handler func(` + t.pkgs["context"] + `.Context, ` + t.pkgs["psrpc"] + `.StreamReader[*` + inputType + `]) (*` + outputType + `, error)`
+	default:
+		params = `handler func(` + t.pkgs["context"] + `.Context, *` + inputType + `) (*` + outputType + `, error)`
+	}
+
+	if opts.Type == options.Routing_AFFINITY {
+		if streamType == noStream {
+			params += `, affinityFunc ` + t.pkgs["server"] + `.AffinityFunc[*` + inputType + `]`
+		} else {
+			params += `, affinityFunc ` + t.pkgs["server"] + `.StreamAffinityFunc`
+		}
+	}
+	if topics := t.topicsForMethod(method); len(topics) != 0 {
+		params += `, ` + topics.FormatParams()
+	}
+	return params
+}
+
 func (t *psrpc) generateServerImplSignature(method *descriptor.MethodDescriptorProto, opts *options.Options) {
 	methName := methodNameCamelCased(method)
 	inputType := t.goTypeName(method.GetInputType())
 	outputType := t.goTypeName(method.GetOutputType())
 
-	if opts.Stream {
-		t.P(`  `, methName, `(`, t.pkgs["psrpc"], `.ServerStream[*`, outputType, `, *`, inputType, `]) error`)
+	if streamType := streamTypeForMethod(method, opts); streamType != noStream {
+		switch streamType {
+		case bidiStream:
+			t.P(`  `, methName, `(`, t.pkgs["psrpc"], `.ServerStream[*`, outputType, `, *`, inputType, `]) error`)
+		case serverStream:
+			t.P(`  `, methName, `(`, t.pkgs["context"], `.Context, *`, inputType, `, `, t.pkgs["psrpc"], `.StreamWriter[*`, outputType, `]) error`)
+		case clientStream:
+			t.P(`  `, methName, `(`, t.pkgs["context"], `.Context, `, t.pkgs["psrpc"], `.StreamReader[*`, inputType, `]) (*`, outputType, `, error)`)
+		}
 		if opts.Type == options.Routing_AFFINITY {
 			t.P(`  `, methName, `Affinity(context.Context) float32`)
 		}
@@ -577,10 +1370,18 @@ func (t *psrpc) generateServerImplSignature(method *descriptor.MethodDescriptorP
 
 func (t *psrpc) generateServerSignature(method *descriptor.MethodDescriptorProto, opts *options.Options) {
 	methName := methodNameCamelCased(method)
+	inputType := t.goTypeName(method.GetInputType())
 	outputType := t.goTypeName(method.GetOutputType())
 	topics := t.topicsForMethod(method)
 
-	if opts.Subscription {
+	if opts.Notification {
+		t.W(`  Subscribe`, methName, `(ctx `, t.pkgs["context"], `.Context`)
+		if opts.Topics {
+			t.W(`, `, topics.FormatParams())
+		}
+		t.P(`) (`, t.pkgs["psrpc"], `.Subscription[*`, inputType, `], error)`)
+		t.P()
+	} else if opts.Subscription {
 		t.W(`  Publish`, methName, `(ctx `, t.pkgs["context"], `.Context`)
 		if opts.Topics {
 			t.W(`, `, topics.FormatParams())
@@ -629,7 +1430,7 @@ func (t *psrpc) generateServer(service *descriptor.ServiceDescriptorProto) {
 			fmt.Sprint(opts.Type == options.Routing_QUEUE), `)`,
 		)
 
-		if opts.Subscription || opts.Topics {
+		if opts.Subscription || opts.Notification || opts.Topics {
 			continue
 		}
 
@@ -638,10 +1439,7 @@ func (t *psrpc) generateServer(service *descriptor.ServiceDescriptorProto) {
 			errVar = true
 		}
 
-		registerFuncName := "RegisterHandler"
-		if opts.Stream {
-			registerFuncName = "RegisterStreamHandler"
-		}
+		registerFuncName := streamTypeForMethod(method, opts).registerFuncName()
 		t.W(`  err = `, t.pkgs["server"], `.`, registerFuncName, `(s, "`, methName, `", nil, svc.`, methName)
 		if t.getOptions(method).Type == options.Routing_AFFINITY {
 			t.W(`, svc.`, methName, `Affinity`)
@@ -665,15 +1463,30 @@ func (t *psrpc) generateServer(service *descriptor.ServiceDescriptorProto) {
 
 	for _, method := range service.Method {
 		opts := t.getOptions(method)
-		if !opts.Subscription && !opts.Topics {
+		if !opts.Subscription && !opts.Notification && !opts.Topics {
 			continue
 		}
 
 		methName := methodNameCamelCased(method)
+		inputType := t.goTypeName(method.GetInputType())
 		outputType := t.goTypeName(method.GetOutputType())
 		topics := t.topicsForMethod(method)
 
-		if opts.Subscription {
+		if opts.Notification {
+			t.W(`func (s *`, servStruct, servTopics.FormatTypeParams(), `) Subscribe`, methName, `(ctx `, t.pkgs["context"], `.Context`)
+			if opts.Topics {
+				t.W(`, `, topics.FormatParams())
+			}
+			t.P(`) (`, t.pkgs["psrpc"], `.Subscription[*`, inputType, `], error) {`)
+			if opts.Type == options.Routing_MULTI {
+				t.W(`  return `, t.pkgs["server"], `.Join[*`)
+			} else {
+				t.W(`  return `, t.pkgs["server"], `.JoinQueue[*`)
+			}
+			t.P(inputType, `](ctx, s.rpc, "`, methName, `", `, topics.FormatCastToStringSlice(), `)`)
+			t.P(`}`)
+			t.P()
+		} else if opts.Subscription {
 			t.W(`func (s *`, servStruct, servTopics.FormatTypeParams(), `) Publish`, methName, `(ctx `, t.pkgs["context"], `.Context`)
 			if opts.Topics {
 				t.W(`, `, topics.FormatParams())
@@ -683,10 +1496,7 @@ func (t *psrpc) generateServer(service *descriptor.ServiceDescriptorProto) {
 			t.P(`}`)
 			t.P()
 		} else {
-			registerFuncName := "RegisterHandler"
-			if opts.Stream {
-				registerFuncName = "RegisterStreamHandler"
-			}
+			registerFuncName := streamTypeForMethod(method, opts).registerFuncName()
 			t.P(`func (s *`, servStruct, servTopics.FormatTypeParams(), `) Register`, methName, `Topic(`, topics.FormatParams(), `) error {`)
 			t.W(`  return `, t.pkgs["server"], `.`, registerFuncName, `(s.rpc, "`, methName, `", `, topics.FormatCastToStringSlice(), `, s.svc.`, methName)
 			if t.getOptions(method).Type == options.Routing_AFFINITY {
@@ -739,16 +1549,132 @@ func (t *psrpc) getOptions(method *descriptor.MethodDescriptorProto) *options.Op
 	}
 
 	opts := proto.GetExtension(method.Options, options.E_Options).(*options.Options)
+	if opts.Routing != nil && opts.Type == options.Routing_QUEUE {
+		opts.Type = routingForOneof(opts)
+	}
+
+	return opts
+}
+
+func routingForOneof(opts *options.Options) options.Routing {
 	switch opts.Routing.(type) {
 	case *options.Options_AffinityFunc:
-		opts.Type = options.Routing_AFFINITY
+		return options.Routing_AFFINITY
 	case *options.Options_Multi:
-		opts.Type = options.Routing_MULTI
-	case *options.Options_Queue:
-		opts.Type = options.Routing_QUEUE
+		return options.Routing_MULTI
+	default:
+		return options.Routing_QUEUE
 	}
+}
 
-	return opts
+type streamType int
+
+const (
+	noStream streamType = iota
+	bidiStream
+	serverStream
+	clientStream
+)
+
+// streamTypeForMethod uses the stream option or the proto stream keywords. Methods streaming in both
+// directions are bidirectional streams
+func streamTypeForMethod(method *descriptor.MethodDescriptorProto, opts *options.Options) streamType {
+	switch {
+	case opts.Stream || (method.GetClientStreaming() && method.GetServerStreaming()):
+		return bidiStream
+	case method.GetServerStreaming():
+		return serverStream
+	case method.GetClientStreaming():
+		return clientStream
+	default:
+		return noStream
+	}
+}
+
+func (s streamType) registerFuncName() string {
+	switch s {
+	case bidiStream:
+		return "RegisterStreamHandler"
+	case serverStream:
+		return "RegisterServerStreamHandler"
+	case clientStream:
+		return "RegisterClientStreamHandler"
+	default:
+		return "RegisterHandler"
+	}
+}
+
+// validateOptions rejects option combinations the generated client and server cannot support
+func validateOptions(method *descriptor.MethodDescriptorProto, opts *options.Options) error {
+	if opts.Routing != nil && opts.Type != routingForOneof(opts) {
+		return errors.New("type conflicts with the deprecated multi, affinity_func or queue option")
+	}
+
+	streaming := streamTypeForMethod(method, opts) != noStream
+	switch {
+	case opts.Subscription && opts.Notification:
+		return errors.New("methods cannot be both subscriptions and notifications")
+	case (opts.Subscription || opts.Notification) && streaming:
+		return errors.New("subscriptions and notifications cannot stream")
+	case opts.Notification && opts.Type == options.Routing_AFFINITY:
+		return errors.New("notifications cannot use affinity")
+	case opts.Subscription && opts.Type == options.Routing_AFFINITY:
+		return errors.New("subscriptions cannot use affinity")
+	case streaming && opts.Type == options.Routing_MULTI:
+		return errors.New("streams are opened with a single server and cannot use multi")
+	case opts.TopicParams != nil && !opts.Topics:
+		return errors.New("topic_params requires topics")
+	case opts.Timeout != "" && (opts.Subscription || opts.Notification):
+		return errors.New("subscriptions and notifications cannot have a timeout")
+	}
+
+	_, err := parseTimeout(opts)
+	return err
+}
+
+func parseTimeout(opts *options.Options) (time.Duration, error) {
+	if opts.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(opts.Timeout)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid timeout")
+	}
+	if timeout <= 0 {
+		return 0, errors.New("timeout must be positive")
+	}
+	return timeout, nil
+}
+
+func (t *psrpc) hasTimeouts(file *descriptor.FileDescriptorProto) bool {
+	for _, service := range file.Service {
+		for _, method := range service.Method {
+			if timeout, _ := parseTimeout(t.getOptions(method)); timeout > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// formatDuration writes d as a multiple of the largest time unit dividing it
+func (t *psrpc) formatDuration(d time.Duration) string {
+	units := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"Hour", time.Hour},
+		{"Minute", time.Minute},
+		{"Second", time.Second},
+		{"Millisecond", time.Millisecond},
+		{"Microsecond", time.Microsecond},
+	}
+	for _, u := range units {
+		if d%u.d == 0 {
+			return fmt.Sprintf("%d * %s.%s", d/u.d, t.pkgs["time"], u.name)
+		}
+	}
+	return fmt.Sprintf("%d * %s.Nanosecond", d, t.pkgs["time"])
 }
 
 func (t *psrpc) getRequireClaim(opts *options.Options) bool {
@@ -871,7 +1797,7 @@ func (t *psrpc) topicGroupsForService(service *descriptor.ServiceDescriptorProto
 	found := map[string]int{}
 	for _, m := range service.Method {
 		opt := t.getOptions(m)
-		if opt.TopicParams == nil || opt.TopicParams.Group == "" || opt.Subscription {
+		if opt.TopicParams == nil || opt.TopicParams.Group == "" || opt.Subscription || opt.Notification {
 			continue
 		}
 
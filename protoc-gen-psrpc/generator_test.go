@@ -17,9 +17,15 @@ import (
 	"os"
 	"os/exec"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
+	descriptor "google.golang.org/protobuf/types/descriptorpb"
 	plugin "google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/protoc-gen-psrpc/options"
 )
 
 func TestGenerateParseCommandLineParamsError(t *testing.T) {
@@ -38,3 +44,109 @@ func TestGenerateParseCommandLineParamsError(t *testing.T) {
 	}
 	t.Fatalf("process ran with err %v, want exit status 1", err)
 }
+
+func TestValidateOptions(t *testing.T) {
+	cases := []struct {
+		name      string
+		streaming bool
+		opts      *options.Options
+		valid     bool
+	}{
+		{"Queue", false, &options.Options{}, true},
+		{"Multi", false, &options.Options{Type: options.Routing_MULTI}, true},
+		{"DeprecatedAffinity", false, &options.Options{Routing: &options.Options_AffinityFunc{AffinityFunc: true}}, true},
+		{"ConflictingRouting", false, &options.Options{Type: options.Routing_MULTI, Routing: &options.Options_AffinityFunc{AffinityFunc: true}}, false},
+		{"AffinityStream", true, &options.Options{Type: options.Routing_AFFINITY}, true},
+		{"MultiStream", false, &options.Options{Stream: true, Type: options.Routing_MULTI}, false},
+		{"MultiServerStream", true, &options.Options{Type: options.Routing_MULTI}, false},
+		{"StreamingSubscription", true, &options.Options{Subscription: true}, false},
+		{"AffinitySubscription", false, &options.Options{Subscription: true, Type: options.Routing_AFFINITY}, false},
+		{"MultiNotification", false, &options.Options{Notification: true, Type: options.Routing_MULTI}, true},
+		{"StreamingNotification", true, &options.Options{Notification: true}, false},
+		{"AffinityNotification", false, &options.Options{Notification: true, Type: options.Routing_AFFINITY}, false},
+		{"SubscriptionNotification", false, &options.Options{Notification: true, Subscription: true}, false},
+		{"TopicParamsWithoutTopics", false, &options.Options{TopicParams: &options.TopicParamOptions{Names: []string{"region"}}}, false},
+		{"Timeout", false, &options.Options{Timeout: "30s"}, true},
+		{"InvalidTimeout", false, &options.Options{Timeout: "30"}, false},
+		{"NegativeTimeout", false, &options.Options{Timeout: "-1s"}, false},
+		{"SubscriptionTimeout", false, &options.Options{Subscription: true, Timeout: "30s"}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			method := &descriptor.MethodDescriptorProto{
+				Options:         &descriptor.MethodOptions{},
+				ServerStreaming: proto.Bool(c.streaming),
+			}
+			proto.SetExtension(method.Options, options.E_Options, c.opts)
+
+			err := validateOptions(method, (&psrpc{}).getOptions(method))
+			if c.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	g := &psrpc{pkgs: map[string]string{"time": "time"}}
+	require.Equal(t, "2 * time.Hour", g.formatDuration(2*time.Hour))
+	require.Equal(t, "90 * time.Second", g.formatDuration(90*time.Second))
+	require.Equal(t, "1500 * time.Millisecond", g.formatDuration(1500*time.Millisecond))
+	require.Equal(t, "3 * time.Nanosecond", g.formatDuration(3))
+}
+
+func TestDocAnchor(t *testing.T) {
+	require.Equal(t, "myservicenormalrpc", docAnchor("MyService.NormalRPC"))
+	require.Equal(t, "psrpcinternaltesttyped_topicsroomevent", docAnchor("psrpc.internal.test.typed_topics.RoomEvent"))
+	require.Equal(t, "large-responses", docAnchor("Large responses"))
+}
+
+func TestMessageSchema(t *testing.T) {
+	defs := make(map[string]map[string]any)
+	addMessageSchema(defs, (&internal.Request{}).ProtoReflect().Descriptor())
+
+	properties := defs["internal.Request"]["properties"].(map[string]any)
+	require.Equal(t, map[string]any{"type": "string", "contentEncoding": "base64"}, properties["rawRequest"])
+	require.Equal(t, map[string]any{"$ref": "#/$defs/google.protobuf.Any"}, properties["request"])
+	require.Equal(t, "object", properties["metadata"].(map[string]any)["type"])
+	require.Contains(t, defs, "google.protobuf.Any")
+}
+
+func TestGenerateImportMapping(t *testing.T) {
+	msg := func(name string) []*descriptor.DescriptorProto {
+		return []*descriptor.DescriptorProto{{Name: proto.String(name)}}
+	}
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{"foo.proto"},
+		Parameter:      proto.String("module=example.com,Mfoo.proto=example.com/foo;foopb,Mbar.proto=example.com/bar;barpb"),
+		ProtoFile: []*descriptor.FileDescriptorProto{
+			{
+				Name:        proto.String("bar.proto"),
+				Package:     proto.String("bar"),
+				Options:     &descriptor.FileOptions{GoPackage: proto.String("example.com/ignored/bar")},
+				MessageType: msg("Bar"),
+			},
+			{
+				Name:       proto.String("foo.proto"),
+				Package:    proto.String("foo"),
+				Dependency: []string{"bar.proto"},
+				Service: []*descriptor.ServiceDescriptorProto{{
+					Name: proto.String("Foo"),
+					Method: []*descriptor.MethodDescriptorProto{{
+						Name:       proto.String("Get"),
+						InputType:  proto.String(".bar.Bar"),
+						OutputType: proto.String(".bar.Bar"),
+					}},
+				}},
+			},
+		},
+	}
+
+	res := newGenerator().Generate(req)
+	require.Len(t, res.File, 1)
+	require.Equal(t, "foo/foo.psrpc.go", res.File[0].GetName())
+	require.Contains(t, res.File[0].GetContent(), "package foopb")
+	require.Contains(t, res.File[0].GetContent(), `bar "example.com/bar"`)
+}
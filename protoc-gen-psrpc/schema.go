@@ -0,0 +1,319 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	descriptor "google.golang.org/protobuf/types/descriptorpb"
+	plugin "google.golang.org/protobuf/types/pluginpb"
+
+	"github.com/livekit/psrpc/internal"
+	"github.com/livekit/psrpc/protoc-gen-psrpc/internal/gen"
+	"github.com/livekit/psrpc/protoc-gen-psrpc/options"
+	"github.com/livekit/psrpc/version"
+)
+
+// envelopes wrap payloads on the bus. their schemas are included for clients written in other languages
+var envelopes = []proto.Message{
+	&internal.Request{},
+	&internal.Response{},
+	&internal.ClaimRequest{},
+	&internal.ClaimResponse{},
+	&internal.Cancel{},
+	&internal.Stream{},
+	&internal.ServerLeaving{},
+	&internal.ServerHeartbeat{},
+}
+
+type schemaFile struct {
+	Schema   string                    `json:"$schema"`
+	Comment  string                    `json:"$comment"`
+	Title    string                    `json:"title"`
+	Version  string                    `json:"psrpcVersion"`
+	Services []schemaService           `json:"services"`
+	Defs     map[string]map[string]any `json:"$defs"`
+}
+
+type schemaService struct {
+	Name     string         `json:"name"`
+	Channels schemaChannels `json:"channels"`
+	Methods  []schemaMethod `json:"methods"`
+}
+
+type schemaMethod struct {
+	Name         string         `json:"name"`
+	Kind         string         `json:"kind"`
+	Queue        bool           `json:"queue"`
+	RequireClaim bool           `json:"requireClaim"`
+	Affinity     bool           `json:"affinity"`
+	Topics       []string       `json:"topics"`
+	Timeout      string         `json:"timeout,omitempty"`
+	Request      map[string]any `json:"request,omitempty"`
+	Response     map[string]any `json:"response,omitempty"`
+	Channels     schemaChannels `json:"channels"`
+}
+
+// schemaChannels holds channel name templates. placeholders in braces are replaced with sanitized values
+type schemaChannels map[string]string
+
+func (t *psrpc) generateSchema(file *descriptor.FileDescriptorProto) *plugin.CodeGeneratorResponse_File {
+	if len(file.Service) == 0 {
+		return nil
+	}
+
+	s := &schemaFile{
+		Schema:  "https://json-schema.org/draft/2020-12/schema",
+		Comment: "Code generated by protoc-gen-psrpc " + version.Version + ", DO NOT EDIT.",
+		Title:   file.GetName(),
+		Version: version.Version,
+		Defs:    make(map[string]map[string]any),
+	}
+
+	for _, m := range envelopes {
+		addMessageSchema(s.Defs, m.ProtoReflect().Descriptor())
+	}
+
+	for _, service := range file.Service {
+		servName := serviceNameCamelCased(service)
+		ss := schemaService{
+			Name: servName,
+			Channels: schemaChannels{
+				"response":      channelTemplate(servName, "{clientId}", "RES"),
+				"claimRequest":  channelTemplate(servName, "{clientId}", "CLAIM"),
+				"stream":        channelTemplate(servName, "{nodeId}", "STR"),
+				"serverLeaving": channelTemplate(servName, "LEAVE"),
+				"heartbeat":     channelTemplate(servName, "HEARTBEAT"),
+			},
+		}
+
+		for _, method := range service.Method {
+			opts := t.getOptions(method)
+			ss.Methods = append(ss.Methods, t.generateMethodSchema(s.Defs, servName, method, opts))
+		}
+		s.Services = append(s.Services, ss)
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		gen.Fail("could not encode schema", err.Error())
+	}
+
+	resp := &plugin.CodeGeneratorResponse_File{
+		Name:    proto.String(strings.TrimSuffix(t.goFileName(file), ".psrpc.go") + ".psrpc.json"),
+		Content: proto.String(string(b) + "\n"),
+	}
+	return resp
+}
+
+func (t *psrpc) generateMethodSchema(
+	defs map[string]map[string]any,
+	servName string,
+	method *descriptor.MethodDescriptorProto,
+	opts *options.Options,
+) schemaMethod {
+	methName := methodNameCamelCased(method)
+
+	topics := make([]string, 0)
+	if opts.Topics {
+		if opts.TopicParams == nil || len(opts.TopicParams.Names) == 0 {
+			topics = append(topics, "topic")
+		} else {
+			topics = append(topics, opts.TopicParams.Names...)
+		}
+	}
+	parts := []string{servName, methName}
+	for _, topic := range topics {
+		parts = append(parts, "{"+topic+"}")
+	}
+
+	m := schemaMethod{
+		Name:         methName,
+		Kind:         methodSchemaKind(method, opts),
+		Queue:        opts.Type == options.Routing_QUEUE,
+		RequireClaim: t.getRequireClaim(opts) && !opts.Subscription && !opts.Notification,
+		Affinity:     opts.Type == options.Routing_AFFINITY,
+		Topics:       topics,
+		Timeout:      opts.Timeout,
+		Channels:     schemaChannels{},
+	}
+
+	types := documentedTypes(method, opts)
+	for i, name := range types {
+		if name == "" {
+			continue
+		}
+		desc, err := t.files.FindDescriptorByName(protoreflect.FullName(strings.TrimPrefix(name, ".")))
+		if err != nil {
+			continue
+		}
+		md := desc.(protoreflect.MessageDescriptor)
+		addMessageSchema(defs, md)
+		if i == 0 {
+			m.Request = schemaRef(md)
+		} else {
+			m.Response = schemaRef(md)
+		}
+	}
+
+	switch {
+	case opts.Subscription || opts.Notification:
+		m.Channels["message"] = channelTemplate(append(parts, "REQ")...)
+
+	case streamTypeForMethod(method, opts) != noStream:
+		m.Channels["stream"] = channelTemplate(append(parts, "STR")...)
+		if m.RequireClaim {
+			m.Channels["claimResponse"] = channelTemplate(append(parts, "RCLAIM")...)
+		}
+
+	default:
+		m.Channels["request"] = channelTemplate(append(parts, "REQ")...)
+		if opts.Type != options.Routing_MULTI {
+			m.Channels["serverRequest"] = channelTemplate(append(parts, "{serverId}", "SREQ")...)
+		}
+		if m.RequireClaim {
+			m.Channels["claimResponse"] = channelTemplate(append(parts, "RCLAIM")...)
+		}
+		m.Channels["cancel"] = channelTemplate(append(parts, "CANCEL")...)
+	}
+	return m
+}
+
+func methodSchemaKind(method *descriptor.MethodDescriptorProto, opts *options.Options) string {
+	switch {
+	case opts.Subscription:
+		return "subscription"
+	case opts.Notification:
+		return "notification"
+	}
+
+	switch streamTypeForMethod(method, opts) {
+	case bidiStream:
+		return "bidi_stream"
+	case serverStream:
+		return "server_stream"
+	case clientStream:
+		return "client_stream"
+	}
+
+	if opts.Type == options.Routing_MULTI {
+		return "multi_rpc"
+	}
+	return "rpc"
+}
+
+func channelTemplate(parts ...string) string {
+	return strings.Join(parts, "|")
+}
+
+func schemaRef(md protoreflect.MessageDescriptor) map[string]any {
+	return map[string]any{"$ref": "#/$defs/" + string(md.FullName())}
+}
+
+// addMessageSchema adds the schema of the protojson encoding of md and the messages it references
+func addMessageSchema(defs map[string]map[string]any, md protoreflect.MessageDescriptor) {
+	name := string(md.FullName())
+	if _, ok := defs[name]; ok {
+		return
+	}
+	if s, ok := wellKnownSchemas[name]; ok {
+		defs[name] = s
+		return
+	}
+
+	properties := make(map[string]any)
+	s := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	defs[name] = s
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch {
+		case fd.IsMap():
+			properties[fd.JSONName()] = map[string]any{
+				"type":                 "object",
+				"additionalProperties": fieldSchema(defs, fd.MapValue()),
+			}
+		case fd.IsList():
+			properties[fd.JSONName()] = map[string]any{
+				"type":  "array",
+				"items": fieldSchema(defs, fd),
+			}
+		default:
+			properties[fd.JSONName()] = fieldSchema(defs, fd)
+		}
+	}
+}
+
+func fieldSchema(defs map[string]map[string]any, fd protoreflect.FieldDescriptor) map[string]any {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// protojson encodes 64 bit integers as strings
+		return map[string]any{"type": []string{"string", "integer"}, "format": "int64"}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return map[string]any{"type": "number"}
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "contentEncoding": "base64"}
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		names := make([]string, 0, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		return map[string]any{"type": "string", "enum": names}
+	default:
+		addMessageSchema(defs, fd.Message())
+		return schemaRef(fd.Message())
+	}
+}
+
+// wellKnownSchemas describe the special protojson encodings of well known types
+var wellKnownSchemas = map[string]map[string]any{
+	"google.protobuf.Any": {
+		"type":       "object",
+		"properties": map[string]any{"@type": map[string]any{"type": "string"}},
+		"required":   []string{"@type"},
+	},
+	"google.protobuf.Timestamp":   {"type": "string", "format": "date-time"},
+	"google.protobuf.Duration":    {"type": "string", "pattern": `^-?[0-9]+(\.[0-9]+)?s$`},
+	"google.protobuf.FieldMask":   {"type": "string"},
+	"google.protobuf.Empty":       {"type": "object"},
+	"google.protobuf.Struct":      {"type": "object"},
+	"google.protobuf.Value":       {},
+	"google.protobuf.ListValue":   {"type": "array"},
+	"google.protobuf.BoolValue":   {"type": "boolean"},
+	"google.protobuf.StringValue": {"type": "string"},
+	"google.protobuf.BytesValue":  {"type": "string", "contentEncoding": "base64"},
+	"google.protobuf.Int32Value":  {"type": "integer"},
+	"google.protobuf.UInt32Value": {"type": "integer"},
+	"google.protobuf.Int64Value":  {"type": []string{"string", "integer"}, "format": "int64"},
+	"google.protobuf.UInt64Value": {"type": []string{"string", "integer"}, "format": "int64"},
+	"google.protobuf.FloatValue":  {"type": "number"},
+	"google.protobuf.DoubleValue": {"type": "number"},
+}
@@ -17,23 +17,47 @@ package psrpc
 import (
 	"time"
 
+	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
 
 type RequestOption func(*RequestOpts)
 
 type RequestOpts struct {
-	Timeout       time.Duration
-	SelectionOpts SelectionOpts
-	Interceptors  []any
+	Timeout         time.Duration
+	SelectionOpts   SelectionOpts
+	IdempotencyKey  string
+	TargetServerID  string
+	Priority        int32
+	ResponseInfo    *ResponseInfo
+	SessionKey      string
+	Quorum          int
+	ExpectedServers int
+	FailFast        bool
+	Interceptors    []any
+}
+
+type ResponseInfo struct {
+	RequestID        string        // id of the last request sent
+	ServerID         string        // server that sent the response
+	ClaimLatency     time.Duration // time spent selecting a server on the last attempt, including failed selections
+	Claims           int           // claims received on the last attempt
+	AffinityTimedOut bool          // the last attempt's selection ended when SelectionOpts.AffinityTimeout expired
+	Latency          time.Duration // total time until the response was returned
+	Attempts         int           // number of requests sent, including retries
+	ProtocolVersion  uint32        // protocol version negotiated with the server that sent the response
 }
 
 type SelectionOpts struct {
-	MinimumAffinity      float32       // minimum affinity for a server to be considered a valid handler
-	MaximumAffinity      float32       // if > 0, any server returning a max score will be selected immediately
-	AcceptFirstAvailable bool          // go fast
-	AffinityTimeout      time.Duration // server selection deadline
-	ShortCircuitTimeout  time.Duration // deadline imposed after receiving first response
+	MinimumAffinity      float32           // minimum affinity for a server to be considered a valid handler
+	MaximumAffinity      float32           // if > 0, any server returning a max score will be selected immediately
+	AcceptFirstAvailable bool              // go fast
+	AffinityTimeout      time.Duration     // server selection deadline
+	ShortCircuitTimeout  time.Duration     // deadline imposed after receiving first response
+	RequiredLabels       map[string]string // claims from servers without all of these labels are ignored
+	PreferredLabels      map[string]string // servers with all of these labels are selected over other servers, if one claims within the short circuit timeout
+	CanaryLabels         map[string]string // servers with all of these labels are preferred for CanaryRatio of requests
+	CanaryRatio          float64           // the other requests prefer servers without the canary labels
 }
 
 func WithRequestTimeout(timeout time.Duration) RequestOption {
@@ -48,6 +72,117 @@ func WithSelectionOpts(opts SelectionOpts) RequestOption {
 	}
 }
 
+// WithRequiredLabels only selects servers started with all of labels. Servers without them ignore multi rpcs and
+// notifications, and fail requests that are not claimed, such as queue rpcs, with FailedPrecondition
+func WithRequiredLabels(labels map[string]string) RequestOption {
+	return func(o *RequestOpts) {
+		o.SelectionOpts.RequiredLabels = labels
+	}
+}
+
+// WithPreferredLabels selects servers started with all of labels over other servers. Once another server claims the
+// request, selection waits for a preferred server for the short circuit timeout, or DefaultAffinityShortCircuit.
+// Requests that are not claimed, such as queue rpcs, RequestMulti and RequestNone, fail with InvalidArgument
+func WithPreferredLabels(labels map[string]string) RequestOption {
+	return func(o *RequestOpts) {
+		o.SelectionOpts.PreferredLabels = labels
+	}
+}
+
+// VersionLabel is the server label set by WithServerVersion
+const VersionLabel = "version"
+
+// WithVersion only selects servers started with WithServerVersion(version), and is enforced by servers like
+// WithRequiredLabels
+func WithVersion(version string) RequestOption {
+	return func(o *RequestOpts) {
+		o.SelectionOpts.RequiredLabels = withLabel(o.SelectionOpts.RequiredLabels, VersionLabel, version)
+	}
+}
+
+// WithPreferredVersion selects servers started with WithServerVersion(version), falling back to other versions.
+// Like WithPreferredLabels, requests that are not claimed fail with InvalidArgument
+func WithPreferredVersion(version string) RequestOption {
+	return func(o *RequestOpts) {
+		o.SelectionOpts.PreferredLabels = withLabel(o.SelectionOpts.PreferredLabels, VersionLabel, version)
+	}
+}
+
+func withLabel(labels map[string]string, k, v string) map[string]string {
+	labels = maps.Clone(labels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	labels[k] = v
+	return labels
+}
+
+// WithCanary sends ratio (0 to 1) of requests to servers with all of labels, and the rest to the other servers.
+// If no server in the selected group claims the request within the short circuit timeout, a server from the other group
+// is used. Requests that are not claimed, such as queue rpcs, RequestMulti and RequestNone, fail with InvalidArgument
+func WithCanary(labels map[string]string, ratio float64) RequestOption {
+	return func(o *RequestOpts) {
+		o.SelectionOpts.CanaryLabels = labels
+		o.SelectionOpts.CanaryRatio = ratio
+	}
+}
+
+func WithIdempotencyKey(key string) RequestOption {
+	return func(o *RequestOpts) {
+		o.IdempotencyKey = key
+	}
+}
+
+// WithTargetServer sends the request directly to serverID, skipping server selection
+func WithTargetServer(serverID string) RequestOption {
+	return func(o *RequestOpts) {
+		o.TargetServerID = serverID
+	}
+}
+
+// requests with higher priority are handled first by servers with a concurrency limit
+func WithPriority(level int32) RequestOption {
+	return func(o *RequestOpts) {
+		o.Priority = level
+	}
+}
+
+// WithResponseInfo populates info with details about how a single RPC was handled
+func WithResponseInfo(info *ResponseInfo) RequestOption {
+	return func(o *RequestOpts) {
+		o.ResponseInfo = info
+	}
+}
+
+// WithSessionKey routes requests with the same key to the same server while the set of servers is unchanged.
+// Servers claim keyed requests with rendezvous hashed affinity, so every claim is collected before a server is selected
+func WithSessionKey(key string) RequestOption {
+	return func(o *RequestOpts) {
+		o.SessionKey = key
+	}
+}
+
+// WithQuorum closes RequestMulti response channels once n successful responses have been received
+func WithQuorum(n int) RequestOption {
+	return func(o *RequestOpts) {
+		o.Quorum = n
+	}
+}
+
+// WithExpectedServers closes RequestMulti response channels once n servers have responded
+func WithExpectedServers(n int) RequestOption {
+	return func(o *RequestOpts) {
+		o.ExpectedServers = n
+	}
+}
+
+// WithFailFast causes RequestAll to return as soon as a server responds with an error
+func WithFailFast() RequestOption {
+	return func(o *RequestOpts) {
+		o.FailFast = true
+	}
+}
+
 type RequestInterceptor interface {
 	ClientRPCInterceptor | ClientMultiRPCInterceptor | StreamInterceptor
 }
@@ -21,17 +21,58 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-const DefaultServerTimeout = time.Second * 3
+const (
+	DefaultServerTimeout  = time.Second * 3
+	DefaultIdempotencyTTL = time.Minute
+
+	DefaultIdempotencyMaxEntries = 10000
+)
 
 type ServerOption func(*ServerOpts)
 
 type ServerOpts struct {
-	ServerID           string
-	Timeout            time.Duration
-	ChannelSize        int
-	Interceptors       []ServerRPCInterceptor
-	StreamInterceptors []StreamInterceptor
-	ChainedInterceptor ServerRPCInterceptor
+	ServerID              string
+	Labels                map[string]string
+	Version               string
+	Timeout               time.Duration
+	ChannelSize           int
+	ResponseChunkSize     int
+	StreamWindowSize      int
+	StreamRetryInterval   time.Duration
+	StreamPingInterval    time.Duration
+	StreamPingTimeout     time.Duration
+	Backpressure          BackpressurePolicy
+	IdempotencyTTL        time.Duration
+	IdempotencyMaxEntries int
+	DedupWindow           time.Duration
+	MaxConcurrency        int
+	HandlerConcurrency    map[string]int
+	RejectExcess          bool
+	StrictUnmarshal       bool
+	DeterministicMarshal  bool
+	MaxInFlight           int
+	MaxQueueDepth         int
+	QueueRetryAfter       time.Duration
+	ClaimTimeout          time.Duration
+	ClaimLoadCapacity     int
+	ClaimMaxDelay         time.Duration
+	ShutdownGracePeriod   time.Duration
+	HeartbeatInterval     time.Duration
+	OnExpired             ExpiredRequestHandler
+	SlowRequestThreshold  time.Duration
+	OnSlowRequest         SlowRequestHandler
+	OnOverflow            OverflowHandler
+	OnHandlerMetrics      HandlerMetricsHandler
+	OnPayloadSize         PayloadSizeHandler
+	Compression           Compression
+	CompressionThreshold  int
+	MaxMessageSize        int
+	BlobStore             BlobStore
+	BlobThreshold         int
+	Interceptors          []ServerRPCInterceptor
+	StreamInterceptors    []StreamInterceptor
+	StreamOpenHooks       []StreamOpenHook
+	ChainedInterceptor    ServerRPCInterceptor
 }
 
 func WithServerID(id string) ServerOption {
@@ -40,6 +81,20 @@ func WithServerID(id string) ServerOption {
 	}
 }
 
+// labels are sent with every claim, so clients can select servers by zone, version or capability
+func WithServerLabels(labels map[string]string) ServerOption {
+	return func(o *ServerOpts) {
+		o.Labels = labels
+	}
+}
+
+// version is added to the server labels as VersionLabel, so clients can pin requests to a version with WithVersion
+func WithServerVersion(version string) ServerOption {
+	return func(o *ServerOpts) {
+		o.Version = version
+	}
+}
+
 func WithServerTimeout(timeout time.Duration) ServerOption {
 	return func(o *ServerOpts) {
 		o.Timeout = timeout
@@ -54,6 +109,220 @@ func WithServerChannelSize(size int) ServerOption {
 	}
 }
 
+// Stream senders may have at most size messages waiting for room in the server's stream channels
+func WithServerStreamWindowSize(size int) ServerOption {
+	return func(o *ServerOpts) {
+		o.StreamWindowSize = size
+	}
+}
+
+// Unacknowledged stream messages are resent every interval until they time out, so streams survive bus outages shorter
+// than the send timeout. Messages are not resent once their Send fails, and later messages are delivered without them
+func WithServerStreamRetryInterval(interval time.Duration) ServerOption {
+	return func(o *ServerOpts) {
+		o.StreamRetryInterval = interval
+	}
+}
+
+// responses larger than size bytes are split into chunks and reassembled by the client. size <= 0 disables chunking
+func WithServerResponseChunkSize(size int) ServerOption {
+	return func(o *ServerOpts) {
+		o.ResponseChunkSize = size
+	}
+}
+
+// Idle streams ping the client every interval, and are closed if the client does not respond within timeout
+func WithServerStreamKeepalive(interval, timeout time.Duration) ServerOption {
+	return func(o *ServerOpts) {
+		o.StreamPingInterval = interval
+		o.StreamPingTimeout = timeout
+	}
+}
+
+// policy for stream messages that arrive while the channel is full
+func WithServerBackpressurePolicy(policy BackpressurePolicy) ServerOption {
+	return func(o *ServerOpts) {
+		o.Backpressure = policy
+	}
+}
+
+// results for requests with idempotency keys are cached for ttl. ttl <= 0 disables caching
+func WithServerIdempotencyTTL(ttl time.Duration) ServerOption {
+	return func(o *ServerOpts) {
+		o.IdempotencyTTL = ttl
+	}
+}
+
+// at most n idempotency results are cached per handler, evicting the oldest first. n <= 0 removes the bound
+func WithServerIdempotencyMaxEntries(n int) ServerOption {
+	return func(o *ServerOpts) {
+		o.IdempotencyMaxEntries = n
+	}
+}
+
+// requests redelivered by the bus within window of their first delivery are dropped. window <= 0 disables deduplication
+func WithServerDedupWindow(window time.Duration) ServerOption {
+	return func(o *ServerOpts) {
+		o.DedupWindow = window
+	}
+}
+
+// at most n requests are handled concurrently. queued requests are handled in priority order
+func WithServerMaxConcurrency(n int) ServerOption {
+	return func(o *ServerOpts) {
+		o.MaxConcurrency = n
+	}
+}
+
+// at most n requests to rpc are handled concurrently, in addition to the server limit
+func WithServerHandlerConcurrency(rpc string, n int) ServerOption {
+	return func(o *ServerOpts) {
+		if o.HandlerConcurrency == nil {
+			o.HandlerConcurrency = make(map[string]int)
+		}
+		o.HandlerConcurrency[rpc] = n
+	}
+}
+
+// requests with fields unknown to the server are rejected with MalformedRequest instead of handled with the unknown
+// fields ignored
+func WithServerStrictUnmarshal() ServerOption {
+	return func(o *ServerOpts) {
+		o.StrictUnmarshal = true
+	}
+}
+
+// protobuf response payloads are marshaled with map entries in a stable order, so equal responses have equal bytes
+func WithServerDeterministicMarshal() ServerOption {
+	return func(o *ServerOpts) {
+		o.DeterministicMarshal = true
+	}
+}
+
+// requests over the concurrency limits are rejected with ResourceExhausted instead of queued
+func WithServerRejectExcess() ServerOption {
+	return func(o *ServerOpts) {
+		o.RejectExcess = true
+	}
+}
+
+// servers with n queued or running requests stop claiming new requests, and reject requests that are not claimed
+func WithServerLoadShedding(n int) ServerOption {
+	return func(o *ServerOpts) {
+		o.MaxInFlight = n
+	}
+}
+
+// affinity functions get a context that expires after timeout. requests are not claimed if the affinity is not
+// computed in time, which bounds slow affinity functions such as cache or database lookups
+func WithServerClaimTimeout(timeout time.Duration) ServerOption {
+	return func(o *ServerOpts) {
+		o.ClaimTimeout = timeout
+	}
+}
+
+// servers lower their claim affinity as in-flight requests approach capacity, and delay claims by up to maxDelay at capacity,
+// so clients prefer idle servers
+func WithServerClaimBackoff(capacity int, maxDelay time.Duration) ServerOption {
+	return func(o *ServerOpts) {
+		o.ClaimLoadCapacity = capacity
+		o.ClaimMaxDelay = maxDelay
+	}
+}
+
+// servers with n requests waiting for a handler slot reject new requests with Unavailable, suggesting the client
+// retry after retryAfter
+func WithServerMaxQueueDepth(n int, retryAfter time.Duration) ServerOption {
+	return func(o *ServerOpts) {
+		o.MaxQueueDepth = n
+		o.QueueRetryAfter = retryAfter
+	}
+}
+
+// Shutdown waits at most d for in-flight handlers to complete. Clients ignore the server's claims for d, or for a minute
+// without a grace period, unless a new server with the same id sends one
+func WithServerShutdownGracePeriod(d time.Duration) ServerOption {
+	return func(o *ServerOpts) {
+		o.ShutdownGracePeriod = d
+	}
+}
+
+// Heartbeat announces the server to client.ServerRegistry every interval. Servers do not send heartbeats by default,
+// and registries only list servers that do
+func WithServerHeartbeat(interval time.Duration) ServerOption {
+	return func(o *ServerOpts) {
+		o.HeartbeatInterval = interval
+	}
+}
+
+type ExpiredRequestHandler func(info RPCInfo, requestID string, expiry time.Time)
+
+// onExpired is called for requests dropped because their deadline passed before they were handled
+func WithServerExpiredRequestHandler(onExpired ExpiredRequestHandler) ServerOption {
+	return func(o *ServerOpts) {
+		o.OnExpired = onExpired
+	}
+}
+
+// requests taking longer than threshold to handle are passed to onSlow with the time spent in each phase.
+// If onSlow is nil they are logged with LogSlowRequest
+func WithServerSlowRequestThreshold(threshold time.Duration, onSlow SlowRequestHandler) ServerOption {
+	return func(o *ServerOpts) {
+		o.SlowRequestThreshold = threshold
+		o.OnSlowRequest = onSlow
+		if onSlow == nil {
+			o.OnSlowRequest = LogSlowRequest
+		}
+	}
+}
+
+// onOverflow is called for claim responses and stream messages dropped because their channel is full
+func WithServerOverflowHandler(onOverflow OverflowHandler) ServerOption {
+	return func(o *ServerOpts) {
+		o.OnOverflow = onOverflow
+	}
+}
+
+// onMetrics is called with a handler's counters each time one of its requests starts or completes
+func WithServerHandlerMetrics(onMetrics HandlerMetricsHandler) ServerOption {
+	return func(o *ServerOpts) {
+		o.OnHandlerMetrics = onMetrics
+	}
+}
+
+// onSize is called with the length of each request payload received and each response payload published
+func WithServerPayloadSizes(onSize PayloadSizeHandler) ServerOption {
+	return func(o *ServerOpts) {
+		o.OnPayloadSize = onSize
+	}
+}
+
+// responses of at least threshold bytes are compressed with c, for clients that accept it. Older clients receive
+// uncompressed responses
+func WithServerCompression(c Compression, threshold int) ServerOption {
+	return func(o *ServerOpts) {
+		o.Compression = c
+		o.CompressionThreshold = threshold
+	}
+}
+
+// responses with payloads larger than size bytes are replaced with a ResourceExhausted error, unless they are split into
+// chunks of at most size bytes. size <= 0 disables the limit
+func WithServerMaxMessageSize(size int) ServerOption {
+	return func(o *ServerOpts) {
+		o.MaxMessageSize = size
+	}
+}
+
+// response payloads larger than threshold bytes are put in store and sent by reference. Clients need the same store
+// to load them, and requests sent by reference are loaded from it
+func WithServerBlobStore(store BlobStore, threshold int) ServerOption {
+	return func(o *ServerOpts) {
+		o.BlobStore = store
+		o.BlobThreshold = threshold
+	}
+}
+
 // Server interceptors wrap the service implementation
 type ServerRPCInterceptor func(ctx context.Context, req proto.Message, info RPCInfo, handler ServerRPCHandler) (proto.Message, error)
 type ServerRPCHandler func(context.Context, proto.Message) (proto.Message, error)
@@ -74,6 +343,12 @@ func WithServerStreamInterceptors(interceptors ...StreamInterceptor) ServerOptio
 	}
 }
 
+func WithServerStreamOpenHooks(hooks ...StreamOpenHook) ServerOption {
+	return func(o *ServerOpts) {
+		o.StreamOpenHooks = append(o.StreamOpenHooks, hooks...)
+	}
+}
+
 func WithServerOptions(opts ...ServerOption) ServerOption {
 	return func(o *ServerOpts) {
 		for _, opt := range opts {
@@ -15,6 +15,7 @@
 package psrpc
 
 import (
+	"context"
 	"time"
 
 	"google.golang.org/protobuf/proto"
@@ -33,6 +34,10 @@ func WithTimeout(timeout time.Duration) StreamOption {
 }
 
 type StreamInterceptor func(info RPCInfo, next StreamHandler) StreamHandler
+
+// StreamOpenHook runs before a stream is opened. Clients can add outgoing metadata to the returned context, and
+// servers close the stream with the error if the hook fails
+type StreamOpenHook func(ctx context.Context, info RPCInfo) (context.Context, error)
 type StreamHandler interface {
 	Recv(msg proto.Message) error
 	Send(msg proto.Message, opts ...StreamOption) error
@@ -16,6 +16,7 @@ package psrpc
 
 import (
 	"context"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 
@@ -25,8 +26,135 @@ import (
 type Subscription[MessageType proto.Message] bus.Subscription[MessageType]
 
 type Response[ResponseType proto.Message] struct {
-	Result ResponseType
-	Err    error
+	Result   ResponseType
+	Err      error
+	ServerID string // the server that sent the response, empty for errors raised by the client
+}
+
+// BackpressurePolicy decides what happens to messages that arrive while a consumer's channel is full. Streams without
+// a window discard new messages by default, and multi-RPC responses block until the channel has room
+type BackpressurePolicy int
+
+const (
+	BackpressureBlock      BackpressurePolicy = iota + 1 // wait for the consumer to make room, holding up to the channel size of messages
+	BackpressureDropOldest                               // discard the oldest buffered message
+	BackpressureDropNewest                               // discard the new message
+	BackpressureFail                                     // close the stream or request with ErrSlowConsumer
+)
+
+type ServerHealth struct {
+	ServerID string
+	Handlers []string // registered handlers, as method and topic
+	InFlight int
+	Uptime   time.Duration
+	Err      error // set when the server rejected the health check, for example failing auth
+}
+
+type ServerInfo struct {
+	ServerID string
+	Service  string
+	Handlers []string // registered handlers, as method and topic
+	Labels   map[string]string
+	InFlight int
+	Metrics  []HandlerMetrics
+	LastSeen time.Time // when the last heartbeat was received
+}
+
+type HandlerMetrics struct {
+	RPCInfo
+	Active    int
+	Completed uint64
+	Errored   uint64
+	Latency   time.Duration // total time spent in the handler by completed requests
+}
+
+type HandlerMetricsHandler func(m HandlerMetrics)
+
+// ClientStats is a snapshot of a client's counters, for monitoring without a metrics integration
+type ClientStats struct {
+	RequestsSent        uint64 // rpcs, multi-rpcs and requests sent without waiting for a response
+	ResponsesReceived   uint64
+	Timeouts            uint64 // rpcs that timed out waiting for a response
+	PendingRequests     int
+	ActiveSubscriptions int // subscriptions opened with Join and JoinQueue
+}
+
+// ServerStats is a snapshot of a server's counters, for monitoring without a metrics integration
+type ServerStats struct {
+	RequestsReceived    uint64
+	ResponsesSent       uint64
+	Expired             uint64 // requests dropped because their deadline passed before they were handled
+	ClaimsSent          uint64
+	ClaimRacesLost      uint64 // claims for requests the client sent to another server
+	ActiveRequests      int
+	ActiveSubscriptions int // subscriptions opened with Join and JoinQueue
+}
+
+// ChannelStats reports the backlog of a buffer between the bus and a client or server
+type ChannelStats struct {
+	Channel  string
+	Depth    int    // messages waiting to be read
+	Capacity int    // buffer size, set by WithClientChannelSize or WithServerChannelSize
+	Blocked  uint64 // messages that waited for room in a full buffer, stalling later messages
+	Dropped  uint64 // messages discarded because a buffer was full
+}
+
+type OverflowReason string
+
+const (
+	// the channel was full and the message was discarded, so it does not delay the messages behind it
+	OverflowDropped OverflowReason = "dropped"
+	// the message waited for room in a full channel, delaying the messages behind it. Multi-rpc responses wait so the
+	// request's backpressure policy applies
+	OverflowBlocked OverflowReason = "blocked"
+	// the request or stream is no longer registered, such as a response that arrived after its request timed out
+	OverflowUnregistered OverflowReason = "unregistered"
+)
+
+// Overflow is a claim, response or stream message that could not be delivered
+type Overflow struct {
+	Channel   string // claims, responses or streams
+	RequestID string // request id, or stream id for stream messages
+	RemoteID  string // server that sent the message, when known
+	Reason    OverflowReason
+}
+
+type OverflowHandler func(o Overflow)
+
+// SlowRequest is the phase breakdown of a request that took longer than the slow request threshold
+type SlowRequest struct {
+	RPCInfo
+	RequestID string
+	RemoteID  string        // server that responded, or for servers the client that sent the request
+	Duration  time.Duration // total time, from publishing the request on clients or receiving it on servers
+	Publish   time.Duration // time spent publishing the request, measured by clients
+	Queue     time.Duration // time spent waiting for a handler slot, measured by servers
+	Claim     time.Duration // time spent selecting a server, or for servers waiting to be selected
+	Handler   time.Duration // time spent in the handler, reported to clients with the response
+	Response  time.Duration // time spent waiting for the response after the claim, or for servers sending it
+	Err       error
+}
+
+type SlowRequestHandler func(ctx context.Context, r SlowRequest)
+
+// PayloadSize is the length in bytes of a request or response payload as published, after compression. Payloads sent
+// by reference have the length of the reference
+type PayloadSize struct {
+	RPCInfo
+	Response bool // the payload is a response, otherwise a request
+	Size     int
+}
+
+type PayloadSizeHandler func(p PayloadSize)
+
+// InFlightRequest is a request waiting for a response on a client, or being handled by a server
+type InFlightRequest struct {
+	RPCInfo
+	RequestID string
+	RemoteID  string // client that sent the request, or for clients the server a directed request was sent to
+	StartedAt time.Time
+	Age       time.Duration
+	Deadline  time.Time
 }
 
 type Stream[SendType, RecvType proto.Message] interface {
@@ -39,9 +167,41 @@ type Stream[SendType, RecvType proto.Message] interface {
 
 type ClientStream[SendType, RecvType proto.Message] interface {
 	Stream[SendType, RecvType]
+	CloseSend() error // closes the server's channel, the stream stays open to receive
 }
 
 type ServerStream[SendType, RecvType proto.Message] interface {
 	Stream[SendType, RecvType]
 	Hijack()
 }
+
+// ResponseStream receives the responses to a server-streaming rpc. The channel is closed after the last response,
+// and Err returns ErrStreamEOF if the handler completed successfully
+type ResponseStream[ResponseType proto.Message] interface {
+	Context() context.Context
+	Channel() <-chan ResponseType
+	Close(cause error) error
+	Err() error
+}
+
+// RequestStream sends the requests for a client-streaming rpc. CloseAndRecv waits for the handler's response
+type RequestStream[RequestType, ResponseType proto.Message] interface {
+	Context() context.Context
+	Send(msg RequestType, opts ...StreamOption) error
+	CloseAndRecv() (ResponseType, error)
+	Close(cause error) error
+}
+
+// StreamReader receives the requests for a client-streaming rpc. The channel is closed after the last request,
+// and Err returns the cause if the stream was closed before the client finished sending
+type StreamReader[RequestType proto.Message] interface {
+	Context() context.Context
+	Channel() <-chan RequestType
+	Err() error
+}
+
+// StreamWriter sends the responses to a server-streaming rpc
+type StreamWriter[ResponseType proto.Message] interface {
+	Context() context.Context
+	Send(msg ResponseType, opts ...StreamOption) error
+}